@@ -4,8 +4,6 @@ package api
 import (
 	"log"
 	"net/http"
-
-	"github.com/Aman221/4723/internal/handlers"
 )
 
 // SetupRouter initializes the API routes
@@ -13,7 +11,7 @@ func SetupRouter() *http.ServeMux {
 	mux := http.NewServeMux()
 
 	// User-related endpoints
-	mux.HandleFunc("/user", handlers.GetUserHandler)
+	// mux.HandleFunc("/user", handlers.GetUserHandler)
 	// mux.HandleFunc("/user/{id}/calendar", handlers.GetUserCalendarHandler)
 	// mux.HandleFunc("/user/{id}/events", handlers.GetUserEventsHandler)
 	// mux.HandleFunc("/user/{id}/paymentinformation", handlers.GetUserPaymentHandler)
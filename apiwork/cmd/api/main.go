@@ -1,60 +1,162 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	_ "time/tzdata" // Embed zone data so event timezones resolve on any host
 
 	"github.com/gorilla/mux"
 	"github.com/rs/cors" // Import the CORS middleware
 
+	"github.com/Aman221/4723/internal/config"
 	"github.com/Aman221/4723/internal/database"
+	"github.com/Aman221/4723/internal/digest"
 	"github.com/Aman221/4723/internal/handlers"
+	"github.com/Aman221/4723/internal/itip"
+	"github.com/Aman221/4723/internal/mailer"
+	"github.com/Aman221/4723/internal/migrations"
+	"github.com/Aman221/4723/internal/reminders"
+	"github.com/Aman221/4723/internal/store"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "openapi" {
+		if err := runOpenAPI(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	cfg, args, err := config.Load("api", os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
 	r := mux.NewRouter()
-	err := database.InitDB()
+	err = database.InitDB(cfg.Database)
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 	defer database.DB.Close() // Close the database connection when the program exits.
 
-	// r.HandleFunc("/user/{id}", handlers.GetUserHandler).Methods("GET")
-	r.HandleFunc("/user/{id}/calendar", handlers.GetUserCalendarHandler).Methods("GET")
-	r.HandleFunc("/user/{id}/events", handlers.GetUserEventsHandler).Methods("GET")
-	r.HandleFunc("/user/{id}/paymentinformation", handlers.GetUserPaymentHandler).Methods("GET")
-	r.HandleFunc("/user/{id}/endpointapi", handlers.EndpointAPIHandler).Methods("GET")
-
-	// Calendar endpoints
-	r.HandleFunc("/calendars", handlers.GetCalendarsHandler).Methods("GET")
-	r.HandleFunc("/calendars", handlers.AddCalendarHandler).Methods("POST")
-	r.HandleFunc("/calendars/{id}", handlers.UpdateCalendarHandler).Methods("PUT")
-	r.HandleFunc("/calendars/{id}", handlers.DeleteCalendarHandler).Methods("DELETE")
-	r.HandleFunc("/calendars/{id}/visibility", handlers.UpdateCalendarVisibilityHandler).Methods("PUT")
-
-	// Event endpoints
-	r.HandleFunc("/events", handlers.GetEventsHandler).Methods("GET")
-	r.HandleFunc("/events/search", handlers.SearchEventsHandler).Methods("GET")
-	r.HandleFunc("/events", handlers.AddEventHandler).Methods("POST")
-	r.HandleFunc("/events/{eventId}", handlers.UpdateEventHandler).Methods("PUT")
-	r.HandleFunc("/events/{eventId}", handlers.DeleteEventHandler).Methods("DELETE")
-
-	// Calendar Navigation endpoints
-	r.HandleFunc("/calendar/current-date", handlers.GetCurrentDateHandler).Methods("GET")
-	r.HandleFunc("/calendar/navigate/{direction}", handlers.NavigateCalendarHandler).Methods("POST")
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if len(args) > 0 {
+		if args[0] != "migrate" {
+			log.Fatalf("Unknown command %q", args[0])
+		}
+		if err := runMigrate(ctx, args[1:]); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+	if cfg.Database.AutoMigrate {
+		ran, err := migrations.Up(ctx, database.DB, database.Dialect)
+		if err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		for _, m := range ran {
+			log.Printf("Applied migration %04d_%s", m.Version, m.Name)
+		}
+	}
+
+	handlers.Store = store.New(database.DB, database.Dialect)
+
+	mail := newMailer(cfg.Mail)
+	handlers.EventLocation, _ = time.LoadLocation(cfg.EventTimezone) // checked by config.Validate
+
+	// Deliver event reminders in the background. Like resources, digests,
+	// invitations and tasks, reminders need Postgres.
+	if database.Dialect == database.Postgres {
+		scheduler := reminders.NewScheduler(map[string]reminders.Notifier{
+			reminders.MethodEmail: reminders.EmailNotifier{Mailer: mail},
+			reminders.MethodInApp: reminders.InAppNotifier{},
+		}, handlers.EventLocation)
+		go scheduler.Run(ctx)
+	} else {
+		log.Printf("Using %s: only users, calendars and events are supported", database.Dialect)
+	}
+
+	// Email external attendees about their events. Replies are expected to
+	// arrive at the From mailbox and be forwarded to /itip/inbound.
+	handlers.Invitations = &itip.Sender{Mailer: mail, Location: handlers.EventLocation, Address: cfg.Mail.From}
+	handlers.InboundMailToken = cfg.Mail.InboundToken
+
+	// Send the opt-in daily agenda emails
+	if database.Dialect == database.Postgres {
+		digestJob := &digest.Job{Mailer: mail, BaseURL: cfg.PublicBaseURL}
+		go digestJob.Run(ctx)
+	}
+
+	// Empty the trash of what was deleted longer ago than the retention
+	// period. Zero keeps the trash forever.
+	if cfg.TrashRetention > 0 {
+		purger := &store.Purger{Store: handlers.Store, Retention: time.Duration(cfg.TrashRetention)}
+		go purger.Run(ctx)
+	}
+
+	// Let clients keep a replica in sync until their tokens run out
+	handlers.SyncTokenMaxAge = time.Duration(cfg.SyncTokenMaxAge)
+
+	// Tag each request with an ID that errors and the log refer to
+	r.Use(handlers.RequestID)
+
+	// Record who makes each change, for the calendar and event history
+	r.Use(handlers.RecordChanges)
+
+	// Let clients retry POSTs without creating things twice
+	handlers.IdempotencyWindow = time.Duration(cfg.IdempotencyWindow)
+	r.Use(handlers.Idempotency)
+
+	routes(r)
+
+	// Answer unknown paths and methods with JSON errors too. Middleware
+	// doesn't run for them, so they get their request IDs here.
+	r.NotFoundHandler = handlers.RequestID(http.HandlerFunc(handlers.NotFoundHandler))
+	r.MethodNotAllowedHandler = handlers.RequestID(http.HandlerFunc(handlers.MethodNotAllowedHandler))
 
 	// Enable CORS for all origins, methods, and headers
 	c := cors.New(cors.Options{
 		AllowedOrigins: []string{"*"}, // You might want to restrict this in production
-		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders: []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders: []string{"Link"},
+		AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders: []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "If-Match", "If-None-Match", "X-Actor", "X-Change-Source", "Idempotency-Key", "X-Request-ID"},
+		ExposedHeaders: []string{"Link", "ETag", "Location", "Idempotent-Replayed", "X-Request-ID"},
 		// AllowCredentials: true, // If you need to handle cookies
 		MaxAge: 86400, // Maximum age for preflight cache
 	})
 
 	handler := c.Handler(r)
 
-	log.Println("Server starting on 127.0.0.1:8080...")
-	http.ListenAndServe("127.0.0.1:8080", handler)
+	log.Printf("Server starting on %s...", cfg.ListenAddr)
+	if err := http.ListenAndServe(cfg.ListenAddr, handler); err != nil {
+		log.Printf("Server stopped: %v", err)
+	}
+}
+
+// newMailer returns an SMTP mailer for the configured server, or a mailer
+// that only logs when no SMTP host is set. Point it at a local sink such as
+// MailHog (SMTP_HOST=localhost SMTP_PORT=1025) to inspect outgoing mail.
+func newMailer(cfg config.Mail) mailer.Mailer {
+	if cfg.SMTPHost == "" {
+		log.Println("SMTP_HOST not set, emails will only be logged")
+		return mailer.LogMailer{}
+	}
+	return &mailer.SMTPMailer{
+		Host:     cfg.SMTPHost,
+		Port:     cfg.SMTPPort,
+		Username: cfg.SMTPUsername,
+		Password: cfg.SMTPPassword,
+		From:     cfg.From,
+	}
 }
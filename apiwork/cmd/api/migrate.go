@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/Aman221/4723/internal/database"
+	"github.com/Aman221/4723/internal/migrations"
+)
+
+const migrateUsage = `usage: api [flags] migrate <command>
+
+commands:
+  up          apply all pending migrations (default)
+  down [N]    revert the last N migrations (default 1)
+  to VERSION  migrate up or down to VERSION; 0 reverts everything
+  status      list embedded migrations and whether they are applied`
+
+// runMigrate implements the migrate subcommand. The database must already
+// be initialised.
+func runMigrate(ctx context.Context, args []string) error {
+	command := "up"
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+	number := func(def int) (int, error) {
+		if len(args) == 0 {
+			return def, nil
+		}
+		n, err := strconv.Atoi(args[0])
+		if err != nil || len(args) > 1 {
+			return 0, fmt.Errorf("expected a number\n%s", migrateUsage)
+		}
+		return n, nil
+	}
+
+	var ran []migrations.Migration
+	var err error
+	verb := "Applied"
+	switch command {
+	case "up":
+		ran, err = migrations.Up(ctx, database.DB, database.Dialect)
+	case "down":
+		verb = "Reverted"
+		var steps int
+		if steps, err = number(1); err == nil {
+			ran, err = migrations.Down(ctx, database.DB, database.Dialect, steps)
+		}
+	case "to":
+		var version int
+		if len(args) == 0 {
+			return fmt.Errorf("missing version\n%s", migrateUsage)
+		}
+		if version, err = number(0); err == nil {
+			ran, err = migrations.To(ctx, database.DB, database.Dialect, version)
+		}
+	case "status":
+		return printMigrationStatus(ctx)
+	default:
+		return fmt.Errorf("unknown migrate command %q\n%s", command, migrateUsage)
+	}
+
+	for _, m := range ran {
+		fmt.Printf("%s %04d_%s\n", verb, m.Version, m.Name)
+	}
+	if err == nil && len(ran) == 0 {
+		fmt.Println("Nothing to do")
+	}
+	return err
+}
+
+func printMigrationStatus(ctx context.Context) error {
+	all, err := migrations.All(database.Dialect)
+	if err != nil {
+		return err
+	}
+	applied, err := migrations.Status(ctx, database.DB, database.Dialect)
+	if err != nil {
+		return err
+	}
+	at := map[int]string{}
+	for _, a := range applied {
+		at[a.Version] = a.AppliedAt.Local().Format("2006-01-02 15:04:05")
+	}
+	for _, m := range all {
+		state := "pending"
+		if when, ok := at[m.Version]; ok {
+			state = "applied " + when
+		}
+		fmt.Printf("%04d_%-28s %s\n", m.Version, m.Name, state)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/gorilla/mux"
+
+	"github.com/Aman221/4723/internal/handlers"
+	"github.com/Aman221/4723/internal/openapi"
+)
+
+const openAPIUsage = `usage: api openapi <command>
+
+commands:
+  check  compare internal/openapi/openapi.json with the routes and the
+         types the handlers read and write, and list where they differ
+  print  write the OpenAPI description to stdout`
+
+// errDrift is returned when the spec and the API differ.
+var errDrift = errors.New("openapi.json is out of date")
+
+// runOpenAPI implements the openapi subcommand. It needs neither
+// configuration nor a database, so CI can run it.
+func runOpenAPI(args []string) error {
+	if len(args) != 1 {
+		return errors.New(openAPIUsage)
+	}
+	switch args[0] {
+	case "check":
+	case "print":
+		_, err := os.Stdout.Write(openapi.Spec)
+		return err
+	default:
+		return fmt.Errorf("unknown command %q\n%s", args[0], openAPIUsage)
+	}
+
+	served, err := servedRoutes()
+	if err != nil {
+		return err
+	}
+
+	problems, err := openapi.Check(served, handlers.OpenAPISchemas)
+	if err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		for _, problem := range problems {
+			fmt.Fprintln(os.Stderr, problem)
+		}
+		return fmt.Errorf("%w: %d problems", errDrift, len(problems))
+	}
+	fmt.Printf("openapi.json describes all %d routes and %d schemas\n", len(served), len(handlers.OpenAPISchemas))
+	return nil
+}
+
+// servedRoutes lists the methods and paths the router serves.
+func servedRoutes() ([]openapi.Route, error) {
+	r := mux.NewRouter()
+	routes(r)
+	var served []openapi.Route
+	err := r.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return fmt.Errorf("route %s doesn't name its methods", path)
+		}
+		for _, method := range methods {
+			served = append(served, openapi.Route{Method: method, Path: path})
+		}
+		return nil
+	})
+	return served, err
+}
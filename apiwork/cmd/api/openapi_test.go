@@ -0,0 +1,25 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/Aman221/4723/internal/handlers"
+	"github.com/Aman221/4723/internal/openapi"
+)
+
+func TestOpenAPIDescribesRoutes(t *testing.T) {
+	served, err := servedRoutes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(served) == 0 {
+		t.Fatal("the router serves no routes")
+	}
+	problems, err := openapi.Check(served, handlers.OpenAPISchemas)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, problem := range problems {
+		t.Error(problem)
+	}
+}
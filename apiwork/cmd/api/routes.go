@@ -0,0 +1,105 @@
+package main
+
+import (
+	"github.com/gorilla/mux"
+
+	"github.com/Aman221/4723/internal/handlers"
+)
+
+// routes registers the API's endpoints on r. Every one of them must be
+// described in internal/openapi/openapi.json; "api openapi check" says
+// which aren't. Those of the features that need Postgres answer 501 on
+// other databases.
+func routes(r *mux.Router) {
+	r.HandleFunc("/user/{id}", handlers.GetUserHandler).Methods("GET")
+	r.HandleFunc("/user/{id}/calendar", handlers.GetUserCalendarHandler).Methods("GET")
+	r.HandleFunc("/user/{id}/events", handlers.GetUserEventsHandler).Methods("GET")
+	r.HandleFunc("/user/{id}/paymentinformation", handlers.GetUserPaymentHandler).Methods("GET")
+	r.HandleFunc("/user/{id}/endpointapi", handlers.EndpointAPIHandler).Methods("GET")
+	r.HandleFunc("/user/{id}/searches", handlers.GetUserSearchesHandler).Methods("GET")
+	r.HandleFunc("/user/{id}/searches", handlers.AddSearchHandler).Methods("POST")
+
+	// Calendar endpoints
+	r.HandleFunc("/calendars", handlers.GetCalendarsHandler).Methods("GET")
+	r.HandleFunc("/calendars", handlers.AddCalendarHandler).Methods("POST")
+	r.HandleFunc("/calendars/{id}", handlers.GetCalendarHandler).Methods("GET")
+	r.HandleFunc("/calendars/{id}", handlers.UpdateCalendarHandler).Methods("PUT")
+	r.HandleFunc("/calendars/{id}", handlers.PatchCalendarHandler).Methods("PATCH")
+	r.HandleFunc("/calendars/{id}", handlers.DeleteCalendarHandler).Methods("DELETE")
+	r.HandleFunc("/calendars/{id}/visibility", handlers.UpdateCalendarVisibilityHandler).Methods("PUT")
+	r.HandleFunc("/calendars/{id}/restore", handlers.RestoreCalendarHandler).Methods("POST")
+	r.HandleFunc("/calendars/{id}/history", handlers.GetCalendarHistoryHandler).Methods("GET")
+
+	// Event endpoints
+	r.HandleFunc("/events", handlers.GetEventsHandler).Methods("GET")
+	r.HandleFunc("/events/search", handlers.SearchEventsHandler).Methods("GET")
+	r.HandleFunc("/events", handlers.AddEventHandler).Methods("POST")
+	r.HandleFunc("/events/{eventId}", handlers.GetEventHandler).Methods("GET")
+	r.HandleFunc("/events/{eventId}", handlers.UpdateEventHandler).Methods("PUT")
+	r.HandleFunc("/events/{eventId}", handlers.PatchEventHandler).Methods("PATCH")
+	r.HandleFunc("/events/{eventId}", handlers.DeleteEventHandler).Methods("DELETE")
+	r.HandleFunc("/events/{eventId}/restore", handlers.RestoreEventHandler).Methods("POST")
+	r.HandleFunc("/events/{eventId}/history", handlers.GetEventHistoryHandler).Methods("GET")
+	r.HandleFunc("/events/{eventId}/revert", handlers.RevertEventHandler).Methods("POST")
+
+	// Trash
+	r.HandleFunc("/trash", handlers.GetTrashHandler).Methods("GET")
+
+	// Several calendar and event writes in one transaction
+	r.HandleFunc("/batch", handlers.BatchHandler).Methods("POST")
+
+	// Delta sync of calendars and events
+	r.HandleFunc("/sync", handlers.SyncHandler).Methods("GET")
+
+	// Saved searches, whose pinned ones are also smart calendars
+	r.HandleFunc("/searches/{id}", handlers.GetSearchHandler).Methods("GET")
+	r.HandleFunc("/searches/{id}", handlers.UpdateSearchHandler).Methods("PUT")
+	r.HandleFunc("/searches/{id}", handlers.DeleteSearchHandler).Methods("DELETE")
+
+	// Task (to-do) endpoints
+	r.HandleFunc("/tasks", handlers.PostgresOnly(handlers.GetTasksHandler)).Methods("GET")
+	r.HandleFunc("/tasks/overdue", handlers.PostgresOnly(handlers.GetOverdueTasksHandler)).Methods("GET")
+	r.HandleFunc("/tasks/search", handlers.PostgresOnly(handlers.SearchTasksHandler)).Methods("GET")
+	r.HandleFunc("/tasks", handlers.PostgresOnly(handlers.AddTaskHandler)).Methods("POST")
+	r.HandleFunc("/tasks/{taskId}", handlers.PostgresOnly(handlers.GetTaskHandler)).Methods("GET")
+	r.HandleFunc("/tasks/{taskId}", handlers.PostgresOnly(handlers.UpdateTaskHandler)).Methods("PUT")
+	r.HandleFunc("/tasks/{taskId}", handlers.PostgresOnly(handlers.DeleteTaskHandler)).Methods("DELETE")
+
+	// iCalendar export and import
+	r.HandleFunc("/calendars/{id}/export.ics", handlers.PostgresOnly(handlers.ExportCalendarHandler)).Methods("GET")
+	r.HandleFunc("/calendars/{id}/import", handlers.PostgresOnly(handlers.ImportCalendarHandler)).Methods("POST")
+
+	// Resource (rooms and equipment) endpoints
+	r.HandleFunc("/resources", handlers.PostgresOnly(handlers.GetResourcesHandler)).Methods("GET")
+	r.HandleFunc("/resources", handlers.PostgresOnly(handlers.AddResourceHandler)).Methods("POST")
+	r.HandleFunc("/resources/available", handlers.PostgresOnly(handlers.SearchAvailableResourcesHandler)).Methods("GET")
+	r.HandleFunc("/resources/{id}", handlers.PostgresOnly(handlers.GetResourceHandler)).Methods("GET")
+	r.HandleFunc("/resources/{id}", handlers.PostgresOnly(handlers.UpdateResourceHandler)).Methods("PUT")
+	r.HandleFunc("/resources/{id}", handlers.PostgresOnly(handlers.DeleteResourceHandler)).Methods("DELETE")
+	r.HandleFunc("/events/{eventId}/resources", handlers.PostgresOnly(handlers.GetEventResourcesHandler)).Methods("GET")
+
+	// Reminder and notification endpoints
+	r.HandleFunc("/events/{eventId}/reminders", handlers.PostgresOnly(handlers.GetEventRemindersHandler)).Methods("GET")
+	r.HandleFunc("/events/{eventId}/reminders", handlers.PostgresOnly(handlers.UpdateEventRemindersHandler)).Methods("PUT")
+	r.HandleFunc("/calendars/{id}/reminders", handlers.PostgresOnly(handlers.GetCalendarRemindersHandler)).Methods("GET")
+	r.HandleFunc("/calendars/{id}/reminders", handlers.PostgresOnly(handlers.UpdateCalendarRemindersHandler)).Methods("PUT")
+	r.HandleFunc("/notifications", handlers.PostgresOnly(handlers.GetNotificationsHandler)).Methods("GET")
+	r.HandleFunc("/notifications/{id}/read", handlers.PostgresOnly(handlers.MarkNotificationReadHandler)).Methods("PUT")
+
+	// Daily agenda digest endpoints
+	r.HandleFunc("/user/{id}/digest", handlers.PostgresOnly(handlers.GetUserDigestHandler)).Methods("GET")
+	r.HandleFunc("/user/{id}/digest", handlers.PostgresOnly(handlers.UpdateUserDigestHandler)).Methods("PUT")
+	r.HandleFunc("/digest/unsubscribe", handlers.PostgresOnly(handlers.UnsubscribeDigestHandler)).Methods("GET")
+
+	// Email invitation (iMIP) endpoints
+	r.HandleFunc("/events/{eventId}/rsvps", handlers.PostgresOnly(handlers.GetEventRSVPsHandler)).Methods("GET")
+	r.HandleFunc("/itip/inbound", handlers.PostgresOnly(handlers.InboundMailHandler)).Methods("POST")
+
+	// Calendar Navigation endpoints
+	r.HandleFunc("/calendar/current-date", handlers.GetCurrentDateHandler).Methods("GET")
+	r.HandleFunc("/calendar/navigate/{direction}", handlers.NavigateCalendarHandler).Methods("POST")
+
+	// The API's OpenAPI description and a page to read it in
+	r.HandleFunc("/openapi.json", handlers.OpenAPIHandler).Methods("GET")
+	r.HandleFunc("/docs", handlers.DocsHandler).Methods("GET")
+}
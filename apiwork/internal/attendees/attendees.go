@@ -0,0 +1,72 @@
+// Package attendees stores the attendees of events. They live in the
+// event_attendees table, one row per attendee in the order they were
+// given; queries read them back as a JSON array with Column and decode it
+// with Parse.
+package attendees
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+
+	"github.com/Aman221/4723/internal/database"
+)
+
+// Column returns an SQL expression in the given dialect that evaluates to
+// the attendees of the event aliased as event, as a JSON array.
+func Column(dialect, event string) string {
+	if dialect == database.SQLite {
+		return "(SELECT json_group_array(attendee) FROM (SELECT a.attendee FROM event_attendees a WHERE a.event_id = " +
+			event + ".id ORDER BY a.position))"
+	}
+	return "COALESCE((SELECT json_agg(a.attendee ORDER BY a.position) FROM event_attendees a WHERE a.event_id = " +
+		event + ".id), '[]')"
+}
+
+// Parse decodes the value of a Column expression.
+func Parse(raw string) []string {
+	list := []string{}
+	json.Unmarshal([]byte(raw), &list)
+	if list == nil {
+		list = []string{}
+	}
+	return list
+}
+
+// Normalize trims attendees and drops blanks and duplicates, keeping the
+// first occurrence. It never returns nil.
+func Normalize(list []string) []string {
+	clean := []string{}
+	seen := map[string]bool{}
+	for _, a := range list {
+		a = strings.TrimSpace(a)
+		if a == "" || seen[a] {
+			continue
+		}
+		seen[a] = true
+		clean = append(clean, a)
+	}
+	return clean
+}
+
+// Execer is satisfied by *sql.DB, *sql.Tx and *sql.Conn.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Set replaces the attendees of an event. Run it in the transaction that
+// writes the event.
+func Set(ctx context.Context, db Execer, eventID string, list []string) error {
+	if _, err := db.ExecContext(ctx, "DELETE FROM event_attendees WHERE event_id = $1", eventID); err != nil {
+		return err
+	}
+	for i, a := range Normalize(list) {
+		_, err := db.ExecContext(ctx, "INSERT INTO event_attendees (event_id, position, attendee) VALUES ($1, $2, $3)",
+			eventID, i+1, a)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
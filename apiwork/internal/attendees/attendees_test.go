@@ -0,0 +1,115 @@
+package attendees
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Aman221/4723/internal/database"
+	"github.com/Aman221/4723/internal/migrations"
+)
+
+func TestNormalize(t *testing.T) {
+	got := Normalize([]string{" bob ", "", "carol", "bob", "  "})
+	if want := []string{"bob", "carol"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Normalize = %v, want %v", got, want)
+	}
+	if got := Normalize(nil); got == nil || len(got) != 0 {
+		t.Errorf("Normalize(nil) = %#v", got)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := map[string][]string{
+		`["bob","carol"]`: {"bob", "carol"},
+		`[]`:              {},
+		`null`:            {},
+		``:                {},
+		`{bob}`:           {},
+	}
+	for raw, want := range tests {
+		if got := Parse(raw); !reflect.DeepEqual(got, want) {
+			t.Errorf("Parse(%q) = %#v, want %#v", raw, got, want)
+		}
+	}
+}
+
+// openAt returns a fresh SQLite database migrated to version.
+func openAt(t *testing.T, version int) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", "file:"+filepath.Join(t.TempDir(), "calendar.db")+"?_foreign_keys=on")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := migrations.To(context.Background(), db, database.SQLite, version); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// attendeesOf reads the attendees of an event back through Column.
+func attendeesOf(t *testing.T, db *sql.DB, eventID int64) []string {
+	t.Helper()
+	var raw string
+	if err := db.QueryRow("SELECT "+Column(database.SQLite, "e")+" FROM calendar_events e WHERE e.id = ?", eventID).Scan(&raw); err != nil {
+		t.Fatal(err)
+	}
+	return Parse(raw)
+}
+
+func TestSetOnSQLite(t *testing.T) {
+	ctx := context.Background()
+	db := openAt(t, 7)
+	db.Exec("INSERT INTO calendars (id, name) VALUES (1, 'Work')")
+	if _, err := db.Exec("INSERT INTO calendar_events (id, start_time, end_time, day, calendar_id) VALUES (1, '09:00', '10:00', 1, 1)"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := attendeesOf(t, db, 1); !reflect.DeepEqual(got, []string{}) {
+		t.Errorf("an event without attendees has %v", got)
+	}
+	if err := Set(ctx, db, "1", []string{"carol", " bob ", "carol"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := attendeesOf(t, db, 1), []string{"carol", "bob"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("attendees are %v, want %v in the order given", got, want)
+	}
+	if err := Set(ctx, db, "1", []string{"dave"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := attendeesOf(t, db, 1), []string{"dave"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("attendees are %v, want %v", got, want)
+	}
+}
+
+// TestMigrationRepairsRows checks that the migration moving attendees into
+// their own table keeps the ones stored as JSON arrays.
+func TestMigrationRepairsRows(t *testing.T) {
+	db := openAt(t, 6)
+	db.Exec("INSERT INTO calendars (id, name) VALUES (1, 'Work')")
+	rows := map[int64]string{
+		1: `["bob", " carol ", "bob", "", 7]`,
+		2: `[]`,
+		3: `not json`,
+		4: `{"bob": true}`,
+	}
+	for id, list := range rows {
+		_, err := db.Exec("INSERT INTO calendar_events (id, start_time, end_time, day, calendar_id, attendees) VALUES (?, '09:00', '10:00', 1, 1, ?)", id, list)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := migrations.To(context.Background(), db, database.SQLite, 7); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[int64][]string{1: {"bob", "carol"}, 2: {}, 3: {}, 4: {}}
+	for id, list := range want {
+		if got := attendeesOf(t, db, id); !reflect.DeepEqual(got, list) {
+			t.Errorf("event %d has %v, want %v", id, got, list)
+		}
+	}
+}
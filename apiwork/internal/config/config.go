@@ -0,0 +1,309 @@
+// Package config loads the server configuration. Values come from, in
+// increasing order of precedence: built-in defaults, a JSON config file,
+// environment variables and command-line flags.
+//
+// The config file is named with -config or CALENDAR_CONFIG. Durations are
+// written as Go duration strings ("30s", "5m").
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Duration is a time.Duration that reads from JSON strings like "30s".
+type Duration time.Duration
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"30s\": %w", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Database configures the connection pool.
+type Database struct {
+	// DSN is a postgres:// URL or a key=value connection string.
+	DSN string `json:"dsn"`
+	// SSLMode is the libpq sslmode (disable, require, verify-ca,
+	// verify-full). It overrides any sslmode in the DSN when set.
+	SSLMode          string   `json:"sslMode"`
+	MaxOpenConns     int      `json:"maxOpenConns"`
+	MaxIdleConns     int      `json:"maxIdleConns"`
+	ConnMaxLifetime  Duration `json:"connMaxLifetime"`
+	ConnMaxIdleTime  Duration `json:"connMaxIdleTime"`
+	StatementTimeout Duration `json:"statementTimeout"`
+	// ConnectTimeout bounds how long startup keeps retrying to reach the
+	// database before giving up.
+	ConnectTimeout Duration `json:"connectTimeout"`
+	// AutoMigrate applies pending schema migrations at startup.
+	AutoMigrate bool `json:"autoMigrate"`
+}
+
+// Mail configures outgoing and incoming email.
+type Mail struct {
+	SMTPHost     string `json:"smtpHost"`
+	SMTPPort     int    `json:"smtpPort"`
+	SMTPUsername string `json:"smtpUsername"`
+	SMTPPassword string `json:"smtpPassword"`
+	From         string `json:"from"`
+	// InboundToken must accompany messages posted to /itip/inbound.
+	InboundToken string `json:"inboundToken"`
+}
+
+// Config is the complete server configuration.
+type Config struct {
+	ListenAddr    string `json:"listenAddr"`
+	PublicBaseURL string `json:"publicBaseURL"`
+	EventTimezone string `json:"eventTimezone"`
+	// TrashRetention is how long deleted calendars and events can be
+	// restored before they are purged. 0 keeps them forever.
+	TrashRetention Duration `json:"trashRetention"`
+	// SyncTokenMaxAge is how long a client can use a sync token before it
+	// has to sync everything again. 0 keeps tokens good forever.
+	SyncTokenMaxAge Duration `json:"syncTokenMaxAge"`
+	// IdempotencyWindow is how long a POST's Idempotency-Key is kept for
+	// retries. 0 ignores the header.
+	IdempotencyWindow Duration `json:"idempotencyWindow"`
+	Database          Database `json:"database"`
+	Mail              Mail     `json:"mail"`
+}
+
+// Default returns the configuration used when nothing is specified.
+func Default() Config {
+	return Config{
+		ListenAddr:        "127.0.0.1:8080",
+		PublicBaseURL:     "http://127.0.0.1:8080",
+		EventTimezone:     "America/New_York",
+		TrashRetention:    Duration(30 * 24 * time.Hour),
+		SyncTokenMaxAge:   Duration(30 * 24 * time.Hour),
+		IdempotencyWindow: Duration(24 * time.Hour),
+		Database: Database{
+			DSN:              "postgres://localhost:5432/users",
+			SSLMode:          "disable",
+			MaxOpenConns:     25,
+			MaxIdleConns:     5,
+			ConnMaxLifetime:  Duration(30 * time.Minute),
+			ConnMaxIdleTime:  Duration(5 * time.Minute),
+			StatementTimeout: Duration(30 * time.Second),
+			ConnectTimeout:   Duration(30 * time.Second),
+		},
+		Mail: Mail{
+			SMTPPort: 25,
+			From:     "calendar@localhost",
+		},
+	}
+}
+
+// Load builds the configuration for a command from its arguments (without
+// the program name). It returns the remaining positional arguments.
+func Load(name string, args []string) (Config, []string, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("CALENDAR_CONFIG"), "path to a JSON config file")
+	listen := fs.String("listen", cfg.ListenAddr, "address to listen on (LISTEN_ADDR)")
+	dsn := fs.String("db-dsn", cfg.Database.DSN, "database connection string (DATABASE_URL)")
+	sslMode := fs.String("db-sslmode", cfg.Database.SSLMode, "database TLS mode: disable, require, verify-ca or verify-full (DB_SSLMODE)")
+	maxOpen := fs.Int("db-max-open", cfg.Database.MaxOpenConns, "maximum open database connections (DB_MAX_OPEN_CONNS)")
+	maxIdle := fs.Int("db-max-idle", cfg.Database.MaxIdleConns, "maximum idle database connections (DB_MAX_IDLE_CONNS)")
+	lifetime := fs.Duration("db-conn-lifetime", time.Duration(cfg.Database.ConnMaxLifetime), "maximum lifetime of a database connection (DB_CONN_MAX_LIFETIME)")
+	idleTime := fs.Duration("db-conn-idle-time", time.Duration(cfg.Database.ConnMaxIdleTime), "maximum time a database connection may sit idle (DB_CONN_MAX_IDLE_TIME)")
+	stmtTimeout := fs.Duration("db-statement-timeout", time.Duration(cfg.Database.StatementTimeout), "database statement timeout, 0 to disable (DB_STATEMENT_TIMEOUT)")
+	connectTimeout := fs.Duration("db-connect-timeout", time.Duration(cfg.Database.ConnectTimeout), "how long to retry connecting at startup (DB_CONNECT_TIMEOUT)")
+	trashRetention := fs.Duration("trash-retention", time.Duration(cfg.TrashRetention), "how long deleted items stay restorable, 0 to keep them (TRASH_RETENTION)")
+	syncTokenMaxAge := fs.Duration("sync-token-max-age", time.Duration(cfg.SyncTokenMaxAge), "how long sync tokens stay good, 0 to keep them (SYNC_TOKEN_MAX_AGE)")
+	idempotencyWindow := fs.Duration("idempotency-window", time.Duration(cfg.IdempotencyWindow), "how long Idempotency-Keys are kept, 0 to ignore them (IDEMPOTENCY_WINDOW)")
+	autoMigrate := fs.Bool("migrate", cfg.Database.AutoMigrate, "apply pending schema migrations at startup (DB_AUTO_MIGRATE)")
+	if err := fs.Parse(args); err != nil {
+		return cfg, nil, err
+	}
+
+	if *configFile != "" {
+		if err := cfg.readFile(*configFile); err != nil {
+			return cfg, nil, err
+		}
+	}
+	if err := cfg.readEnv(); err != nil {
+		return cfg, nil, err
+	}
+
+	// Flags win over everything else, but only when actually given.
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if set["listen"] {
+		cfg.ListenAddr = *listen
+	}
+	if set["db-dsn"] {
+		cfg.Database.DSN = *dsn
+	}
+	if set["db-sslmode"] {
+		cfg.Database.SSLMode = *sslMode
+	}
+	if set["db-max-open"] {
+		cfg.Database.MaxOpenConns = *maxOpen
+	}
+	if set["db-max-idle"] {
+		cfg.Database.MaxIdleConns = *maxIdle
+	}
+	if set["db-conn-lifetime"] {
+		cfg.Database.ConnMaxLifetime = Duration(*lifetime)
+	}
+	if set["db-conn-idle-time"] {
+		cfg.Database.ConnMaxIdleTime = Duration(*idleTime)
+	}
+	if set["db-statement-timeout"] {
+		cfg.Database.StatementTimeout = Duration(*stmtTimeout)
+	}
+	if set["db-connect-timeout"] {
+		cfg.Database.ConnectTimeout = Duration(*connectTimeout)
+	}
+	if set["trash-retention"] {
+		cfg.TrashRetention = Duration(*trashRetention)
+	}
+	if set["sync-token-max-age"] {
+		cfg.SyncTokenMaxAge = Duration(*syncTokenMaxAge)
+	}
+	if set["idempotency-window"] {
+		cfg.IdempotencyWindow = Duration(*idempotencyWindow)
+	}
+	if set["migrate"] {
+		cfg.Database.AutoMigrate = *autoMigrate
+	}
+
+	return cfg, fs.Args(), cfg.Validate()
+}
+
+func (c *Config) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func (c *Config) readEnv() error {
+	str := func(key string, dst *string) {
+		if v, ok := os.LookupEnv(key); ok {
+			*dst = v
+		}
+	}
+	num := func(key string, dst *int) error {
+		if v, ok := os.LookupEnv(key); ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("%s must be a number, got %q", key, v)
+			}
+			*dst = n
+		}
+		return nil
+	}
+	boolean := func(key string, dst *bool) error {
+		if v, ok := os.LookupEnv(key); ok {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("%s must be true or false, got %q", key, v)
+			}
+			*dst = b
+		}
+		return nil
+	}
+	dur := func(key string, dst *Duration) error {
+		if v, ok := os.LookupEnv(key); ok {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("%s must be a duration like 30s, got %q", key, v)
+			}
+			*dst = Duration(d)
+		}
+		return nil
+	}
+
+	str("LISTEN_ADDR", &c.ListenAddr)
+	str("PUBLIC_BASE_URL", &c.PublicBaseURL)
+	str("EVENT_TIMEZONE", &c.EventTimezone)
+
+	str("DATABASE_URL", &c.Database.DSN)
+	str("DB_SSLMODE", &c.Database.SSLMode)
+	str("SMTP_HOST", &c.Mail.SMTPHost)
+	str("SMTP_USERNAME", &c.Mail.SMTPUsername)
+	str("SMTP_PASSWORD", &c.Mail.SMTPPassword)
+	str("SMTP_FROM", &c.Mail.From)
+	str("INBOUND_MAIL_TOKEN", &c.Mail.InboundToken)
+
+	return errors.Join(
+		num("DB_MAX_OPEN_CONNS", &c.Database.MaxOpenConns),
+		num("DB_MAX_IDLE_CONNS", &c.Database.MaxIdleConns),
+		dur("DB_CONN_MAX_LIFETIME", &c.Database.ConnMaxLifetime),
+		dur("DB_CONN_MAX_IDLE_TIME", &c.Database.ConnMaxIdleTime),
+		dur("DB_STATEMENT_TIMEOUT", &c.Database.StatementTimeout),
+		dur("DB_CONNECT_TIMEOUT", &c.Database.ConnectTimeout),
+		boolean("DB_AUTO_MIGRATE", &c.Database.AutoMigrate),
+		dur("TRASH_RETENTION", &c.TrashRetention),
+		dur("SYNC_TOKEN_MAX_AGE", &c.SyncTokenMaxAge),
+		dur("IDEMPOTENCY_WINDOW", &c.IdempotencyWindow),
+		num("SMTP_PORT", &c.Mail.SMTPPort),
+	)
+}
+
+// Validate reports configuration mistakes all at once.
+func (c Config) Validate() error {
+	var errs []error
+	if c.Database.DSN == "" {
+		errs = append(errs, errors.New("database DSN is empty; set DATABASE_URL or -db-dsn"))
+	}
+	switch c.Database.SSLMode {
+	case "", "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+	default:
+		errs = append(errs, fmt.Errorf("unknown database sslmode %q", c.Database.SSLMode))
+	}
+	if c.Database.MaxOpenConns < 0 || c.Database.MaxIdleConns < 0 {
+		errs = append(errs, errors.New("database pool sizes cannot be negative"))
+	}
+	if c.Database.MaxOpenConns > 0 && c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+		errs = append(errs, errors.New("database maxIdleConns cannot exceed maxOpenConns"))
+	}
+	if c.Database.StatementTimeout < 0 || c.Database.ConnMaxLifetime < 0 || c.Database.ConnMaxIdleTime < 0 || c.Database.ConnectTimeout < 0 {
+		errs = append(errs, errors.New("database durations cannot be negative"))
+	}
+	if c.TrashRetention < 0 {
+		errs = append(errs, errors.New("trash retention cannot be negative"))
+	}
+	if c.SyncTokenMaxAge < 0 {
+		errs = append(errs, errors.New("sync token max age cannot be negative"))
+	}
+	if c.IdempotencyWindow < 0 {
+		errs = append(errs, errors.New("idempotency window cannot be negative"))
+	}
+	if _, err := time.LoadLocation(c.EventTimezone); err != nil || c.EventTimezone == "" {
+		errs = append(errs, fmt.Errorf("unknown event timezone %q", c.EventTimezone))
+	}
+	if _, err := url.Parse(c.PublicBaseURL); err != nil {
+		errs = append(errs, fmt.Errorf("invalid public base URL: %w", err))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadDefaults(t *testing.T) {
+	t.Setenv("CALENDAR_CONFIG", "")
+	cfg, args, err := Load("api", []string{"serve"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg, Default()) {
+		t.Errorf("loaded %+v, want the defaults", cfg)
+	}
+	if !reflect.DeepEqual(args, []string{"serve"}) {
+		t.Errorf("arguments left are %v", args)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(file, []byte(`{
+		"listenAddr": ":9000",
+		"eventTimezone": "Europe/Berlin",
+		"database": {"maxOpenConns": 50, "maxIdleConns": 10, "connMaxIdleTime": "1m", "connMaxLifetime": "1h"}
+	}`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CALENDAR_CONFIG", file)
+	t.Setenv("DB_MAX_OPEN_CONNS", "40")
+	t.Setenv("DB_CONN_MAX_IDLE_TIME", "2m")
+	t.Setenv("DB_CONN_MAX_LIFETIME", "2h")
+
+	cfg, _, err := Load("api", []string{"-db-max-open", "30", "-db-conn-idle-time", "3m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		got, want interface{}
+	}{
+		{"default", cfg.PublicBaseURL, Default().PublicBaseURL},
+		{"file", cfg.ListenAddr, ":9000"},
+		{"file", cfg.Database.MaxIdleConns, 10},
+		{"env over file", cfg.Database.ConnMaxLifetime, Duration(2 * time.Hour)},
+		{"flag over env", cfg.Database.MaxOpenConns, 30},
+		{"flag over env", cfg.Database.ConnMaxIdleTime, Duration(3 * time.Minute)},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	t.Setenv("CALENDAR_CONFIG", "")
+	file := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(file, []byte(`{"listen": ":9000"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := Load("api", []string{"-config", file}); err == nil || !strings.Contains(err.Error(), "listen") {
+		t.Errorf("unknown field in the file: %v", err)
+	}
+
+	t.Setenv("DB_MAX_IDLE_CONNS", "some")
+	t.Setenv("DB_CONN_MAX_IDLE_TIME", "5")
+	_, _, err := Load("api", nil)
+	if err == nil || !strings.Contains(err.Error(), "DB_MAX_IDLE_CONNS") || !strings.Contains(err.Error(), "DB_CONN_MAX_IDLE_TIME") {
+		t.Errorf("bad environment: %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(c *Config)
+		want   string
+	}{
+		{"empty DSN", func(c *Config) { c.Database.DSN = "" }, "DSN is empty"},
+		{"sslmode", func(c *Config) { c.Database.SSLMode = "on" }, "sslmode"},
+		{"negative pool", func(c *Config) { c.Database.MaxIdleConns = -1 }, "cannot be negative"},
+		{"idle over open", func(c *Config) { c.Database.MaxIdleConns = 30 }, "cannot exceed"},
+		{"negative idle time", func(c *Config) { c.Database.ConnMaxIdleTime = Duration(-time.Minute) }, "durations cannot be negative"},
+		{"negative retention", func(c *Config) { c.TrashRetention = -1 }, "trash retention"},
+		{"negative token age", func(c *Config) { c.SyncTokenMaxAge = -1 }, "sync token"},
+		{"negative idempotency window", func(c *Config) { c.IdempotencyWindow = -1 }, "idempotency window"},
+		{"timezone", func(c *Config) { c.EventTimezone = "Mars/Olympus" }, "timezone"},
+		{"empty timezone", func(c *Config) { c.EventTimezone = "" }, "timezone"},
+		{"base URL", func(c *Config) { c.PublicBaseURL = "http://[::1" }, "base URL"},
+	}
+	for _, tt := range tests {
+		cfg := Default()
+		tt.change(&cfg)
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got %v, want an error with %q", tt.name, err, tt.want)
+		}
+	}
+
+	cfg := Default()
+	cfg.Database.MaxOpenConns = 0 // unlimited
+	cfg.Database.MaxIdleConns = 100
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("unlimited open connections: %v", err)
+	}
+}
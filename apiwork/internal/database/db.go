@@ -1,15 +1,159 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
-	_ "github.com/lib/pq" // PostgreSQL driver
+	_ "github.com/lib/pq"           // PostgreSQL driver
+	_ "github.com/mattn/go-sqlite3" // SQLite driver
+
+	"github.com/Aman221/4723/internal/config"
 )
 
 var DB *sql.DB
 
-func InitDB() error {
-	var err error
-	DB, err = sql.Open("postgres", "postgres://amanuel:@localhost/users?sslmode=disable")
-	return err
+// Supported SQL dialects.
+const (
+	Postgres = "postgres"
+	SQLite   = "sqlite"
+)
+
+// Dialect is the dialect of DB, chosen by the scheme of the DSN: sqlite:
+// (e.g. sqlite:calendar.db or sqlite:///var/lib/calendar.db) selects
+// SQLite, anything else Postgres.
+var Dialect = Postgres
+
+// InitDB opens the connection pool described by cfg and waits until the
+// database answers, retrying with exponential backoff for up to
+// cfg.ConnectTimeout.
+func InitDB(cfg config.Database) error {
+	dialect, driver := Postgres, "postgres"
+	dsn, err := buildDSN(cfg)
+	if path, ok := sqlitePath(cfg.DSN); ok {
+		dialect, driver = SQLite, "sqlite3"
+		dsn, err = sqliteDSN(path), nil
+	}
+	if err != nil {
+		return err
+	}
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return fmt.Errorf("opening database %s: %w", Redact(dsn), err)
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(time.Duration(cfg.ConnMaxLifetime))
+	db.SetConnMaxIdleTime(time.Duration(cfg.ConnMaxIdleTime))
+
+	if err := pingWithRetry(db, time.Duration(cfg.ConnectTimeout)); err != nil {
+		db.Close()
+		return fmt.Errorf("could not connect to database %s: %w (check DATABASE_URL, -db-dsn or the config file)", Redact(dsn), err)
+	}
+	DB, Dialect = db, dialect
+	return nil
+}
+
+// sqlitePath returns the file named by a sqlite: DSN.
+func sqlitePath(dsn string) (string, bool) {
+	for _, prefix := range []string{"sqlite://", "sqlite3://", "sqlite:", "sqlite3:"} {
+		if strings.HasPrefix(dsn, prefix) {
+			return strings.TrimPrefix(dsn, prefix), true
+		}
+	}
+	return "", false
+}
+
+// sqliteDSN turns a file path, optionally followed by driver options, into
+// a go-sqlite3 DSN. Foreign keys are enforced as they are in Postgres, and
+// WAL plus a busy timeout lets readers and a writer share the file.
+func sqliteDSN(path string) string {
+	path, query, _ := strings.Cut(path, "?")
+	params, _ := url.ParseQuery(query)
+	if path == ":memory:" {
+		// Every connection in the pool must see the same database.
+		path = "file::memory:"
+		params.Set("cache", "shared")
+	} else if !strings.HasPrefix(path, "file:") {
+		path = "file:" + path
+	}
+	defaults := map[string]string{"_foreign_keys": "on", "_busy_timeout": "5000", "_journal_mode": "WAL", "_txlock": "immediate"}
+	for k, v := range defaults {
+		if params.Get(k) == "" {
+			params.Set(k, v)
+		}
+	}
+	return path + "?" + params.Encode()
+}
+
+func pingWithRetry(db *sql.DB, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	backoff := 250 * time.Millisecond
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err := db.PingContext(ctx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if time.Now().Add(backoff).After(deadline) {
+			return fmt.Errorf("gave up after %d attempts: %w", attempt, err)
+		}
+		log.Printf("Database not reachable yet (attempt %d): %v; retrying in %s", attempt, err, backoff)
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > 5*time.Second {
+			backoff = 5 * time.Second
+		}
+	}
+}
+
+// buildDSN applies the TLS mode and statement timeout to the configured
+// DSN, which may be a URL or a key=value string.
+func buildDSN(cfg config.Database) (string, error) {
+	params := map[string]string{}
+	if cfg.SSLMode != "" {
+		params["sslmode"] = cfg.SSLMode
+	}
+	if cfg.StatementTimeout > 0 {
+		// lib/pq passes unknown parameters to the server as run-time settings.
+		params["statement_timeout"] = strconv.FormatInt(time.Duration(cfg.StatementTimeout).Milliseconds(), 10)
+	}
+
+	if strings.HasPrefix(cfg.DSN, "postgres://") || strings.HasPrefix(cfg.DSN, "postgresql://") {
+		u, err := url.Parse(cfg.DSN)
+		if err != nil {
+			return "", fmt.Errorf("invalid database URL: %w", err)
+		}
+		q := u.Query()
+		for k, v := range params {
+			q.Set(k, v)
+		}
+		u.RawQuery = q.Encode()
+		return u.String(), nil
+	}
+
+	dsn := cfg.DSN
+	for k, v := range params {
+		dsn += " " + k + "=" + v
+	}
+	return strings.TrimSpace(dsn), nil
+}
+
+// Redact hides the password in a DSN so it can be logged.
+func Redact(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" {
+		return u.Redacted()
+	}
+	fields := strings.Fields(dsn)
+	for i, f := range fields {
+		if strings.HasPrefix(f, "password=") {
+			fields[i] = "password=xxxxx"
+		}
+	}
+	return strings.Join(fields, " ")
 }
@@ -0,0 +1,286 @@
+// Package digest emails users a morning summary of the day's events.
+//
+// Users opt in through a row in digest_subscriptions holding the local time
+// and timezone to send at. Every message carries an unsubscribe link built
+// from the subscription's random token.
+package digest
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"log"
+	"net/url"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/Aman221/4723/internal/database"
+	"github.com/Aman221/4723/internal/mailer"
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+var (
+	textTemplate = texttemplate.Must(texttemplate.ParseFS(templateFS, "templates/digest.txt.tmpl"))
+	htmlTemplate = htmltemplate.Must(htmltemplate.ParseFS(templateFS, "templates/digest.html.tmpl"))
+)
+
+// ErrNotFound is returned for unknown users and unsubscribe tokens.
+var ErrNotFound = errors.New("digest subscription not found")
+
+// Subscription is a user's digest preference.
+type Subscription struct {
+	UserID   string `json:"userId"`
+	Email    string `json:"email"`
+	Enabled  bool   `json:"enabled"`
+	SendAt   string `json:"sendAt"`   // local time of day, HH:MM
+	Timezone string `json:"timezone"` // IANA name, e.g. America/New_York
+	token    string
+}
+
+// Validate checks the send time and timezone.
+func (s *Subscription) Validate() error {
+	if _, err := time.Parse("15:04", s.SendAt); err != nil {
+		return errors.New("sendAt must be formatted as HH:MM")
+	}
+	if _, err := time.LoadLocation(s.Timezone); err != nil || s.Timezone == "" {
+		return fmt.Errorf("unknown timezone %q", s.Timezone)
+	}
+	s.Email = strings.TrimSpace(s.Email)
+	if s.Enabled && !strings.Contains(s.Email, "@") {
+		return errors.New("a valid email is required to enable the digest")
+	}
+	return nil
+}
+
+// Get returns a user's subscription. Users who never opted in get a
+// disabled subscription addressed to their account email.
+func Get(userID string) (Subscription, error) {
+	sub := Subscription{UserID: userID, SendAt: "07:00", Timezone: "America/New_York"}
+	err := database.DB.QueryRow(`
+		SELECT COALESCE(NULLIF(d.email, ''), u.email), COALESCE(d.enabled, FALSE), COALESCE(d.send_at, '07:00'), COALESCE(d.timezone, 'America/New_York')
+		FROM users u
+		LEFT JOIN digest_subscriptions d ON d.user_id = u.id
+		WHERE u.id = $1
+	`, userID).Scan(&sub.Email, &sub.Enabled, &sub.SendAt, &sub.Timezone)
+	if errors.Is(err, sql.ErrNoRows) {
+		return sub, ErrNotFound
+	}
+	return sub, err
+}
+
+func newToken() (string, error) {
+	var b [24]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b[:]), nil
+}
+
+// Save stores a user's subscription. The unsubscribe token is created on
+// first save and kept afterwards so links in old emails keep working.
+func Save(sub Subscription) (Subscription, error) {
+	if err := sub.Validate(); err != nil {
+		return sub, err
+	}
+	token, err := newToken()
+	if err != nil {
+		return sub, err
+	}
+	_, err = database.DB.Exec(`
+		INSERT INTO digest_subscriptions (user_id, email, enabled, send_at, timezone, unsubscribe_token)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id) DO UPDATE
+		SET email = EXCLUDED.email, enabled = EXCLUDED.enabled, send_at = EXCLUDED.send_at, timezone = EXCLUDED.timezone
+	`, sub.UserID, sub.Email, sub.Enabled, sub.SendAt, sub.Timezone, token)
+	if err != nil {
+		return sub, err
+	}
+	return Get(sub.UserID)
+}
+
+// Unsubscribe disables the subscription owning the token.
+func Unsubscribe(token string) error {
+	if token == "" {
+		return ErrNotFound
+	}
+	res, err := database.DB.Exec("UPDATE digest_subscriptions SET enabled = FALSE WHERE unsubscribe_token = $1", token)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Event is one line of the agenda.
+type Event struct {
+	Title        string
+	StartTime    string
+	EndTime      string
+	Location     string
+	CalendarName string
+}
+
+// Agenda is the data passed to the digest templates.
+type Agenda struct {
+	Date           time.Time
+	Events         []Event
+	UnsubscribeURL string
+}
+
+// Render produces the text and HTML bodies of a digest.
+func Render(a Agenda) (text, html string, err error) {
+	var t, h bytes.Buffer
+	if err := textTemplate.Execute(&t, a); err != nil {
+		return "", "", err
+	}
+	if err := htmlTemplate.Execute(&h, a); err != nil {
+		return "", "", err
+	}
+	return t.String(), h.String(), nil
+}
+
+// Job sends digests whose local send time has passed today.
+type Job struct {
+	Mailer mailer.Mailer
+	// BaseURL is the public address of the API, used for unsubscribe links.
+	BaseURL  string
+	Interval time.Duration
+}
+
+// Run sends digests until ctx is cancelled.
+func (j *Job) Run(ctx context.Context) {
+	interval := j.Interval
+	if interval == 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := j.Tick(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			log.Printf("digest: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Tick sends every digest that is due at now.
+func (j *Job) Tick(ctx context.Context, now time.Time) error {
+	rows, err := database.DB.QueryContext(ctx, `
+		SELECT user_id, email, send_at, timezone, unsubscribe_token
+		FROM digest_subscriptions
+		WHERE enabled = TRUE
+	`)
+	if err != nil {
+		return err
+	}
+	var subs []Subscription
+	for rows.Next() {
+		var s Subscription
+		if err := rows.Scan(&s.UserID, &s.Email, &s.SendAt, &s.Timezone, &s.token); err != nil {
+			rows.Close()
+			return err
+		}
+		subs = append(subs, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, s := range subs {
+		loc, err := time.LoadLocation(s.Timezone)
+		if err != nil {
+			log.Printf("digest: user %s has invalid timezone %q", s.UserID, s.Timezone)
+			continue
+		}
+		local := now.In(loc)
+		if local.Format("15:04") < s.SendAt {
+			continue
+		}
+		today := local.Format("2006-01-02")
+
+		// Claiming the day before sending keeps concurrent instances from
+		// sending the same digest twice.
+		res, err := database.DB.ExecContext(ctx, `
+			UPDATE digest_subscriptions SET last_sent_on = $1
+			WHERE user_id = $2 AND enabled = TRUE AND (last_sent_on IS NULL OR last_sent_on < $1)
+		`, today, s.UserID)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			continue
+		}
+		if err := j.send(ctx, s, local); err != nil {
+			log.Printf("digest: sending to user %s: %v", s.UserID, err)
+		}
+	}
+	return nil
+}
+
+func (j *Job) send(ctx context.Context, s Subscription, local time.Time) error {
+	events, err := todaysEvents(ctx, s.UserID, local)
+	if err != nil {
+		return err
+	}
+	unsubscribe := strings.TrimRight(j.BaseURL, "/") + "/digest/unsubscribe?token=" + url.QueryEscape(s.token)
+	text, html, err := Render(Agenda{Date: local, Events: events, UnsubscribeURL: unsubscribe})
+	if err != nil {
+		return err
+	}
+	return j.Mailer.Send(ctx, mailer.Message{
+		To:      []string{s.Email},
+		Subject: "Your agenda for " + local.Format("Monday, January 2"),
+		Text:    text,
+		HTML:    html,
+		Headers: map[string]string{"List-Unsubscribe": "<" + unsubscribe + ">"},
+	})
+}
+
+// todaysEvents lists the events on the user's visible calendars that take
+// place on the local date, including weekly events on that weekday.
+func todaysEvents(ctx context.Context, userID string, local time.Time) ([]Event, error) {
+	weekday := int(local.Weekday())
+	if weekday == 0 {
+		weekday = 7
+	}
+	rows, err := database.DB.QueryContext(ctx, `
+		SELECT e.title, e.start_time, e.end_time, e.location, c.name
+		FROM calendar_events e
+		JOIN calendars c ON c.id = e.calendar_id
+		WHERE c.user_id = $1 AND c.visible = TRUE AND e.deleted_at IS NULL
+		  AND (e.date = $2 OR (e.date IS NULL AND e.day = $3))
+		ORDER BY e.start_time, e.title
+	`, userID, local.Format("2006-01-02"), weekday)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []Event
+	for rows.Next() {
+		var e Event
+		if err := rows.Scan(&e.Title, &e.StartTime, &e.EndTime, &e.Location, &e.CalendarName); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
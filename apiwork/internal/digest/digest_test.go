@@ -0,0 +1,74 @@
+package digest
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	s := Subscription{Enabled: true, Email: " bob@example.com ", SendAt: "07:30", Timezone: "Europe/Paris"}
+	if err := s.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if s.Email != "bob@example.com" {
+		t.Errorf("email is %q", s.Email)
+	}
+	// A disabled digest needs no address.
+	off := Subscription{SendAt: "07:30", Timezone: "UTC"}
+	if err := off.Validate(); err != nil {
+		t.Errorf("disabled digest: %v", err)
+	}
+
+	for _, bad := range []Subscription{
+		{SendAt: "7:30am", Timezone: "UTC"},
+		{SendAt: "25:00", Timezone: "UTC"},
+		{SendAt: "07:30", Timezone: "Mars/Olympus"},
+		{SendAt: "07:30", Timezone: ""},
+		{Enabled: true, Email: "bob", SendAt: "07:30", Timezone: "UTC"},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("%+v is valid", bad)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	date := time.Date(2026, 1, 7, 7, 30, 0, 0, time.UTC)
+	agenda := Agenda{
+		Date: date,
+		Events: []Event{
+			{Title: "Standup", StartTime: "09:00", EndTime: "09:15", Location: "Room 4", CalendarName: "Work"},
+			{Title: "Lunch <& learn>", StartTime: "12:00", EndTime: "13:00", CalendarName: "Team"},
+		},
+		UnsubscribeURL: "https://cal.example.com/digest/unsubscribe?token=abc",
+	}
+	text, html, err := Render(agenda)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"Your agenda for Wednesday, January 7",
+		"09:00-09:15  Standup (Room 4)\n    Work",
+		"12:00-13:00  Lunch <& learn>\n    Team",
+		"Unsubscribe: https://cal.example.com/digest/unsubscribe?token=abc",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text lacks %q:\n%s", want, text)
+		}
+	}
+	if strings.Contains(html, "<& learn>") || !strings.Contains(html, "Lunch &lt;&amp; learn&gt;") {
+		t.Errorf("HTML doesn't escape titles:\n%s", html)
+	}
+	if !strings.Contains(html, "https://cal.example.com/digest/unsubscribe?token=abc") {
+		t.Errorf("HTML lacks the unsubscribe link:\n%s", html)
+	}
+
+	text, _, err = Render(Agenda{Date: date, UnsubscribeURL: "u"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(text, "Nothing scheduled today.") {
+		t.Errorf("empty agenda:\n%s", text)
+	}
+}
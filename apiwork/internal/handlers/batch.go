@@ -0,0 +1,409 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Aman221/4723/internal/store"
+)
+
+// POST /batch runs a list of operations in one transaction:
+//
+//	{"mode": "all-or-nothing", "operations": [
+//	  {"action": "create", "type": "event", "data": {...}},
+//	  {"action": "update", "type": "calendar", "id": "2", "version": 3, "data": {"name": "Work"}},
+//	  {"action": "delete", "type": "event", "id": "7", "version": 1},
+//	  {"action": "moveEvents", "from": "2", "to": "5"},
+//	  {"action": "deleteMatching", "query": "standup", "calendarIds": ["2"]}
+//	]}
+//
+// create takes the body of POST /calendars or /events, update a merge patch
+// as PATCH does, and update and delete the version they are based on.
+// moveEvents moves every event of one calendar to another and
+// deleteMatching deletes the events a search finds; neither takes versions.
+//
+// The response lists a result per operation, with the status its own
+// endpoint would have answered and what it returned or the error. In
+// all-or-nothing mode, the default, the first operation to fail rolls back
+// the batch; the response has that operation's status, and the others
+// report 424 Failed Dependency. In per-item mode each operation that fails
+// is rolled back alone and the response is 200 OK.
+
+// MaxBatchOperations is how many operations one batch can have.
+const MaxBatchOperations = 100
+
+// Batch modes.
+const (
+	batchAllOrNothing = "all-or-nothing"
+	batchPerItem      = "per-item"
+)
+
+type batchRequest struct {
+	Mode       string           `json:"mode"`
+	Operations []batchOperation `json:"operations"`
+}
+
+type batchOperation struct {
+	Action  string          `json:"action"`
+	Type    string          `json:"type"`
+	ID      string          `json:"id"`
+	Version int             `json:"version"`
+	Data    json.RawMessage `json:"data"`
+	// moveEvents
+	From string `json:"from"`
+	To   string `json:"to"`
+	// deleteMatching
+	Query         string   `json:"query"`
+	CalendarIDs   []string `json:"calendarIds"`
+	IncludeHidden bool     `json:"includeHidden"`
+}
+
+type batchResult struct {
+	Status   int             `json:"status"`
+	Error    *apiError       `json:"error,omitempty"`
+	Calendar *Calendar       `json:"calendar,omitempty"`
+	Event    *CalendarEvent  `json:"event,omitempty"`
+	Events   []CalendarEvent `json:"events,omitempty"`
+	Deleted  []string        `json:"deleted,omitempty"`
+}
+
+// batchResponse has the results of a batch's operations, in order.
+type batchResponse struct {
+	Results []batchResult `json:"results"`
+}
+
+// A batchError is why an operation failed, with the status and error its
+// own endpoint would have answered.
+type batchError struct {
+	result batchResult
+}
+
+func (e *batchError) Error() string { return e.result.Error.Message }
+
+func failed(status int, format string, args ...interface{}) error {
+	e := newError(status, fmt.Sprintf(format, args...))
+	return &batchError{batchResult{Status: status, Error: &e}}
+}
+
+// changed is the failure of an operation on a calendar or event that has
+// changed since the version it was based on.
+func changed(result batchResult, kind string) error {
+	e := newError(http.StatusPreconditionFailed, kind+" has changed")
+	result.Status, result.Error = http.StatusPreconditionFailed, &e
+	return &batchError{result}
+}
+
+// invalidOperation is the failure of an operation whose kind has problems.
+func invalidOperation(kind string, problems []fieldError) error {
+	e := newError(http.StatusBadRequest, invalidMessage(kind, problems), problems...)
+	return &batchError{batchResult{Status: http.StatusBadRequest, Error: &e}}
+}
+
+// BatchHandler runs a batch of calendar and event operations.
+func BatchHandler(w http.ResponseWriter, r *http.Request) {
+	var batch batchRequest
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	defer r.Body.Close()
+	var problems []fieldError
+	if batch.Mode == "" {
+		batch.Mode = batchAllOrNothing
+	}
+	if batch.Mode != batchAllOrNothing && batch.Mode != batchPerItem {
+		problems = append(problems, fieldError{"mode", fmt.Sprintf("mode must be %q or %q", batchAllOrNothing, batchPerItem)})
+	}
+	if len(batch.Operations) == 0 || len(batch.Operations) > MaxBatchOperations {
+		problems = append(problems, fieldError{"operations", fmt.Sprintf("operations must list 1 to %d operations", MaxBatchOperations)})
+	}
+	if invalid(w, "batch", problems) {
+		return
+	}
+
+	ctx := r.Context()
+	results := make([]batchResult, len(batch.Operations))
+	var followUps []func()
+	failedAt := -1
+	err := Store.Batch(ctx, func(tx store.Store) error {
+		for i, op := range batch.Operations {
+			var followUp func()
+			run := func(s store.Store) error {
+				var err error
+				results[i], followUp, err = runOperation(ctx, s, op)
+				return err
+			}
+			var err error
+			if batch.Mode == batchPerItem {
+				err = tx.Batch(ctx, run)
+			} else {
+				err = run(tx)
+			}
+			if err != nil {
+				results[i] = operationFailed(w, err)
+				if batch.Mode == batchPerItem {
+					continue
+				}
+				failedAt = i
+				return err
+			}
+			if followUp != nil {
+				followUps = append(followUps, followUp)
+			}
+		}
+		return nil
+	})
+	status := http.StatusOK
+	if failedAt >= 0 {
+		status = results[failedAt].Status
+		for i := range results {
+			if i != failedAt {
+				notApplied := newError(http.StatusFailedDependency, fmt.Sprintf("Not applied: operation %d failed", failedAt))
+				results[i] = batchResult{Status: http.StatusFailedDependency, Error: &notApplied}
+			}
+		}
+	} else if err != nil {
+		serverError(w, err)
+		return
+	} else {
+		for _, followUp := range followUps {
+			followUp()
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(batchResponse{results})
+}
+
+// operationFailed is the result of an operation that returned err.
+func operationFailed(w http.ResponseWriter, err error) batchResult {
+	var failure *batchError
+	if errors.As(err, &failure) {
+		return failure.result
+	}
+	status, e := describeError(w, err)
+	return batchResult{Status: status, Error: &e}
+}
+
+// runOperation runs op against s. It returns the result and the follow-up
+// work, if any, to do once the batch has committed.
+func runOperation(ctx context.Context, s store.Store, op batchOperation) (batchResult, func(), error) {
+	switch op.Action {
+	case "create", "update", "delete":
+		if op.Type != "calendar" && op.Type != "event" {
+			return batchResult{}, nil, failed(http.StatusBadRequest, `type must be "calendar" or "event"`)
+		}
+		if op.Action != "create" && op.Version < 1 {
+			return batchResult{}, nil, failed(http.StatusPreconditionRequired, "version is required")
+		}
+	case "moveEvents":
+		return moveEvents(ctx, s, op)
+	case "deleteMatching":
+		return deleteMatching(ctx, s, op)
+	default:
+		return batchResult{}, nil, failed(http.StatusBadRequest,
+			"action must be create, update, delete, moveEvents or deleteMatching")
+	}
+
+	if op.Action != "delete" && len(op.Data) == 0 {
+		return batchResult{}, nil, failed(http.StatusBadRequest, "data is required")
+	}
+	switch op.Action + " " + op.Type {
+	case "create calendar":
+		var newCalendar NCalendar
+		if err := json.Unmarshal(op.Data, &newCalendar); err != nil {
+			return batchResult{}, nil, failed(http.StatusBadRequest, "Invalid calendar")
+		}
+		if problems := validateNewCalendar(newCalendar); len(problems) > 0 {
+			return batchResult{}, nil, invalidOperation("calendar", problems)
+		}
+		created, err := s.CreateCalendar(ctx, newCalendar)
+		return batchResult{Status: http.StatusCreated, Calendar: &created}, nil, err
+
+	case "create event":
+		var newEvent NCalendarEvent
+		if err := json.Unmarshal(op.Data, &newEvent); err != nil {
+			return batchResult{}, nil, failed(http.StatusBadRequest, "Invalid event")
+		}
+		newEvent.Day = weekday(newEvent.Date, newEvent.Day)
+		problems, err := validateNewEvent(ctx, s, &newEvent)
+		if err != nil {
+			return batchResult{}, nil, err
+		}
+		if len(problems) > 0 {
+			return batchResult{}, nil, invalidOperation("event", problems)
+		}
+		created, err := s.CreateEvent(ctx, newEvent)
+		if errors.Is(err, store.ErrUnknownCalendar) {
+			return batchResult{}, nil, invalidOperation("event", []fieldError{unknownCalendar(newEvent.CalendarID)})
+		}
+		return batchResult{Status: http.StatusCreated, Event: &created},
+			func() { eventSaved(created.ID, newEvent, false) }, err
+
+	case "update calendar":
+		patch, err := batchPatch(op.Data)
+		if err != nil {
+			return batchResult{}, nil, err
+		}
+		current, err := s.GetCalendar(ctx, op.ID)
+		if errors.Is(err, store.ErrNotFound) {
+			return batchResult{}, nil, failed(http.StatusNotFound, "Calendar not found")
+		}
+		if err != nil {
+			return batchResult{}, nil, err
+		}
+		var patched Calendar
+		if err := applyPatch(current, patch, &patched); err != nil {
+			return batchResult{}, nil, failed(http.StatusBadRequest, "Invalid calendar")
+		}
+		patched.ID, patched.Version = op.ID, op.Version
+		if problems := validateCalendar(patched); len(problems) > 0 {
+			return batchResult{}, nil, invalidOperation("calendar", problems)
+		}
+		saved, err := s.UpdateCalendar(ctx, patched)
+		if errors.Is(err, store.ErrVersionMismatch) {
+			return batchResult{}, nil, changed(batchResult{Calendar: &current}, "Calendar")
+		}
+		return batchResult{Status: http.StatusOK, Calendar: &saved}, nil, err
+
+	case "update event":
+		patch, err := batchPatch(op.Data)
+		if err != nil {
+			return batchResult{}, nil, err
+		}
+		current, err := s.GetEvent(ctx, op.ID)
+		if errors.Is(err, store.ErrNotFound) {
+			return batchResult{}, nil, failed(http.StatusNotFound, "Event not found")
+		}
+		if err != nil {
+			return batchResult{}, nil, err
+		}
+		patched, err := patchEvent(current, patch)
+		if err != nil {
+			return batchResult{}, nil, failed(http.StatusBadRequest, "Invalid event")
+		}
+		patched.ID, patched.Version = op.ID, op.Version
+		patched.Day = weekday(patched.Date, patched.Day)
+		problems, err := validateEvent(ctx, s, &patched)
+		if err != nil {
+			return batchResult{}, nil, err
+		}
+		if len(problems) > 0 {
+			return batchResult{}, nil, invalidOperation("event", problems)
+		}
+		saved, err := s.UpdateEvent(ctx, patched)
+		if errors.Is(err, store.ErrVersionMismatch) {
+			return batchResult{}, nil, changed(batchResult{Event: &current}, "Event")
+		}
+		if errors.Is(err, store.ErrUnknownCalendar) {
+			return batchResult{}, nil, invalidOperation("event", []fieldError{unknownCalendar(patched.CalendarID)})
+		}
+		return batchResult{Status: http.StatusOK, Event: &saved},
+			func() { eventSaved(saved.ID, eventFields(saved), true) }, err
+
+	case "delete calendar":
+		events, err := calendarEvents(ctx, s, op.ID)
+		if err != nil {
+			return batchResult{}, nil, err
+		}
+		err = s.DeleteCalendar(ctx, op.ID, op.Version)
+		if errors.Is(err, store.ErrNotFound) {
+			return batchResult{}, nil, failed(http.StatusNotFound, "Calendar not found")
+		}
+		if errors.Is(err, store.ErrResourceCalendar) {
+			return batchResult{}, nil, failed(http.StatusConflict, resourceCalendarMessage)
+		}
+		if errors.Is(err, store.ErrVersionMismatch) {
+			current, _ := s.GetCalendar(ctx, op.ID)
+			return batchResult{}, nil, changed(batchResult{Calendar: &current}, "Calendar")
+		}
+		return batchResult{Status: http.StatusNoContent}, func() { eventsDeleted(events) }, err
+
+	default: // delete event
+		err := s.DeleteEvent(ctx, op.ID, op.Version)
+		if errors.Is(err, store.ErrNotFound) {
+			return batchResult{}, nil, failed(http.StatusNotFound, "Event not found")
+		}
+		if errors.Is(err, store.ErrVersionMismatch) {
+			current, _ := s.GetEvent(ctx, op.ID)
+			return batchResult{}, nil, changed(batchResult{Event: &current}, "Event")
+		}
+		return batchResult{Status: http.StatusNoContent}, func() { eventDeleted(op.ID) }, err
+	}
+}
+
+// batchPatch reads the merge patch of an update, leaving out what readPatch
+// does.
+func batchPatch(data json.RawMessage) (map[string]interface{}, error) {
+	var patch map[string]interface{}
+	if err := json.Unmarshal(data, &patch); err != nil || patch == nil {
+		return nil, failed(http.StatusBadRequest, "data must be a JSON object")
+	}
+	for _, name := range unpatchable {
+		delete(patch, name)
+	}
+	return patch, nil
+}
+
+// moveEvents moves the events of calendar op.From to op.To.
+func moveEvents(ctx context.Context, s store.Store, op batchOperation) (batchResult, func(), error) {
+	if _, err := s.GetCalendar(ctx, op.From); errors.Is(err, store.ErrNotFound) {
+		return batchResult{}, nil, failed(http.StatusNotFound, "Calendar %q not found", op.From)
+	} else if err != nil {
+		return batchResult{}, nil, err
+	}
+	if _, err := s.GetCalendar(ctx, op.To); errors.Is(err, store.ErrNotFound) {
+		return batchResult{}, nil, failed(http.StatusBadRequest, "Calendar %q not found", op.To)
+	} else if err != nil {
+		return batchResult{}, nil, err
+	}
+	events, err := s.ListEvents(ctx, []string{op.From}, store.Page{})
+	if err != nil {
+		return batchResult{}, nil, err
+	}
+	moved := make([]CalendarEvent, 0, len(events))
+	for _, event := range events {
+		event.CalendarID = op.To
+		saved, err := s.UpdateEvent(ctx, event)
+		if err != nil {
+			return batchResult{}, nil, err
+		}
+		moved = append(moved, saved)
+	}
+	return batchResult{Status: http.StatusOK, Events: moved}, func() {
+		for _, event := range moved {
+			eventSaved(event.ID, eventFields(event), true)
+		}
+	}, nil
+}
+
+// deleteMatching deletes the events that the search op.Query finds.
+func deleteMatching(ctx context.Context, s store.Store, op batchOperation) (batchResult, func(), error) {
+	if strings.TrimSpace(op.Query) == "" {
+		return batchResult{}, nil, failed(http.StatusBadRequest, "query is required")
+	}
+	if _, err := store.ParseQuery(op.Query); err != nil {
+		return batchResult{}, nil, failed(http.StatusBadRequest, "query: %v", err)
+	}
+	matches, err := searchAll(ctx, s, store.EventSearch{Query: op.Query, CalendarIDs: op.CalendarIDs, IncludeHidden: op.IncludeHidden})
+	if err != nil {
+		return batchResult{}, nil, err
+	}
+	deleted := make([]string, 0, len(matches))
+	for _, event := range matches {
+		if err := s.DeleteEvent(ctx, event.ID, 0); err != nil {
+			return batchResult{}, nil, err
+		}
+		deleted = append(deleted, event.ID)
+	}
+	return batchResult{Status: http.StatusOK, Deleted: deleted}, func() {
+		for _, id := range deleted {
+			eventDeleted(id)
+		}
+	}, nil
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestBatch(t *testing.T) {
+	r := newTestRouter()
+	r.HandleFunc("/batch", BatchHandler).Methods("POST")
+	var work, home Calendar
+	expect(t, serve(r, "POST", "/calendars", `{"name": "Work", "visible": true}`, ""), http.StatusCreated, &work)
+	expect(t, serve(r, "POST", "/calendars", `{"name": "Home", "visible": true}`, ""), http.StatusCreated, &home)
+	var standup CalendarEvent
+	expect(t, serve(r, "POST", "/events", `{"title": "Standup", "startTime": "9:00", "endTime": "9:15",
+		"day": 1, "calendarId": "`+work.ID+`"}`, ""), http.StatusCreated, &standup)
+
+	operations := `[
+		{"action": "create", "type": "event", "data": {"title": "Retro", "startTime": "14:00", "endTime": "15:00", "day": 5, "calendarId": "` + work.ID + `"}},
+		{"action": "update", "type": "calendar", "id": "` + work.ID + `", "version": 1, "data": {"name": "Office"}},
+		{"action": "delete", "type": "event", "id": "` + standup.ID + `", "version": 7}
+	]`
+
+	// All or nothing: the stale delete fails the batch, and the rest is
+	// rolled back.
+	var resp batchResponse
+	expect(t, serve(r, "POST", "/batch", `{"operations": `+operations+`}`, ""), http.StatusPreconditionFailed, &resp)
+	if len(resp.Results) != 3 || resp.Results[0].Status != http.StatusFailedDependency ||
+		resp.Results[1].Status != http.StatusFailedDependency || resp.Results[2].Status != http.StatusPreconditionFailed {
+		t.Fatalf("all-or-nothing results are %+v", resp.Results)
+	}
+	if resp.Results[0].Error.Code != "not_applied" {
+		t.Errorf("not applied reported as %+v", resp.Results[0].Error)
+	}
+	var cal Calendar
+	expect(t, serve(r, "GET", "/calendars/"+work.ID, "", ""), http.StatusOK, &cal)
+	var events []CalendarEvent
+	expect(t, serve(r, "GET", "/events?calendarIds[]="+work.ID, "", ""), http.StatusOK, &events)
+	if cal.Name != "Work" || len(events) != 1 {
+		t.Errorf("after the failed batch the calendar is %+v and its events %+v", cal, events)
+	}
+
+	// Per item: the rest goes through.
+	expect(t, serve(r, "POST", "/batch", `{"mode": "per-item", "operations": `+operations+`}`, ""), http.StatusOK, &resp)
+	if resp.Results[0].Status != http.StatusCreated || resp.Results[0].Event == nil ||
+		resp.Results[1].Status != http.StatusOK || resp.Results[1].Calendar.Name != "Office" ||
+		resp.Results[2].Status != http.StatusPreconditionFailed {
+		t.Fatalf("per-item results are %+v", resp.Results)
+	}
+	retro := resp.Results[0].Event
+	expect(t, serve(r, "GET", "/events?calendarIds[]="+work.ID, "", ""), http.StatusOK, &events)
+	if len(events) != 2 {
+		t.Errorf("after the per-item batch the events are %+v", events)
+	}
+
+	expect(t, serve(r, "POST", "/batch", `{"operations": [
+		{"action": "moveEvents", "from": "`+work.ID+`", "to": "`+home.ID+`"},
+		{"action": "deleteMatching", "query": "retro", "calendarIds": ["`+home.ID+`"]}
+	]}`, ""), http.StatusOK, &resp)
+	if len(resp.Results[0].Events) != 2 || len(resp.Results[1].Deleted) != 1 || resp.Results[1].Deleted[0] != retro.ID {
+		t.Errorf("move and delete results are %+v", resp.Results)
+	}
+	expect(t, serve(r, "GET", "/events?calendarIds[]="+home.ID, "", ""), http.StatusOK, &events)
+	if len(events) != 1 || events[0].ID != standup.ID {
+		t.Errorf("home calendar events are %+v", events)
+	}
+
+	expectProblems(t, serve(r, "POST", "/batch", `{"mode": "some", "operations": []}`, ""), "mode", "operations")
+	expect(t, serve(r, "POST", "/batch", `{"operations": [{"action": "merge"}]}`, ""), http.StatusBadRequest, &resp)
+	expect(t, serve(r, "POST", "/batch", `{"operations": [{"action": "update", "type": "event", "id": "`+standup.ID+`", "data": {}}]}`, ""),
+		http.StatusPreconditionRequired, &resp)
+}
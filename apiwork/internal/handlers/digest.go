@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/Aman221/4723/internal/digest"
+)
+
+// GetUserDigestHandler returns a user's daily agenda email settings
+func GetUserDigestHandler(w http.ResponseWriter, r *http.Request) {
+	sub, err := digest.Get(mux.Vars(r)["id"])
+	if errors.Is(err, digest.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sub)
+}
+
+// UpdateUserDigestHandler opts a user in or out of the daily agenda email
+// and sets when and where it is sent
+func UpdateUserDigestHandler(w http.ResponseWriter, r *http.Request) {
+	userID := mux.Vars(r)["id"]
+	current, err := digest.Get(userID)
+	if errors.Is(err, digest.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	// Start from the stored settings so omitted fields keep their values.
+	sub := current
+	if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	defer r.Body.Close()
+	sub.UserID = userID
+
+	if err := sub.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	saved, err := digest.Save(sub)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(saved)
+}
+
+// UnsubscribeDigestHandler handles the unsubscribe link in digest emails.
+// It accepts GET so the link works from any mail client.
+func UnsubscribeDigestHandler(w http.ResponseWriter, r *http.Request) {
+	err := digest.Unsubscribe(r.URL.Query().Get("token"))
+	if errors.Is(err, digest.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "Unknown unsubscribe link")
+		return
+	}
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("You will no longer receive the daily agenda email."))
+}
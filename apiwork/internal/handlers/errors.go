@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+// Errors are JSON, in an envelope:
+//
+//	{"error": {"code": "invalid_request", "message": "Invalid event: ...",
+//	  "details": [{"field": "startTime", "message": "must be HH:MM"}],
+//	  "requestId": "9f2c41d07a3be815"}}
+//
+// code is a stable name for the kind of error and message is for people.
+// details, on validation errors, says what is wrong with each field. The
+// request ID is also in the X-Request-ID response header and in the log
+// line of every server error, whose message never says more than that
+// something went wrong. A 412 is the exception: its body is the current
+// version of what was being changed.
+
+const requestIDHeader = "X-Request-ID"
+
+const maxRequestIDLength = 128
+
+// An errorResponse is the envelope errors are written in.
+type errorResponse struct {
+	Error apiError `json:"error"`
+}
+
+type apiError struct {
+	Code      string       `json:"code"`
+	Message   string       `json:"message"`
+	Details   []fieldError `json:"details,omitempty"`
+	RequestID string       `json:"requestId,omitempty"`
+}
+
+// A fieldError is what is wrong with one field of a request.
+type fieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+var errorCodes = map[int]string{
+	http.StatusBadRequest:            "invalid_request",
+	http.StatusUnauthorized:          "unauthorized",
+	http.StatusNotFound:              "not_found",
+	http.StatusMethodNotAllowed:      "method_not_allowed",
+	http.StatusConflict:              "conflict",
+	http.StatusGone:                  "gone",
+	http.StatusPreconditionFailed:    "version_mismatch",
+	http.StatusRequestEntityTooLarge: "too_large",
+	http.StatusUnsupportedMediaType:  "unsupported_media_type",
+	http.StatusUnprocessableEntity:   "unprocessable",
+	http.StatusFailedDependency:      "not_applied",
+	http.StatusPreconditionRequired:  "version_required",
+	http.StatusInternalServerError:   "internal_error",
+	http.StatusNotImplemented:        "not_implemented",
+}
+
+// newError is the error for status, with the status's code.
+func newError(status int, message string, details ...fieldError) apiError {
+	code, ok := errorCodes[status]
+	if !ok {
+		code = "error"
+	}
+	return apiError{Code: code, Message: message, Details: details}
+}
+
+// writeError writes an error response with the status's code.
+func writeError(w http.ResponseWriter, status int, message string, details ...fieldError) {
+	writeAPIError(w, status, newError(status, message, details...))
+}
+
+func writeAPIError(w http.ResponseWriter, status int, e apiError) {
+	e.RequestID = w.Header().Get(requestIDHeader)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorResponse{e})
+}
+
+// serverError answers a request that failed with err, an error from the
+// database or some other error the client can't fix by changing the
+// request.
+func serverError(w http.ResponseWriter, err error) {
+	status, e := describeError(w, err)
+	writeAPIError(w, status, e)
+}
+
+// describeError is the status and error a client gets for err. Database
+// errors the request caused, such as a duplicate or a value the column
+// won't take, are a 4xx. Anything else is logged and is a 500 that doesn't
+// say what went wrong.
+func describeError(w http.ResponseWriter, err error) (int, apiError) {
+	status := http.StatusConflict
+	var e apiError
+	switch constraint, column := violation(err); constraint {
+	case "unique":
+		e = newError(status, "Something with the same values already exists")
+		e.Code = "already_exists"
+	case "reference":
+		e = newError(status, "It refers to something that doesn't exist, or something else still refers to it")
+		e.Code = "invalid_reference"
+	case "value":
+		status = http.StatusBadRequest
+		e = newError(status, "A value is missing or not valid")
+		e.Code = "invalid_value"
+		if column != "" {
+			e.Details = []fieldError{{column, column + " is not valid"}}
+		}
+	default:
+		log.Printf("Request %s failed: %v", w.Header().Get(requestIDHeader), err)
+		return http.StatusInternalServerError, newError(http.StatusInternalServerError, "Internal server error")
+	}
+	return status, e
+}
+
+// violation says which kind of constraint a database error is about:
+// "unique", "reference" (a foreign key), "value" (invalid input, a missing
+// value or a failed check) or "" for none of them, and the column, if the
+// database says.
+func violation(err error) (string, string) {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch {
+		case pqErr.Code == "23505": // unique_violation
+			return "unique", ""
+		case pqErr.Code == "23503": // foreign_key_violation
+			return "reference", ""
+		case pqErr.Code == "23502", pqErr.Code == "23514", pqErr.Code.Class() == "22": // not null, check, data exception
+			return "value", pqErr.Column
+		}
+	}
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		switch sqliteErr.ExtendedCode {
+		case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey:
+			return "unique", ""
+		case sqlite3.ErrConstraintForeignKey:
+			return "reference", ""
+		case sqlite3.ErrConstraintNotNull, sqlite3.ErrConstraintCheck:
+			return "value", ""
+		}
+	}
+	return "", ""
+}
+
+// RequestID is middleware that gives each request an ID, the client's
+// X-Request-ID if it sent a usable one, and returns it in X-Request-ID.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			var b [8]byte
+			rand.Read(b[:])
+			id = hex.EncodeToString(b[:])
+		}
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// NotFoundHandler answers requests for paths there is no route for.
+func NotFoundHandler(w http.ResponseWriter, r *http.Request) {
+	writeError(w, http.StatusNotFound, "No such endpoint: "+r.URL.Path)
+}
+
+// MethodNotAllowedHandler answers requests with a method their path
+// doesn't take.
+func MethodNotAllowedHandler(w http.ResponseWriter, r *http.Request) {
+	writeError(w, http.StatusMethodNotAllowed, r.Method+" is not allowed on "+r.URL.Path)
+}
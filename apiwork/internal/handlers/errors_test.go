@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestErrorEnvelope(t *testing.T) {
+	r := newTestRouter()
+	r.NotFoundHandler = http.HandlerFunc(NotFoundHandler)
+	r.MethodNotAllowedHandler = http.HandlerFunc(MethodNotAllowedHandler)
+	h := RequestID(r)
+
+	w := serve(h, "GET", "/calendars/999", "", "")
+	var resp errorResponse
+	expect(t, w, http.StatusNotFound, &resp)
+	id := w.Header().Get("X-Request-ID")
+	if len(id) != 16 {
+		t.Errorf("generated request ID is %q", id)
+	}
+	if resp.Error.Code != "not_found" || resp.Error.Message == "" || resp.Error.RequestID != id {
+		t.Errorf("error is %+v, want not_found with request ID %s", resp.Error, id)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type is %s", ct)
+	}
+
+	w = serve(h, "GET", "/nowhere", "", "")
+	resp = errorResponse{}
+	expect(t, w, http.StatusNotFound, &resp)
+	if resp.Error.Code != "not_found" || !strings.Contains(resp.Error.Message, "/nowhere") {
+		t.Errorf("unrouted path gives %+v", resp.Error)
+	}
+
+	w = serve(h, "PATCH", "/calendars", "", "")
+	resp = errorResponse{}
+	expect(t, w, http.StatusMethodNotAllowed, &resp)
+	if resp.Error.Code != "method_not_allowed" || !strings.Contains(resp.Error.Message, "PATCH") {
+		t.Errorf("wrong method gives %+v", resp.Error)
+	}
+
+	w = serve(h, "POST", "/calendars", `{"name": ""}`, "")
+	resp = errorResponse{}
+	expect(t, w, http.StatusBadRequest, &resp)
+	if resp.Error.Code != "invalid_request" || len(resp.Error.Details) == 0 || resp.Error.Details[0].Field != "name" {
+		t.Errorf("invalid calendar gives %+v", resp.Error)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	h := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for _, tt := range []struct {
+		sent string
+		kept bool
+	}{
+		{"abc-123", true},
+		{strings.Repeat("a", 128), true},
+		{strings.Repeat("a", 129), false},
+		{"has space", false},
+		{"naïve", false},
+		{"", false},
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		if tt.sent != "" {
+			req.Header.Set("X-Request-ID", tt.sent)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		got := w.Header().Get("X-Request-ID")
+		if kept := got == tt.sent; kept != tt.kept || got == "" {
+			t.Errorf("sent %q, got back %q", tt.sent, got)
+		}
+	}
+}
+
+func TestDescribeError(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:?_foreign_keys=on")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	_, err = db.Exec(`
+		CREATE TABLE parents (id INTEGER PRIMARY KEY, name TEXT NOT NULL UNIQUE);
+		CREATE TABLE children (id INTEGER PRIMARY KEY, parent INTEGER NOT NULL REFERENCES parents (id));
+		INSERT INTO parents (id, name) VALUES (1, 'a');
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fail := func(query string) error {
+		_, err := db.Exec(query)
+		if err == nil {
+			t.Fatalf("%s succeeded", query)
+		}
+		return err
+	}
+
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{"unique", fail("INSERT INTO parents (name) VALUES ('a')"), http.StatusConflict, "already_exists"},
+		{"foreign key", fail("INSERT INTO children (parent) VALUES (9)"), http.StatusConflict, "invalid_reference"},
+		{"not null", fail("INSERT INTO parents (name) VALUES (NULL)"), http.StatusBadRequest, "invalid_value"},
+		{"anything else", errors.New("connection refused to db.internal:5432"), http.StatusInternalServerError, "internal_error"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		w.Header().Set("X-Request-ID", "req-1")
+		serverError(w, tt.err)
+		var resp errorResponse
+		expect(t, w, tt.status, &resp)
+		if resp.Error.Code != tt.code || resp.Error.RequestID != "req-1" {
+			t.Errorf("%s: error is %+v, want %s", tt.name, resp.Error, tt.code)
+		}
+		// The database's own words never reach the client.
+		if body := w.Body.String(); strings.Contains(body, "constraint") || strings.Contains(body, "db.internal") {
+			t.Errorf("%s: body says too much: %s", tt.name, body)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Aman221/4723/internal/store"
+)
+
+// Calendars and events are versioned. Reads return the version as a strong
+// ETag, e.g. "3", and updates and deletes must say which version they are
+// based on, with If-Match or a version field (a version query parameter for
+// DELETE). A write based on an older version fails with 412 Precondition
+// Failed and the current representation, so the client can merge and retry.
+
+var (
+	errMissingVersion = errors.New("If-Match header or version is required")
+	errBadIfMatch     = errors.New("If-Match must be a single version ETag or *")
+	errVersionClash   = errors.New("If-Match and version name different versions")
+)
+
+// etag formats version as an ETag.
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// parseIfMatch reads the If-Match header. It returns 0 for *, which matches
+// any version.
+func parseIfMatch(header string) (int, error) {
+	header = strings.TrimSpace(header)
+	if header == "*" {
+		return 0, nil
+	}
+	header = strings.TrimPrefix(header, "W/")
+	if len(header) < 3 || header[0] != '"' || header[len(header)-1] != '"' {
+		return 0, errBadIfMatch
+	}
+	version, err := strconv.Atoi(header[1 : len(header)-1])
+	if err != nil || version < 1 {
+		return 0, errBadIfMatch
+	}
+	return version, nil
+}
+
+// expectedVersion returns the version a write is based on, from If-Match or
+// from the version the client sent in the body or query. It writes an error
+// response and returns false if there is none or they disagree.
+func expectedVersion(w http.ResponseWriter, r *http.Request, sent int) (int, bool) {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		if sent < 1 {
+			writeError(w, http.StatusPreconditionRequired, errMissingVersion.Error())
+			return 0, false
+		}
+		return sent, true
+	}
+	version, err := parseIfMatch(header)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return 0, false
+	}
+	if sent != 0 && version != 0 && sent != version {
+		writeError(w, http.StatusBadRequest, errVersionClash.Error())
+		return 0, false
+	}
+	if version == 0 {
+		version = sent
+	}
+	return version, true
+}
+
+// queryVersion reads the version query parameter of a DELETE.
+func queryVersion(r *http.Request) int {
+	version, _ := strconv.Atoi(r.URL.Query().Get("version"))
+	return version
+}
+
+// notModified answers a conditional GET whose If-None-Match names version.
+func notModified(w http.ResponseWriter, r *http.Request, version int) bool {
+	for _, tag := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag(version) {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// writeVersioned writes a calendar or event with its ETag.
+func writeVersioned(w http.ResponseWriter, status, version int, v interface{}) {
+	w.Header().Set("ETag", etag(version))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// calendarChanged answers a write that failed with ErrVersionMismatch with
+// the calendar as it is now.
+func calendarChanged(w http.ResponseWriter, r *http.Request, id string) {
+	current, err := Store.GetCalendar(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "Calendar not found")
+		return
+	}
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	writeVersioned(w, http.StatusPreconditionFailed, current.Version, current)
+}
+
+// eventChanged is calendarChanged for events.
+func eventChanged(w http.ResponseWriter, r *http.Request, id string) {
+	current, err := Store.GetEvent(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "Event not found")
+		return
+	}
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	writeVersioned(w, http.StatusPreconditionFailed, current.Version, current)
+}
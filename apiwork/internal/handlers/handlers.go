@@ -1,141 +1,117 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"math"
 	"net/http"
+	"net/url"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
-	"github.com/lib/pq"
-
 	"github.com/Aman221/4723/internal/database" // Import your database package
 	"github.com/gorilla/mux"                    // Import gorilla mux for route variables
+
+	"github.com/Aman221/4723/internal/models"
+	"github.com/Aman221/4723/internal/reminders"
+	"github.com/Aman221/4723/internal/resources"
+	"github.com/Aman221/4723/internal/store"
 )
 
-// Define the Go structs based on your TypeScript interfaces
-type CalendarEvent struct {
-	ID          string   `json:"id"`
-	Title       string   `json:"title"`
-	StartTime   string   `json:"startTime"`
-	EndTime     string   `json:"endTime"`
-	Color       string   `json:"color"`
-	Day         int      `json:"day"`
-	Description string   `json:"description"`
-	Location    string   `json:"location"`
-	Attendees   []string `json:"attendees"`
-	Organizer   string   `json:"organizer"`
-	CalendarID  string   `json:"calendarId"`
-	Date        *string  `json:"date,omitempty"` // Use pointer to handle optional field
-}
-
-type NCalendarEvent struct {
-	Title       string   `json:"title"`
-	StartTime   string   `json:"startTime"`
-	EndTime     string   `json:"endTime"`
-	Color       string   `json:"color"`
-	Day         int      `json:"day"`
-	Description string   `json:"description"`
-	Location    string   `json:"location"`
-	Attendees   []string `json:"attendees"`
-	Organizer   string   `json:"organizer"`
-	CalendarID  string   `json:"calendarId"`
-	Date        *string  `json:"date,omitempty"` // Use pointer to handle optional field
-}
-
-type Calendar struct {
-	ID      string `json:"id"`
-	Name    string `json:"name"`
-	Color   string `json:"color"`
-	Visible bool   `json:"visible"`
-}
-
-type NCalendar struct {
-	Name    string `json:"name"`
-	Color   string `json:"color"`
-	Visible bool   `json:"visible"`
-}
+// The API types live in models so the store can share them.
+type (
+	CalendarEvent  = models.CalendarEvent
+	NCalendarEvent = models.NCalendarEvent
+	Calendar       = models.Calendar
+	NCalendar      = models.NCalendar
+	SavedSearch    = models.SavedSearch
+	NSavedSearch   = models.NSavedSearch
+)
 
-// GetUserCalendarHandler handles requests to fetch a user's calendar (example)
-func GetUserCalendarHandler(w http.ResponseWriter, r *http.Request) {
+// Store holds the users, calendars and events served by these handlers.
+var Store store.Store
+
+// userID reads and checks the {id} route variable of the user endpoints.
+func userID(w http.ResponseWriter, r *http.Request) (string, bool) {
 	vars := mux.Vars(r)
 	userIDStr, ok := vars["id"]
 	if !ok {
-		http.Error(w, "User ID not provided", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, "User ID not provided")
+		return "", false
+	}
+
+	if _, err := strconv.Atoi(userIDStr); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid user ID")
+		return "", false
+	}
+	return userIDStr, true
+}
+
+// GetUserHandler handles requests to fetch a user
+func GetUserHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := userID(w, r)
+	if !ok {
 		return
 	}
 
-	userID, err := strconv.Atoi(userIDStr)
+	user, err := Store.GetUser(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "User not found")
+		return
+	}
 	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		serverError(w, err)
 		return
 	}
 
-	// Example database query (adjust based on your schema)
-	rows, err := database.DB.Query("SELECT id, name, color, visible FROM calendars WHERE user_id = $1", userID)
-	if err != nil {
-		http.Error(w, "Database error", http.StatusInternalServerError)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+// GetUserCalendarHandler handles requests to fetch a user's calendar (example)
+func GetUserCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := userID(w, r)
+	if !ok {
 		return
 	}
-	defer rows.Close()
 
-	var calendars []Calendar
-	for rows.Next() {
-		var cal Calendar
-		if err := rows.Scan(&cal.ID, &cal.Name, &cal.Color, &cal.Visible); err != nil {
-			http.Error(w, "Error scanning calendar data", http.StatusInternalServerError)
-			return
-		}
-		calendars = append(calendars, cal)
+	calendars, err := Store.ListUserCalendars(r.Context(), id)
+	if err == nil {
+		calendars, err = withSmartCalendars(r.Context(), calendars, id)
+	}
+	if err != nil {
+		serverError(w, err)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(calendars)
 }
 
-// GetUserEventsHandler handles requests to fetch a user's events (example)
+// GetUserEventsHandler handles requests to fetch a user's events (example),
+// a page at a time
 func GetUserEventsHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	userIDStr, ok := vars["id"]
+	id, ok := userID(w, r)
 	if !ok {
-		http.Error(w, "User ID not provided", http.StatusBadRequest)
 		return
 	}
-
-	userID, err := strconv.Atoi(userIDStr)
-	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+	page, ok := readPage(w, r)
+	if !ok {
 		return
 	}
 
-	// Example database query (adjust based on your schema)
-	rows, err := database.DB.Query("SELECT id, title, start_time, end_time, color, day, description, location, attendees, organizer, calendar_id, date FROM calendar_events WHERE user_id = $1", userID)
+	events, err := Store.ListUserEvents(r.Context(), id, oneMore(page))
 	if err != nil {
-		http.Error(w, "Database error", http.StatusInternalServerError)
+		serverError(w, err)
 		return
 	}
-	defer rows.Close()
-
-	var events []CalendarEvent
-	for rows.Next() {
-		var event CalendarEvent
-		var attendeesStr string // Assuming attendees are stored as a string in the DB
-		var date sql.NullString
-
-		if err := rows.Scan(&event.ID, &event.Title, &event.StartTime, &event.EndTime, &event.Color, &event.Day, &event.Description, &event.Location, &attendeesStr, &event.Organizer, &event.CalendarID, &date); err != nil {
-			http.Error(w, "Error scanning event data", http.StatusInternalServerError)
-			return
-		}
-		if date.Valid {
-			event.Date = &date.String
-		}
-		if err := json.Unmarshal([]byte(attendeesStr), &event.Attendees); err != nil {
-			fmt.Println("Error unmarshalling attendees:", err) // Log the error, might need more robust handling
-			event.Attendees = []string{}                       // Initialize to empty slice in case of error
-		}
-		events = append(events, event)
-	}
+	events, _ = paginate(w, r, page, events, eventID)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(events)
@@ -146,13 +122,13 @@ func GetUserPaymentHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	userIDStr, ok := vars["id"]
 	if !ok {
-		http.Error(w, "User ID not provided", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, "User ID not provided")
 		return
 	}
 
 	userID, err := strconv.Atoi(userIDStr)
 	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, "Invalid user ID")
 		return
 	}
 
@@ -169,13 +145,13 @@ func EndpointAPIHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	userIDStr, ok := vars["id"]
 	if !ok {
-		http.Error(w, "User ID not provided", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, "User ID not provided")
 		return
 	}
 
 	userID, err := strconv.Atoi(userIDStr)
 	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, "Invalid user ID")
 		return
 	}
 
@@ -187,49 +163,80 @@ func EndpointAPIHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(responseData))
 }
 
-// GetCalendarsHandler handles requests to get all calendars
+// GetCalendarsHandler handles requests to get all calendars a page at a
+// time, followed by the smart calendars of pinned saved searches
 func GetCalendarsHandler(w http.ResponseWriter, r *http.Request) {
-	rows, err := database.DB.Query("SELECT id, name, color, visible FROM calendars")
-	if err != nil {
-		http.Error(w, "Database error", http.StatusInternalServerError)
+	page, c, ok := readPageAt(w, r, "smart")
+	if !ok {
+		return
+	}
+	if c.kind == "smart" {
+		calendars, err := smartCalendarsPage(w, r, page, []Calendar{}, c)
+		if err != nil {
+			serverError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(calendars)
 		return
 	}
-	defer rows.Close()
 
-	var calendars []Calendar
-	for rows.Next() {
-		var cal Calendar
-		if err := rows.Scan(&cal.ID, &cal.Name, &cal.Color, &cal.Visible); err != nil {
-			http.Error(w, "Error scanning calendar data", http.StatusInternalServerError)
+	calendars, err := Store.ListCalendars(r.Context(), oneMore(page))
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	calendars, last := paginate(w, r, page, calendars, calendarID)
+	if last {
+		if calendars, err = smartCalendarsPage(w, r, page, calendars, cursor{}); err != nil {
+			serverError(w, err)
 			return
 		}
-		calendars = append(calendars, cal)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(calendars)
 }
 
+// GetCalendarHandler handles requests to get a single calendar
+func GetCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	cal, err := Store.GetCalendar(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "Calendar not found")
+		return
+	}
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	if notModified(w, r, cal.Version) {
+		return
+	}
+	writeVersioned(w, http.StatusOK, cal.Version, cal)
+}
+
 // AddCalendarHandler handles requests to add a new calendar
 func AddCalendarHandler(w http.ResponseWriter, r *http.Request) {
 	var newCalendar NCalendar
 	err := json.NewDecoder(r.Body).Decode(&newCalendar)
 	if err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 	defer r.Body.Close()
+	if invalid(w, "calendar", validateNewCalendar(newCalendar)) {
+		return
+	}
 
-	// Example database insertion (adjust based on your schema)
-	_, err = database.DB.Exec("INSERT INTO calendars (name, color, visible) VALUES ($1, $2, $3)",
-		newCalendar.Name, newCalendar.Color, newCalendar.Visible)
+	created, err := Store.CreateCalendar(r.Context(), newCalendar)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		serverError(w, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(newCalendar)
+	w.Header().Set("Location", "/calendars/"+created.ID)
+	writeVersioned(w, http.StatusCreated, created.Version, created)
 }
 
 // UpdateCalendarHandler handles requests to update a calendar
@@ -237,273 +244,615 @@ func UpdateCalendarHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, ok := vars["id"]
 	if !ok {
-		http.Error(w, "Calendar ID not provided", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, "Calendar ID not provided")
 		return
 	}
 
 	var updatedCalendar Calendar
-	err := json.NewDecoder(r.Body).Decode(&updatedCalendar)
-	if err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	defer r.Body.Close()
+	if !readFields(w, r, &updatedCalendar, "name", "color", "visible") {
 		return
 	}
+
+	version, ok := expectedVersion(w, r, updatedCalendar.Version)
+	if !ok {
+		return
+	}
+
+	updatedCalendar.ID = id
+	updatedCalendar.Version = version
+	saveCalendar(w, r, updatedCalendar)
+}
+
+// PatchCalendarHandler handles requests to change some fields of a calendar
+func PatchCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
 	defer r.Body.Close()
+	patch, sent, ok := readPatch(w, r)
+	if !ok {
+		return
+	}
+	version, ok := expectedVersion(w, r, sent)
+	if !ok {
+		return
+	}
+
+	current, err := Store.GetCalendar(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "Calendar not found")
+		return
+	}
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	if version == 0 {
+		// If-Match: * still mustn't overwrite a change made since the read
+		// above.
+		version = current.Version
+	}
+
+	var patched Calendar
+	if err := applyPatch(current, patch, &patched); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	patched.ID = id
+	patched.Version = version
+	saveCalendar(w, r, patched)
+}
 
-	// Example database update (adjust based on your schema)
-	_, err = database.DB.Exec("UPDATE calendars SET name = $1, color = $2, visible = $3 WHERE id = $4",
-		updatedCalendar.Name, updatedCalendar.Color, updatedCalendar.Visible, id)
+// saveCalendar validates and stores a replaced or patched calendar and
+// writes the response.
+func saveCalendar(w http.ResponseWriter, r *http.Request, cal Calendar) {
+	if invalid(w, "calendar", validateCalendar(cal)) {
+		return
+	}
+	saved, err := Store.UpdateCalendar(r.Context(), cal)
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "Calendar not found")
+		return
+	}
+	if errors.Is(err, store.ErrVersionMismatch) {
+		calendarChanged(w, r, cal.ID)
+		return
+	}
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		serverError(w, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(updatedCalendar)
+	writeVersioned(w, http.StatusOK, saved.Version, saved)
 }
 
+// resourceCalendarMessage answers a delete of a resource's calendar.
+const resourceCalendarMessage = "The calendar belongs to a resource; delete the resource instead"
+
 // DeleteCalendarHandler handles requests to delete a calendar
 func DeleteCalendarHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, ok := vars["id"]
 	if !ok {
-		http.Error(w, "Calendar ID not provided", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, "Calendar ID not provided")
 		return
 	}
 
-	// Example database deletion (adjust based on your schema)
-	_, err := database.DB.Exec("DELETE FROM calendars WHERE id = $1", id)
+	version, ok := expectedVersion(w, r, queryVersion(r))
+	if !ok {
+		return
+	}
+
+	// The events go to the trash with the calendar, so they lose their
+	// bookings, reminders and invitations as if deleted one by one.
+	events, err := calendarEvents(r.Context(), Store, id)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	err = Store.DeleteCalendar(r.Context(), id, version)
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "Calendar not found")
+		return
+	}
+	if errors.Is(err, store.ErrResourceCalendar) {
+		writeError(w, http.StatusConflict, resourceCalendarMessage)
+		return
+	}
+	if errors.Is(err, store.ErrVersionMismatch) {
+		calendarChanged(w, r, id)
+		return
+	}
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		serverError(w, err)
 		return
 	}
+	eventsDeleted(events)
 
 	w.WriteHeader(http.StatusNoContent) // 204 No Content for successful deletion
 }
 
+// visibilityUpdate is the body of PUT /calendars/{id}/visibility.
+type visibilityUpdate struct {
+	Visible bool `json:"visible"`
+	Version int  `json:"version"`
+}
+
 // UpdateCalendarVisibilityHandler handles requests to update calendar visibility
 func UpdateCalendarVisibilityHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, ok := vars["id"]
 	if !ok {
-		http.Error(w, "Calendar ID not provided", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, "Calendar ID not provided")
 		return
 	}
 
-	var visibilityData struct {
-		Visible bool `json:"visible"`
-	}
+	var visibilityData visibilityUpdate
 	err := json.NewDecoder(r.Body).Decode(&visibilityData)
 	if err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 	defer r.Body.Close()
 
-	// Example database update (adjust based on your schema)
-	_, err = database.DB.Exec("UPDATE calendars SET visible = $1 WHERE id = $2", visibilityData.Visible, id)
+	// Toggling visibility doesn't conflict with other edits, so the version
+	// is checked only if the client sends one.
+	version := 0
+	if r.Header.Get("If-Match") != "" || visibilityData.Version != 0 {
+		if version, ok = expectedVersion(w, r, visibilityData.Version); !ok {
+			return
+		}
+	}
+
+	saved, err := Store.SetCalendarVisible(r.Context(), id, visibilityData.Visible, version)
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "Calendar not found")
+		return
+	}
+	if errors.Is(err, store.ErrVersionMismatch) {
+		calendarChanged(w, r, id)
+		return
+	}
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		serverError(w, err)
 		return
 	}
 
-	// Optionally return the updated calendar or a success message
-	w.WriteHeader(http.StatusOK)
-	// You might want to fetch and return the updated calendar here
+	writeVersioned(w, http.StatusOK, saved.Version, saved)
 }
 
-// GetEventsHandler handles requests to get events filtered by calendar IDs
+// GetEventsHandler handles requests to get events filtered by calendar IDs,
+// which may include smart calendars, a page at a time
 func GetEventsHandler(w http.ResponseWriter, r *http.Request) {
 	calendarIDs := r.URL.Query()["calendarIds[]"] // Get multiple calendarIds
 
 	if len(calendarIDs) == 0 {
-		http.Error(w, "calendarIds parameter is required", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, "calendarIds parameter is required")
 		return
 	}
-
-	// Construct the SQL query dynamically based on the number of calendar IDs
-	query := "SELECT id, title, start_time, end_time, color, day, description, location, attendees, organizer, calendar_id, date FROM calendar_events WHERE calendar_id IN ("
-	for i := range calendarIDs {
-		if i > 0 {
-			query += ","
+	var realIDs, searchIDs []string
+	for _, id := range calendarIDs {
+		if searchID, ok := strings.CutPrefix(id, smartCalendarPrefix); ok {
+			searchIDs = append(searchIDs, searchID)
+		} else {
+			realIDs = append(realIDs, id)
 		}
-		query += "$" + strconv.Itoa(i+1)
 	}
-	query += ")"
-
-	args := make([]interface{}, len(calendarIDs))
-	for i, id := range calendarIDs {
-		args[i] = id
+	page, ok := readPage(w, r)
+	if !ok {
+		return
 	}
 
-	rows, err := database.DB.Query(query, args...)
+	events, err := Store.ListEvents(r.Context(), realIDs, oneMore(page))
+	if err == nil && len(searchIDs) > 0 {
+		events, err = withSmartEvents(r.Context(), events, searchIDs, page)
+	}
 	if err != nil {
-		http.Error(w, "Database error", http.StatusInternalServerError)
+		serverError(w, err)
 		return
 	}
-	defer rows.Close()
-
-	var events []CalendarEvent
-	for rows.Next() {
-		var event CalendarEvent
-		var attendeesStr string
-		var date sql.NullString
-
-		if err := rows.Scan(&event.ID, &event.Title, &event.StartTime, &event.EndTime, &event.Color, &event.Day, &event.Description, &event.Location, &attendeesStr, &event.Organizer, &event.CalendarID, &date); err != nil {
-			http.Error(w, "Error scanning event data", http.StatusInternalServerError)
-			return
-		}
-		if date.Valid {
-			event.Date = &date.String
-		}
-		if err := json.Unmarshal([]byte(attendeesStr), &event.Attendees); err != nil {
-			fmt.Println("Error unmarshalling attendees:", err)
-			event.Attendees = []string{}
-		}
-		events = append(events, event)
-	}
+	events, _ = paginate(w, r, page, events, eventID)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(events)
 }
 
-// SearchEventsHandler handles requests to search events
+// SearchEventsHandler handles requests to search events. q is a search
+// query (see store.ParseQuery), e.g. attendee:alice location:"room 4"
+// after:2026-01-01 -cancelled, matched against the title, location,
+// organizer, attendees and description; calendarIds[], from and to
+// (YYYY-MM-DD), attendee and organizer filter the results, which come in
+// pages of limit starting at offset or cursor.
 func SearchEventsHandler(w http.ResponseWriter, r *http.Request) {
-	query := r.URL.Query().Get("query")
-	includeHiddenStr := r.URL.Query().Get("includeHidden")
-	includeHidden := false
-	if includeHiddenStr == "true" {
-		includeHidden = true
+	params := r.URL.Query()
+	search := store.EventSearch{
+		Query:         strings.TrimSpace(params.Get("q")),
+		IncludeHidden: params.Get("includeHidden") == "true",
+		CalendarIDs:   params["calendarIds[]"],
+		From:          params.Get("from"),
+		To:            params.Get("to"),
+		Attendee:      strings.TrimSpace(params.Get("attendee")),
+		Organizer:     strings.TrimSpace(params.Get("organizer")),
+	}
+	if search.Query == "" {
+		search.Query = strings.TrimSpace(params.Get("query")) // the old name
+	}
+
+	var problems []fieldError
+	if _, err := store.ParseQuery(search.Query); err != nil {
+		problems = append(problems, fieldError{"q", "q: " + err.Error()})
+	}
+	for name, date := range map[string]string{"from": search.From, "to": search.To} {
+		if _, err := time.Parse("2006-01-02", date); date != "" && err != nil {
+			problems = append(problems, fieldError{name, name + " must be YYYY-MM-DD"})
+		}
+	}
+	var err error
+	if search.Limit, err = intParam(params, "limit", 1, store.MaxSearchLimit); err != nil {
+		problems = append(problems, fieldError{"limit", err.Error()})
+	}
+	if search.Offset, err = intParam(params, "offset", 0, math.MaxInt32); err != nil {
+		problems = append(problems, fieldError{"offset", err.Error()})
+	}
+	if c, err := readCursor(r, "offset"); err != nil {
+		problems = append(problems, fieldError{"cursor", err.Error()})
+	} else if c.kind != "" {
+		search.Offset = c.value
+	}
+	if search.Query == "" && len(search.CalendarIDs) == 0 && search.From == "" && search.To == "" &&
+		search.Attendee == "" && search.Organizer == "" {
+		problems = append(problems, fieldError{"q", "q or a filter is required"})
+	}
+	sort.Slice(problems, func(i, j int) bool { return problems[i].Message < problems[j].Message })
+	if invalid(w, "search", problems) {
+		return
 	}
 
-	if query == "" {
-		http.Error(w, "query parameter is required", http.StatusBadRequest)
+	results, err := Store.SearchEvents(r.Context(), search)
+	if err != nil {
+		serverError(w, err)
 		return
 	}
+	setSearchLinks(w, r, results)
 
-	// Example database query (adjust based on your schema and search logic)
-	searchQuery := "%" + query + "%"
-	var rows *sql.Rows
-	var err error
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
 
-	if includeHidden {
-		rows, err = database.DB.Query("SELECT id, title, start_time, end_time, color, day, description, location, attendees, organizer, calendar_id, date FROM calendar_events WHERE title LIKE $1 OR description LIKE $1 OR location LIKE $1", searchQuery)
-	} else {
-		rows, err = database.DB.Query(`
-			SELECT e.id, e.title, e.start_time, e.end_time, e.color, e.day, e.description, e.location, e.attendees, e.organizer, e.calendar_id, e.date
-			FROM calendar_events e
-			JOIN calendars c ON e.calendar_id = c.id
-			WHERE (e.title LIKE $1 OR e.description LIKE $1 OR e.location LIKE $1) AND c.visible = TRUE
-		`, searchQuery)
+// intParam reads an optional integer query parameter from lo to hi,
+// returning 0 if it is absent.
+func intParam(params url.Values, name string, lo, hi int) (int, error) {
+	raw := params.Get(name)
+	if raw == "" {
+		return 0, nil
 	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < lo || n > hi {
+		return 0, fmt.Errorf("%s must be an integer from %d to %d", name, lo, hi)
+	}
+	return n, nil
+}
 
+// weekday fills in the ISO weekday (1 = Monday ... 7 = Sunday) of a dated
+// event that didn't specify one.
+func weekday(date *string, day int) int {
+	if date == nil || day != 0 {
+		return day
+	}
+	parsed, err := time.Parse("2006-01-02", *date)
 	if err != nil {
-		http.Error(w, "Database error", http.StatusInternalServerError)
-		return
+		return day
 	}
-	defer rows.Close()
+	if parsed.Weekday() == time.Sunday {
+		return 7
+	}
+	return int(parsed.Weekday())
+}
 
-	var events []CalendarEvent
-	for rows.Next() {
-		var event CalendarEvent
-		var attendeesStr string
-		var date sql.NullString
+// postgresFeatures reports whether the features that use Postgres-specific
+// SQL (resources, reminders, invitations) are available.
+func postgresFeatures() bool {
+	return database.DB != nil && database.Dialect == database.Postgres
+}
 
-		if err := rows.Scan(&event.ID, &event.Title, &event.StartTime, &event.EndTime, &event.Color, &event.Day, &event.Description, &event.Location, &attendeesStr, &event.Organizer, &event.CalendarID, &date); err != nil {
-			http.Error(w, "Error scanning event data", http.StatusInternalServerError)
+// PostgresOnly answers requests to an endpoint of those features with a
+// 501 when they aren't available.
+func PostgresOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !postgresFeatures() {
+			writeError(w, http.StatusNotImplemented, "This endpoint needs the API to run on Postgres")
 			return
 		}
-		if date.Valid {
-			event.Date = &date.String
+		next(w, r)
+	}
+}
+
+// eventSaved does the follow-up work for a created or updated event:
+// rescheduling reminders, booking resources and emailing invitations.
+// Those features are written for Postgres, so this is skipped on SQLite
+// and when the handlers run on the in-memory store.
+func eventSaved(eventID string, event NCalendarEvent, updated bool) {
+	if !postgresFeatures() {
+		return
+	}
+	if updated {
+		if err := reminders.EventChanged(eventID); err != nil {
+			log.Printf("Error rescheduling reminders for event %s: %v", eventID, err)
 		}
-		if err := json.Unmarshal([]byte(attendeesStr), &event.Attendees); err != nil {
-			fmt.Println("Error unmarshalling attendees:", err)
-			event.Attendees = []string{}
+	}
+	syncResourceBookings(eventID, event)
+	sendInvitations(eventID, updated)
+}
+
+// releaseEvent releases, in tx, what eventSaved set up for an event that is
+// being deleted: its resource bookings and pending reminders.
+func releaseEvent(tx *sql.Tx, eventID string) error {
+	if err := resources.ReleaseBookings(tx, eventID); err != nil {
+		return err
+	}
+	return reminders.EventDeleted(tx, eventID)
+}
+
+// deleteEvent moves an event to the trash, releasing its bookings and
+// reminders in the same transaction, and then sends its cancellations.
+func deleteEvent(ctx context.Context, eventID string, version int) error {
+	if !postgresFeatures() {
+		return Store.DeleteEvent(ctx, eventID, version)
+	}
+	sendCancellations := prepareCancellation(eventID)
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := store.InTx(tx, database.Dialect).DeleteEvent(ctx, eventID, version); err != nil {
+		return err
+	}
+	if err := releaseEvent(tx, eventID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	sendCancellations()
+	return nil
+}
+
+// eventDeleted does what deleteEvent does for an event deleted already, in
+// a batch or with its calendar.
+func eventDeleted(eventID string) {
+	if !postgresFeatures() {
+		return
+	}
+	sendCancellations := prepareCancellation(eventID)
+	tx, err := database.DB.Begin()
+	if err == nil {
+		defer tx.Rollback()
+		if err = releaseEvent(tx, eventID); err == nil {
+			err = tx.Commit()
 		}
-		events = append(events, event)
 	}
+	if err != nil {
+		log.Printf("Error releasing bookings and reminders of event %s: %v", eventID, err)
+		return
+	}
+	sendCancellations()
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(events)
+// calendarEvents lists the events of a calendar, for the follow-up work on
+// them when the calendar is deleted or restored. It lists none when
+// eventSaved and eventDeleted have nothing to do.
+func calendarEvents(ctx context.Context, s store.Store, calendarID string) ([]CalendarEvent, error) {
+	if !postgresFeatures() {
+		return nil, nil
+	}
+	return s.ListEvents(ctx, []string{calendarID}, store.Page{})
+}
+
+// eventsDeleted calls eventDeleted for events trashed with their calendar.
+func eventsDeleted(events []CalendarEvent) {
+	for _, event := range events {
+		eventDeleted(event.ID)
+	}
+}
+
+// GetEventHandler handles requests to get a single event
+func GetEventHandler(w http.ResponseWriter, r *http.Request) {
+	eventID := mux.Vars(r)["eventId"]
+	event, err := Store.GetEvent(r.Context(), eventID)
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "Event not found")
+		return
+	}
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	if notModified(w, r, event.Version) {
+		return
+	}
+	writeVersioned(w, http.StatusOK, event.Version, event)
 }
 
 func AddEventHandler(w http.ResponseWriter, r *http.Request) {
 	var newEvent NCalendarEvent
 	err := json.NewDecoder(r.Body).Decode(&newEvent)
 	if err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 	defer r.Body.Close()
-	if newEvent.Date != nil && newEvent.Day == 0 {
-		date, err := time.Parse(time.RFC3339, *newEvent.Date+"T00:00:00Z")
-		if err == nil {
-			newEvent.Day = int(date.Weekday())
-			if newEvent.Day == 0 {
-				newEvent.Day = 7
-			}
-		}
+	newEvent.Day = weekday(newEvent.Date, newEvent.Day)
+	problems, err := validateNewEvent(r.Context(), Store, &newEvent)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	if invalid(w, "event", problems) {
+		return
+	}
+
+	created, err := Store.CreateEvent(r.Context(), newEvent)
+	if errors.Is(err, store.ErrUnknownCalendar) {
+		invalid(w, "event", []fieldError{unknownCalendar(newEvent.CalendarID)})
+		return
 	}
-	_, err = database.DB.Exec(`
-        INSERT INTO calendar_events (title, start_time, end_time, color, day, description, location, attendees, organizer, calendar_id, date)
-        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
-    `, newEvent.Title, newEvent.StartTime, newEvent.EndTime, newEvent.Color, newEvent.Day, newEvent.Description, newEvent.Location,
-		pq.Array(newEvent.Attendees),
-		newEvent.Organizer, newEvent.CalendarID, newEvent.Date)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		serverError(w, err)
 		return
 	}
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(newEvent)
+	eventSaved(created.ID, newEvent, false)
+	w.Header().Set("Location", "/events/"+created.ID)
+	writeVersioned(w, http.StatusCreated, created.Version, created)
 }
 
 func UpdateEventHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	eventID, _ := vars["eventId"]
 	var updatedEvent CalendarEvent
-	err := json.NewDecoder(r.Body).Decode(&updatedEvent)
-	if err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	defer r.Body.Close()
+	if !readFields(w, r, &updatedEvent, "title", "startTime", "endTime", "calendarId", "day|date",
+		"color", "description", "location", "attendees", "organizer") {
 		return
 	}
+	version, ok := expectedVersion(w, r, updatedEvent.Version)
+	if !ok {
+		return
+	}
+	updatedEvent.ID = eventID
+	updatedEvent.Version = version
+	saveEvent(w, r, updatedEvent)
+}
+
+// PatchEventHandler handles requests to change some fields of an event
+func PatchEventHandler(w http.ResponseWriter, r *http.Request) {
+	eventID := mux.Vars(r)["eventId"]
 	defer r.Body.Close()
-	if updatedEvent.Date != nil && updatedEvent.Day == 0 {
-		date, err := time.Parse(time.RFC3339, *updatedEvent.Date+"T00:00:00Z")
-		if err == nil {
-			updatedEvent.Day = int(date.Weekday())
-			if updatedEvent.Day == 0 {
-				updatedEvent.Day = 7
-			}
+	patch, sent, ok := readPatch(w, r)
+	if !ok {
+		return
+	}
+	version, ok := expectedVersion(w, r, sent)
+	if !ok {
+		return
+	}
+
+	current, err := Store.GetEvent(r.Context(), eventID)
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "Event not found")
+		return
+	}
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	if version == 0 {
+		version = current.Version
+	}
+
+	patched, err := patchEvent(current, patch)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	patched.ID = eventID
+	patched.Version = version
+	saveEvent(w, r, patched)
+}
+
+// patchEvent applies a merge patch to an event.
+func patchEvent(current CalendarEvent, patch map[string]interface{}) (CalendarEvent, error) {
+	if date, ok := patch["date"]; ok && date != nil {
+		// A new date moves the event to that date's weekday unless the
+		// patch sets the day too.
+		if _, ok := patch["day"]; !ok {
+			patch["day"] = 0
 		}
 	}
-	_, err = database.DB.Exec(`
-		UPDATE calendar_events
-		SET title = $1, start_time = $2, end_time = $3, color = $4, day = $5, description = $6, location = $7, attendees = $8, organizer = $9, calendar_id = $10, date = $11
-		WHERE id = $12
-	`, updatedEvent.Title, updatedEvent.StartTime, updatedEvent.EndTime, updatedEvent.Color, updatedEvent.Day, updatedEvent.Description, updatedEvent.Location,
-		`[]`, updatedEvent.Organizer, updatedEvent.CalendarID, updatedEvent.Date, eventID)
+	var patched CalendarEvent
+	err := applyPatch(current, patch, &patched)
+	return patched, err
+}
+
+// saveEvent validates and stores a replaced or patched event, does the
+// follow-up work in eventSaved and writes the response.
+func saveEvent(w http.ResponseWriter, r *http.Request, event CalendarEvent) {
+	event.Day = weekday(event.Date, event.Day)
+	problems, err := validateEvent(r.Context(), Store, &event)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		serverError(w, err)
 		return
 	}
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(updatedEvent)
+	if invalid(w, "event", problems) {
+		return
+	}
+
+	eventID := event.ID
+	saved, err := Store.UpdateEvent(r.Context(), event)
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "Event not found")
+		return
+	}
+	if errors.Is(err, store.ErrVersionMismatch) {
+		eventChanged(w, r, eventID)
+		return
+	}
+	if errors.Is(err, store.ErrUnknownCalendar) {
+		invalid(w, "event", []fieldError{unknownCalendar(event.CalendarID)})
+		return
+	}
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	eventSaved(eventID, eventFields(saved), true)
+	writeVersioned(w, http.StatusOK, saved.Version, saved)
+}
+
+// eventFields is what eventSaved needs of a saved event.
+func eventFields(saved CalendarEvent) NCalendarEvent {
+	return NCalendarEvent{
+		Title:      saved.Title,
+		StartTime:  saved.StartTime,
+		EndTime:    saved.EndTime,
+		Day:        saved.Day,
+		Attendees:  saved.Attendees,
+		Organizer:  saved.Organizer,
+		CalendarID: saved.CalendarID,
+		Date:       saved.Date,
+	}
 }
 
 func DeleteEventHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	eventID, _ := vars["eventId"]
-	_, err := database.DB.Exec("DELETE FROM calendar_events WHERE id = $1", eventID)
+	version, ok := expectedVersion(w, r, queryVersion(r))
+	if !ok {
+		return
+	}
+	err := deleteEvent(r.Context(), eventID, version)
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "Event not found")
+		return
+	}
+	if errors.Is(err, store.ErrVersionMismatch) {
+		eventChanged(w, r, eventID)
+		return
+	}
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		serverError(w, err)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// currentDate is the date the calendar navigation endpoints answer with.
+type currentDate struct {
+	CurrentDate string `json:"currentDate"`
+}
+
 func GetCurrentDateHandler(w http.ResponseWriter, r *http.Request) {
 	now := time.Now().In(time.FixedZone("America/New_York", -4*60*60)) // Atlanta is in EDT (UTC-4)
-	response := map[string]string{"currentDate": now.Format(time.RFC3339)}
+	response := currentDate{now.Format(time.RFC3339)}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
@@ -512,7 +861,7 @@ func NavigateCalendarHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	direction, ok := vars["direction"]
 	if !ok {
-		http.Error(w, "Navigation direction not provided", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, "Navigation direction not provided")
 		return
 	}
 
@@ -531,11 +880,11 @@ func NavigateCalendarHandler(w http.ResponseWriter, r *http.Request) {
 	case "today":
 		newDate = time.Now().In(time.FixedZone("America/New_York", -4*60*60))
 	default:
-		http.Error(w, "Invalid navigation direction", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, "Invalid navigation direction")
 		return
 	}
 
-	response := map[string]string{"currentDate": newDate.Format(time.RFC3339)}
+	response := currentDate{newDate.Format(time.RFC3339)}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"github.com/Aman221/4723/internal/store"
+)
+
+// newTestRouter serves the calendar, event and trash endpoints from an
+// empty in-memory store.
+func newTestRouter() *mux.Router {
+	Store = store.NewMemory()
+	r := mux.NewRouter()
+	r.HandleFunc("/calendars", GetCalendarsHandler).Methods("GET")
+	r.HandleFunc("/calendars", AddCalendarHandler).Methods("POST")
+	r.HandleFunc("/calendars/{id}", GetCalendarHandler).Methods("GET")
+	r.HandleFunc("/calendars/{id}", UpdateCalendarHandler).Methods("PUT")
+	r.HandleFunc("/calendars/{id}", PatchCalendarHandler).Methods("PATCH")
+	r.HandleFunc("/calendars/{id}", DeleteCalendarHandler).Methods("DELETE")
+	r.HandleFunc("/events", GetEventsHandler).Methods("GET")
+	r.HandleFunc("/events/search", SearchEventsHandler).Methods("GET")
+	r.HandleFunc("/events", AddEventHandler).Methods("POST")
+	r.HandleFunc("/events/{eventId}", GetEventHandler).Methods("GET")
+	r.HandleFunc("/events/{eventId}", UpdateEventHandler).Methods("PUT")
+	r.HandleFunc("/events/{eventId}", PatchEventHandler).Methods("PATCH")
+	r.HandleFunc("/events/{eventId}", DeleteEventHandler).Methods("DELETE")
+	r.HandleFunc("/trash", GetTrashHandler).Methods("GET")
+	r.HandleFunc("/calendars/{id}/restore", RestoreCalendarHandler).Methods("POST")
+	r.HandleFunc("/events/{eventId}/restore", RestoreEventHandler).Methods("POST")
+	return r
+}
+
+// serve sends a request to r, with If-Match set if ifMatch isn't empty.
+func serve(r http.Handler, method, path, body, ifMatch string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// expect fails the test unless w has status, and decodes its body into v
+// if v isn't nil.
+func expect(t *testing.T, w *httptest.ResponseRecorder, status int, v interface{}) {
+	t.Helper()
+	if w.Code != status {
+		t.Fatalf("status %d, want %d: %s", w.Code, status, w.Body)
+	}
+	if v != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+			t.Fatalf("decoding %s: %v", w.Body, err)
+		}
+	}
+}
+
+// expectProblems fails the test unless w is a 400 whose details name the
+// fields.
+func expectProblems(t *testing.T, w *httptest.ResponseRecorder, fields ...string) {
+	t.Helper()
+	var resp errorResponse
+	expect(t, w, http.StatusBadRequest, &resp)
+	var got []string
+	for _, d := range resp.Error.Details {
+		got = append(got, d.Field)
+	}
+	if strings.Join(got, ",") != strings.Join(fields, ",") {
+		t.Errorf("problems with %v, want %v: %s", got, fields, resp.Error.Message)
+	}
+}
+
+func TestCalendarCRUD(t *testing.T) {
+	r := newTestRouter()
+
+	var created Calendar
+	w := serve(r, "POST", "/calendars", `{"name": "Work", "color": "#ff0000", "visible": true}`, "")
+	expect(t, w, http.StatusCreated, &created)
+	if created.ID == "" || created.Name != "Work" || created.Version != 1 {
+		t.Fatalf("created %+v", created)
+	}
+	if got := w.Header().Get("Location"); got != "/calendars/"+created.ID {
+		t.Errorf("Location is %q", got)
+	}
+	if got := w.Header().Get("ETag"); got != `"1"` {
+		t.Errorf("ETag is %q", got)
+	}
+	path := "/calendars/" + created.ID
+
+	var got Calendar
+	expect(t, serve(r, "GET", path, "", ""), http.StatusOK, &got)
+	if got != created {
+		t.Errorf("got %+v, want %+v", got, created)
+	}
+	var list []Calendar
+	expect(t, serve(r, "GET", "/calendars", "", ""), http.StatusOK, &list)
+	if len(list) != 1 || list[0].ID != created.ID {
+		t.Errorf("listed %+v", list)
+	}
+
+	replacement := `{"name": "Office", "color": "#00ff00", "visible": false}`
+	expect(t, serve(r, "PUT", path, replacement, ""), http.StatusPreconditionRequired, nil)
+	expect(t, serve(r, "PUT", path, replacement, `"1"`), http.StatusOK, &got)
+	if got.Name != "Office" || got.Visible || got.Version != 2 {
+		t.Errorf("replaced with %+v", got)
+	}
+	expect(t, serve(r, "PUT", path, `{"name": "Office"}`, `"2"`), http.StatusBadRequest, nil)
+
+	// A write based on an old version answers with the current one.
+	expect(t, serve(r, "PATCH", path, `{"name": "Home"}`, `"1"`), http.StatusPreconditionFailed, &got)
+	if got.Name != "Office" || got.Version != 2 {
+		t.Errorf("412 answered with %+v", got)
+	}
+	expect(t, serve(r, "PATCH", path, `{"name": "Home"}`, `"2"`), http.StatusOK, &got)
+	if got.Name != "Home" || got.Color != "#00ff00" || got.Version != 3 {
+		t.Errorf("patched to %+v", got)
+	}
+
+	expect(t, serve(r, "DELETE", path, "", ""), http.StatusPreconditionRequired, nil)
+	expect(t, serve(r, "DELETE", path+"?version=3", "", ""), http.StatusNoContent, nil)
+	expect(t, serve(r, "GET", path, "", ""), http.StatusNotFound, nil)
+	expect(t, serve(r, "DELETE", path+"?version=3", "", ""), http.StatusNotFound, nil)
+}
+
+func TestCalendarValidation(t *testing.T) {
+	r := newTestRouter()
+	expectProblems(t, serve(r, "POST", "/calendars", `{"name": "", "color": "red"}`, ""), "name", "color")
+	expect(t, serve(r, "POST", "/calendars", `{"name": `, ""), http.StatusBadRequest, nil)
+
+	var created Calendar
+	expect(t, serve(r, "POST", "/calendars", `{"name": "Work"}`, ""), http.StatusCreated, &created)
+	expectProblems(t, serve(r, "PATCH", "/calendars/"+created.ID, `{"name": ""}`, `"1"`), "name")
+	expect(t, serve(r, "PATCH", "/calendars/none", `{"name": "Home"}`, `"1"`), http.StatusNotFound, nil)
+}
+
+func TestEventCRUD(t *testing.T) {
+	r := newTestRouter()
+	var cal Calendar
+	expect(t, serve(r, "POST", "/calendars", `{"name": "Work"}`, ""), http.StatusCreated, &cal)
+
+	var created CalendarEvent
+	w := serve(r, "POST", "/events", `{"title": "Standup", "startTime": "9:00", "endTime": "9:15",
+		"date": "2026-01-07", "calendarId": "`+cal.ID+`", "attendees": ["bob@example.com"]}`, "")
+	expect(t, w, http.StatusCreated, &created)
+	// The time is stored as HH:MM and the day follows the date, a Wednesday.
+	if created.StartTime != "09:00" || created.EndTime != "09:15" || created.Day != 3 || created.Version != 1 {
+		t.Fatalf("created %+v", created)
+	}
+	if got := w.Header().Get("Location"); got != "/events/"+created.ID {
+		t.Errorf("Location is %q", got)
+	}
+	path := "/events/" + created.ID
+
+	var got CalendarEvent
+	expect(t, serve(r, "GET", path, "", ""), http.StatusOK, &got)
+	if got.Title != "Standup" || got.CalendarID != cal.ID || len(got.Attendees) != 1 {
+		t.Errorf("got %+v", got)
+	}
+	var list []CalendarEvent
+	expect(t, serve(r, "GET", "/events?calendarIds[]="+cal.ID, "", ""), http.StatusOK, &list)
+	if len(list) != 1 || list[0].ID != created.ID {
+		t.Errorf("listed %+v", list)
+	}
+
+	expect(t, serve(r, "PUT", path, `{"title": "Retro"}`, `"1"`), http.StatusBadRequest, nil)
+	got = CalendarEvent{}
+	expect(t, serve(r, "PUT", path, `{"title": "Retro", "startTime": "14:00", "endTime": "15:00",
+		"day": 5, "calendarId": "`+cal.ID+`", "color": "", "description": "", "location": "Room 4",
+		"attendees": [], "organizer": ""}`, `"1"`), http.StatusOK, &got)
+	if got.Title != "Retro" || got.Location != "Room 4" || got.Day != 5 || got.Date != nil || len(got.Attendees) != 0 || got.Version != 2 {
+		t.Errorf("replaced with %+v", got)
+	}
+
+	expect(t, serve(r, "PATCH", path, `{"title": "Review"}`, `"1"`), http.StatusPreconditionFailed, &got)
+	if got.Title != "Retro" {
+		t.Errorf("412 answered with %+v", got)
+	}
+	expect(t, serve(r, "PATCH", path, `{"date": "2026-01-09", "version": 2}`, ""), http.StatusOK, &got)
+	if got.Title != "Retro" || got.Day != 5 || got.Date == nil || *got.Date != "2026-01-09" || got.Version != 3 {
+		t.Errorf("patched to %+v", got)
+	}
+
+	expect(t, serve(r, "DELETE", path+"?version=2", "", ""), http.StatusPreconditionFailed, nil)
+	expect(t, serve(r, "DELETE", path, "", `"3"`), http.StatusNoContent, nil)
+	expect(t, serve(r, "GET", path, "", ""), http.StatusNotFound, nil)
+}
+
+func TestEventValidation(t *testing.T) {
+	r := newTestRouter()
+	var cal Calendar
+	expect(t, serve(r, "POST", "/calendars", `{"name": "Work"}`, ""), http.StatusCreated, &cal)
+
+	expectProblems(t, serve(r, "POST", "/events", `{"title": "", "startTime": "25:00", "endTime": "10:00",
+		"day": 1, "calendarId": "`+cal.ID+`"}`, ""), "title", "startTime")
+	expectProblems(t, serve(r, "POST", "/events", `{"title": "Standup", "startTime": "10:00", "endTime": "9:00",
+		"day": 1, "calendarId": "`+cal.ID+`"}`, ""), "endTime")
+	expectProblems(t, serve(r, "POST", "/events", `{"title": "Standup", "startTime": "9:00", "endTime": "10:00",
+		"day": 1, "calendarId": "none"}`, ""), "calendarId")
+
+	// Events can't go in a calendar in the trash.
+	expect(t, serve(r, "DELETE", "/calendars/"+cal.ID+"?version=1", "", ""), http.StatusNoContent, nil)
+	expectProblems(t, serve(r, "POST", "/events", `{"title": "Standup", "startTime": "9:00", "endTime": "10:00",
+		"day": 1, "calendarId": "`+cal.ID+`"}`, ""), "calendarId")
+}
+
+func TestPostgresOnly(t *testing.T) {
+	called := false
+	h := PostgresOnly(func(w http.ResponseWriter, r *http.Request) { called = true })
+	var resp errorResponse
+	expect(t, serve(h, "GET", "/resources", "", ""), http.StatusNotImplemented, &resp)
+	if called || resp.Error.Code != "not_implemented" {
+		t.Errorf("called %v, answered %+v", called, resp.Error)
+	}
+}
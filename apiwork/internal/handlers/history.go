@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/Aman221/4723/internal/store"
+)
+
+// Every change to a calendar or event is kept in its history. Clients say
+// who is making a change with the X-Actor header, and sync clients say what
+// they are with X-Change-Source (livesync or caldav); everything else is
+// recorded as coming from the API, or from the import for .ics uploads.
+
+// maxActorLength caps the X-Actor header.
+const maxActorLength = 200
+
+// RecordChanges is middleware that attaches the actor and source of a
+// request to its context, for the history.
+func RecordChanges(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		change := store.Change{
+			Actor:  strings.TrimSpace(r.Header.Get("X-Actor")),
+			Source: strings.ToLower(strings.TrimSpace(r.Header.Get("X-Change-Source"))),
+		}
+		if len(change.Actor) > maxActorLength {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("X-Actor must be at most %d characters", maxActorLength))
+			return
+		}
+		switch change.Source {
+		case "", store.SourceAPI, store.SourceLiveSync, store.SourceCalDAV:
+		default:
+			writeError(w, http.StatusBadRequest, "X-Change-Source must be api, livesync or caldav")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(store.WithChange(r.Context(), change)))
+	})
+}
+
+// GetEventHistoryHandler lists the changes to an event, oldest first. The
+// history outlives the event.
+func GetEventHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	writeHistory(w, r, store.EntityEvent, mux.Vars(r)["eventId"], "Event not found")
+}
+
+// GetCalendarHistoryHandler lists the changes to a calendar, oldest first.
+func GetCalendarHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	writeHistory(w, r, store.EntityCalendar, mux.Vars(r)["id"], "Calendar not found")
+}
+
+func writeHistory(w http.ResponseWriter, r *http.Request, entity, id, notFound string) {
+	entries, err := Store.History(r.Context(), entity, id)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	if len(entries) == 0 {
+		// Calendars and events older than the history have none.
+		if entity == store.EntityEvent {
+			_, err = Store.GetEvent(r.Context(), id)
+		} else {
+			_, err = Store.GetCalendar(r.Context(), id)
+		}
+		if errors.Is(err, store.ErrNotFound) {
+			writeError(w, http.StatusNotFound, notFound)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+// revertRequest is the body of POST /events/{eventId}/revert.
+type revertRequest struct {
+	ToVersion int `json:"toVersion"`
+}
+
+// RevertEventHandler puts an event back the way it was at an earlier
+// version, e.g. {"toVersion": 3}. Like an update it must name the version
+// it is based on with If-Match, and the revert is itself recorded as a new
+// version.
+func RevertEventHandler(w http.ResponseWriter, r *http.Request) {
+	eventID := mux.Vars(r)["eventId"]
+	var body revertRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.ToVersion < 1 {
+		writeError(w, http.StatusBadRequest, "Invalid request body: toVersion must be a positive integer")
+		return
+	}
+	version, ok := expectedVersion(w, r, 0)
+	if !ok {
+		return
+	}
+
+	entries, err := Store.History(r.Context(), store.EntityEvent, eventID)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	var target *CalendarEvent
+	for _, e := range entries {
+		if e.Version == body.ToVersion {
+			target = &CalendarEvent{}
+			if err := json.Unmarshal(e.After, target); err != nil {
+				serverError(w, fmt.Errorf("corrupt history entry %s: %w", e.ID, err))
+				return
+			}
+		}
+	}
+	if target == nil {
+		if _, err := Store.GetEvent(r.Context(), eventID); errors.Is(err, store.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "Event not found")
+			return
+		}
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Version %d is not in the event's history", body.ToVersion))
+		return
+	}
+
+	target.ID = eventID
+	target.Version = version
+	target.DeletedAt = nil
+	change := store.ChangeFrom(r.Context())
+	change.Action = store.ActionRevert
+	saveEvent(w, r.WithContext(store.WithChange(r.Context(), change)), *target)
+}
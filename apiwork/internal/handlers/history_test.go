@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Aman221/4723/internal/models"
+	"github.com/Aman221/4723/internal/store"
+)
+
+// newHistoryRouter is newTestRouter with the history endpoints, recording
+// the actor and source of changes.
+func newHistoryRouter() http.Handler {
+	r := newTestRouter()
+	r.HandleFunc("/calendars/{id}/history", GetCalendarHistoryHandler).Methods("GET")
+	r.HandleFunc("/events/{eventId}/history", GetEventHistoryHandler).Methods("GET")
+	r.HandleFunc("/events/{eventId}/revert", RevertEventHandler).Methods("POST")
+	return RecordChanges(r)
+}
+
+// serveAs is serve with the X-Actor header set.
+func serveAs(r http.Handler, actor, method, path, body, ifMatch string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Actor", actor)
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestHistoryAndRevert(t *testing.T) {
+	r := newHistoryRouter()
+	var cal Calendar
+	expect(t, serveAs(r, "alice", "POST", "/calendars", `{"name": "Work"}`, ""), http.StatusCreated, &cal)
+	var event CalendarEvent
+	expect(t, serveAs(r, "alice", "POST", "/events", `{"title": "Standup", "startTime": "9:00", "endTime": "9:15",
+		"day": 1, "calendarId": "`+cal.ID+`"}`, ""), http.StatusCreated, &event)
+	path := "/events/" + event.ID
+	expect(t, serveAs(r, "bob", "PATCH", path, `{"startTime": "10:00", "endTime": "10:15"}`, `"1"`), http.StatusOK, nil)
+
+	var history []models.HistoryEntry
+	expect(t, serve(r, "GET", path+"/history", "", ""), http.StatusOK, &history)
+	if len(history) != 2 {
+		t.Fatalf("history is %+v", history)
+	}
+	moved := history[1]
+	if moved.Action != store.ActionUpdate || moved.Actor != "bob" || moved.Source != store.SourceAPI || moved.Version != 2 {
+		t.Errorf("the move was recorded as %+v", moved)
+	}
+	if len(moved.Changes) != 2 || string(moved.Changes["startTime"].Before) != `"09:00"` {
+		t.Errorf("the move changed %v", moved.Changes)
+	}
+
+	expect(t, serveAs(r, "carol", "POST", path+"/revert", `{"toVersion": 1}`, `"1"`), http.StatusPreconditionFailed, nil)
+	expect(t, serveAs(r, "carol", "POST", path+"/revert", `{"toVersion": 5}`, `"2"`), http.StatusBadRequest, nil)
+	expect(t, serveAs(r, "carol", "POST", path+"/revert", `{"toVersion": 0}`, `"2"`), http.StatusBadRequest, nil)
+	expect(t, serveAs(r, "carol", "POST", "/events/99/revert", `{"toVersion": 1}`, `"2"`), http.StatusNotFound, nil)
+
+	var reverted CalendarEvent
+	expect(t, serveAs(r, "carol", "POST", path+"/revert", `{"toVersion": 1}`, `"2"`), http.StatusOK, &reverted)
+	if reverted.StartTime != "09:00" || reverted.EndTime != "09:15" || reverted.Version != 3 {
+		t.Errorf("reverted to %+v", reverted)
+	}
+	expect(t, serve(r, "GET", path+"/history", "", ""), http.StatusOK, &history)
+	if last := history[len(history)-1]; len(history) != 3 || last.Action != store.ActionRevert || last.Actor != "carol" {
+		t.Errorf("the revert was recorded as %+v", last)
+	}
+
+	// The history outlives the event, and calendars have one too.
+	expect(t, serve(r, "DELETE", path, "", `"3"`), http.StatusNoContent, nil)
+	expect(t, serve(r, "GET", path+"/history", "", ""), http.StatusOK, &history)
+	if len(history) != 4 || history[3].Action != store.ActionDelete {
+		t.Errorf("history after the delete is %+v", history)
+	}
+	expect(t, serve(r, "GET", "/calendars/"+cal.ID+"/history", "", ""), http.StatusOK, &history)
+	if len(history) != 1 || history[0].Actor != "alice" {
+		t.Errorf("calendar history is %+v", history)
+	}
+	expect(t, serve(r, "GET", "/events/99/history", "", ""), http.StatusNotFound, nil)
+}
+
+func TestRecordChangesChecksHeaders(t *testing.T) {
+	r := newHistoryRouter()
+	req := httptest.NewRequest("GET", "/calendars", nil)
+	req.Header.Set("X-Change-Source", "fax")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	expect(t, w, http.StatusBadRequest, nil)
+
+	expect(t, serveAs(r, strings.Repeat("a", maxActorLength+1), "GET", "/calendars", "", ""), http.StatusBadRequest, nil)
+}
@@ -0,0 +1,357 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/Aman221/4723/internal/attendees"
+	"github.com/Aman221/4723/internal/database"
+	"github.com/Aman221/4723/internal/ical"
+	"github.com/Aman221/4723/internal/itip"
+	"github.com/Aman221/4723/internal/store"
+)
+
+// maxImportSize caps the size of an uploaded .ics file.
+const maxImportSize = 10 << 20
+
+// vtodo describes a task as a VTODO component.
+func vtodo(t Task) *ical.Component {
+	c := ical.NewComponent("VTODO")
+	c.Add("UID", t.UID, nil)
+	c.AddTime("DTSTAMP", time.Now())
+	c.AddText("SUMMARY", t.Title)
+	if t.Description != "" {
+		c.AddText("DESCRIPTION", t.Description)
+	}
+	if t.DueDate != nil {
+		day, err := time.ParseInLocation("2006-01-02", *t.DueDate, EventLocation)
+		if err == nil {
+			if t.DueTime != nil {
+				if clock, err := time.Parse("15:04", *t.DueTime); err == nil {
+					due := time.Date(day.Year(), day.Month(), day.Day(), clock.Hour(), clock.Minute(), 0, 0, EventLocation)
+					c.AddTime("DUE", due)
+				}
+			} else {
+				c.Add("DUE", day.Format("20060102"), map[string]string{"VALUE": "DATE"})
+			}
+		}
+	}
+	if t.Priority > 0 {
+		c.Add("PRIORITY", strconv.Itoa(t.Priority), nil)
+	}
+	c.Add("STATUS", t.Status, nil)
+	c.Add("PERCENT-COMPLETE", strconv.Itoa(t.PercentComplete), nil)
+	if t.Recurrence != "" {
+		c.Add("RRULE", t.Recurrence, nil)
+	}
+	if t.CompletedAt != nil {
+		c.AddTime("COMPLETED", *t.CompletedAt)
+	}
+	return c
+}
+
+// ExportCalendarHandler returns a calendar's events and tasks as an .ics file
+func ExportCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	calendarID := mux.Vars(r)["id"]
+
+	var name string
+	err := database.DB.QueryRow("SELECT name FROM calendars WHERE id = $1 AND deleted_at IS NULL", calendarID).Scan(&name)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "Calendar not found")
+		return
+	}
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	var components []*ical.Component
+
+	rows, err := database.DB.Query("SELECT id FROM calendar_events WHERE calendar_id = $1 AND deleted_at IS NULL ORDER BY id", calendarID)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	var eventIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			serverError(w, err)
+			return
+		}
+		eventIDs = append(eventIDs, id)
+	}
+	rows.Close()
+	for _, id := range eventIDs {
+		ev, err := itip.Load(id)
+		if err != nil {
+			serverError(w, err)
+			return
+		}
+		organizer := ""
+		if strings.Contains(ev.Organizer, "@") {
+			organizer = ev.Organizer
+		}
+		vevent, err := itip.VEvent(ev, EventLocation, organizer)
+		if err != nil {
+			// Events with malformed times can't be expressed in iCalendar.
+			continue
+		}
+		components = append(components, vevent)
+	}
+
+	rows, err = database.DB.Query("SELECT "+taskColumns+" FROM tasks WHERE calendar_id = $1 ORDER BY id", calendarID)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		t, err := scanTask(rows)
+		if err != nil {
+			serverError(w, err)
+			return
+		}
+		components = append(components, vtodo(t))
+	}
+
+	cal := itip.Calendar("", components...)
+	cal.AddText("X-WR-CALNAME", name)
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "calendar-"+calendarID+".ics"))
+	cal.Encode(w)
+}
+
+type importResult struct {
+	EventsCreated int      `json:"eventsCreated"`
+	EventsUpdated int      `json:"eventsUpdated"`
+	TasksCreated  int      `json:"tasksCreated"`
+	TasksUpdated  int      `json:"tasksUpdated"`
+	Skipped       []string `json:"skipped"`
+}
+
+// ImportCalendarHandler adds the VEVENTs and VTODOs of an uploaded .ics file
+// to a calendar. Items whose UID already exists in the calendar are updated.
+func ImportCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	calendarID := mux.Vars(r)["id"]
+
+	var exists bool
+	if err := database.DB.QueryRow("SELECT EXISTS (SELECT 1 FROM calendars WHERE id = $1 AND deleted_at IS NULL)", calendarID).Scan(&exists); err != nil {
+		serverError(w, err)
+		return
+	}
+	if !exists {
+		writeError(w, http.StatusNotFound, "Calendar not found")
+		return
+	}
+
+	defer r.Body.Close()
+	cal, err := ical.Decode(io.LimitReader(r.Body, maxImportSize))
+	if err != nil || cal.Name != "VCALENDAR" {
+		writeError(w, http.StatusBadRequest, "Request body must be an iCalendar file")
+		return
+	}
+
+	// Imported events are recorded in their history as coming from the
+	// import, by whoever uploaded the file.
+	change := store.ChangeFrom(r.Context())
+	change.Source = store.SourceImport
+	ctx := store.WithChange(r.Context(), change)
+
+	result := importResult{Skipped: []string{}}
+	for _, c := range cal.Children("VEVENT") {
+		created, err := importEvent(ctx, calendarID, c)
+		if err != nil {
+			result.Skipped = append(result.Skipped, fmt.Sprintf("VEVENT %s: %v", c.Text("UID"), err))
+			continue
+		}
+		if created {
+			result.EventsCreated++
+		} else {
+			result.EventsUpdated++
+		}
+	}
+	for _, c := range cal.Children("VTODO") {
+		created, err := importTask(calendarID, c)
+		if err != nil {
+			result.Skipped = append(result.Skipped, fmt.Sprintf("VTODO %s: %v", c.Text("UID"), err))
+			continue
+		}
+		if created {
+			result.TasksCreated++
+		} else {
+			result.TasksUpdated++
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// importEvent stores a VEVENT and reports whether it was newly created.
+func importEvent(ctx context.Context, calendarID string, c *ical.Component) (bool, error) {
+	startProp, ok := c.Get("DTSTART")
+	if !ok {
+		return false, errors.New("missing DTSTART")
+	}
+	start, allDay, err := ical.ParseTime(startProp, EventLocation)
+	if err != nil {
+		return false, err
+	}
+	start = start.In(EventLocation)
+	end := start.Add(time.Hour)
+	if endProp, ok := c.Get("DTEND"); ok {
+		if end, _, err = ical.ParseTime(endProp, EventLocation); err != nil {
+			return false, err
+		}
+		end = end.In(EventLocation)
+	}
+
+	event := NCalendarEvent{
+		Title:       c.Text("SUMMARY"),
+		StartTime:   start.Format("15:04"),
+		EndTime:     end.Format("15:04"),
+		Description: c.Text("DESCRIPTION"),
+		Location:    c.Text("LOCATION"),
+		Attendees:   []string{},
+		CalendarID:  calendarID,
+	}
+	if allDay {
+		event.StartTime, event.EndTime = "00:00", "23:59"
+	}
+	if end.Format("2006-01-02") != start.Format("2006-01-02") && !allDay {
+		event.EndTime = "23:59"
+	}
+	event.Day = int(start.Weekday())
+	if event.Day == 0 {
+		event.Day = 7
+	}
+	if rrule := c.Text("RRULE"); !strings.Contains(strings.ToUpper(rrule), "FREQ=WEEKLY") {
+		date := start.Format("2006-01-02")
+		event.Date = &date
+	}
+	if org, ok := c.Get("ORGANIZER"); ok {
+		event.Organizer = org.Params["CN"]
+		if event.Organizer == "" {
+			event.Organizer = strings.TrimPrefix(strings.ToLower(org.Value), "mailto:")
+		}
+	}
+	for _, a := range c.All("ATTENDEE") {
+		event.Attendees = append(event.Attendees, strings.TrimPrefix(strings.ToLower(a.Value), "mailto:"))
+	}
+
+	uid := c.Text("UID")
+	if uid == "" {
+		uid = itip.NewUID()
+	}
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var eventID string
+	err = tx.QueryRowContext(ctx, "SELECT id FROM calendar_events WHERE uid = $1 AND calendar_id = $2 AND deleted_at IS NULL",
+		uid, calendarID).Scan(&eventID)
+	created := errors.Is(err, sql.ErrNoRows)
+	var before *CalendarEvent
+	switch {
+	case created:
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO calendar_events (title, start_time, end_time, color, day, description, location, organizer, calendar_id, date, uid)
+			SELECT $1, $2, $3, color, $4, $5, $6, $7, id, $8, $9 FROM calendars WHERE id = $10
+			RETURNING id
+		`, event.Title, event.StartTime, event.EndTime, event.Day, event.Description, event.Location,
+			event.Organizer, event.Date, uid, calendarID).Scan(&eventID)
+	case err == nil:
+		var current CalendarEvent
+		if current, err = store.ReadEvent(ctx, tx, database.Dialect, eventID); err != nil {
+			return false, err
+		}
+		before = &current
+		_, err = tx.ExecContext(ctx, `
+			UPDATE calendar_events
+			SET title = $1, start_time = $2, end_time = $3, day = $4, description = $5, location = $6, organizer = $7, date = $8,
+			    version = version + 1, updated_at = NOW()
+			WHERE id = $9
+		`, event.Title, event.StartTime, event.EndTime, event.Day, event.Description, event.Location,
+			event.Organizer, event.Date, eventID)
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := attendees.Set(ctx, tx, eventID, event.Attendees); err != nil {
+		return false, err
+	}
+	action := store.ActionUpdate
+	if created {
+		action = store.ActionCreate
+	}
+	if err := store.RecordEvent(ctx, tx, database.Dialect, action, before, eventID); err != nil {
+		return false, err
+	}
+	return created, tx.Commit()
+}
+
+// importTask stores a VTODO and reports whether it was newly created.
+func importTask(calendarID string, c *ical.Component) (bool, error) {
+	t := Task{
+		CalendarID:  calendarID,
+		Title:       c.Text("SUMMARY"),
+		Description: c.Text("DESCRIPTION"),
+		Status:      c.Text("STATUS"),
+		Recurrence:  c.Text("RRULE"),
+		UID:         c.Text("UID"),
+	}
+	if due, ok := c.Get("DUE"); ok {
+		at, dateOnly, err := ical.ParseTime(due, EventLocation)
+		if err != nil {
+			return false, err
+		}
+		at = at.In(EventLocation)
+		date := at.Format("2006-01-02")
+		t.DueDate = &date
+		if !dateOnly {
+			clock := at.Format("15:04")
+			t.DueTime = &clock
+		}
+	}
+	if p := c.Text("PRIORITY"); p != "" {
+		t.Priority, _ = strconv.Atoi(p)
+	}
+	if p := c.Text("PERCENT-COMPLETE"); p != "" {
+		t.PercentComplete, _ = strconv.Atoi(p)
+	}
+	if err := t.validate(); err != nil {
+		return false, err
+	}
+
+	if t.UID != "" {
+		res, err := database.DB.Exec(`
+			UPDATE tasks
+			SET title = $1, description = $2, due_date = $3, due_time = $4, priority = $5, status = $6, percent_complete = $7, recurrence = $8,
+			    completed_at = CASE WHEN $6 = 'COMPLETED' THEN COALESCE(completed_at, NOW()) END
+			WHERE uid = $9 AND calendar_id = $10
+		`, t.Title, t.Description, t.DueDate, t.DueTime, t.Priority, t.Status, t.PercentComplete, t.Recurrence, t.UID, calendarID)
+		if err != nil {
+			return false, err
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			return false, nil
+		}
+	}
+	_, err := insertTask(t)
+	return err == nil, err
+}
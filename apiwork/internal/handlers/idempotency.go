@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Aman221/4723/internal/models"
+)
+
+// A POST sent with an Idempotency-Key header runs once. Retries with the
+// same key within IdempotencyWindow get the first response again, marked
+// with Idempotent-Replayed: true, as long as they are the same request:
+// the same path and body. Reusing a key for a different request is a 422,
+// and retrying while the first request is still running a 409. Responses
+// with a 5xx status aren't kept, so those requests can be retried.
+
+// IdempotencyWindow is how long Idempotency-Keys are kept. 0 turns them
+// off.
+var IdempotencyWindow = 24 * time.Hour
+
+const (
+	maxIdempotencyKeyLength = 255
+	// maxIdempotentBodySize bounds the bodies read to fingerprint them. A
+	// full batch is well under it.
+	maxIdempotentBodySize = 4 << 20
+	// A request still running after idempotencyLease is taken to have died
+	// with the server, and a retry runs it again.
+	idempotencyLease = 5 * time.Minute
+)
+
+// replayedHeaders are the response headers kept with a response.
+var replayedHeaders = []string{"Content-Type", "Location", "ETag", "Link"}
+
+// Idempotency is middleware that makes POSTs with an Idempotency-Key safe
+// to retry.
+func Idempotency(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if r.Method != http.MethodPost || key == "" || IdempotencyWindow <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("Idempotency-Key must be at most %d characters", maxIdempotencyKeyLength))
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentBodySize))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("A request with an Idempotency-Key must be at most %d bytes", maxIdempotentBodySize))
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		sum := sha256.Sum256([]byte(r.URL.RequestURI() + "\n" + string(body)))
+		fingerprint := hex.EncodeToString(sum[:])
+
+		// The response is stored even if the client has gone, which is
+		// when it will retry.
+		ctx := context.WithoutCancel(r.Context())
+		// Postgres keeps microseconds, and a claim is released only if its
+		// time reads back the same.
+		now := time.Now().Truncate(time.Microsecond)
+		claim := models.IdempotentRequest{Fingerprint: fingerprint, ClaimedAt: now}
+		earlier, claimed, err := Store.ClaimIdempotencyKey(ctx, key, claim, now.Add(-IdempotencyWindow))
+		if err == nil && !claimed && earlier.Status == 0 && now.Sub(earlier.ClaimedAt) > idempotencyLease {
+			// Release only the stale claim, not one a concurrent retry has
+			// just made in its place.
+			if err = Store.ReleaseIdempotencyKey(ctx, key, earlier); err == nil {
+				earlier, claimed, err = Store.ClaimIdempotencyKey(ctx, key, claim, now.Add(-IdempotencyWindow))
+			}
+		}
+		if err != nil {
+			serverError(w, err)
+			return
+		}
+		if !claimed {
+			replay(w, fingerprint, earlier)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+		if recorder.status >= 500 {
+			err = Store.ReleaseIdempotencyKey(ctx, key, claim)
+		} else {
+			response := models.IdempotentRequest{Status: recorder.status, Header: map[string]string{}, Body: recorder.body.Bytes()}
+			for _, name := range replayedHeaders {
+				if value := w.Header().Get(name); value != "" {
+					response.Header[name] = value
+				}
+			}
+			err = Store.CompleteIdempotencyKey(ctx, key, response)
+		}
+		if err != nil {
+			log.Printf("Error storing the response for Idempotency-Key %q: %v", key, err)
+		}
+	})
+}
+
+// replay answers a retry of the request that claimed its Idempotency-Key.
+func replay(w http.ResponseWriter, fingerprint string, earlier models.IdempotentRequest) {
+	if earlier.Fingerprint != fingerprint {
+		writeError(w, http.StatusUnprocessableEntity, "Idempotency-Key was already used for a different request")
+		return
+	}
+	if earlier.Status == 0 {
+		writeError(w, http.StatusConflict, "A request with this Idempotency-Key is still running")
+		return
+	}
+	for name, value := range earlier.Header {
+		w.Header().Set(name, value)
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(earlier.Status)
+	w.Write(earlier.Body)
+}
+
+// responseRecorder passes a response through and keeps a copy.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Aman221/4723/internal/models"
+)
+
+// serveKey is serve for a POST with an Idempotency-Key.
+func serveKey(r http.Handler, key, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", key)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotentReplay(t *testing.T) {
+	r := Idempotency(newTestRouter())
+	body := `{"name": "Work"}`
+	first := serveKey(r, "k1", "/calendars", body)
+	var cal Calendar
+	expect(t, first, http.StatusCreated, &cal)
+	if first.Header().Get("Idempotent-Replayed") != "" {
+		t.Error("the first response is marked as replayed")
+	}
+
+	retry := serveKey(r, "k1", "/calendars", body)
+	expect(t, retry, http.StatusCreated, nil)
+	if retry.Header().Get("Idempotent-Replayed") != "true" || retry.Body.String() != first.Body.String() ||
+		retry.Header().Get("Location") != first.Header().Get("Location") || retry.Header().Get("ETag") != first.Header().Get("ETag") {
+		t.Errorf("replayed %v %s, want %v %s", retry.Header(), retry.Body, first.Header(), first.Body)
+	}
+	var calendars []Calendar
+	expect(t, serve(r, "GET", "/calendars", "", ""), http.StatusOK, &calendars)
+	if len(calendars) != 1 {
+		t.Errorf("the retry created another calendar: %+v", calendars)
+	}
+
+	// A different request with the same key is refused; another key runs.
+	var resp errorResponse
+	expect(t, serveKey(r, "k1", "/calendars", `{"name": "Home"}`), http.StatusUnprocessableEntity, &resp)
+	expect(t, serveKey(r, "k1", "/events", body), http.StatusUnprocessableEntity, nil)
+	expect(t, serveKey(r, "k2", "/calendars", body), http.StatusCreated, nil)
+	expect(t, serveKey(r, strings.Repeat("k", maxIdempotencyKeyLength+1), "/calendars", body), http.StatusBadRequest, nil)
+
+	// Failures of the client's making are kept too.
+	expect(t, serveKey(r, "k3", "/calendars", `{"name": ""}`), http.StatusBadRequest, nil)
+	if w := serveKey(r, "k3", "/calendars", `{"name": ""}`); w.Code != http.StatusBadRequest || w.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("retrying a 400 got %d %v", w.Code, w.Header())
+	}
+}
+
+func TestIdempotencyKeyInUse(t *testing.T) {
+	running, release := make(chan struct{}), make(chan struct{})
+	r := Idempotency(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(running)
+		<-release
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	newTestRouter() // for its empty store
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- serveKey(r, "k", "/slow", "{}") }()
+	<-running
+	var resp errorResponse
+	expect(t, serveKey(r, "k", "/slow", "{}"), http.StatusConflict, &resp)
+	if resp.Error.Code != "conflict" {
+		t.Errorf("answered %+v", resp.Error)
+	}
+	close(release)
+	expect(t, <-done, http.StatusAccepted, nil)
+}
+
+func TestIdempotencyRunsAgain(t *testing.T) {
+	calls, status := 0, http.StatusServiceUnavailable
+	r := Idempotency(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(status)
+	}))
+	newTestRouter() // for its empty store
+
+	// Server errors aren't kept.
+	expect(t, serveKey(r, "k", "/retry", "{}"), http.StatusServiceUnavailable, nil)
+	status = http.StatusOK
+	expect(t, serveKey(r, "k", "/retry", "{}"), http.StatusOK, nil)
+	expect(t, serveKey(r, "k", "/retry", "{}"), http.StatusOK, nil)
+	if calls != 2 {
+		t.Errorf("ran %d times, want 2", calls)
+	}
+
+	// Nor is a claim whose request died with the server.
+	sum := sha256.Sum256([]byte("/stale\n{}"))
+	claim := models.IdempotentRequest{Fingerprint: hex.EncodeToString(sum[:]), ClaimedAt: time.Now().Add(-2 * idempotencyLease)}
+	if _, _, err := Store.ClaimIdempotencyKey(context.Background(), "stale", claim, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	expect(t, serveKey(r, "stale", "/stale", "{}"), http.StatusOK, nil)
+	if calls != 3 {
+		t.Errorf("a stale claim kept the request from running")
+	}
+
+	// Without a window, keys are ignored.
+	defer func(window time.Duration) { IdempotencyWindow = window }(IdempotencyWindow)
+	IdempotencyWindow = 0
+	serveKey(r, "k", "/retry", "{}")
+	if calls != 4 {
+		t.Errorf("ran %d times, want 4", calls)
+	}
+}
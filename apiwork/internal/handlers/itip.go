@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/Aman221/4723/internal/itip"
+)
+
+// Invitations sends iMIP invitations to external attendees. It is nil when
+// email is not configured, in which case no invitations are sent.
+var Invitations *itip.Sender
+
+// InboundMailToken, when set, must be presented in the X-Inbound-Token
+// header by whatever forwards incoming mail to InboundMailHandler.
+var InboundMailToken string
+
+// maxInboundMessage caps the size of forwarded email messages.
+const maxInboundMessage = 10 << 20
+
+// sendInvitations emails external attendees about a created or updated
+// event in the background.
+func sendInvitations(eventID string, updated bool) {
+	if Invitations == nil {
+		return
+	}
+	itip.Async("sending invitations for event "+eventID, func(ctx context.Context) error {
+		return Invitations.EventSaved(ctx, eventID, updated)
+	})
+}
+
+// prepareCancellation captures what is needed to tell external attendees
+// about an event that is about to be deleted. The returned func sends the
+// cancellations and must be called after the delete succeeds.
+func prepareCancellation(eventID string) func() {
+	if Invitations == nil {
+		return func() {}
+	}
+	ev, err := itip.Load(eventID)
+	if err != nil {
+		return func() {}
+	}
+	invitees, err := itip.Invitees(context.Background(), eventID)
+	if err != nil {
+		log.Printf("Error loading invitees of event %s: %v", eventID, err)
+		return func() {}
+	}
+	return func() {
+		itip.Async("sending cancellations for event "+eventID, func(ctx context.Context) error {
+			return Invitations.EventDeleted(ctx, ev, invitees)
+		})
+	}
+}
+
+// GetEventRSVPsHandler lists the answers external attendees sent to an invitation
+func GetEventRSVPsHandler(w http.ResponseWriter, r *http.Request) {
+	rsvps, err := itip.RSVPs(mux.Vars(r)["eventId"])
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rsvps)
+}
+
+// InboundMailHandler accepts a raw MIME email (as forwarded by a mail
+// server pipe or webhook) carrying an iTIP REPLY and records the RSVPs in it
+func InboundMailHandler(w http.ResponseWriter, r *http.Request) {
+	if InboundMailToken != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Inbound-Token")), []byte(InboundMailToken)) != 1 {
+		writeError(w, http.StatusUnauthorized, "Invalid inbound token")
+		return
+	}
+	defer r.Body.Close()
+
+	results, err := itip.HandleReply(r.Context(), io.LimitReader(r.Body, maxInboundMessage))
+	var bad *itip.MessageError
+	switch {
+	case errors.Is(err, itip.ErrNoCalendar) || errors.Is(err, itip.ErrNotReply):
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	case errors.As(err, &bad):
+		writeError(w, http.StatusBadRequest, "Invalid message: "+bad.Error())
+		return
+	case err != nil:
+		serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
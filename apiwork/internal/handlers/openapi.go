@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/Aman221/4723/internal/digest"
+	"github.com/Aman221/4723/internal/itip"
+	"github.com/Aman221/4723/internal/models"
+	"github.com/Aman221/4723/internal/openapi"
+	"github.com/Aman221/4723/internal/reminders"
+	"github.com/Aman221/4723/internal/resources"
+)
+
+// The API is described in internal/openapi/openapi.json, served at
+// /openapi.json and shown at /docs. "api openapi check" compares it with
+// the routes and with the types below, so a change to either that the
+// spec doesn't follow fails the check.
+
+// OpenAPISchemas are the types the spec's component schemas describe, by
+// schema name.
+var OpenAPISchemas = map[string]interface{}{
+	"User":               models.User{},
+	"Calendar":           Calendar{},
+	"NewCalendar":        NCalendar{},
+	"VisibilityUpdate":   visibilityUpdate{},
+	"Event":              CalendarEvent{},
+	"NewEvent":           NCalendarEvent{},
+	"EventSearchResults": models.EventSearchResults{},
+	"EventSearchHit":     models.EventSearchHit{},
+	"HistoryEntry":       models.HistoryEntry{},
+	"FieldChange":        models.FieldChange{},
+	"RevertRequest":      revertRequest{},
+	"Trash":              models.Trash{},
+	"SyncDelta":          models.SyncDelta{},
+	"BatchRequest":       batchRequest{},
+	"BatchOperation":     batchOperation{},
+	"BatchResponse":      batchResponse{},
+	"BatchResult":        batchResult{},
+	"SavedSearch":        SavedSearch{},
+	"NewSavedSearch":     NSavedSearch{},
+	"Task":               Task{},
+	"ImportResult":       importResult{},
+	"Resource":           resources.Resource{},
+	"Booking":            resources.Booking{},
+	"Reminder":           reminders.Reminder{},
+	"EventReminders":     reminders.EventSettings{},
+	"ReminderSettings":   reminderSettings{},
+	"Notification":       reminders.Notification{},
+	"DigestSubscription": digest.Subscription{},
+	"RSVP":               itip.RSVP{},
+	"ReplyResult":        itip.ReplyResult{},
+	"CurrentDate":        currentDate{},
+	"ErrorResponse":      errorResponse{},
+	"Error":              apiError{},
+	"FieldError":         fieldError{},
+}
+
+// OpenAPIHandler serves the OpenAPI description of the API.
+func OpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openapi.Spec)
+}
+
+// DocsHandler serves a page that shows the OpenAPI description.
+func DocsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(openapi.Docs)
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Aman221/4723/internal/models"
+	"github.com/Aman221/4723/internal/store"
+)
+
+// The calendar and event lists come in pages of ?limit= items, in id order,
+// and search results in pages of the best matches, ranked by each search
+// anew, so their cursors count results. The Link header names
+// the next and previous pages (rel="next", rel="prev") with an opaque
+// ?cursor=, which clients pass back as is along with their other
+// parameters.
+
+var errBadCursor = errors.New("cursor is not one this endpoint handed out")
+
+// A cursor says where a page starts: after or before an id, or at an
+// offset into search results.
+type cursor struct {
+	kind  string // "after", "before" or "offset"
+	value int
+}
+
+func (c cursor) String() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(c.kind + ":" + strconv.Itoa(c.value)))
+}
+
+// readCursor decodes the ?cursor= parameter, which must be of one of the
+// given kinds. It returns the zero cursor if there is none.
+func readCursor(r *http.Request, kinds ...string) (cursor, error) {
+	raw := r.URL.Query().Get("cursor")
+	if raw == "" {
+		return cursor{}, nil
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return cursor{}, errBadCursor
+	}
+	kind, value, _ := strings.Cut(string(decoded), ":")
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return cursor{}, errBadCursor
+	}
+	for _, k := range kinds {
+		if kind == k {
+			return cursor{kind, n}, nil
+		}
+	}
+	return cursor{}, errBadCursor
+}
+
+// readPage reads ?limit= and ?cursor= for a list endpoint. It writes a 400
+// and returns false if either is invalid.
+func readPage(w http.ResponseWriter, r *http.Request) (store.Page, bool) {
+	page, _, ok := readPageAt(w, r)
+	return page, ok
+}
+
+// readPageAt is readPage for a list that goes on past its ids, where pages
+// start at cursors of the other kinds given. It returns the cursor too.
+func readPageAt(w http.ResponseWriter, r *http.Request, kinds ...string) (store.Page, cursor, bool) {
+	var problems []fieldError
+	limit, err := intParam(r.URL.Query(), "limit", 1, store.MaxPageSize)
+	if err != nil {
+		problems = append(problems, fieldError{"limit", err.Error()})
+	}
+	if limit == 0 {
+		limit = store.DefaultPageSize
+	}
+	page := store.Page{Limit: limit}
+	c, err := readCursor(r, append([]string{"after", "before"}, kinds...)...)
+	if err != nil {
+		problems = append(problems, fieldError{"cursor", err.Error()})
+	}
+	switch c.kind {
+	case "after":
+		page.After = strconv.Itoa(c.value)
+	case "before":
+		page.Before = strconv.Itoa(c.value)
+	}
+	return page, c, !invalid(w, "page", problems)
+}
+
+// oneMore is page with room for one more item, which tells whether there
+// is another page.
+func oneMore(page store.Page) store.Page {
+	page.Limit++
+	return page
+}
+
+// paginate cuts items, read in id order with oneMore(page) or filtered by
+// page.Includes, down to page and sets the Link header. It returns the
+// page of items and whether it is the last page.
+func paginate[T any](w http.ResponseWriter, r *http.Request, page store.Page, items []T, id func(T) string) ([]T, bool) {
+	backwards := page.Before != ""
+	more := len(items) > page.Limit
+	if more && backwards {
+		items = items[len(items)-page.Limit:]
+	} else if more {
+		items = items[:page.Limit]
+	}
+	isLast := !more && !backwards
+	if len(items) == 0 {
+		return items, isLast
+	}
+	first, _ := strconv.Atoi(id(items[0]))
+	last, _ := strconv.Atoi(id(items[len(items)-1]))
+	var links []string
+	if backwards || more {
+		links = append(links, pageLink(r, cursor{"after", last}, "next"))
+	}
+	if page.After != "" || backwards && more {
+		links = append(links, pageLink(r, cursor{"before", first}, "prev"))
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+	return items, isLast
+}
+
+// setSearchLinks links to the pages of search results before and after
+// results.
+func setSearchLinks(w http.ResponseWriter, r *http.Request, results models.EventSearchResults) {
+	var links []string
+	if next := results.Offset + len(results.Results); next < results.Total {
+		links = append(links, pageLink(r, cursor{"offset", next}, "next"))
+	}
+	if results.Offset > 0 {
+		links = append(links, pageLink(r, cursor{"offset", max(results.Offset-results.Limit, 0)}, "prev"))
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+}
+
+// pageLink links to the page at c with the request's other parameters.
+func pageLink(r *http.Request, c cursor, rel string) string {
+	params := r.URL.Query()
+	params.Set("cursor", c.String())
+	params.Del("offset")
+	return "<" + r.URL.Path + "?" + params.Encode() + `>; rel="` + rel + `"`
+}
+
+func calendarID(cal Calendar) string { return cal.ID }
+
+func eventID(event CalendarEvent) string { return event.ID }
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func TestCursor(t *testing.T) {
+	c := cursor{"after", 42}
+	req := httptest.NewRequest("GET", "/calendars?cursor="+c.String(), nil)
+	if got, err := readCursor(req, "after", "before"); err != nil || got != c {
+		t.Errorf("read back %+v, %v", got, err)
+	}
+	if _, err := readCursor(req, "offset"); err != errBadCursor {
+		t.Errorf("a cursor of another kind: %v", err)
+	}
+	for _, raw := range []string{"!!", cursor{"after", -1}.String(), "YWZ0ZXI6eA"} { // "after:x"
+		req := httptest.NewRequest("GET", "/calendars?cursor="+raw, nil)
+		if _, err := readCursor(req, "after"); err != errBadCursor {
+			t.Errorf("cursor %q: %v", raw, err)
+		}
+	}
+	req = httptest.NewRequest("GET", "/calendars", nil)
+	if got, err := readCursor(req, "after"); err != nil || got != (cursor{}) {
+		t.Errorf("no cursor read as %+v, %v", got, err)
+	}
+}
+
+var linkPattern = regexp.MustCompile(`<([^>]*)>; rel="(\w+)"`)
+
+// links returns the targets of the Link header of w by rel.
+func links(w *httptest.ResponseRecorder) map[string]string {
+	out := map[string]string{}
+	for _, m := range linkPattern.FindAllStringSubmatch(w.Header().Get("Link"), -1) {
+		out[m[2]] = m[1]
+	}
+	return out
+}
+
+// names lists the names of calendars.
+func names(calendars []Calendar) string {
+	var s string
+	for _, cal := range calendars {
+		s += cal.Name + " "
+	}
+	return s
+}
+
+func TestCalendarPages(t *testing.T) {
+	r, user := newSearchRouter(t)
+	var c3 Calendar
+	for i := 1; i <= 5; i++ {
+		var cal Calendar
+		expect(t, serve(r, "POST", "/calendars", `{"name": "c`+strconv.Itoa(i)+`"}`, ""), http.StatusCreated, &cal)
+		if i == 3 {
+			c3 = cal
+		}
+	}
+
+	var page []Calendar
+	w := serve(r, "GET", "/calendars?limit=2", "", "")
+	expect(t, w, http.StatusOK, &page)
+	if names(page) != "c1 c2 " || links(w)["prev"] != "" {
+		t.Fatalf("first page is %s with links %v", names(page), links(w))
+	}
+	w = serve(r, "GET", links(w)["next"], "", "")
+	expect(t, w, http.StatusOK, &page)
+	if names(page) != "c3 c4 " {
+		t.Fatalf("second page is %s", names(page))
+	}
+	next, prev := links(w)["next"], links(w)["prev"]
+	w = serve(r, "GET", next, "", "")
+	expect(t, w, http.StatusOK, &page)
+	if names(page) != "c5 " || links(w)["next"] != "" {
+		t.Errorf("last page is %s with links %v", names(page), links(w))
+	}
+	w = serve(r, "GET", prev, "", "")
+	expect(t, w, http.StatusOK, &page)
+	if names(page) != "c1 c2 " || links(w)["prev"] != "" {
+		t.Errorf("going back got %s with links %v", names(page), links(w))
+	}
+
+	expectProblems(t, serve(r, "GET", "/calendars?limit=0", "", ""), "limit")
+	expectProblems(t, serve(r, "GET", "/calendars?cursor="+cursor{"offset", 2}.String(), "", ""), "cursor")
+
+	// Smart calendars come after the others and count toward the limit.
+	for _, name := range []string{"s1", "s2", "s3"} {
+		expect(t, serve(r, "POST", "/user/"+user.ID+"/searches", `{"name": "`+name+`", "query": "x", "pinned": true}`, ""), http.StatusCreated, nil)
+	}
+	id, _ := strconv.Atoi(c3.ID)
+	w = serve(r, "GET", "/calendars?limit=3&cursor="+cursor{"after", id}.String(), "", "")
+	expect(t, w, http.StatusOK, &page)
+	if names(page) != "c4 c5 s1 " {
+		t.Fatalf("page at the end of the calendars is %s", names(page))
+	}
+	w = serve(r, "GET", links(w)["next"], "", "")
+	expect(t, w, http.StatusOK, &page)
+	if names(page) != "s2 s3 " || links(w)["next"] != "" {
+		t.Errorf("page of smart calendars is %s with links %v", names(page), links(w))
+	}
+	w = serve(r, "GET", links(w)["prev"], "", "")
+	expect(t, w, http.StatusOK, &page)
+	if names(page) != "s1 s2 s3 " {
+		t.Errorf("going back got %s", names(page))
+	}
+	w = serve(r, "GET", links(w)["prev"], "", "")
+	expect(t, w, http.StatusOK, &page)
+	if names(page) != "c3 c4 c5 " {
+		t.Errorf("going back past the smart calendars got %s", names(page))
+	}
+}
+
+func TestSearchLinks(t *testing.T) {
+	r := newTestRouter()
+	var cal Calendar
+	expect(t, serve(r, "POST", "/calendars", `{"name": "Work", "visible": true}`, ""), http.StatusCreated, &cal)
+	for i := 0; i < 5; i++ {
+		expect(t, serve(r, "POST", "/events", `{"title": "Standup", "startTime": "9:00", "endTime": "9:15",
+			"day": 1, "calendarId": "`+cal.ID+`"}`, ""), http.StatusCreated, nil)
+	}
+
+	w := serve(r, "GET", "/events/search?q=standup&limit=2&offset=2", "", "")
+	expect(t, w, http.StatusOK, nil)
+	l := links(w)
+	next, prev := cursor{"offset", 4}, cursor{"offset", 0}
+	if want := "/events/search?cursor=" + next.String() + "&limit=2&q=standup"; l["next"] != want {
+		t.Errorf("next is %q, want %q", l["next"], want)
+	}
+	if want := "/events/search?cursor=" + prev.String() + "&limit=2&q=standup"; l["prev"] != want {
+		t.Errorf("prev is %q, want %q", l["prev"], want)
+	}
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// PUT replaces a calendar or event and must send all of its fields; PATCH
+// takes a JSON Merge Patch (RFC 7396) and changes only the fields it names,
+// with null clearing a field. Both check the result with validateCalendar
+// or validateEvent before storing it.
+
+// readFields decodes a PUT body into v after checking that it has every
+// required field. It writes an error response and returns false otherwise.
+func readFields(w http.ResponseWriter, r *http.Request, v interface{}, required ...string) bool {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return false
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil || fields == nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return false
+	}
+	var missing []string
+	for _, name := range required {
+		found := false
+		for _, alt := range strings.Split(name, "|") {
+			if _, ok := fields[alt]; ok {
+				found = true
+			}
+		}
+		if !found {
+			missing = append(missing, strings.ReplaceAll(name, "|", " or "))
+		}
+	}
+	if len(missing) > 0 {
+		writeError(w, http.StatusBadRequest, "PUT replaces the whole resource; missing "+strings.Join(missing, ", ")+" (use PATCH to change some fields)")
+		return false
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return false
+	}
+	return true
+}
+
+// readPatch reads a merge patch. The version it names, if any, is returned
+// separately; id, version, updatedAt and deletedAt can't be patched.
+func readPatch(w http.ResponseWriter, r *http.Request) (map[string]interface{}, int, bool) {
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		mediaType, _, err := mime.ParseMediaType(ct)
+		if err != nil || (mediaType != "application/merge-patch+json" && mediaType != "application/json") {
+			writeError(w, http.StatusUnsupportedMediaType, "PATCH takes application/merge-patch+json")
+			return nil, 0, false
+		}
+	}
+	var patch map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil || patch == nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body: a merge patch must be a JSON object")
+		return nil, 0, false
+	}
+
+	version := 0
+	if v, ok := patch["version"]; ok && v != nil {
+		n, isNumber := v.(float64)
+		if !isNumber || n < 1 || n != float64(int(n)) {
+			writeError(w, http.StatusBadRequest, "version must be a positive integer")
+			return nil, 0, false
+		}
+		version = int(n)
+	}
+	for _, name := range unpatchable {
+		delete(patch, name)
+	}
+	return patch, version, true
+}
+
+// unpatchable lists the fields a merge patch can't change.
+var unpatchable = []string{"id", "version", "updatedAt", "deletedAt"}
+
+// mergePatch applies patch to target as RFC 7396 describes.
+func mergePatch(target, patch interface{}) interface{} {
+	fields, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	doc, ok := target.(map[string]interface{})
+	if !ok {
+		doc = map[string]interface{}{}
+	}
+	for name, value := range fields {
+		if value == nil {
+			delete(doc, name)
+		} else {
+			doc[name] = mergePatch(doc[name], value)
+		}
+	}
+	return doc
+}
+
+// applyPatch merges patch into the JSON form of current and decodes the
+// result into patched.
+func applyPatch(current interface{}, patch map[string]interface{}, patched interface{}) error {
+	raw, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+	var doc interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return err
+	}
+	merged, err := json.Marshal(mergePatch(doc, patch))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(merged, patched)
+}
+
+// invalid writes a 400 listing problems, if there are any.
+func invalid(w http.ResponseWriter, kind string, problems []fieldError) bool {
+	if len(problems) == 0 {
+		return false
+	}
+	writeError(w, http.StatusBadRequest, invalidMessage(kind, problems), problems...)
+	return true
+}
+
+// invalidMessage sums up problems in one line.
+func invalidMessage(kind string, problems []fieldError) string {
+	messages := make([]string, len(problems))
+	for i, p := range problems {
+		messages[i] = p.Message
+	}
+	return fmt.Sprintf("Invalid %s: %s", kind, strings.Join(messages, "; "))
+}
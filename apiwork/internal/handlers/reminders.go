@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/Aman221/4723/internal/reminders"
+)
+
+// GetEventRemindersHandler returns an event's reminder settings
+func GetEventRemindersHandler(w http.ResponseWriter, r *http.Request) {
+	settings, err := reminders.ForEvent(mux.Vars(r)["eventId"])
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "Event not found")
+		return
+	}
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settings)
+}
+
+// reminderSettings is the body of PUT /events/{eventId}/reminders.
+type reminderSettings struct {
+	UseDefault bool                 `json:"useDefault"`
+	Overrides  []reminders.Reminder `json:"overrides"`
+}
+
+// UpdateEventRemindersHandler sets whether an event uses its calendar's
+// default reminders and which reminders it overrides them with
+func UpdateEventRemindersHandler(w http.ResponseWriter, r *http.Request) {
+	eventID := mux.Vars(r)["eventId"]
+
+	var body reminderSettings
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	defer r.Body.Close()
+
+	if err := reminders.Validate(body.Overrides); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if _, err := reminders.ForEvent(eventID); errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "Event not found")
+		return
+	}
+	if err := reminders.SetForEvent(eventID, body.UseDefault, body.Overrides); err != nil {
+		serverError(w, err)
+		return
+	}
+
+	GetEventRemindersHandler(w, r)
+}
+
+// GetCalendarRemindersHandler returns a calendar's default reminders
+func GetCalendarRemindersHandler(w http.ResponseWriter, r *http.Request) {
+	list, err := reminders.CalendarDefaults(mux.Vars(r)["id"])
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// UpdateCalendarRemindersHandler replaces a calendar's default reminders
+func UpdateCalendarRemindersHandler(w http.ResponseWriter, r *http.Request) {
+	var list []reminders.Reminder
+	if err := json.NewDecoder(r.Body).Decode(&list); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	defer r.Body.Close()
+
+	if err := reminders.Validate(list); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := reminders.SetCalendarDefaults(mux.Vars(r)["id"], list); err != nil {
+		serverError(w, err)
+		return
+	}
+
+	GetCalendarRemindersHandler(w, r)
+}
+
+// GetNotificationsHandler lists in-app notifications for ?recipient=, with
+// ?unread=true limiting the list to unread ones
+func GetNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	recipient := r.URL.Query().Get("recipient")
+	if recipient == "" {
+		writeError(w, http.StatusBadRequest, "recipient parameter is required")
+		return
+	}
+
+	list, err := reminders.Notifications(recipient, r.URL.Query().Get("unread") == "true")
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// MarkNotificationReadHandler marks an in-app notification as read
+func MarkNotificationReadHandler(w http.ResponseWriter, r *http.Request) {
+	found, err := reminders.MarkRead(mux.Vars(r)["id"])
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	if !found {
+		writeError(w, http.StatusNotFound, "Notification not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/Aman221/4723/internal/resources"
+)
+
+// GetResourcesHandler lists rooms and equipment, optionally filtered by ?kind=
+func GetResourcesHandler(w http.ResponseWriter, r *http.Request) {
+	list, err := resources.List(r.URL.Query().Get("kind"))
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// GetResourceHandler returns a single resource
+func GetResourceHandler(w http.ResponseWriter, r *http.Request) {
+	res, err := resources.Get(mux.Vars(r)["id"])
+	if errors.Is(err, resources.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "Resource not found")
+		return
+	}
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+// AddResourceHandler creates a resource and the calendar that backs it
+func AddResourceHandler(w http.ResponseWriter, r *http.Request) {
+	var newResource resources.Resource
+	if err := json.NewDecoder(r.Body).Decode(&newResource); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	defer r.Body.Close()
+
+	if err := newResource.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	created, err := resources.Create(newResource)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", "/resources/"+created.ID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// UpdateResourceHandler replaces a resource's name, kind, email, capacity and attributes
+func UpdateResourceHandler(w http.ResponseWriter, r *http.Request) {
+	var updatedResource resources.Resource
+	if err := json.NewDecoder(r.Body).Decode(&updatedResource); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	defer r.Body.Close()
+
+	if err := updatedResource.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	updated, err := resources.Update(mux.Vars(r)["id"], updatedResource)
+	if errors.Is(err, resources.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "Resource not found")
+		return
+	}
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// DeleteResourceHandler removes a resource along with its calendar and bookings
+func DeleteResourceHandler(w http.ResponseWriter, r *http.Request) {
+	err := resources.Delete(mux.Vars(r)["id"])
+	if errors.Is(err, resources.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "Resource not found")
+		return
+	}
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SearchAvailableResourcesHandler finds resources that are free for a time slot.
+// Example: /resources/available?kind=room&capacity=6&date=2026-03-02&startTime=09:00&endTime=10:00&attributes[]=projector:yes
+func SearchAvailableResourcesHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	filter := resources.Filter{Kind: q.Get("kind"), Attributes: map[string]string{}}
+	if c := q.Get("capacity"); c != "" {
+		capacity, err := strconv.Atoi(c)
+		if err != nil || capacity < 0 {
+			writeError(w, http.StatusBadRequest, "Invalid capacity")
+			return
+		}
+		filter.MinCapacity = capacity
+	}
+	for _, attr := range q["attributes[]"] {
+		key, value, found := strings.Cut(attr, ":")
+		if !found || key == "" {
+			writeError(w, http.StatusBadRequest, "attributes must be given as key:value")
+			return
+		}
+		filter.Attributes[key] = value
+	}
+
+	slot := resources.Slot{StartTime: q.Get("startTime"), EndTime: q.Get("endTime")}
+	if date := q.Get("date"); date != "" {
+		slot.Date = &date
+	} else if day := q.Get("day"); day != "" {
+		d, err := strconv.Atoi(day)
+		if err != nil || d < 1 || d > 7 {
+			writeError(w, http.StatusBadRequest, "Invalid day")
+			return
+		}
+		slot.Day = d
+	} else {
+		writeError(w, http.StatusBadRequest, "date or day parameter is required")
+		return
+	}
+
+	available, err := resources.Available(filter, slot)
+	if err != nil {
+		if errors.Is(err, resources.ErrInvalidTime) || errors.Is(err, resources.ErrEndBeforeStart) || errors.Is(err, resources.ErrInvalidDate) {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(available)
+}
+
+// GetEventResourcesHandler shows whether each invited resource accepted or declined
+func GetEventResourcesHandler(w http.ResponseWriter, r *http.Request) {
+	bookings, err := resources.Bookings(mux.Vars(r)["eventId"])
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(bookings)
+}
+
+// syncResourceBookings lets invited resources answer an event invitation.
+// Failures are logged rather than failing the request, since the event
+// itself has already been saved.
+func syncResourceBookings(eventID string, event NCalendarEvent) {
+	_, err := resources.SyncBookings(resources.Invitation{
+		EventID:   eventID,
+		Title:     event.Title,
+		Organizer: event.Organizer,
+		Attendees: event.Attendees,
+		Slot: resources.Slot{
+			Date:      event.Date,
+			Day:       event.Day,
+			StartTime: event.StartTime,
+			EndTime:   event.EndTime,
+		},
+	})
+	if err != nil {
+		log.Printf("Error booking resources for event %s: %v", eventID, err)
+	}
+}
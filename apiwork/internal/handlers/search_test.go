@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/Aman221/4723/internal/models"
+)
+
+func TestSearchEvents(t *testing.T) {
+	r := newTestRouter()
+	var cal Calendar
+	expect(t, serve(r, "POST", "/calendars", `{"name": "Work", "visible": true}`, ""), http.StatusCreated, &cal)
+	for _, title := range []string{"Standup", "Retro", "Standup review"} {
+		expect(t, serve(r, "POST", "/events", `{"title": "`+title+`", "startTime": "9:00", "endTime": "9:15",
+			"day": 1, "calendarId": "`+cal.ID+`"}`, ""), http.StatusCreated, nil)
+	}
+
+	var results models.EventSearchResults
+	expect(t, serve(r, "GET", "/events/search?q=standup", "", ""), http.StatusOK, &results)
+	if results.Total != 2 || results.Results[0].Title != "Standup" || results.Results[0].Highlights["title"] != "<mark>Standup</mark>" {
+		t.Errorf("found %+v", results)
+	}
+	// The old parameter name still works.
+	expect(t, serve(r, "GET", "/events/search?query=retro", "", ""), http.StatusOK, &results)
+	if results.Total != 1 {
+		t.Errorf("found %+v", results)
+	}
+
+	expectProblems(t, serve(r, "GET", "/events/search", "", ""), "q")
+	expectProblems(t, serve(r, "GET", "/events/search?q=(standup", "", ""), "q")
+	expectProblems(t, serve(r, "GET", "/events/search?q=standup&from=2026-1-5&limit=0&offset=-1", "", ""), "from", "limit", "offset")
+}
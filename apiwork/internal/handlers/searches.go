@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/Aman221/4723/internal/store"
+)
+
+// Users keep searches they run often. A pinned saved search shows up among
+// the calendars as a read-only smart calendar with the id "search-" plus
+// the saved search's id, and GET /events takes that id in calendarIds[] to
+// list the events that match the search at the time.
+
+const smartCalendarPrefix = "search-"
+
+// GetUserSearchesHandler lists a user's saved searches.
+func GetUserSearchesHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := userID(w, r)
+	if !ok {
+		return
+	}
+
+	searches, err := Store.ListSavedSearches(r.Context(), id)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(searches)
+}
+
+// AddSearchHandler saves a search for a user.
+func AddSearchHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := userID(w, r)
+	if !ok {
+		return
+	}
+
+	var newSearch NSavedSearch
+	if err := json.NewDecoder(r.Body).Decode(&newSearch); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	defer r.Body.Close()
+	if invalid(w, "saved search", validateSearch(newSearch.Name, newSearch.Query)) {
+		return
+	}
+
+	created, err := Store.CreateSavedSearch(r.Context(), id, newSearch)
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/searches/"+created.ID)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// GetSearchHandler returns a saved search.
+func GetSearchHandler(w http.ResponseWriter, r *http.Request) {
+	saved, err := Store.GetSavedSearch(r.Context(), mux.Vars(r)["id"])
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "Saved search not found")
+		return
+	}
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(saved)
+}
+
+// UpdateSearchHandler replaces a saved search.
+func UpdateSearchHandler(w http.ResponseWriter, r *http.Request) {
+	var updated SavedSearch
+	defer r.Body.Close()
+	if !readFields(w, r, &updated, "name", "query", "color", "pinned") {
+		return
+	}
+	if invalid(w, "saved search", validateSearch(updated.Name, updated.Query)) {
+		return
+	}
+
+	updated.ID = mux.Vars(r)["id"]
+	saved, err := Store.UpdateSavedSearch(r.Context(), updated)
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "Saved search not found")
+		return
+	}
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(saved)
+}
+
+// DeleteSearchHandler deletes a saved search, and with it its smart
+// calendar.
+func DeleteSearchHandler(w http.ResponseWriter, r *http.Request) {
+	err := Store.DeleteSavedSearch(r.Context(), mux.Vars(r)["id"])
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "Saved search not found")
+		return
+	}
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// validateSearch lists what is wrong with a saved search about to be
+// stored.
+func validateSearch(name, query string) []fieldError {
+	var problems []fieldError
+	if strings.TrimSpace(name) == "" {
+		problems = append(problems, fieldError{"name", "name is required"})
+	}
+	if strings.TrimSpace(query) == "" {
+		problems = append(problems, fieldError{"query", "query is required"})
+	} else if _, err := store.ParseQuery(query); err != nil {
+		problems = append(problems, fieldError{"query", "query: " + err.Error()})
+	}
+	return problems
+}
+
+// withSmartCalendars appends the smart calendars of the pinned saved
+// searches of a user, or of every user if userID is empty.
+func withSmartCalendars(ctx context.Context, calendars []Calendar, userID string) ([]Calendar, error) {
+	searches, err := Store.ListSavedSearches(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range searches {
+		if s.Pinned {
+			calendars = append(calendars, Calendar{
+				ID:      smartCalendarPrefix + s.ID,
+				Name:    s.Name,
+				Color:   s.Color,
+				Visible: true,
+				Query:   s.Query,
+			})
+		}
+	}
+	return calendars, nil
+}
+
+// smartCalendarsPage fills the rest of the last page of calendars with
+// smart calendars, and links to the next page if any are left. Pages past
+// the last calendar start at a "smart" cursor c, the offset into the smart
+// calendars, and hold those alone.
+func smartCalendarsPage(w http.ResponseWriter, r *http.Request, page store.Page, calendars []Calendar, c cursor) ([]Calendar, error) {
+	smart, err := withSmartCalendars(r.Context(), []Calendar{}, "")
+	if err != nil {
+		return nil, err
+	}
+	offset := min(c.value, len(smart))
+	end := min(offset+page.Limit-len(calendars), len(smart))
+	calendars = append(calendars, smart[offset:end]...)
+
+	var links []string
+	if link := w.Header().Get("Link"); link != "" {
+		links = append(links, link)
+	}
+	if end < len(smart) {
+		links = append(links, pageLink(r, cursor{"smart", end}, "next"))
+	}
+	if c.kind == "smart" {
+		// The previous page ends with the last calendars, unless it holds
+		// smart calendars too.
+		prev := cursor{"before", math.MaxInt32}
+		if offset > 0 {
+			prev = cursor{"smart", max(offset-page.Limit, 0)}
+		}
+		links = append(links, pageLink(r, prev, "prev"))
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+	return calendars, nil
+}
+
+// withSmartEvents adds the events within page that match the saved
+// searches behind smart calendar ids to events, leaving out those already
+// there, and returns them in id order. Unknown ids are ignored, as they are
+// for calendars.
+func withSmartEvents(ctx context.Context, events []CalendarEvent, searchIDs []string, page store.Page) ([]CalendarEvent, error) {
+	seen := map[string]bool{}
+	for _, event := range events {
+		seen[event.ID] = true
+	}
+	for _, id := range searchIDs {
+		saved, err := Store.GetSavedSearch(ctx, id)
+		if errors.Is(err, store.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		// Smart calendars show matches from hidden calendars too; the
+		// smart calendar is what the user chose to look at.
+		matches, err := searchAll(ctx, Store, store.EventSearch{Query: saved.Query, IncludeHidden: true})
+		if err != nil {
+			return nil, err
+		}
+		for _, event := range matches {
+			if !seen[event.ID] && page.Includes(event.ID) {
+				seen[event.ID] = true
+				events = append(events, event)
+			}
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		a, _ := strconv.Atoi(events[i].ID)
+		b, _ := strconv.Atoi(events[j].ID)
+		return a < b
+	})
+	return events, nil
+}
+
+// searchAll returns every event that search finds in s, best match first.
+func searchAll(ctx context.Context, s store.Store, search store.EventSearch) ([]CalendarEvent, error) {
+	search.Limit = store.MaxSearchLimit
+	var events []CalendarEvent
+	for {
+		results, err := s.SearchEvents(ctx, search)
+		if err != nil {
+			return nil, err
+		}
+		for _, hit := range results.Results {
+			events = append(events, hit.CalendarEvent)
+		}
+		search.Offset += len(results.Results)
+		if len(results.Results) == 0 || search.Offset >= results.Total {
+			return events, nil
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"github.com/Aman221/4723/internal/models"
+)
+
+// newSearchRouter is newTestRouter with the saved search endpoints, and
+// the user the searches belong to.
+func newSearchRouter(t *testing.T) (*mux.Router, models.User) {
+	r := newTestRouter()
+	r.HandleFunc("/user/{id}/calendar", GetUserCalendarHandler).Methods("GET")
+	r.HandleFunc("/user/{id}/searches", GetUserSearchesHandler).Methods("GET")
+	r.HandleFunc("/user/{id}/searches", AddSearchHandler).Methods("POST")
+	r.HandleFunc("/searches/{id}", GetSearchHandler).Methods("GET")
+	r.HandleFunc("/searches/{id}", UpdateSearchHandler).Methods("PUT")
+	r.HandleFunc("/searches/{id}", DeleteSearchHandler).Methods("DELETE")
+	user, err := Store.CreateUser(context.Background(), models.User{Username: "alice", Email: "alice@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r, user
+}
+
+func TestSavedSearches(t *testing.T) {
+	r, user := newSearchRouter(t)
+	path := "/user/" + user.ID + "/searches"
+
+	var saved SavedSearch
+	w := serve(r, "POST", path, `{"name": "Acme", "query": "acme -cancelled"}`, "")
+	expect(t, w, http.StatusCreated, &saved)
+	if saved.UserID != user.ID || saved.Pinned || w.Header().Get("Location") != "/searches/"+saved.ID {
+		t.Errorf("saved %+v at %s", saved, w.Header().Get("Location"))
+	}
+	expectProblems(t, serve(r, "POST", path, `{"name": " ", "query": "(acme"}`, ""), "name", "query")
+	expect(t, serve(r, "POST", "/user/99/searches", `{"name": "Acme", "query": "acme"}`, ""), http.StatusNotFound, nil)
+
+	var list []SavedSearch
+	expect(t, serve(r, "GET", path, "", ""), http.StatusOK, &list)
+	if len(list) != 1 || list[0] != saved {
+		t.Errorf("listed %+v", list)
+	}
+
+	// PUT replaces the whole search.
+	expect(t, serve(r, "PUT", "/searches/"+saved.ID, `{"name": "Acme"}`, ""), http.StatusBadRequest, nil)
+	expect(t, serve(r, "PUT", "/searches/"+saved.ID, `{"name": "Acme Corp", "query": "acme", "color": "#f00", "pinned": true}`, ""), http.StatusOK, &saved)
+	if saved.Name != "Acme Corp" || !saved.Pinned || saved.UserID != user.ID {
+		t.Errorf("replaced with %+v", saved)
+	}
+	var got SavedSearch
+	expect(t, serve(r, "GET", "/searches/"+saved.ID, "", ""), http.StatusOK, &got)
+	if got != saved {
+		t.Errorf("got %+v, want %+v", got, saved)
+	}
+
+	expect(t, serve(r, "DELETE", "/searches/"+saved.ID, "", ""), http.StatusNoContent, nil)
+	expect(t, serve(r, "GET", "/searches/"+saved.ID, "", ""), http.StatusNotFound, nil)
+	expect(t, serve(r, "PUT", "/searches/"+saved.ID, `{"name": "Acme", "query": "acme", "color": "", "pinned": false}`, ""), http.StatusNotFound, nil)
+	expect(t, serve(r, "DELETE", "/searches/"+saved.ID, "", ""), http.StatusNotFound, nil)
+}
+
+func TestSmartCalendars(t *testing.T) {
+	r, user := newSearchRouter(t)
+	var work, private Calendar
+	expect(t, serve(r, "POST", "/calendars", `{"name": "Work", "visible": true}`, ""), http.StatusCreated, &work)
+	expect(t, serve(r, "POST", "/calendars", `{"name": "Private"}`, ""), http.StatusCreated, &private)
+	var acme, other, hidden CalendarEvent
+	for _, e := range []struct {
+		event *CalendarEvent
+		body  string
+	}{
+		{&acme, `{"title": "Acme kickoff", "calendarId": "` + work.ID + `"`},
+		{&other, `{"title": "Standup", "calendarId": "` + work.ID + `"`},
+		{&hidden, `{"title": "Dinner with Acme", "calendarId": "` + private.ID + `"`},
+	} {
+		expect(t, serve(r, "POST", "/events", e.body+`, "startTime": "9:00", "endTime": "10:00", "day": 1}`, ""), http.StatusCreated, e.event)
+	}
+
+	var pinned, unpinned SavedSearch
+	expect(t, serve(r, "POST", "/user/"+user.ID+"/searches", `{"name": "Acme", "query": "acme", "color": "#f00", "pinned": true}`, ""), http.StatusCreated, &pinned)
+	expect(t, serve(r, "POST", "/user/"+user.ID+"/searches", `{"name": "Standups", "query": "standup"}`, ""), http.StatusCreated, &unpinned)
+
+	// Only pinned searches are calendars.
+	var calendars []Calendar
+	expect(t, serve(r, "GET", "/calendars", "", ""), http.StatusOK, &calendars)
+	if len(calendars) != 3 {
+		t.Fatalf("calendars are %+v", calendars)
+	}
+	smart := calendars[2]
+	if smart.ID != "search-"+pinned.ID || smart.Name != "Acme" || smart.Query != "acme" || !smart.Visible {
+		t.Errorf("smart calendar is %+v", smart)
+	}
+
+	// Its events match the search, in hidden calendars too, and mix with
+	// those of real calendars.
+	var events []CalendarEvent
+	expect(t, serve(r, "GET", "/events?calendarIds[]="+smart.ID, "", ""), http.StatusOK, &events)
+	if len(events) != 2 || events[0].ID != acme.ID || events[1].ID != hidden.ID {
+		t.Errorf("smart calendar events are %+v", events)
+	}
+	expect(t, serve(r, "GET", "/events?calendarIds[]="+smart.ID+"&calendarIds[]="+work.ID, "", ""), http.StatusOK, &events)
+	if len(events) != 3 || events[0].ID != acme.ID || events[1].ID != other.ID || events[2].ID != hidden.ID {
+		t.Errorf("work and smart calendar events are %+v", events)
+	}
+	expect(t, serve(r, "GET", "/events?calendarIds[]=search-"+unpinned.ID+"&calendarIds[]=search-99", "", ""), http.StatusOK, &events)
+	if len(events) != 1 || events[0].ID != other.ID {
+		t.Errorf("unpinned search events are %+v", events)
+	}
+
+	// A user's calendars hold that user's smart calendars.
+	expect(t, serve(r, "GET", "/user/"+user.ID+"/calendar", "", ""), http.StatusOK, &calendars)
+	if n := len(calendars); n == 0 || calendars[n-1].ID != smart.ID {
+		t.Errorf("user's calendars are %+v", calendars)
+	}
+}
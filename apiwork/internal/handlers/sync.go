@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Aman221/4723/internal/store"
+)
+
+// Clients keep a replica of the calendars and events with GET /sync. The
+// first sync, without ?token=, returns everything; each later one passes
+// the syncToken of the one before and gets what was created, changed or
+// deleted since. Deleted ids include calendars and events moved to the
+// trash, and a restore comes back as a change. A token older than
+// SyncTokenMaxAge, or one the database no longer knows, gets 410 Gone:
+// the client drops its replica and syncs again without a token.
+
+// SyncTokenMaxAge is how long a sync token stays good. 0 keeps tokens good
+// forever.
+var SyncTokenMaxAge time.Duration
+
+// A syncToken is the store's position in the history and when the token
+// was handed out.
+type syncToken struct {
+	position string
+	issued   time.Time
+}
+
+func (t syncToken) String() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(t.issued.Unix(), 10) + ":" + t.position))
+}
+
+func readSyncToken(raw string) (syncToken, error) {
+	errBad := errors.New("sync token is not one this server handed out")
+	decoded, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return syncToken{}, errBad
+	}
+	issued, position, ok := strings.Cut(string(decoded), ":")
+	seconds, err := strconv.ParseInt(issued, 10, 64)
+	if !ok || err != nil || position == "" {
+		return syncToken{}, errBad
+	}
+	return syncToken{position: position, issued: time.Unix(seconds, 0)}, nil
+}
+
+// SyncHandler returns the changes since ?token=, or everything without
+// one, with the token for the next sync.
+func SyncHandler(w http.ResponseWriter, r *http.Request) {
+	var since string
+	if raw := r.URL.Query().Get("token"); raw != "" {
+		token, err := readSyncToken(raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid sync token: "+err.Error())
+			return
+		}
+		if SyncTokenMaxAge > 0 && time.Since(token.issued) > SyncTokenMaxAge {
+			writeError(w, http.StatusGone, "Sync token expired; sync again without a token")
+			return
+		}
+		since = token.position
+	}
+
+	delta, err := Store.Sync(r.Context(), since)
+	if errors.Is(err, store.ErrSyncReset) {
+		writeError(w, http.StatusGone, "Sync token is no longer valid; sync again without a token")
+		return
+	}
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	delta.SyncToken = syncToken{position: delta.SyncToken, issued: time.Now()}.String()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(delta)
+}
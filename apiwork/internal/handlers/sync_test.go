@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Aman221/4723/internal/models"
+)
+
+func TestSync(t *testing.T) {
+	r := newTestRouter()
+	r.HandleFunc("/sync", SyncHandler).Methods("GET")
+	var work, home Calendar
+	expect(t, serve(r, "POST", "/calendars", `{"name": "Work"}`, ""), http.StatusCreated, &work)
+	expect(t, serve(r, "POST", "/calendars", `{"name": "Home"}`, ""), http.StatusCreated, &home)
+	var standup, retro CalendarEvent
+	expect(t, serve(r, "POST", "/events", `{"title": "Standup", "startTime": "9:00", "endTime": "9:15",
+		"day": 1, "calendarId": "`+work.ID+`"}`, ""), http.StatusCreated, &standup)
+	expect(t, serve(r, "POST", "/events", `{"title": "Retro", "startTime": "9:00", "endTime": "9:15",
+		"day": 5, "calendarId": "`+work.ID+`"}`, ""), http.StatusCreated, &retro)
+
+	var full models.SyncDelta
+	expect(t, serve(r, "GET", "/sync", "", ""), http.StatusOK, &full)
+	if !full.Full || len(full.Calendars) != 2 || len(full.Events) != 2 || full.SyncToken == "" {
+		t.Fatalf("first sync is %+v", full)
+	}
+
+	// Nothing changed yet.
+	var delta models.SyncDelta
+	expect(t, serve(r, "GET", "/sync?token="+url.QueryEscape(full.SyncToken), "", ""), http.StatusOK, &delta)
+	if delta.Full || len(delta.Calendars)+len(delta.Events)+len(delta.DeletedCalendars)+len(delta.DeletedEvents) != 0 {
+		t.Errorf("sync without changes is %+v", delta)
+	}
+
+	expect(t, serve(r, "PATCH", "/calendars/"+home.ID, `{"name": "House"}`, `"1"`), http.StatusOK, nil)
+	expect(t, serve(r, "PATCH", "/events/"+standup.ID, `{"title": "Daily standup"}`, `"1"`), http.StatusOK, nil)
+	expect(t, serve(r, "DELETE", "/events/"+retro.ID, "", `"1"`), http.StatusNoContent, nil)
+	expect(t, serve(r, "GET", "/sync?token="+url.QueryEscape(full.SyncToken), "", ""), http.StatusOK, &delta)
+	if delta.Full || len(delta.Calendars) != 1 || delta.Calendars[0].Name != "House" ||
+		len(delta.Events) != 1 || delta.Events[0].Title != "Daily standup" ||
+		len(delta.DeletedEvents) != 1 || delta.DeletedEvents[0] != retro.ID || len(delta.DeletedCalendars) != 0 {
+		t.Errorf("delta is %+v", delta)
+	}
+
+	// A restore comes back as a change.
+	r.HandleFunc("/events/{eventId}/restore", RestoreEventHandler).Methods("POST")
+	expect(t, serve(r, "POST", "/events/"+retro.ID+"/restore", "", ""), http.StatusOK, nil)
+	expect(t, serve(r, "GET", "/sync?token="+url.QueryEscape(delta.SyncToken), "", ""), http.StatusOK, &delta)
+	if len(delta.Events) != 1 || delta.Events[0].ID != retro.ID || len(delta.DeletedEvents) != 0 {
+		t.Errorf("delta after the restore is %+v", delta)
+	}
+}
+
+func TestSyncTokens(t *testing.T) {
+	r := newTestRouter()
+	r.HandleFunc("/sync", SyncHandler).Methods("GET")
+	defer func(age time.Duration) { SyncTokenMaxAge = age }(SyncTokenMaxAge)
+	SyncTokenMaxAge = time.Hour
+
+	token := syncToken{position: "0", issued: time.Now().Add(-time.Minute).Truncate(time.Second)}
+	if got, err := readSyncToken(token.String()); err != nil || got != token {
+		t.Errorf("read back %+v, %v", got, err)
+	}
+	expect(t, serve(r, "GET", "/sync?token="+token.String(), "", ""), http.StatusOK, nil)
+
+	var resp errorResponse
+	for _, raw := range []string{"!!", "MTIz", syncToken{position: "", issued: time.Now()}.String()} {
+		expect(t, serve(r, "GET", "/sync?token="+raw, "", ""), http.StatusBadRequest, &resp)
+	}
+	// A position past the end of the history, as after a restore from a
+	// backup, and an expired token both start the client over.
+	reset := syncToken{position: "99", issued: time.Now()}
+	expect(t, serve(r, "GET", "/sync?token="+reset.String(), "", ""), http.StatusGone, &resp)
+	if resp.Error.Code != "gone" {
+		t.Errorf("reset answered with %+v", resp.Error)
+	}
+	expired := syncToken{position: "0", issued: time.Now().Add(-2 * time.Hour)}
+	expect(t, serve(r, "GET", "/sync?token="+expired.String(), "", ""), http.StatusGone, nil)
+}
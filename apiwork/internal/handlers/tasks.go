@@ -0,0 +1,389 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/Aman221/4723/internal/database"
+	"github.com/Aman221/4723/internal/itip"
+)
+
+// Task statuses, matching the iCalendar VTODO STATUS values
+const (
+	TaskNeedsAction = "NEEDS-ACTION"
+	TaskInProcess   = "IN-PROCESS"
+	TaskCompleted   = "COMPLETED"
+	TaskCancelled   = "CANCELLED"
+)
+
+// EventLocation is the timezone event and task dates are interpreted in.
+var EventLocation = time.Local
+
+type Task struct {
+	ID              string     `json:"id"`
+	CalendarID      string     `json:"calendarId"`
+	Title           string     `json:"title"`
+	Description     string     `json:"description"`
+	DueDate         *string    `json:"dueDate,omitempty"` // YYYY-MM-DD
+	DueTime         *string    `json:"dueTime,omitempty"` // HH:MM, optional
+	Priority        int        `json:"priority"`          // 0 = undefined, 1 = highest ... 9 = lowest
+	Status          string     `json:"status"`
+	PercentComplete int        `json:"percentComplete"`
+	Recurrence      string     `json:"recurrence,omitempty"` // RRULE, e.g. FREQ=WEEKLY;INTERVAL=2
+	CompletedAt     *time.Time `json:"completedAt,omitempty"`
+	UID             string     `json:"uid,omitempty"`
+}
+
+const taskColumns = "id, calendar_id, title, description, due_date, due_time, priority, status, percent_complete, recurrence, completed_at, uid"
+
+// liveTask leaves out tasks whose calendar is in the trash.
+const liveTask = "calendar_id IN (SELECT id FROM calendars WHERE deleted_at IS NULL)"
+
+func scanTask(scanner interface{ Scan(...interface{}) error }) (Task, error) {
+	var t Task
+	var dueDate, dueTime, uid sql.NullString
+	var completedAt sql.NullTime
+	err := scanner.Scan(&t.ID, &t.CalendarID, &t.Title, &t.Description, &dueDate, &dueTime, &t.Priority, &t.Status,
+		&t.PercentComplete, &t.Recurrence, &completedAt, &uid)
+	if err != nil {
+		return t, err
+	}
+	if dueDate.Valid {
+		t.DueDate = &dueDate.String
+	}
+	if dueTime.Valid {
+		t.DueTime = &dueTime.String
+	}
+	if completedAt.Valid {
+		t.CompletedAt = &completedAt.Time
+	}
+	t.UID = uid.String
+	return t, nil
+}
+
+func writeTasks(w http.ResponseWriter, rows *sql.Rows) {
+	defer rows.Close()
+	tasks := []Task{}
+	for rows.Next() {
+		t, err := scanTask(rows)
+		if err != nil {
+			serverError(w, err)
+			return
+		}
+		tasks = append(tasks, t)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tasks)
+}
+
+// validate normalises a task and checks its fields.
+func (t *Task) validate() error {
+	t.Title = strings.TrimSpace(t.Title)
+	if t.Title == "" {
+		return errors.New("title is required")
+	}
+	if t.CalendarID == "" {
+		return errors.New("calendarId is required")
+	}
+	if t.Status == "" {
+		t.Status = TaskNeedsAction
+	}
+	t.Status = strings.ToUpper(t.Status)
+	switch t.Status {
+	case TaskNeedsAction, TaskInProcess, TaskCompleted, TaskCancelled:
+	default:
+		return fmt.Errorf("invalid status %q", t.Status)
+	}
+	if t.Priority < 0 || t.Priority > 9 {
+		return errors.New("priority must be between 0 and 9")
+	}
+	if t.PercentComplete < 0 || t.PercentComplete > 100 {
+		return errors.New("percentComplete must be between 0 and 100")
+	}
+	if t.DueDate != nil && *t.DueDate == "" {
+		t.DueDate = nil
+	}
+	if t.DueDate != nil {
+		if _, err := time.Parse("2006-01-02", *t.DueDate); err != nil {
+			return errors.New("dueDate must be formatted as YYYY-MM-DD")
+		}
+	}
+	if t.DueTime != nil && *t.DueTime == "" {
+		t.DueTime = nil
+	}
+	if t.DueTime != nil {
+		if t.DueDate == nil {
+			return errors.New("dueTime requires dueDate")
+		}
+		if _, err := time.Parse("15:04", *t.DueTime); err != nil {
+			return errors.New("dueTime must be formatted as HH:MM")
+		}
+	}
+	t.Recurrence = strings.TrimPrefix(strings.TrimSpace(t.Recurrence), "RRULE:")
+	if t.Recurrence != "" {
+		if t.DueDate == nil {
+			return errors.New("recurring tasks need a dueDate")
+		}
+		if _, err := nextOccurrence(t.Recurrence, *t.DueDate); err != nil {
+			return err
+		}
+	}
+	if t.Status == TaskCompleted {
+		t.PercentComplete = 100
+	}
+	return nil
+}
+
+// nextOccurrence applies a recurrence rule to a date. Only FREQ, INTERVAL
+// and UNTIL are supported. It returns "" once the rule has run out.
+func nextOccurrence(rule, date string) (string, error) {
+	d, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return "", err
+	}
+	freq, interval, until := "", 1, ""
+	for _, part := range strings.Split(rule, ";") {
+		key, value, _ := strings.Cut(part, "=")
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			freq = strings.ToUpper(value)
+		case "INTERVAL":
+			interval, err = strconv.Atoi(value)
+			if err != nil || interval < 1 {
+				return "", fmt.Errorf("invalid recurrence INTERVAL %q", value)
+			}
+		case "UNTIL":
+			if len(value) < 8 {
+				return "", fmt.Errorf("invalid recurrence UNTIL %q", value)
+			}
+			u, err := time.Parse("20060102", value[:8])
+			if err != nil {
+				return "", fmt.Errorf("invalid recurrence UNTIL %q", value)
+			}
+			until = u.Format("2006-01-02")
+		default:
+			return "", fmt.Errorf("unsupported recurrence part %q", key)
+		}
+	}
+	switch freq {
+	case "DAILY":
+		d = d.AddDate(0, 0, interval)
+	case "WEEKLY":
+		d = d.AddDate(0, 0, 7*interval)
+	case "MONTHLY":
+		d = d.AddDate(0, interval, 0)
+	case "YEARLY":
+		d = d.AddDate(interval, 0, 0)
+	default:
+		return "", fmt.Errorf("recurrence FREQ must be DAILY, WEEKLY, MONTHLY or YEARLY")
+	}
+	next := d.Format("2006-01-02")
+	if until != "" && next > until {
+		return "", nil
+	}
+	return next, nil
+}
+
+// GetTasksHandler handles requests to get tasks filtered by calendar IDs
+func GetTasksHandler(w http.ResponseWriter, r *http.Request) {
+	calendarIDs := r.URL.Query()["calendarIds[]"]
+	if len(calendarIDs) == 0 {
+		writeError(w, http.StatusBadRequest, "calendarIds parameter is required")
+		return
+	}
+
+	placeholders := make([]string, len(calendarIDs))
+	args := make([]interface{}, len(calendarIDs))
+	for i, id := range calendarIDs {
+		placeholders[i] = "$" + strconv.Itoa(i+1)
+		args[i] = id
+	}
+	rows, err := database.DB.Query("SELECT "+taskColumns+" FROM tasks WHERE calendar_id IN ("+strings.Join(placeholders, ",")+") AND "+liveTask+" ORDER BY due_date NULLS LAST, due_time NULLS LAST, priority, id", args...)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	writeTasks(w, rows)
+}
+
+// GetOverdueTasksHandler lists open tasks whose due date has passed, optionally
+// restricted to some calendars with calendarIds[]
+func GetOverdueTasksHandler(w http.ResponseWriter, r *http.Request) {
+	now := time.Now().In(EventLocation)
+	args := []interface{}{now.Format("2006-01-02"), now.Format("15:04"), TaskCompleted, TaskCancelled}
+	query := "SELECT " + taskColumns + ` FROM tasks
+		WHERE status NOT IN ($3, $4) AND ` + liveTask + `
+		  AND (due_date < $1 OR (due_date = $1 AND due_time IS NOT NULL AND due_time < $2))`
+	if calendarIDs := r.URL.Query()["calendarIds[]"]; len(calendarIDs) > 0 {
+		placeholders := make([]string, len(calendarIDs))
+		for i, id := range calendarIDs {
+			args = append(args, id)
+			placeholders[i] = "$" + strconv.Itoa(len(args))
+		}
+		query += " AND calendar_id IN (" + strings.Join(placeholders, ",") + ")"
+	}
+	query += " ORDER BY due_date, due_time NULLS FIRST, priority, id"
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	writeTasks(w, rows)
+}
+
+// SearchTasksHandler handles requests to search tasks, like SearchEventsHandler does for events
+func SearchTasksHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("query")
+	if query == "" {
+		writeError(w, http.StatusBadRequest, "query parameter is required")
+		return
+	}
+	searchQuery := "%" + query + "%"
+
+	var rows *sql.Rows
+	var err error
+	if r.URL.Query().Get("includeHidden") == "true" {
+		rows, err = database.DB.Query("SELECT "+taskColumns+" FROM tasks WHERE (title LIKE $1 OR description LIKE $1) AND "+liveTask+" ORDER BY due_date NULLS LAST, id", searchQuery)
+	} else {
+		rows, err = database.DB.Query(`
+			SELECT t.id, t.calendar_id, t.title, t.description, t.due_date, t.due_time, t.priority, t.status, t.percent_complete, t.recurrence, t.completed_at, t.uid
+			FROM tasks t
+			JOIN calendars c ON t.calendar_id = c.id
+			WHERE (t.title LIKE $1 OR t.description LIKE $1) AND c.visible = TRUE AND c.deleted_at IS NULL
+			ORDER BY t.due_date NULLS LAST, t.id
+		`, searchQuery)
+	}
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	writeTasks(w, rows)
+}
+
+// GetTaskHandler handles requests to get a single task
+func GetTaskHandler(w http.ResponseWriter, r *http.Request) {
+	taskID := mux.Vars(r)["taskId"]
+	if _, err := strconv.Atoi(taskID); err != nil {
+		writeError(w, http.StatusNotFound, "Task not found")
+		return
+	}
+	task, err := scanTask(database.DB.QueryRow("SELECT "+taskColumns+" FROM tasks WHERE id = $1 AND "+liveTask, taskID))
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "Task not found")
+		return
+	}
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(task)
+}
+
+// AddTaskHandler handles requests to add a new task
+func AddTaskHandler(w http.ResponseWriter, r *http.Request) {
+	var newTask Task
+	if err := json.NewDecoder(r.Body).Decode(&newTask); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	defer r.Body.Close()
+
+	if err := newTask.validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	created, err := insertTask(newTask)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", "/tasks/"+created.ID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+func insertTask(t Task) (Task, error) {
+	if t.UID == "" {
+		t.UID = itip.NewUID()
+	}
+	return scanTask(database.DB.QueryRow(`
+		INSERT INTO tasks (calendar_id, title, description, due_date, due_time, priority, status, percent_complete, recurrence, completed_at, uid)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, CASE WHEN $7 = 'COMPLETED' THEN NOW() END, $10)
+		RETURNING `+taskColumns,
+		t.CalendarID, t.Title, t.Description, t.DueDate, t.DueTime, t.Priority, t.Status, t.PercentComplete, t.Recurrence, t.UID))
+}
+
+// UpdateTaskHandler handles requests to update a task. Completing a recurring
+// task moves it to its next due date instead of closing it.
+func UpdateTaskHandler(w http.ResponseWriter, r *http.Request) {
+	taskID := mux.Vars(r)["taskId"]
+
+	var updatedTask Task
+	if err := json.NewDecoder(r.Body).Decode(&updatedTask); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	defer r.Body.Close()
+
+	if err := updatedTask.validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if updatedTask.Status == TaskCompleted && updatedTask.Recurrence != "" {
+		next, err := nextOccurrence(updatedTask.Recurrence, *updatedTask.DueDate)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if next != "" {
+			updatedTask.DueDate = &next
+			updatedTask.Status = TaskNeedsAction
+			updatedTask.PercentComplete = 0
+		}
+	}
+
+	saved, err := scanTask(database.DB.QueryRow(`
+		UPDATE tasks
+		SET calendar_id = $1, title = $2, description = $3, due_date = $4, due_time = $5, priority = $6, status = $7,
+		    percent_complete = $8, recurrence = $9,
+		    completed_at = CASE WHEN $7 = 'COMPLETED' THEN COALESCE(completed_at, NOW()) END
+		WHERE id = $10
+		RETURNING `+taskColumns,
+		updatedTask.CalendarID, updatedTask.Title, updatedTask.Description, updatedTask.DueDate, updatedTask.DueTime,
+		updatedTask.Priority, updatedTask.Status, updatedTask.PercentComplete, updatedTask.Recurrence, taskID))
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "Task not found")
+		return
+	}
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(saved)
+}
+
+// DeleteTaskHandler handles requests to delete a task
+func DeleteTaskHandler(w http.ResponseWriter, r *http.Request) {
+	_, err := database.DB.Exec("DELETE FROM tasks WHERE id = $1", mux.Vars(r)["taskId"])
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNextOccurrence(t *testing.T) {
+	tests := []struct {
+		rule, date, want string
+	}{
+		{"FREQ=DAILY", "2026-01-07", "2026-01-08"},
+		{"FREQ=WEEKLY;INTERVAL=2", "2026-01-07", "2026-01-21"},
+		{"freq=monthly", "2026-01-07", "2026-02-07"},
+		{"FREQ=YEARLY", "2028-02-28", "2029-02-28"},
+		{"FREQ=WEEKLY;UNTIL=20260114", "2026-01-07", "2026-01-14"},
+		{"FREQ=WEEKLY;UNTIL=20260113T235959Z", "2026-01-07", ""},
+	}
+	for _, tt := range tests {
+		got, err := nextOccurrence(tt.rule, tt.date)
+		if err != nil || got != tt.want {
+			t.Errorf("nextOccurrence(%q, %q) = %q, %v, want %q", tt.rule, tt.date, got, err, tt.want)
+		}
+	}
+
+	for _, rule := range []string{"", "FREQ=HOURLY", "FREQ=DAILY;INTERVAL=0", "FREQ=DAILY;UNTIL=2026", "FREQ=DAILY;BYDAY=MO"} {
+		if _, err := nextOccurrence(rule, "2026-01-07"); err == nil {
+			t.Errorf("nextOccurrence accepted %q", rule)
+		}
+	}
+}
+
+func TestTaskValidate(t *testing.T) {
+	date, clock, empty := "2026-01-07", "17:00", ""
+	task := Task{CalendarID: "1", Title: " Taxes ", Status: "completed", DueDate: &date, DueTime: &clock, Recurrence: "RRULE:FREQ=YEARLY"}
+	if err := task.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if task.Title != "Taxes" || task.Status != TaskCompleted || task.PercentComplete != 100 || task.Recurrence != "FREQ=YEARLY" {
+		t.Errorf("normalized to %+v", task)
+	}
+
+	task = Task{CalendarID: "1", Title: "Taxes", DueDate: &empty, DueTime: &empty}
+	if err := task.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if task.Status != TaskNeedsAction || task.DueDate != nil || task.DueTime != nil {
+		t.Errorf("normalized to %+v", task)
+	}
+
+	bad, late := "2026-13-01", "25:00"
+	tests := []struct {
+		task Task
+		want string
+	}{
+		{Task{CalendarID: "1"}, "title is required"},
+		{Task{Title: "Taxes"}, "calendarId is required"},
+		{Task{CalendarID: "1", Title: "Taxes", Status: "DONE"}, "invalid status"},
+		{Task{CalendarID: "1", Title: "Taxes", Priority: 10}, "priority"},
+		{Task{CalendarID: "1", Title: "Taxes", PercentComplete: 101}, "percentComplete"},
+		{Task{CalendarID: "1", Title: "Taxes", DueDate: &bad}, "dueDate"},
+		{Task{CalendarID: "1", Title: "Taxes", DueTime: &clock}, "dueTime requires dueDate"},
+		{Task{CalendarID: "1", Title: "Taxes", DueDate: &date, DueTime: &late}, "dueTime must be"},
+		{Task{CalendarID: "1", Title: "Taxes", Recurrence: "FREQ=DAILY"}, "need a dueDate"},
+		{Task{CalendarID: "1", Title: "Taxes", DueDate: &date, Recurrence: "FREQ=SOMETIMES"}, "FREQ"},
+	}
+	for _, tt := range tests {
+		if err := tt.task.validate(); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%+v: got %v, want an error with %q", tt.task, err, tt.want)
+		}
+	}
+}
+
+func TestVTODO(t *testing.T) {
+	defer func(loc *time.Location) { EventLocation = loc }(EventLocation)
+	EventLocation = time.FixedZone("CET", 3600)
+
+	date, clock := "2026-01-07", "17:00"
+	c := vtodo(Task{UID: "1@calendar.local", Title: "Taxes", DueDate: &date, DueTime: &clock, Priority: 1, Status: TaskNeedsAction, Recurrence: "FREQ=YEARLY"})
+	for name, want := range map[string]string{
+		"UID": "1@calendar.local", "SUMMARY": "Taxes", "DUE": "20260107T160000Z", "PRIORITY": "1",
+		"STATUS": TaskNeedsAction, "PERCENT-COMPLETE": "0", "RRULE": "FREQ=YEARLY",
+	} {
+		if got := c.Text(name); got != want {
+			t.Errorf("%s is %q, want %q", name, got, want)
+		}
+	}
+
+	c = vtodo(Task{Title: "Taxes", DueDate: &date, Status: TaskNeedsAction})
+	if due, _ := c.Get("DUE"); due.Value != "20260107" || due.Params["VALUE"] != "DATE" {
+		t.Errorf("DUE of a task without a time is %+v", due)
+	}
+	for _, name := range []string{"PRIORITY", "RRULE", "COMPLETED", "DESCRIPTION"} {
+		if _, ok := c.Get(name); ok {
+			t.Errorf("task has %s", name)
+		}
+	}
+}
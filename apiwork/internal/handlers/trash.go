@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/Aman221/4723/internal/store"
+)
+
+// Deleted calendars and events go to the trash, where they can be restored
+// until the retention period (config TrashRetention) runs out and they are
+// purged. Deleting a calendar trashes its events with it; restoring the
+// calendar brings back those events but not ones deleted on their own.
+// Events keep their reminder settings in the trash; only the reminders
+// they were due to send are dropped.
+
+// GetTrashHandler lists the calendars and events in the trash, most
+// recently deleted first.
+func GetTrashHandler(w http.ResponseWriter, r *http.Request) {
+	trash, err := Store.ListTrash(r.Context())
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(trash)
+}
+
+// RestoreCalendarHandler takes a calendar out of the trash.
+func RestoreCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	cal, err := Store.RestoreCalendar(r.Context(), mux.Vars(r)["id"])
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "Calendar not found in the trash")
+		return
+	}
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	// The calendar's events were all in the trash, so the ones it has now
+	// came back with it.
+	events, err := calendarEvents(r.Context(), Store, cal.ID)
+	if err != nil {
+		log.Printf("Error listing the events restored with calendar %s: %v", cal.ID, err)
+	}
+	for _, event := range events {
+		eventSaved(event.ID, eventFields(event), true)
+	}
+	writeVersioned(w, http.StatusOK, cal.Version, cal)
+}
+
+// RestoreEventHandler takes an event out of the trash. An event whose
+// calendar is in the trash can only come back with its calendar.
+func RestoreEventHandler(w http.ResponseWriter, r *http.Request) {
+	event, err := Store.RestoreEvent(r.Context(), mux.Vars(r)["eventId"])
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "Event not found in the trash")
+		return
+	}
+	if errors.Is(err, store.ErrCalendarDeleted) {
+		writeError(w, http.StatusConflict, "The event's calendar is in the trash; restore the calendar first")
+		return
+	}
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	eventSaved(event.ID, eventFields(event), true)
+	writeVersioned(w, http.StatusOK, event.Version, event)
+}
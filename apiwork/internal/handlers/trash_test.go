@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/Aman221/4723/internal/models"
+)
+
+func TestTrashAndRestore(t *testing.T) {
+	r := newTestRouter()
+	var cal Calendar
+	expect(t, serve(r, "POST", "/calendars", `{"name": "Work"}`, ""), http.StatusCreated, &cal)
+	var standup, retro CalendarEvent
+	expect(t, serve(r, "POST", "/events", `{"title": "Standup", "startTime": "9:00", "endTime": "9:15",
+		"day": 1, "calendarId": "`+cal.ID+`"}`, ""), http.StatusCreated, &standup)
+	expect(t, serve(r, "POST", "/events", `{"title": "Retro", "startTime": "14:00", "endTime": "15:00",
+		"day": 5, "calendarId": "`+cal.ID+`"}`, ""), http.StatusCreated, &retro)
+
+	// A delete based on an old version leaves the event where it is.
+	expect(t, serve(r, "PATCH", "/events/"+retro.ID, `{"endTime": "16:00"}`, `"1"`), http.StatusOK, nil)
+	expect(t, serve(r, "DELETE", "/events/"+retro.ID, "", `"1"`), http.StatusPreconditionFailed, nil)
+	expect(t, serve(r, "GET", "/events/"+retro.ID, "", ""), http.StatusOK, nil)
+
+	expect(t, serve(r, "DELETE", "/events/"+standup.ID, "", `"1"`), http.StatusNoContent, nil)
+	var trash models.Trash
+	expect(t, serve(r, "GET", "/trash", "", ""), http.StatusOK, &trash)
+	if len(trash.Calendars) != 0 || len(trash.Events) != 1 || trash.Events[0].ID != standup.ID || trash.Events[0].DeletedAt == nil {
+		t.Fatalf("trash holds %+v", trash)
+	}
+
+	// The calendar takes its remaining events with it.
+	expect(t, serve(r, "DELETE", "/calendars/"+cal.ID, "", `"1"`), http.StatusNoContent, nil)
+	expect(t, serve(r, "GET", "/events/"+retro.ID, "", ""), http.StatusNotFound, nil)
+	expect(t, serve(r, "GET", "/trash", "", ""), http.StatusOK, &trash)
+	if len(trash.Calendars) != 1 || len(trash.Events) != 2 {
+		t.Fatalf("trash holds %+v", trash)
+	}
+	expect(t, serve(r, "POST", "/events/"+standup.ID+"/restore", "", ""), http.StatusConflict, nil)
+
+	// Restoring the calendar brings back what was deleted with it only.
+	var restored Calendar
+	expect(t, serve(r, "POST", "/calendars/"+cal.ID+"/restore", "", ""), http.StatusOK, &restored)
+	if restored.DeletedAt != nil || restored.Version != 3 {
+		t.Errorf("restored %+v", restored)
+	}
+	var list []CalendarEvent
+	expect(t, serve(r, "GET", "/events?calendarIds[]="+cal.ID, "", ""), http.StatusOK, &list)
+	if len(list) != 1 || list[0].ID != retro.ID {
+		t.Errorf("after restoring the calendar: %+v", list)
+	}
+
+	var event CalendarEvent
+	expect(t, serve(r, "POST", "/events/"+standup.ID+"/restore", "", ""), http.StatusOK, &event)
+	if event.DeletedAt != nil || event.Title != "Standup" || event.Version != 3 {
+		t.Errorf("restored %+v", event)
+	}
+	expect(t, serve(r, "POST", "/events/"+standup.ID+"/restore", "", ""), http.StatusNotFound, nil)
+	expect(t, serve(r, "POST", "/calendars/none/restore", "", ""), http.StatusNotFound, nil)
+	expect(t, serve(r, "GET", "/trash", "", ""), http.StatusOK, &trash)
+	if len(trash.Calendars) != 0 || len(trash.Events) != 0 {
+		t.Errorf("trash still holds %+v", trash)
+	}
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Aman221/4723/internal/resources"
+	"github.com/Aman221/4723/internal/store"
+	"github.com/Aman221/4723/internal/validate"
+)
+
+// Calendars and events are checked against the validate tags of their
+// models, then against the rules that involve more than one field or the
+// database: an event ends after it starts, and its calendar exists, isn't
+// in the trash and isn't a smart calendar or the calendar of a resource. Every problem is reported at
+// once, so a client can fix them all before trying again. Times such as
+// "9:00" are stored as "09:00", so that they compare as strings.
+
+// validateNewEvent lists what is wrong with an event about to be created
+// in s, and normalizes its times.
+func validateNewEvent(ctx context.Context, s store.Store, event *NCalendarEvent) ([]fieldError, error) {
+	return checkEvent(ctx, s, validate.Struct(event), &event.StartTime, &event.EndTime, event.CalendarID)
+}
+
+// validateEvent lists what is wrong with a replaced or patched event about
+// to be stored in s, and normalizes its times.
+func validateEvent(ctx context.Context, s store.Store, event *CalendarEvent) ([]fieldError, error) {
+	return checkEvent(ctx, s, validate.Struct(event), &event.StartTime, &event.EndTime, event.CalendarID)
+}
+
+func checkEvent(ctx context.Context, s store.Store, problems []validate.Problem, start, end *string, calendarID string) ([]fieldError, error) {
+	bad := map[string]bool{}
+	for _, p := range problems {
+		bad[p.Field] = true
+	}
+	if !bad["startTime"] && !bad["endTime"] {
+		// The clock rule has passed both, so they normalize.
+		*start, _ = resources.NormalizeClock(*start)
+		*end, _ = resources.NormalizeClock(*end)
+		if *end <= *start {
+			problems = append(problems, validate.Problem{Field: "endTime", Message: "endTime must be after startTime"})
+		}
+	}
+	fields := fieldErrors(problems)
+	if !bad["calendarId"] {
+		problem, err := calendarProblem(ctx, s, calendarID)
+		if err != nil {
+			return nil, err
+		}
+		if problem != nil {
+			fields = append(fields, *problem)
+		}
+	}
+	return fields, nil
+}
+
+// calendarProblem says why events can't be stored in the calendar with id,
+// or returns nil if they can.
+func calendarProblem(ctx context.Context, s store.Store, id string) (*fieldError, error) {
+	if strings.HasPrefix(id, smartCalendarPrefix) {
+		return &fieldError{"calendarId", "calendarId is a smart calendar, which shows the results of a search and can't hold events"}, nil
+	}
+	_, err := s.GetCalendar(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		problem := unknownCalendar(id)
+		return &problem, nil
+	}
+	if err != nil || !postgresFeatures() {
+		return nil, err
+	}
+	res, err := resources.ByCalendar(id)
+	if errors.Is(err, resources.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &fieldError{"calendarId", fmt.Sprintf("calendarId is the calendar of the resource %q, which holds its bookings; invite the resource instead", res.Name)}, nil
+}
+
+// unknownCalendar is the problem with an event whose calendar doesn't
+// exist, which the store reports too if the calendar goes away after the
+// check above.
+func unknownCalendar(id string) fieldError {
+	return fieldError{"calendarId", fmt.Sprintf("calendarId %s is not a calendar, or it is in the trash", id)}
+}
+
+// validateNewCalendar lists what is wrong with a calendar about to be
+// created.
+func validateNewCalendar(cal NCalendar) []fieldError {
+	return fieldErrors(validate.Struct(cal))
+}
+
+// validateCalendar lists what is wrong with a replaced or patched calendar
+// about to be stored.
+func validateCalendar(cal Calendar) []fieldError {
+	return fieldErrors(validate.Struct(cal))
+}
+
+func fieldErrors(problems []validate.Problem) []fieldError {
+	var fields []fieldError
+	for _, p := range problems {
+		fields = append(fields, fieldError{p.Field, p.Message})
+	}
+	return fields
+}
@@ -0,0 +1,320 @@
+// Package ical reads and writes iCalendar (RFC 5545) data. It deals with
+// the generic structure only: components, properties, parameters, line
+// folding and text escaping. Meaning is given to it by callers.
+package ical
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Property is a single content line such as
+// ATTENDEE;PARTSTAT=ACCEPTED:mailto:alice@example.com
+type Property struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// Component is a BEGIN/END block, e.g. VCALENDAR, VEVENT or VTODO.
+type Component struct {
+	Name       string
+	Props      []Property
+	Components []*Component
+}
+
+// NewComponent returns an empty component.
+func NewComponent(name string) *Component {
+	return &Component{Name: name}
+}
+
+// Add appends a property with a raw (already escaped) value.
+func (c *Component) Add(name, value string, params map[string]string) {
+	c.Props = append(c.Props, Property{Name: strings.ToUpper(name), Params: params, Value: value})
+}
+
+// AddText appends a TEXT property, escaping the value.
+func (c *Component) AddText(name, value string) {
+	c.Add(name, EscapeText(value), nil)
+}
+
+// AddTime appends a DATE-TIME property in UTC.
+func (c *Component) AddTime(name string, t time.Time) {
+	c.Add(name, FormatTime(t), nil)
+}
+
+// Get returns the first property with the given name.
+func (c *Component) Get(name string) (Property, bool) {
+	name = strings.ToUpper(name)
+	for _, p := range c.Props {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Property{}, false
+}
+
+// Text returns the unescaped value of the first property with the name.
+func (c *Component) Text(name string) string {
+	p, ok := c.Get(name)
+	if !ok {
+		return ""
+	}
+	return UnescapeText(p.Value)
+}
+
+// All returns every property with the given name.
+func (c *Component) All(name string) []Property {
+	name = strings.ToUpper(name)
+	var out []Property
+	for _, p := range c.Props {
+		if p.Name == name {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// Children returns the direct sub-components with the given name.
+func (c *Component) Children(name string) []*Component {
+	name = strings.ToUpper(name)
+	var out []*Component
+	for _, child := range c.Components {
+		if child.Name == name {
+			out = append(out, child)
+		}
+	}
+	return out
+}
+
+// Encode writes the component in iCalendar form with CRLF line endings
+// and lines folded at 75 octets.
+func (c *Component) Encode(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	c.encode(bw)
+	return bw.Flush()
+}
+
+// Bytes returns the encoded component.
+func (c *Component) Bytes() []byte {
+	var buf bytes.Buffer
+	c.Encode(&buf)
+	return buf.Bytes()
+}
+
+func (c *Component) encode(w *bufio.Writer) {
+	writeLine(w, "BEGIN:"+c.Name)
+	for _, p := range c.Props {
+		var line strings.Builder
+		line.WriteString(p.Name)
+		keys := make([]string, 0, len(p.Params))
+		for k := range p.Params {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			line.WriteString(";" + strings.ToUpper(k) + "=" + quoteParam(p.Params[k]))
+		}
+		line.WriteString(":" + p.Value)
+		writeLine(w, line.String())
+	}
+	for _, child := range c.Components {
+		child.encode(w)
+	}
+	writeLine(w, "END:"+c.Name)
+}
+
+// writeLine folds a content line so no physical line exceeds 75 octets,
+// taking care not to split UTF-8 sequences.
+func writeLine(w *bufio.Writer, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		w.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		limit = 74 // the leading space counts
+	}
+	w.WriteString(line + "\r\n")
+}
+
+func quoteParam(v string) string {
+	if strings.ContainsAny(v, ":;,") {
+		return `"` + strings.ReplaceAll(v, `"`, "") + `"`
+	}
+	return v
+}
+
+// EscapeText escapes a TEXT value.
+func EscapeText(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+	return r.Replace(s)
+}
+
+// UnescapeText reverses EscapeText.
+func UnescapeText(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			switch s[i] {
+			case 'n', 'N':
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(s[i])
+			}
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// FormatTime formats t as a UTC DATE-TIME value.
+func FormatTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// ParseTime parses a DATE-TIME or DATE property. Floating times and dates
+// are interpreted in loc; a TZID parameter takes precedence when known.
+func ParseTime(p Property, loc *time.Location) (t time.Time, dateOnly bool, err error) {
+	if tzid := p.Params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+	v := p.Value
+	switch {
+	case strings.HasSuffix(v, "Z"):
+		t, err = time.Parse("20060102T150405Z", v)
+	case len(v) == 8:
+		t, err = time.ParseInLocation("20060102", v, loc)
+		dateOnly = true
+	default:
+		t, err = time.ParseInLocation("20060102T150405", v, loc)
+	}
+	return t, dateOnly, err
+}
+
+// Decode parses iCalendar data and returns its top-level component,
+// normally a VCALENDAR.
+func Decode(r io.Reader) (*Component, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var stack []*Component
+	var root *Component
+	for n, line := range lines {
+		if line == "" {
+			continue
+		}
+		p, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("ical: line %d: %w", n+1, err)
+		}
+		switch p.Name {
+		case "BEGIN":
+			c := NewComponent(strings.ToUpper(p.Value))
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.Components = append(parent.Components, c)
+			} else if root == nil {
+				root = c
+			}
+			stack = append(stack, c)
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1].Name != strings.ToUpper(p.Value) {
+				return nil, fmt.Errorf("ical: line %d: unexpected END:%s", n+1, p.Value)
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("ical: line %d: property outside of a component", n+1)
+			}
+			top := stack[len(stack)-1]
+			top.Props = append(top.Props, p)
+		}
+	}
+	if root == nil {
+		return nil, errors.New("ical: no component found")
+	}
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("ical: missing END:%s", stack[len(stack)-1].Name)
+	}
+	return root, nil
+}
+
+func unfold(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+// parseLine splits "NAME;PARAM=x;PARAM2="y:z":value" into its parts.
+func parseLine(line string) (Property, error) {
+	p := Property{}
+	i := 0
+	for i < len(line) && line[i] != ';' && line[i] != ':' {
+		i++
+	}
+	if i == len(line) {
+		return p, fmt.Errorf("missing ':' in %q", line)
+	}
+	p.Name = strings.ToUpper(line[:i])
+
+	for i < len(line) && line[i] == ';' {
+		i++
+		start := i
+		for i < len(line) && line[i] != '=' {
+			i++
+		}
+		if i == len(line) {
+			return p, fmt.Errorf("malformed parameter in %q", line)
+		}
+		key := strings.ToUpper(line[start:i])
+		i++
+		var val string
+		if i < len(line) && line[i] == '"' {
+			end := strings.IndexByte(line[i+1:], '"')
+			if end < 0 {
+				return p, fmt.Errorf("unterminated quote in %q", line)
+			}
+			val = line[i+1 : i+1+end]
+			i += end + 2
+		} else {
+			start := i
+			for i < len(line) && line[i] != ';' && line[i] != ':' {
+				i++
+			}
+			val = line[start:i]
+		}
+		if p.Params == nil {
+			p.Params = map[string]string{}
+		}
+		p.Params[key] = val
+	}
+	if i >= len(line) || line[i] != ':' {
+		return p, fmt.Errorf("missing ':' in %q", line)
+	}
+	p.Value = line[i+1:]
+	return p, nil
+}
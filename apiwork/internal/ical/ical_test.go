@@ -0,0 +1,135 @@
+package ical
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	cal := NewComponent("VCALENDAR")
+	cal.Add("VERSION", "2.0", nil)
+	ev := NewComponent("VEVENT")
+	ev.Add("UID", "1@example.com", nil)
+	ev.AddText("SUMMARY", "Lunch; then a walk, maybe\nor not")
+	ev.AddTime("DTSTART", time.Date(2026, 1, 7, 9, 30, 0, 0, time.FixedZone("CET", 3600)))
+	ev.Add("ATTENDEE", "mailto:bob@example.com", map[string]string{"CN": "Bob, Jr.", "PARTSTAT": "ACCEPTED"})
+	cal.Components = append(cal.Components, ev)
+
+	data := cal.Bytes()
+	for _, want := range []string{
+		`SUMMARY:Lunch\; then a walk\, maybe\nor not` + "\r\n",
+		"DTSTART:20260107T083000Z\r\n",
+		// Parameters are sorted and quoted when they hold a separator.
+		"ATTENDEE;CN=\"Bob, Jr.\";PARTSTAT=ACCEPTED:mailto:bob@example.com\r\n",
+	} {
+		if !bytes.Contains(data, []byte(want)) {
+			t.Errorf("encoded calendar lacks %q:\n%s", want, data)
+		}
+	}
+
+	got, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, cal) {
+		t.Errorf("decoded %+v, want %+v", got, cal)
+	}
+	events := got.Children("vevent")
+	if len(events) != 1 {
+		t.Fatalf("decoded %d events", len(events))
+	}
+	if s := events[0].Text("summary"); s != "Lunch; then a walk, maybe\nor not" {
+		t.Errorf("summary is %q", s)
+	}
+	if a, _ := events[0].Get("ATTENDEE"); a.Params["CN"] != "Bob, Jr." {
+		t.Errorf("attendee CN is %q", a.Params["CN"])
+	}
+}
+
+func TestFolding(t *testing.T) {
+	c := NewComponent("VEVENT")
+	long := strings.Repeat("é", 60) // 120 octets
+	c.AddText("DESCRIPTION", long)
+	data := c.Bytes()
+
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("line of %d octets: %q", len(line), line)
+		}
+		if !utf8.ValidString(strings.TrimPrefix(line, " ")) {
+			t.Errorf("line splits a UTF-8 sequence: %q", line)
+		}
+	}
+
+	got, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := got.Text("DESCRIPTION"); d != long {
+		t.Errorf("unfolded to %q", d)
+	}
+}
+
+func TestUnescapeText(t *testing.T) {
+	tests := map[string]string{
+		`a\,b\;c`:   "a,b;c",
+		`one\Ntwo`:  "one\ntwo",
+		`back\\`:    `back\`,
+		`trailing\`: `trailing\`,
+	}
+	for in, want := range tests {
+		if got := UnescapeText(in); got != want {
+			t.Errorf("UnescapeText(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip(err)
+	}
+	tests := []struct {
+		p        Property
+		want     time.Time
+		dateOnly bool
+	}{
+		{Property{Value: "20260107T090000Z"}, time.Date(2026, 1, 7, 9, 0, 0, 0, time.UTC), false},
+		{Property{Value: "20260107T090000"}, time.Date(2026, 1, 7, 9, 0, 0, 0, time.UTC), false},
+		{Property{Value: "20260107"}, time.Date(2026, 1, 7, 0, 0, 0, 0, time.UTC), true},
+		{Property{Value: "20260107T090000", Params: map[string]string{"TZID": "Europe/Berlin"}}, time.Date(2026, 1, 7, 9, 0, 0, 0, berlin), false},
+		// An unknown TZID falls back to loc.
+		{Property{Value: "20260107T090000", Params: map[string]string{"TZID": "Nowhere/Special"}}, time.Date(2026, 1, 7, 9, 0, 0, 0, time.UTC), false},
+	}
+	for _, tt := range tests {
+		got, dateOnly, err := ParseTime(tt.p, time.UTC)
+		if err != nil || !got.Equal(tt.want) || dateOnly != tt.dateOnly {
+			t.Errorf("ParseTime(%+v) = %v, %v, %v, want %v, %v", tt.p, got, dateOnly, err, tt.want, tt.dateOnly)
+		}
+	}
+	if _, _, err := ParseTime(Property{Value: "tomorrow"}, time.UTC); err == nil {
+		t.Error("ParseTime accepted tomorrow")
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := map[string]string{
+		"":                                    "no component found",
+		"BEGIN:VCALENDAR\r\n":                 "missing END:VCALENDAR",
+		"BEGIN:VCALENDAR\r\nEND:VEVENT\r\n":   "unexpected END",
+		"VERSION:2.0\r\n":                     "property outside of a component",
+		"BEGIN:VCALENDAR\r\nSUMMARY\r\n":      "missing ':'",
+		"BEGIN:VCALENDAR\r\nX;CN=\"Bob:1\r\n": "unterminated quote",
+		"BEGIN:VCALENDAR\r\nX;CN:1\r\n":       "malformed parameter",
+	}
+	for in, want := range tests {
+		_, err := Decode(strings.NewReader(in))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Decode(%q) = %v, want an error with %q", in, err, want)
+		}
+	}
+}
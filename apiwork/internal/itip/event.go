@@ -0,0 +1,181 @@
+package itip
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Aman221/4723/internal/attendees"
+	"github.com/Aman221/4723/internal/database"
+	"github.com/Aman221/4723/internal/ical"
+)
+
+// ErrNotFound is returned when an event does not exist.
+var ErrNotFound = errors.New("event not found")
+
+// Event is the view of a calendar_events row needed to describe it in
+// iCalendar form.
+type Event struct {
+	ID          string
+	UID         string
+	Sequence    int
+	Title       string
+	Description string
+	Location    string
+	Organizer   string
+	Attendees   []string
+	CalendarID  string
+	Date        *string
+	Day         int
+	StartTime   string
+	EndTime     string
+}
+
+// UIDDomain is the right-hand side of generated UIDs.
+var UIDDomain = "calendar.local"
+
+// NewUID returns a globally unique identifier for an event or task.
+func NewUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:]) + "@" + UIDDomain
+}
+
+// EnsureUID gives an event a UID if it doesn't have one yet and returns it.
+func EnsureUID(eventID string) (string, error) {
+	var uid string
+	err := database.DB.QueryRow(`
+		UPDATE calendar_events SET uid = COALESCE(uid, $2) WHERE id = $1 RETURNING uid
+	`, eventID, NewUID()).Scan(&uid)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNotFound
+	}
+	return uid, err
+}
+
+// Load reads an event, assigning it a UID first if necessary.
+func Load(eventID string) (Event, error) {
+	if _, err := EnsureUID(eventID); err != nil {
+		return Event{}, err
+	}
+	var ev Event
+	var attendeeList string
+	var date sql.NullString
+	err := database.DB.QueryRow(`
+		SELECT e.id, e.uid, e.sequence, e.title, e.description, e.location, e.organizer, `+attendees.Column(database.Dialect, "e")+`,
+		       e.calendar_id, e.date, e.day, e.start_time, e.end_time
+		FROM calendar_events e WHERE e.id = $1
+	`, eventID).Scan(&ev.ID, &ev.UID, &ev.Sequence, &ev.Title, &ev.Description, &ev.Location, &ev.Organizer,
+		&attendeeList, &ev.CalendarID, &date, &ev.Day, &ev.StartTime, &ev.EndTime)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ev, ErrNotFound
+	}
+	if err != nil {
+		return ev, err
+	}
+	ev.Attendees = attendees.Parse(attendeeList)
+	if date.Valid && len(date.String) >= 10 {
+		d := date.String[:10]
+		ev.Date = &d
+	}
+	return ev, nil
+}
+
+// Times returns when the event starts and ends in loc. Events without a
+// date repeat weekly; for those the next occurrence on or after now is
+// returned and weekly is true.
+func (ev Event) Times(loc *time.Location, now time.Time) (start, end time.Time, weekly bool, err error) {
+	clock := func(s string) (time.Time, error) {
+		s = strings.TrimSpace(s)
+		if len(s) == 4 && s[1] == ':' {
+			s = "0" + s
+		}
+		if len(s) > 5 {
+			s = s[:5]
+		}
+		return time.Parse("15:04", s)
+	}
+	startClock, err := clock(ev.StartTime)
+	if err != nil {
+		return start, end, false, err
+	}
+	endClock, err := clock(ev.EndTime)
+	if err != nil {
+		return start, end, false, err
+	}
+
+	var day time.Time
+	if ev.Date != nil {
+		day, err = time.ParseInLocation("2006-01-02", *ev.Date, loc)
+		if err != nil {
+			return start, end, false, err
+		}
+	} else {
+		weekly = true
+		day = now.In(loc)
+		for i := 0; i < 7; i++ {
+			wd := int(day.Weekday())
+			if wd == 0 {
+				wd = 7
+			}
+			if wd == ev.Day {
+				break
+			}
+			day = day.AddDate(0, 0, 1)
+		}
+	}
+	at := func(c time.Time) time.Time {
+		return time.Date(day.Year(), day.Month(), day.Day(), c.Hour(), c.Minute(), 0, 0, loc)
+	}
+	start, end = at(startClock), at(endClock)
+	if !end.After(start) {
+		end = start.Add(time.Hour)
+	}
+	return start, end, weekly, nil
+}
+
+var weekdays = [...]string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// VEvent describes the event as a VEVENT component. organizer is the
+// address replies should go to.
+func VEvent(ev Event, loc *time.Location, organizer string) (*ical.Component, error) {
+	start, end, weekly, err := ev.Times(loc, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	c := ical.NewComponent("VEVENT")
+	c.Add("UID", ev.UID, nil)
+	c.Add("SEQUENCE", strconv.Itoa(ev.Sequence), nil)
+	c.AddTime("DTSTAMP", time.Now())
+	c.AddTime("DTSTART", start)
+	c.AddTime("DTEND", end)
+	if weekly {
+		c.Add("RRULE", "FREQ=WEEKLY;BYDAY="+weekdays[start.Weekday()], nil)
+	}
+	c.AddText("SUMMARY", ev.Title)
+	if ev.Description != "" {
+		c.AddText("DESCRIPTION", ev.Description)
+	}
+	if ev.Location != "" {
+		c.AddText("LOCATION", ev.Location)
+	}
+	if organizer != "" {
+		params := map[string]string{}
+		if ev.Organizer != "" {
+			params["CN"] = ev.Organizer
+		}
+		c.Add("ORGANIZER", "mailto:"+organizer, params)
+	}
+	for _, a := range ev.Attendees {
+		if strings.Contains(a, "@") {
+			c.Add("ATTENDEE", "mailto:"+strings.ToLower(strings.TrimSpace(a)), map[string]string{
+				"ROLE": "REQ-PARTICIPANT", "PARTSTAT": "NEEDS-ACTION", "RSVP": "TRUE",
+			})
+		}
+	}
+	return c, nil
+}
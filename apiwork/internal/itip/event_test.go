@@ -0,0 +1,90 @@
+package itip
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimes(t *testing.T) {
+	loc := time.FixedZone("CET", 3600)
+	friday := time.Date(2026, 1, 9, 10, 0, 0, 0, loc)
+	date := "2026-01-07"
+	tests := []struct {
+		name       string
+		ev         Event
+		start, end time.Time
+		weekly     bool
+	}{
+		{"dated", Event{Date: &date, StartTime: "9:00", EndTime: "10:30:00"},
+			time.Date(2026, 1, 7, 9, 0, 0, 0, loc), time.Date(2026, 1, 7, 10, 30, 0, 0, loc), false},
+		{"weekly, next week", Event{Day: 3, StartTime: "09:00", EndTime: "10:00"},
+			time.Date(2026, 1, 14, 9, 0, 0, 0, loc), time.Date(2026, 1, 14, 10, 0, 0, 0, loc), true},
+		{"weekly, today", Event{Day: 5, StartTime: "09:00", EndTime: "10:00"},
+			time.Date(2026, 1, 9, 9, 0, 0, 0, loc), time.Date(2026, 1, 9, 10, 0, 0, 0, loc), true},
+		{"weekly on Sunday", Event{Day: 7, StartTime: "09:00", EndTime: "10:00"},
+			time.Date(2026, 1, 11, 9, 0, 0, 0, loc), time.Date(2026, 1, 11, 10, 0, 0, 0, loc), true},
+		{"ends at the start", Event{Date: &date, StartTime: "09:00", EndTime: "09:00"},
+			time.Date(2026, 1, 7, 9, 0, 0, 0, loc), time.Date(2026, 1, 7, 10, 0, 0, 0, loc), false},
+	}
+	for _, tt := range tests {
+		start, end, weekly, err := tt.ev.Times(loc, friday)
+		if err != nil || !start.Equal(tt.start) || !end.Equal(tt.end) || weekly != tt.weekly {
+			t.Errorf("%s: got %v, %v, %v, %v, want %v, %v, %v", tt.name, start, end, weekly, err, tt.start, tt.end, tt.weekly)
+		}
+	}
+
+	if _, _, _, err := (Event{Date: &date, StartTime: "nine", EndTime: "10:00"}).Times(loc, friday); err == nil {
+		t.Error("Times accepted a start of nine")
+	}
+}
+
+func TestVEvent(t *testing.T) {
+	ev := Event{
+		UID:       "1@calendar.local",
+		Sequence:  2,
+		Title:     "Standup",
+		Organizer: "Alice",
+		Attendees: []string{"bob", " Carol@Example.com "},
+		Day:       1,
+		StartTime: "09:00",
+		EndTime:   "09:15",
+	}
+	c, err := VEvent(ev, time.UTC, "calendar@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule := c.Text("RRULE"); rule != "FREQ=WEEKLY;BYDAY=MO" {
+		t.Errorf("RRULE is %q", rule)
+	}
+	if seq := c.Text("SEQUENCE"); seq != "2" {
+		t.Errorf("SEQUENCE is %q", seq)
+	}
+	if org, _ := c.Get("ORGANIZER"); org.Value != "mailto:calendar@example.com" || org.Params["CN"] != "Alice" {
+		t.Errorf("ORGANIZER is %+v", org)
+	}
+	// Attendees without an address, users for instance, aren't invited by
+	// email.
+	attendees := c.All("ATTENDEE")
+	if len(attendees) != 1 || attendees[0].Value != "mailto:carol@example.com" || attendees[0].Params["RSVP"] != "TRUE" {
+		t.Errorf("ATTENDEE lines are %+v", attendees)
+	}
+	if _, ok := c.Get("DESCRIPTION"); ok {
+		t.Error("an event without a description has a DESCRIPTION")
+	}
+
+	date := "2026-01-07"
+	ev.Date = &date
+	c, err = VEvent(ev, time.UTC, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Get("RRULE"); ok {
+		t.Error("a dated event has an RRULE")
+	}
+	if _, ok := c.Get("ORGANIZER"); ok {
+		t.Error("an event without an organizer address has an ORGANIZER")
+	}
+	if start := c.Text("DTSTART"); start != "20260107T090000Z" {
+		t.Errorf("DTSTART is %q", start)
+	}
+}
@@ -0,0 +1,178 @@
+package itip
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+
+	"github.com/Aman221/4723/internal/database"
+	"github.com/Aman221/4723/internal/ical"
+)
+
+var (
+	// ErrNoCalendar means the message carried no iCalendar part.
+	ErrNoCalendar = errors.New("message contains no text/calendar part")
+	// ErrNotReply means the calendar part is not an iTIP REPLY.
+	ErrNotReply = errors.New("calendar method is not REPLY")
+)
+
+// A MessageError means the message could not be parsed.
+type MessageError struct {
+	Err error
+}
+
+func (e *MessageError) Error() string { return e.Err.Error() }
+
+func (e *MessageError) Unwrap() error { return e.Err }
+
+var validStatuses = map[string]bool{
+	StatusNeedsAction: true, StatusAccepted: true, StatusDeclined: true, StatusTentative: true,
+}
+
+// ReplyResult reports what happened to one ATTENDEE line of a REPLY.
+type ReplyResult struct {
+	UID      string `json:"uid"`
+	EventID  string `json:"eventId,omitempty"`
+	Attendee string `json:"attendee"`
+	Status   string `json:"status"`
+	Updated  bool   `json:"updated"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// HandleReply parses a raw MIME message containing an iTIP REPLY and
+// updates the RSVP status of every attendee it answers for.
+func HandleReply(ctx context.Context, raw io.Reader) ([]ReplyResult, error) {
+	msg, err := mail.ReadMessage(raw)
+	if err != nil {
+		return nil, &MessageError{fmt.Errorf("reading message: %w", err)}
+	}
+	cal, err := findCalendar(msg.Header.Get("Content-Type"), msg.Header.Get("Content-Transfer-Encoding"), msg.Body)
+	if err != nil {
+		return nil, &MessageError{err}
+	}
+	if !strings.EqualFold(cal.Text("METHOD"), "REPLY") {
+		return nil, ErrNotReply
+	}
+	// The envelope sender must be the attendee answering, otherwise anyone
+	// could answer on somebody else's behalf. A reply whose sender can't be
+	// read answers for nobody.
+	var sender string
+	if from, err := mail.ParseAddress(msg.Header.Get("From")); err == nil {
+		sender = strings.ToLower(from.Address)
+	}
+
+	results := []ReplyResult{}
+	for _, vevent := range cal.Children("VEVENT") {
+		uid := vevent.Text("UID")
+		var eventID string
+		err := database.DB.QueryRowContext(ctx, "SELECT id FROM calendar_events WHERE uid = $1 AND deleted_at IS NULL", uid).Scan(&eventID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+
+		for _, att := range vevent.All("ATTENDEE") {
+			res := ReplyResult{
+				UID:      uid,
+				EventID:  eventID,
+				Attendee: calAddress(att.Value),
+				Status:   strings.ToUpper(att.Params["PARTSTAT"]),
+			}
+			switch {
+			case eventID == "":
+				res.Reason = "unknown event UID"
+			case !validStatuses[res.Status]:
+				res.Reason = "unsupported PARTSTAT"
+			case sender == "":
+				res.Reason = "reply has no valid From address"
+			case sender != res.Attendee:
+				res.Reason = "reply was not sent by the attendee"
+			default:
+				tag, err := database.DB.ExecContext(ctx, `
+					UPDATE event_rsvps SET status = $1, updated_at = NOW()
+					WHERE event_id = $2 AND attendee = $3
+				`, res.Status, eventID, res.Attendee)
+				if err != nil {
+					return nil, err
+				}
+				if n, _ := tag.RowsAffected(); n > 0 {
+					res.Updated = true
+				} else {
+					res.Reason = "attendee was not invited"
+				}
+			}
+			results = append(results, res)
+		}
+	}
+	return results, nil
+}
+
+// findCalendar walks a MIME entity looking for the first iCalendar part.
+func findCalendar(contentType, encoding string, body io.Reader) (*ical.Component, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = "text/plain"
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := mr.NextRawPart()
+			if err == io.EOF {
+				return nil, ErrNoCalendar
+			}
+			if err != nil {
+				return nil, fmt.Errorf("reading MIME part: %w", err)
+			}
+			cal, err := findCalendar(part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"), part)
+			if err == nil || !errors.Is(err, ErrNoCalendar) {
+				return cal, err
+			}
+		}
+	}
+
+	if mediaType != "text/calendar" && mediaType != "application/ics" {
+		return nil, ErrNoCalendar
+	}
+	decoded, err := decodeBody(encoding, body)
+	if err != nil {
+		return nil, err
+	}
+	cal, err := ical.Decode(bytes.NewReader(decoded))
+	if err != nil {
+		return nil, err
+	}
+	if cal.Name != "VCALENDAR" {
+		return nil, fmt.Errorf("expected VCALENDAR, got %s", cal.Name)
+	}
+	return cal, nil
+}
+
+func decodeBody(encoding string, body io.Reader) ([]byte, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return io.ReadAll(base64.NewDecoder(base64.StdEncoding, body))
+	case "quoted-printable":
+		return io.ReadAll(quotedprintable.NewReader(body))
+	default:
+		return io.ReadAll(body)
+	}
+}
+
+// calAddress turns a CAL-ADDRESS such as "MAILTO:Alice@Example.com" into a
+// lower-case email address.
+func calAddress(v string) string {
+	v = strings.TrimSpace(v)
+	if len(v) >= 7 && strings.EqualFold(v[:7], "mailto:") {
+		v = v[7:]
+	}
+	return strings.ToLower(v)
+}
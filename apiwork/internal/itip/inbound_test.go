@@ -0,0 +1,91 @@
+package itip
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const reply = "BEGIN:VCALENDAR\r\nMETHOD:REPLY\r\nBEGIN:VEVENT\r\nUID:1@calendar.local\r\n" +
+	"ATTENDEE;PARTSTAT=ACCEPTED:mailto:bob@example.com\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+
+func TestFindCalendar(t *testing.T) {
+	encoded := base64.StdEncoding.EncodeToString([]byte(reply))
+	multipart := "--outer\r\n" +
+		"Content-Type: text/plain\r\n\r\nBob has accepted.\r\n" +
+		"--outer\r\n" +
+		"Content-Type: multipart/alternative; boundary=inner\r\n\r\n" +
+		"--inner\r\n" +
+		"Content-Type: text/calendar; method=REPLY\r\n" +
+		"Content-Transfer-Encoding: base64\r\n\r\n" +
+		encoded[:40] + "\r\n" + encoded[40:] + "\r\n" +
+		"--inner--\r\n" +
+		"--outer--\r\n"
+
+	tests := []struct {
+		name, contentType, encoding, body string
+	}{
+		{"plain", "text/calendar; charset=utf-8", "", reply},
+		{"application/ics", "application/ics", "", reply},
+		{"quoted-printable", "text/calendar", "quoted-printable", strings.ReplaceAll(reply, "=", "=3D")},
+		{"nested multipart", "multipart/mixed; boundary=outer", "", multipart},
+	}
+	for _, tt := range tests {
+		cal, err := findCalendar(tt.contentType, tt.encoding, strings.NewReader(tt.body))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if cal.Text("METHOD") != "REPLY" || len(cal.Children("VEVENT")) != 1 {
+			t.Errorf("%s: found %+v", tt.name, cal)
+		}
+	}
+
+	for _, contentType := range []string{"text/plain", "", "multipart/mixed; boundary=outer"} {
+		body := "--outer\r\nContent-Type: text/plain\r\n\r\nHello\r\n--outer--\r\n"
+		if _, err := findCalendar(contentType, "", strings.NewReader(body)); !errors.Is(err, ErrNoCalendar) {
+			t.Errorf("%q: got %v, want ErrNoCalendar", contentType, err)
+		}
+	}
+
+	if _, err := findCalendar("text/calendar", "", strings.NewReader("BEGIN:VEVENT\r\nEND:VEVENT\r\n")); err == nil {
+		t.Error("accepted a VEVENT outside of a VCALENDAR")
+	}
+}
+
+func TestHandleReplyRejects(t *testing.T) {
+	tests := []struct {
+		name, message string
+		want          func(error) bool
+	}{
+		{"no headers", "not a message", func(err error) bool {
+			var merr *MessageError
+			return errors.As(err, &merr)
+		}},
+		{"no calendar", "From: bob@example.com\r\nContent-Type: text/plain\r\n\r\nYes!\r\n", func(err error) bool {
+			return errors.Is(err, ErrNoCalendar)
+		}},
+		{"a request", "From: bob@example.com\r\nContent-Type: text/calendar\r\n\r\n" + strings.Replace(reply, "REPLY", "REQUEST", 1), func(err error) bool {
+			return errors.Is(err, ErrNotReply)
+		}},
+	}
+	for _, tt := range tests {
+		if _, err := HandleReply(context.Background(), strings.NewReader(tt.message)); !tt.want(err) {
+			t.Errorf("%s: got %v", tt.name, err)
+		}
+	}
+}
+
+func TestCalAddress(t *testing.T) {
+	for in, want := range map[string]string{
+		"MAILTO:Bob@Example.com":   "bob@example.com",
+		" mailto:bob@example.com ": "bob@example.com",
+		"bob@example.com":          "bob@example.com",
+	} {
+		if got := calAddress(in); got != want {
+			t.Errorf("calAddress(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
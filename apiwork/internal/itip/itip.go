@@ -0,0 +1,263 @@
+// Package itip keeps attendees outside the system informed about events
+// using iMIP, i.e. iTIP (RFC 5546) messages carried over email (RFC 6047).
+//
+// Attendees who are neither users nor bookable resources are external.
+// They receive a REQUEST when an event is created or changed and a CANCEL
+// when it is deleted or they are removed from it. Their REPLY messages are
+// fed back through HandleReply, which records their answer in event_rsvps.
+package itip
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Aman221/4723/internal/database"
+	"github.com/Aman221/4723/internal/ical"
+	"github.com/Aman221/4723/internal/mailer"
+)
+
+// Participation statuses (PARTSTAT values).
+const (
+	StatusNeedsAction = "NEEDS-ACTION"
+	StatusAccepted    = "ACCEPTED"
+	StatusDeclined    = "DECLINED"
+	StatusTentative   = "TENTATIVE"
+)
+
+// ProdID identifies this software in generated calendars.
+const ProdID = "-//Aman221//4723 Calendar//EN"
+
+// Sender sends invitations and cancellations.
+type Sender struct {
+	Mailer mailer.Mailer
+	// Location is used to interpret event dates and times.
+	Location *time.Location
+	// Address is the mailbox that receives replies. It is used as the
+	// ORGANIZER of outgoing invitations so answers come back to us rather
+	// than to the organizer's personal mailbox.
+	Address string
+}
+
+// Calendar wraps components in a VCALENDAR with the given METHOD (empty
+// for plain exports).
+func Calendar(method string, components ...*ical.Component) *ical.Component {
+	cal := ical.NewComponent("VCALENDAR")
+	cal.Add("PRODID", ProdID, nil)
+	cal.Add("VERSION", "2.0", nil)
+	cal.Add("CALSCALE", "GREGORIAN", nil)
+	if method != "" {
+		cal.Add("METHOD", method, nil)
+	}
+	cal.Components = components
+	return cal
+}
+
+// externalAttendees returns the attendees of ev that are email addresses
+// not belonging to a user or a resource.
+func externalAttendees(ctx context.Context, ev Event) ([]string, error) {
+	var candidates []string
+	seen := map[string]bool{}
+	for _, a := range ev.Attendees {
+		a = strings.ToLower(strings.TrimSpace(a))
+		if strings.Contains(a, "@") && !seen[a] {
+			seen[a] = true
+			candidates = append(candidates, a)
+		}
+	}
+	var external []string
+	for _, a := range candidates {
+		var internal bool
+		err := database.DB.QueryRowContext(ctx, `
+			SELECT EXISTS (SELECT 1 FROM users WHERE LOWER(email) = $1)
+			    OR EXISTS (SELECT 1 FROM resources WHERE email = $1)
+		`, a).Scan(&internal)
+		if err != nil {
+			return nil, err
+		}
+		if !internal {
+			external = append(external, a)
+		}
+	}
+	return external, nil
+}
+
+// invited returns the external attendees already sent a REQUEST.
+func invited(ctx context.Context, eventID string) (map[string]bool, error) {
+	rows, err := database.DB.QueryContext(ctx, "SELECT attendee FROM event_rsvps WHERE event_id = $1", eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	set := map[string]bool{}
+	for rows.Next() {
+		var a string
+		if err := rows.Scan(&a); err != nil {
+			return nil, err
+		}
+		set[a] = true
+	}
+	return set, rows.Err()
+}
+
+// EventSaved sends a REQUEST to every external attendee of a created or
+// updated event, and a CANCEL to external attendees who were removed.
+// Callers pass updated=true for changes to an existing event so that its
+// SEQUENCE is bumped and clients replace their copy.
+func (s *Sender) EventSaved(ctx context.Context, eventID string, updated bool) error {
+	if updated {
+		if _, err := database.DB.ExecContext(ctx, "UPDATE calendar_events SET sequence = sequence + 1 WHERE id = $1", eventID); err != nil {
+			return err
+		}
+	}
+	ev, err := Load(eventID)
+	if err != nil {
+		return err
+	}
+	external, err := externalAttendees(ctx, ev)
+	if err != nil {
+		return err
+	}
+	previously, err := invited(ctx, eventID)
+	if err != nil {
+		return err
+	}
+
+	current := map[string]bool{}
+	for _, a := range external {
+		current[a] = true
+		_, err := database.DB.ExecContext(ctx, `
+			INSERT INTO event_rsvps (event_id, attendee, status, updated_at) VALUES ($1, $2, $3, NOW())
+			ON CONFLICT (event_id, attendee) DO NOTHING
+		`, eventID, a, StatusNeedsAction)
+		if err != nil {
+			return err
+		}
+	}
+	var removed []string
+	for a := range previously {
+		if !current[a] {
+			removed = append(removed, a)
+			if _, err := database.DB.ExecContext(ctx, "DELETE FROM event_rsvps WHERE event_id = $1 AND attendee = $2", eventID, a); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(external) > 0 {
+		if err := s.send(ctx, ev, "REQUEST", external); err != nil {
+			return err
+		}
+	}
+	if len(removed) > 0 {
+		if err := s.send(ctx, ev, "CANCEL", removed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Invitees returns the external attendees who were sent a REQUEST for the
+// event. Read them before deleting an event; they are needed to cancel it.
+func Invitees(ctx context.Context, eventID string) ([]string, error) {
+	set, err := invited(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]string, 0, len(set))
+	for a := range set {
+		list = append(list, a)
+	}
+	return list, nil
+}
+
+// EventDeleted sends a CANCEL for a deleted event to the invitees
+// previously returned by Invitees.
+func (s *Sender) EventDeleted(ctx context.Context, ev Event, invitees []string) error {
+	if _, err := database.DB.ExecContext(ctx, "DELETE FROM event_rsvps WHERE event_id = $1", ev.ID); err != nil {
+		return err
+	}
+	if len(invitees) == 0 {
+		return nil
+	}
+	ev.Sequence++
+	return s.send(ctx, ev, "CANCEL", invitees)
+}
+
+func (s *Sender) send(ctx context.Context, ev Event, method string, to []string) error {
+	vevent, err := VEvent(ev, s.Location, s.Address)
+	if err != nil {
+		return fmt.Errorf("describing event %s: %w", ev.ID, err)
+	}
+	subject := "Invitation: " + ev.Title
+	text := fmt.Sprintf("You have been invited to %q.\n", ev.Title)
+	if method == "CANCEL" {
+		vevent.Add("STATUS", "CANCELLED", nil)
+		subject = "Cancelled: " + ev.Title
+		text = fmt.Sprintf("%q has been cancelled.\n", ev.Title)
+	}
+	if start, _, _, err := ev.Times(s.Location, time.Now()); err == nil {
+		text += "When: " + start.Format("Monday, January 2, 2006 15:04 MST") + "\n"
+	}
+	if ev.Location != "" {
+		text += "Where: " + ev.Location + "\n"
+	}
+
+	ics := Calendar(method, vevent).Bytes()
+	for _, recipient := range to {
+		err := s.Mailer.Send(ctx, mailer.Message{
+			From:    s.Address,
+			To:      []string{recipient},
+			Subject: subject,
+			Text:    text,
+			Attachments: []mailer.Attachment{{
+				Filename:    "invite.ics",
+				ContentType: "text/calendar; charset=utf-8; method=" + method,
+				Data:        ics,
+			}},
+		})
+		if err != nil {
+			return fmt.Errorf("sending %s for event %s to %s: %w", method, ev.ID, recipient, err)
+		}
+	}
+	return nil
+}
+
+// Async runs fn in the background with its own timeout, logging failures.
+// Event handlers use it so a slow mail server doesn't delay API responses.
+func Async(what string, fn func(ctx context.Context) error) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		defer cancel()
+		if err := fn(ctx); err != nil {
+			log.Printf("itip: %s: %v", what, err)
+		}
+	}()
+}
+
+// RSVP is an attendee's answer to an invitation.
+type RSVP struct {
+	Attendee  string    `json:"attendee"`
+	Status    string    `json:"status"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// RSVPs lists the answers of an event's external attendees.
+func RSVPs(eventID string) ([]RSVP, error) {
+	rows, err := database.DB.Query("SELECT attendee, status, updated_at FROM event_rsvps WHERE event_id = $1 ORDER BY attendee", eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []RSVP{}
+	for rows.Next() {
+		var r RSVP
+		if err := rows.Scan(&r.Attendee, &r.Status, &r.UpdatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, r)
+	}
+	return list, rows.Err()
+}
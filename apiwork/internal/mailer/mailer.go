@@ -0,0 +1,245 @@
+// Package mailer sends email. Mailer is the delivery abstraction used by
+// notification code; SMTPMailer talks to a real server and LogMailer only
+// logs, which is handy when no mail server is configured.
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Attachment is a file sent along with a message.
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// Message is a single email. At least one of Text and HTML should be set.
+type Message struct {
+	From        string
+	To          []string
+	Subject     string
+	Text        string
+	HTML        string
+	Headers     map[string]string
+	Attachments []Attachment
+}
+
+// Mailer delivers messages.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// SMTPMailer delivers messages through an SMTP server. With an empty
+// Username no authentication is attempted, so it works against local mail
+// sinks such as MailHog or smtp4dev (e.g. Host "localhost", Port 1025).
+type SMTPMailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// Send implements Mailer.
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	if msg.From == "" {
+		msg.From = m.From
+	}
+	if msg.From == "" {
+		return errors.New("mailer: no sender address configured")
+	}
+	if len(msg.To) == 0 {
+		return errors.New("mailer: message has no recipients")
+	}
+	body, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	addr := net.JoinHostPort(m.Host, strconv.Itoa(m.Port))
+
+	// smtp.SendMail has no context support, so run it aside and stop
+	// waiting if the context ends first.
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(addr, auth, addressOf(msg.From), addressesOf(msg.To), body)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("mailer: sending to %s: %w", addr, err)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// LogMailer writes messages to the log instead of sending them.
+type LogMailer struct{}
+
+// Send implements Mailer.
+func (LogMailer) Send(ctx context.Context, msg Message) error {
+	log.Printf("mail to %s: %s", strings.Join(msg.To, ", "), msg.Subject)
+	return nil
+}
+
+func addressOf(s string) string {
+	if s == "" {
+		return s
+	}
+	if i := strings.LastIndex(s, "<"); i >= 0 && strings.HasSuffix(s, ">") {
+		return s[i+1 : len(s)-1]
+	}
+	return s
+}
+
+func addressesOf(list []string) []string {
+	out := make([]string, len(list))
+	for i, s := range list {
+		out[i] = addressOf(s)
+	}
+	return out
+}
+
+func boundary() string {
+	var b [12]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// Bytes renders the message in RFC 5322 form with a MIME body.
+func (msg Message) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	header := func(k, v string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", k, v)
+	}
+	header("From", msg.From)
+	header("To", strings.Join(msg.To, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+	for k, v := range msg.Headers {
+		header(k, v)
+	}
+
+	if len(msg.Attachments) == 0 {
+		if err := writeAlternatives(&buf, msg); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	mixed := multipart.NewWriter(&buf)
+	mixed.SetBoundary(boundary())
+	header("Content-Type", "multipart/mixed; boundary="+mixed.Boundary())
+	buf.WriteString("\r\n")
+
+	var alt bytes.Buffer
+	if err := writeAlternatives(&alt, msg); err != nil {
+		return nil, err
+	}
+	headerEnd := bytes.Index(alt.Bytes(), []byte("\r\n\r\n"))
+	partHeader := textproto.MIMEHeader{}
+	for _, line := range strings.Split(string(alt.Bytes()[:headerEnd]), "\r\n") {
+		if k, v, ok := strings.Cut(line, ": "); ok {
+			partHeader.Set(k, v)
+		}
+	}
+	part, err := mixed.CreatePart(partHeader)
+	if err != nil {
+		return nil, err
+	}
+	part.Write(alt.Bytes()[headerEnd+4:])
+
+	for _, a := range msg.Attachments {
+		ct := a.ContentType
+		if ct == "" {
+			ct = "application/octet-stream"
+		}
+		h := textproto.MIMEHeader{}
+		h.Set("Content-Type", ct)
+		h.Set("Content-Transfer-Encoding", "base64")
+		h.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": a.Filename}))
+		part, err := mixed.CreatePart(h)
+		if err != nil {
+			return nil, err
+		}
+		writeBase64(part, a.Data)
+	}
+	if err := mixed.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeAlternatives writes the text and/or HTML body, preceded by its
+// Content-Type header.
+func writeAlternatives(buf *bytes.Buffer, msg Message) error {
+	writeText := func(contentType, s string) error {
+		fmt.Fprintf(buf, "Content-Type: %s; charset=utf-8\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\n", contentType)
+		qp := quotedprintable.NewWriter(buf)
+		if _, err := qp.Write([]byte(s)); err != nil {
+			return err
+		}
+		return qp.Close()
+	}
+
+	if msg.HTML == "" || msg.Text == "" {
+		if msg.HTML != "" {
+			return writeText("text/html", msg.HTML)
+		}
+		return writeText("text/plain", msg.Text)
+	}
+
+	alt := multipart.NewWriter(buf)
+	alt.SetBoundary(boundary())
+	fmt.Fprintf(buf, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", alt.Boundary())
+	for _, p := range []struct{ ct, body string }{{"text/plain", msg.Text}, {"text/html", msg.HTML}} {
+		h := textproto.MIMEHeader{}
+		h.Set("Content-Type", p.ct+"; charset=utf-8")
+		h.Set("Content-Transfer-Encoding", "quoted-printable")
+		part, err := alt.CreatePart(h)
+		if err != nil {
+			return err
+		}
+		qp := quotedprintable.NewWriter(part)
+		if _, err := qp.Write([]byte(p.body)); err != nil {
+			return err
+		}
+		if err := qp.Close(); err != nil {
+			return err
+		}
+	}
+	return alt.Close()
+}
+
+func writeBase64(w interface{ Write([]byte) (int, error) }, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		w.Write([]byte(encoded[:76] + "\r\n"))
+		encoded = encoded[76:]
+	}
+	w.Write([]byte(encoded + "\r\n"))
+}
@@ -0,0 +1,303 @@
+// Package migrations versions the database schema. The SQL ships inside
+// the binary: each migration is a pair of files NNNN_name.up.sql and
+// NNNN_name.down.sql, applied in version order and recorded in the
+// schema_migrations table. Each dialect has its own directory holding the
+// same versions written for that database.
+//
+// On Postgres, runs hold an advisory lock so several instances starting at
+// once apply each migration exactly once. SQLite serialises writers itself,
+// and a racing run fails on the schema_migrations primary key. Every
+// migration runs in its own transaction together with its bookkeeping.
+//
+// The early migrations use IF NOT EXISTS throughout because databases
+// created before migrations existed already have some or all of their
+// tables; on those the first run just records the versions.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Aman221/4723/internal/database"
+)
+
+//go:embed postgres/*.sql sqlite/*.sql
+var files embed.FS
+
+// lockID is the pg_advisory_lock key guarding migration runs.
+const lockID = 47230001
+
+// Migration is one schema change.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Applied describes a migration recorded in schema_migrations.
+type Applied struct {
+	Version   int
+	Name      string
+	AppliedAt time.Time
+}
+
+// All returns the embedded migrations for a dialect in version order.
+func All(dialect string) ([]Migration, error) {
+	switch dialect {
+	case database.Postgres, database.SQLite:
+		return load(files, dialect)
+	}
+	return nil, fmt.Errorf("no migrations for dialect %q", dialect)
+}
+
+func load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int]*Migration{}
+	for _, e := range entries {
+		name := e.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+		base := strings.TrimSuffix(name, "."+direction+".sql")
+		num, label, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(num)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration file %s: name must look like 0001_description.up.sql", name)
+		}
+		body, err := fs.ReadFile(fsys, path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: label}
+			byVersion[version] = m
+		} else if m.Name != label {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, m.Name, label)
+		}
+		if direction == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	list := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both an up and a down file", m.Version, m.Name)
+		}
+		list = append(list, *m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+// Latest returns the highest embedded version.
+func Latest(dialect string) (int, error) {
+	all, err := All(dialect)
+	if err != nil || len(all) == 0 {
+		return 0, err
+	}
+	return all[len(all)-1].Version, nil
+}
+
+// session is a connection holding the migration lock.
+type session struct {
+	conn    *sql.Conn
+	dialect string
+}
+
+func lock(ctx context.Context, db *sql.DB, dialect string) (*session, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	appliedAt := "TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP"
+	if dialect == database.Postgres {
+		appliedAt = "TIMESTAMPTZ NOT NULL DEFAULT NOW()"
+		if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockID); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("acquiring migration lock: %w", err)
+		}
+	}
+	_, err = conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    INTEGER PRIMARY KEY,
+			name       TEXT NOT NULL,
+			applied_at `+appliedAt+`
+		)
+	`)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("creating schema_migrations: %w", err)
+	}
+	return &session{conn: conn, dialect: dialect}, nil
+}
+
+func (s *session) unlock() {
+	// Use a fresh context: the caller's may already be cancelled, and
+	// closing the connection releases the lock anyway if this fails.
+	if s.dialect == database.Postgres {
+		s.conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", lockID)
+	}
+	s.conn.Close()
+}
+
+func (s *session) applied(ctx context.Context) ([]Applied, error) {
+	rows, err := s.conn.QueryContext(ctx, "SELECT version, name, applied_at FROM schema_migrations ORDER BY version")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []Applied
+	for rows.Next() {
+		var a Applied
+		if err := rows.Scan(&a.Version, &a.Name, &a.AppliedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, a)
+	}
+	return list, rows.Err()
+}
+
+func (s *session) run(ctx context.Context, m Migration, up bool) error {
+	tx, err := s.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	script, bookkeeping := m.Down, "DELETE FROM schema_migrations WHERE version = $1"
+	args := []interface{}{m.Version}
+	if up {
+		script, bookkeeping = m.Up, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)"
+		args = append(args, m.Name)
+	}
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, bookkeeping, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Status lists the migrations recorded in the database.
+func Status(ctx context.Context, db *sql.DB, dialect string) ([]Applied, error) {
+	s, err := lock(ctx, db, dialect)
+	if err != nil {
+		return nil, err
+	}
+	defer s.unlock()
+	return s.applied(ctx)
+}
+
+// Up applies all pending migrations and returns the ones it ran.
+func Up(ctx context.Context, db *sql.DB, dialect string) ([]Migration, error) {
+	latest, err := Latest(dialect)
+	if err != nil {
+		return nil, err
+	}
+	return To(ctx, db, dialect, latest)
+}
+
+// Down rolls back the most recent steps migrations and returns the ones it
+// reverted.
+func Down(ctx context.Context, db *sql.DB, dialect string, steps int) ([]Migration, error) {
+	if steps <= 0 {
+		return nil, errors.New("number of steps must be positive")
+	}
+	s, err := lock(ctx, db, dialect)
+	if err != nil {
+		return nil, err
+	}
+	defer s.unlock()
+
+	applied, err := s.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	target := 0
+	if steps < len(applied) {
+		target = applied[len(applied)-steps-1].Version
+	}
+	return s.migrateTo(ctx, applied, target)
+}
+
+// To migrates up or down until the database is at version (0 reverts
+// everything) and returns the migrations it ran.
+func To(ctx context.Context, db *sql.DB, dialect string, version int) ([]Migration, error) {
+	s, err := lock(ctx, db, dialect)
+	if err != nil {
+		return nil, err
+	}
+	defer s.unlock()
+
+	applied, err := s.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.migrateTo(ctx, applied, version)
+}
+
+func (s *session) migrateTo(ctx context.Context, applied []Applied, version int) ([]Migration, error) {
+	all, err := All(s.dialect)
+	if err != nil {
+		return nil, err
+	}
+	known := map[int]Migration{}
+	for _, m := range all {
+		known[m.Version] = m
+	}
+	if _, ok := known[version]; !ok && version != 0 {
+		return nil, fmt.Errorf("unknown migration version %d", version)
+	}
+	done := map[int]bool{}
+	for _, a := range applied {
+		if _, ok := known[a.Version]; !ok {
+			return nil, fmt.Errorf("database has migration %d (%s) which this binary doesn't know; is it older than the database?", a.Version, a.Name)
+		}
+		done[a.Version] = true
+	}
+
+	var ran []Migration
+	// Roll back newest first, then apply oldest first.
+	for i := len(all) - 1; i >= 0; i-- {
+		m := all[i]
+		if m.Version > version && done[m.Version] {
+			if err := s.run(ctx, m, false); err != nil {
+				return ran, fmt.Errorf("reverting %04d_%s: %w", m.Version, m.Name, err)
+			}
+			ran = append(ran, m)
+		}
+	}
+	for _, m := range all {
+		if m.Version <= version && !done[m.Version] {
+			if err := s.run(ctx, m, true); err != nil {
+				return ran, fmt.Errorf("applying %04d_%s: %w", m.Version, m.Name, err)
+			}
+			ran = append(ran, m)
+		}
+	}
+	return ran, nil
+}
@@ -0,0 +1,135 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/Aman221/4723/internal/database"
+)
+
+func file(s string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(s)} }
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"m/0002_add_color.up.sql":      file("ALTER TABLE t ADD color TEXT"),
+		"m/0002_add_color.down.sql":    file("ALTER TABLE t DROP color"),
+		"m/0010_index.up.sql":          file("CREATE INDEX i ON t (color)"),
+		"m/0010_index.down.sql":        file("DROP INDEX i"),
+		"m/0001_create_table.up.sql":   file("CREATE TABLE t (id INTEGER)"),
+		"m/0001_create_table.down.sql": file("DROP TABLE t"),
+		"m/README":                     file("ignored"),
+	}
+	got, err := load(fsys, "m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Migration{
+		{1, "create_table", "CREATE TABLE t (id INTEGER)", "DROP TABLE t"},
+		{2, "add_color", "ALTER TABLE t ADD color TEXT", "ALTER TABLE t DROP color"},
+		{10, "index", "CREATE INDEX i ON t (color)", "DROP INDEX i"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("loaded %+v, want %+v", got, want)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+		want  string
+	}{
+		{"no number", fstest.MapFS{"m/create.up.sql": file(""), "m/create.down.sql": file("")}, "name must look like"},
+		{"version 0", fstest.MapFS{"m/0000_create.up.sql": file("x"), "m/0000_create.down.sql": file("x")}, "name must look like"},
+		{"two names", fstest.MapFS{"m/0001_create.up.sql": file("x"), "m/0001_make.down.sql": file("x")}, "two names"},
+		{"no down", fstest.MapFS{"m/0001_create.up.sql": file("x")}, "needs both"},
+		{"empty up", fstest.MapFS{"m/0001_create.up.sql": file(""), "m/0001_create.down.sql": file("x")}, "needs both"},
+	}
+	for _, tt := range tests {
+		if _, err := load(tt.files, "m"); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got %v, want an error with %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+// TestDialectsMatch checks that every dialect has the same versions under
+// the same names.
+func TestDialectsMatch(t *testing.T) {
+	names := func(dialect string) []string {
+		all, err := All(dialect)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out []string
+		for _, m := range all {
+			out = append(out, m.Name)
+		}
+		return out
+	}
+	if pg, lite := names(database.Postgres), names(database.SQLite); !reflect.DeepEqual(pg, lite) {
+		t.Errorf("postgres has %v, sqlite has %v", pg, lite)
+	}
+	if _, err := All("mysql"); err == nil {
+		t.Error("found migrations for mysql")
+	}
+}
+
+func TestUpAndDownOnSQLite(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("sqlite3", "file:"+filepath.Join(t.TempDir(), "calendar.db")+"?_foreign_keys=on")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	latest, err := Latest(database.SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ran, err := Up(ctx, db, database.SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ran) != latest {
+		t.Errorf("applied %d migrations, want %d", len(ran), latest)
+	}
+	if ran, err := Up(ctx, db, database.SQLite); err != nil || len(ran) != 0 {
+		t.Errorf("second run applied %d migrations: %v", len(ran), err)
+	}
+
+	ran, err = Down(ctx, db, database.SQLite, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ran) != 2 || ran[0].Version != latest || ran[1].Version != latest-1 {
+		t.Errorf("reverted %+v, want the newest two", ran)
+	}
+
+	if _, err := To(ctx, db, database.SQLite, 0); err != nil {
+		t.Fatal(err)
+	}
+	applied, err := Status(ctx, db, database.SQLite)
+	if err != nil || len(applied) != 0 {
+		t.Errorf("after reverting everything: %+v, %v", applied, err)
+	}
+	var tables int
+	db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name NOT IN ('schema_migrations', 'sqlite_sequence')").Scan(&tables)
+	if tables != 0 {
+		t.Errorf("%d tables are left after reverting everything", tables)
+	}
+
+	if _, err := Up(ctx, db, database.SQLite); err != nil {
+		t.Fatalf("reapplying: %v", err)
+	}
+	if _, err := To(ctx, db, database.SQLite, latest+1); err == nil {
+		t.Error("migrated to an unknown version")
+	}
+	if _, err := Down(ctx, db, database.SQLite, 0); err == nil {
+		t.Error("reverted zero steps")
+	}
+}
@@ -0,0 +1,24 @@
+-- Bookable rooms and equipment. Each resource owns a hidden calendar that
+-- holds a copy of every event it has accepted.
+CREATE TABLE IF NOT EXISTS resources (
+    id          SERIAL PRIMARY KEY,
+    name        TEXT NOT NULL,
+    kind        TEXT NOT NULL,
+    email       TEXT NOT NULL UNIQUE,
+    capacity    INTEGER NOT NULL DEFAULT 0,
+    attributes  JSONB NOT NULL DEFAULT '{}',
+    calendar_id INTEGER NOT NULL REFERENCES calendars (id)
+);
+
+CREATE INDEX IF NOT EXISTS resources_attributes_idx ON resources USING GIN (attributes);
+
+CREATE TABLE IF NOT EXISTS resource_bookings (
+    resource_id      INTEGER NOT NULL REFERENCES resources (id) ON DELETE CASCADE,
+    event_id         INTEGER NOT NULL REFERENCES calendar_events (id) ON DELETE CASCADE,
+    status           TEXT NOT NULL,
+    booking_event_id INTEGER REFERENCES calendar_events (id) ON DELETE SET NULL,
+    updated_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (resource_id, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS resource_bookings_event_id_idx ON resource_bookings (event_id);
@@ -0,0 +1,53 @@
+CREATE TABLE IF NOT EXISTS calendar_reminders (
+    calendar_id    INTEGER NOT NULL REFERENCES calendars (id) ON DELETE CASCADE,
+    minutes_before INTEGER NOT NULL,
+    method         TEXT NOT NULL,
+    PRIMARY KEY (calendar_id, minutes_before, method)
+);
+
+CREATE TABLE IF NOT EXISTS event_reminders (
+    event_id       INTEGER NOT NULL REFERENCES calendar_events (id) ON DELETE CASCADE,
+    minutes_before INTEGER NOT NULL,
+    method         TEXT NOT NULL,
+    PRIMARY KEY (event_id, minutes_before, method)
+);
+
+-- Events without a row here use their calendar's default reminders.
+CREATE TABLE IF NOT EXISTS event_reminder_settings (
+    event_id    INTEGER PRIMARY KEY REFERENCES calendar_events (id) ON DELETE CASCADE,
+    use_default BOOLEAN NOT NULL DEFAULT TRUE
+);
+
+-- One row per reminder to send; see reminders.Scheduler for the life cycle.
+CREATE TABLE IF NOT EXISTS reminder_deliveries (
+    id               BIGSERIAL PRIMARY KEY,
+    event_id         INTEGER NOT NULL REFERENCES calendar_events (id) ON DELETE CASCADE,
+    occurrence_start TIMESTAMPTZ NOT NULL,
+    minutes_before   INTEGER NOT NULL,
+    method           TEXT NOT NULL,
+    recipient        TEXT NOT NULL,
+    fire_at          TIMESTAMPTZ NOT NULL,
+    status           TEXT NOT NULL DEFAULT 'pending',
+    attempts         INTEGER NOT NULL DEFAULT 0,
+    locked_by        TEXT,
+    locked_until     TIMESTAMPTZ,
+    last_error       TEXT,
+    sent_at          TIMESTAMPTZ,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (event_id, occurrence_start, minutes_before, method, recipient)
+);
+
+CREATE INDEX IF NOT EXISTS reminder_deliveries_due_idx ON reminder_deliveries (fire_at) WHERE status IN ('pending', 'sending');
+
+CREATE TABLE IF NOT EXISTS notifications (
+    id          BIGSERIAL PRIMARY KEY,
+    delivery_id BIGINT NOT NULL UNIQUE REFERENCES reminder_deliveries (id) ON DELETE CASCADE,
+    recipient   TEXT NOT NULL,
+    event_id    INTEGER,
+    title       TEXT NOT NULL,
+    body        TEXT NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    read_at     TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS notifications_recipient_idx ON notifications (recipient, created_at DESC);
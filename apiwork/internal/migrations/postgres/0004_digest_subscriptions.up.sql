@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS digest_subscriptions (
+    user_id           INTEGER PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    email             TEXT NOT NULL DEFAULT '',
+    enabled           BOOLEAN NOT NULL DEFAULT FALSE,
+    send_at           TEXT NOT NULL DEFAULT '07:00',
+    timezone          TEXT NOT NULL DEFAULT 'America/New_York',
+    unsubscribe_token TEXT NOT NULL UNIQUE,
+    last_sent_on      TEXT
+);
@@ -0,0 +1,3 @@
+DROP TABLE event_rsvps;
+ALTER TABLE calendar_events DROP COLUMN sequence;
+ALTER TABLE calendar_events DROP COLUMN uid;
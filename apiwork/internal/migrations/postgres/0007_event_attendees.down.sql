@@ -0,0 +1,7 @@
+ALTER TABLE calendar_events ADD COLUMN attendees TEXT NOT NULL DEFAULT '[]';
+
+UPDATE calendar_events e
+SET attendees = (SELECT json_agg(a.attendee ORDER BY a.position)::TEXT FROM event_attendees a WHERE a.event_id = e.id)
+WHERE EXISTS (SELECT 1 FROM event_attendees a WHERE a.event_id = e.id);
+
+DROP TABLE event_attendees;
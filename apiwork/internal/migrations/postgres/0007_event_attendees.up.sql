@@ -0,0 +1,47 @@
+-- Attendees move out of calendar_events.attendees, which over time was
+-- written both as a JSON array ('["a","b"]') and as a Postgres array
+-- literal ('{a,b}'), into one row per attendee.
+CREATE TABLE event_attendees (
+    event_id INTEGER NOT NULL REFERENCES calendar_events (id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    attendee TEXT NOT NULL,
+    PRIMARY KEY (event_id, position),
+    UNIQUE (event_id, attendee)
+);
+
+CREATE INDEX event_attendees_attendee_idx ON event_attendees (LOWER(attendee));
+
+-- Rows that parse as neither format are reported and left without
+-- attendees rather than failing the migration.
+DO $$
+DECLARE
+    ev RECORD;
+    list TEXT[];
+BEGIN
+    FOR ev IN SELECT id, BTRIM(attendees) AS raw FROM calendar_events
+              WHERE attendees IS NOT NULL AND BTRIM(attendees) NOT IN ('', '[]', '{}', 'null')
+    LOOP
+        BEGIN
+            IF LEFT(ev.raw, 1) = '[' THEN
+                SELECT ARRAY(SELECT jsonb_array_elements_text(ev.raw::jsonb)) INTO list;
+            ELSE
+                list := ev.raw::TEXT[];
+            END IF;
+        EXCEPTION WHEN OTHERS THEN
+            RAISE NOTICE 'event %: cannot parse attendees %', ev.id, ev.raw;
+            CONTINUE;
+        END;
+
+        INSERT INTO event_attendees (event_id, position, attendee)
+        SELECT ev.id, ROW_NUMBER() OVER (ORDER BY first_seen), attendee
+        FROM (
+            SELECT BTRIM(a) AS attendee, MIN(ord) AS first_seen
+            FROM UNNEST(list) WITH ORDINALITY AS t(a, ord)
+            WHERE a IS NOT NULL AND BTRIM(a) <> ''
+            GROUP BY BTRIM(a)
+        ) cleaned;
+    END LOOP;
+END
+$$;
+
+ALTER TABLE calendar_events DROP COLUMN attendees;
@@ -0,0 +1,2 @@
+ALTER TABLE calendar_events DROP COLUMN version, DROP COLUMN updated_at;
+ALTER TABLE calendars DROP COLUMN version, DROP COLUMN updated_at;
@@ -0,0 +1,6 @@
+-- Whatever is in the trash is deleted for good.
+DELETE FROM calendars WHERE deleted_at IS NOT NULL;
+DELETE FROM calendar_events WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE calendar_events DROP COLUMN deleted_at;
+ALTER TABLE calendars DROP COLUMN deleted_at;
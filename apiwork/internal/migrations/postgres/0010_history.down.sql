@@ -0,0 +1,2 @@
+DROP TABLE history;
+DROP FUNCTION history_append_only();
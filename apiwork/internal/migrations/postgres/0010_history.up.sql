@@ -0,0 +1,27 @@
+-- Every change to a calendar or event is recorded with who made it, how,
+-- and the row before and after. Rows are never changed or deleted, and
+-- outlive the calendars and events they describe, so entity_id has no
+-- foreign key.
+CREATE TABLE history (
+    id         SERIAL PRIMARY KEY,
+    entity     TEXT NOT NULL CHECK (entity IN ('calendar', 'event')),
+    entity_id  INTEGER NOT NULL,
+    version    INTEGER NOT NULL,
+    action     TEXT NOT NULL,
+    actor      TEXT NOT NULL DEFAULT '',
+    source     TEXT NOT NULL,
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    before     JSONB,
+    after      JSONB NOT NULL
+);
+
+CREATE INDEX history_entity_idx ON history (entity, entity_id, id);
+
+CREATE FUNCTION history_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'history is append-only';
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER history_append_only BEFORE UPDATE OR DELETE ON history
+FOR EACH ROW EXECUTE FUNCTION history_append_only();
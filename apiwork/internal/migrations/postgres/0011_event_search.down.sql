@@ -0,0 +1,9 @@
+DROP INDEX calendar_events_organizer_idx;
+DROP TRIGGER event_attendees_search_vector ON event_attendees;
+DROP FUNCTION event_attendees_search_vector();
+DROP TRIGGER calendar_events_search_vector ON calendar_events;
+DROP FUNCTION calendar_events_search_vector();
+DROP FUNCTION event_search_vector(calendar_events);
+ALTER TABLE calendar_events DROP COLUMN search_vector;
+DROP TEXT SEARCH CONFIGURATION event_search;
+-- unaccent stays installed, since other objects may use it.
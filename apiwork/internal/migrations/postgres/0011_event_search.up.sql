@@ -0,0 +1,48 @@
+-- Full-text search over events. The event_search configuration is English
+-- with accents stripped, so "Café" matches "cafe" and "meetings" matches
+-- "meeting". search_vector weights the title highest, then the location,
+-- then the organizer and attendees, then the description. Attendees live
+-- in their own table, so triggers on both tables keep it up to date.
+CREATE EXTENSION IF NOT EXISTS unaccent;
+
+CREATE TEXT SEARCH CONFIGURATION event_search (COPY = english);
+ALTER TEXT SEARCH CONFIGURATION event_search
+    ALTER MAPPING FOR hword, hword_part, word WITH unaccent, english_stem;
+
+ALTER TABLE calendar_events ADD COLUMN search_vector tsvector;
+
+CREATE FUNCTION event_search_vector(e calendar_events) RETURNS tsvector AS $$
+    SELECT setweight(to_tsvector('event_search', COALESCE(e.title, '')), 'A') ||
+           setweight(to_tsvector('event_search', COALESCE(e.location, '')), 'B') ||
+           setweight(to_tsvector('event_search', COALESCE(e.organizer, '') || ' ' ||
+               COALESCE((SELECT string_agg(a.attendee, ' ') FROM event_attendees a WHERE a.event_id = e.id), '')), 'C') ||
+           setweight(to_tsvector('event_search', COALESCE(e.description, '')), 'D')
+$$ LANGUAGE sql STABLE;
+
+CREATE FUNCTION calendar_events_search_vector() RETURNS trigger AS $$
+BEGIN
+    NEW.search_vector := event_search_vector(NEW);
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER calendar_events_search_vector
+BEFORE INSERT OR UPDATE OF title, location, organizer, description ON calendar_events
+FOR EACH ROW EXECUTE FUNCTION calendar_events_search_vector();
+
+CREATE FUNCTION event_attendees_search_vector() RETURNS trigger AS $$
+BEGIN
+    UPDATE calendar_events e SET search_vector = event_search_vector(e)
+    WHERE e.id = CASE WHEN TG_OP = 'DELETE' THEN OLD.event_id ELSE NEW.event_id END;
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER event_attendees_search_vector
+AFTER INSERT OR UPDATE OR DELETE ON event_attendees
+FOR EACH ROW EXECUTE FUNCTION event_attendees_search_vector();
+
+UPDATE calendar_events e SET search_vector = event_search_vector(e);
+
+CREATE INDEX calendar_events_search_idx ON calendar_events USING GIN (search_vector);
+CREATE INDEX calendar_events_organizer_idx ON calendar_events (LOWER(organizer));
@@ -0,0 +1,2 @@
+DROP INDEX history_txid_idx;
+ALTER TABLE history DROP COLUMN txid;
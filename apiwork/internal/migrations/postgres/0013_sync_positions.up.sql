@@ -0,0 +1,7 @@
+-- Syncs read the history from a transaction id rather than an entry id
+-- (see store.Postgres.Sync): entry ids are handed out as transactions
+-- insert, not as they commit. Existing entries get the id of this
+-- migration's transaction.
+ALTER TABLE history ADD COLUMN txid xid8 NOT NULL DEFAULT pg_current_xact_id();
+
+CREATE INDEX history_txid_idx ON history (txid);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS calendar_events;
+DROP TABLE IF EXISTS calendars;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,41 @@
+-- The tables the API started out with.
+
+CREATE TABLE users (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    username     TEXT NOT NULL UNIQUE,
+    email        TEXT NOT NULL,
+    password     TEXT NOT NULL DEFAULT '',
+    date_created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE calendars (
+    id      INTEGER PRIMARY KEY AUTOINCREMENT,
+    name    TEXT NOT NULL,
+    color   TEXT NOT NULL DEFAULT '',
+    visible BOOLEAN NOT NULL DEFAULT TRUE,
+    user_id INTEGER REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX calendars_user_id_idx ON calendars (user_id);
+
+-- start_time and end_time are "HH:MM" clock times, day is the ISO weekday
+-- (1 = Monday ... 7 = Sunday) and date is "YYYY-MM-DD", or NULL for events
+-- that repeat every week on that day.
+CREATE TABLE calendar_events (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    title       TEXT NOT NULL DEFAULT '',
+    start_time  TEXT NOT NULL,
+    end_time    TEXT NOT NULL,
+    color       TEXT NOT NULL DEFAULT '',
+    day         INTEGER NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    location    TEXT NOT NULL DEFAULT '',
+    attendees   TEXT NOT NULL DEFAULT '[]',
+    organizer   TEXT NOT NULL DEFAULT '',
+    calendar_id INTEGER NOT NULL REFERENCES calendars (id) ON DELETE CASCADE,
+    date        TEXT,
+    user_id     INTEGER REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX calendar_events_calendar_id_idx ON calendar_events (calendar_id);
+CREATE INDEX calendar_events_user_id_idx ON calendar_events (user_id);
@@ -0,0 +1,5 @@
+DROP TABLE notifications;
+DROP TABLE reminder_deliveries;
+DROP TABLE event_reminder_settings;
+DROP TABLE event_reminders;
+DROP TABLE calendar_reminders;
@@ -0,0 +1,53 @@
+CREATE TABLE calendar_reminders (
+    calendar_id    INTEGER NOT NULL REFERENCES calendars (id) ON DELETE CASCADE,
+    minutes_before INTEGER NOT NULL,
+    method         TEXT NOT NULL,
+    PRIMARY KEY (calendar_id, minutes_before, method)
+);
+
+CREATE TABLE event_reminders (
+    event_id       INTEGER NOT NULL REFERENCES calendar_events (id) ON DELETE CASCADE,
+    minutes_before INTEGER NOT NULL,
+    method         TEXT NOT NULL,
+    PRIMARY KEY (event_id, minutes_before, method)
+);
+
+-- Events without a row here use their calendar's default reminders.
+CREATE TABLE event_reminder_settings (
+    event_id    INTEGER PRIMARY KEY REFERENCES calendar_events (id) ON DELETE CASCADE,
+    use_default BOOLEAN NOT NULL DEFAULT TRUE
+);
+
+-- One row per reminder to send; see reminders.Scheduler for the life cycle.
+CREATE TABLE reminder_deliveries (
+    id               INTEGER PRIMARY KEY AUTOINCREMENT,
+    event_id         INTEGER NOT NULL REFERENCES calendar_events (id) ON DELETE CASCADE,
+    occurrence_start TIMESTAMP NOT NULL,
+    minutes_before   INTEGER NOT NULL,
+    method           TEXT NOT NULL,
+    recipient        TEXT NOT NULL,
+    fire_at          TIMESTAMP NOT NULL,
+    status           TEXT NOT NULL DEFAULT 'pending',
+    attempts         INTEGER NOT NULL DEFAULT 0,
+    locked_by        TEXT,
+    locked_until     TIMESTAMP,
+    last_error       TEXT,
+    sent_at          TIMESTAMP,
+    created_at       TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (event_id, occurrence_start, minutes_before, method, recipient)
+);
+
+CREATE INDEX reminder_deliveries_due_idx ON reminder_deliveries (fire_at) WHERE status IN ('pending', 'sending');
+
+CREATE TABLE notifications (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    delivery_id BIGINT NOT NULL UNIQUE REFERENCES reminder_deliveries (id) ON DELETE CASCADE,
+    recipient   TEXT NOT NULL,
+    event_id    INTEGER,
+    title       TEXT NOT NULL,
+    body        TEXT NOT NULL,
+    created_at  TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    read_at     TIMESTAMP
+);
+
+CREATE INDEX notifications_recipient_idx ON notifications (recipient, created_at DESC);
@@ -0,0 +1,4 @@
+DROP INDEX calendar_events_uid_idx;
+DROP TABLE event_rsvps;
+ALTER TABLE calendar_events DROP COLUMN sequence;
+ALTER TABLE calendar_events DROP COLUMN uid;
@@ -0,0 +1,14 @@
+-- iCalendar identity of events, assigned lazily by itip.EnsureUID.
+ALTER TABLE calendar_events ADD COLUMN uid TEXT;
+ALTER TABLE calendar_events ADD COLUMN sequence INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX calendar_events_uid_idx ON calendar_events (uid);
+
+-- Answers of external attendees to iMIP invitations.
+CREATE TABLE event_rsvps (
+    event_id   INTEGER NOT NULL REFERENCES calendar_events (id) ON DELETE CASCADE,
+    attendee   TEXT NOT NULL,
+    status     TEXT NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (event_id, attendee)
+);
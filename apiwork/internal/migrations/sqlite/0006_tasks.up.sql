@@ -0,0 +1,19 @@
+-- To-dos (VTODO). due_date is "YYYY-MM-DD" and due_time "HH:MM"; a task
+-- without a due_time is due at the end of its day.
+CREATE TABLE tasks (
+    id               INTEGER PRIMARY KEY AUTOINCREMENT,
+    calendar_id      INTEGER NOT NULL REFERENCES calendars (id) ON DELETE CASCADE,
+    title            TEXT NOT NULL,
+    description      TEXT NOT NULL DEFAULT '',
+    due_date         TEXT,
+    due_time         TEXT,
+    priority         INTEGER NOT NULL DEFAULT 0,
+    status           TEXT NOT NULL DEFAULT 'NEEDS-ACTION',
+    percent_complete INTEGER NOT NULL DEFAULT 0,
+    recurrence       TEXT NOT NULL DEFAULT '',
+    completed_at     TIMESTAMP,
+    uid              TEXT NOT NULL
+);
+
+CREATE INDEX tasks_calendar_id_idx ON tasks (calendar_id);
+CREATE INDEX tasks_due_date_idx ON tasks (due_date) WHERE status NOT IN ('COMPLETED', 'CANCELLED');
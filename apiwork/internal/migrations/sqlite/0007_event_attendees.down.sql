@@ -0,0 +1,11 @@
+ALTER TABLE calendar_events ADD COLUMN attendees TEXT NOT NULL DEFAULT '[]';
+
+UPDATE calendar_events
+SET attendees = (
+    SELECT json_group_array(attendee) FROM (
+        SELECT attendee FROM event_attendees a WHERE a.event_id = calendar_events.id ORDER BY a.position
+    )
+)
+WHERE EXISTS (SELECT 1 FROM event_attendees a WHERE a.event_id = calendar_events.id);
+
+DROP TABLE event_attendees;
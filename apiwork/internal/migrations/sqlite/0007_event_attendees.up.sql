@@ -0,0 +1,24 @@
+-- Attendees move out of calendar_events.attendees into one row per
+-- attendee. SQLite databases only ever stored JSON arrays; rows that are
+-- not valid JSON are left without attendees.
+CREATE TABLE event_attendees (
+    event_id INTEGER NOT NULL REFERENCES calendar_events (id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    attendee TEXT NOT NULL,
+    PRIMARY KEY (event_id, position),
+    UNIQUE (event_id, attendee)
+);
+
+CREATE INDEX event_attendees_attendee_idx ON event_attendees (LOWER(attendee));
+
+INSERT INTO event_attendees (event_id, position, attendee)
+SELECT event_id, ROW_NUMBER() OVER (PARTITION BY event_id ORDER BY first_seen), attendee
+FROM (
+    SELECT e.id AS event_id, TRIM(j.value) AS attendee, MIN(j.key) AS first_seen
+    FROM calendar_events e, json_each(e.attendees) j
+    WHERE json_valid(e.attendees) AND json_type(e.attendees) = 'array'
+      AND j.type = 'text' AND TRIM(j.value) <> ''
+    GROUP BY e.id, TRIM(j.value)
+);
+
+ALTER TABLE calendar_events DROP COLUMN attendees;
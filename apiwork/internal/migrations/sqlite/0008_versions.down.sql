@@ -0,0 +1,7 @@
+DROP TRIGGER calendar_events_updated_at;
+ALTER TABLE calendar_events DROP COLUMN updated_at;
+ALTER TABLE calendar_events DROP COLUMN version;
+
+DROP TRIGGER calendars_updated_at;
+ALTER TABLE calendars DROP COLUMN updated_at;
+ALTER TABLE calendars DROP COLUMN version;
@@ -0,0 +1,23 @@
+-- Calendars and events carry a version that every write increments, so
+-- clients can make conditional updates with If-Match. SQLite can't add a
+-- column defaulting to CURRENT_TIMESTAMP, so a trigger fills in updated_at
+-- for new rows.
+ALTER TABLE calendars ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE calendars ADD COLUMN updated_at TIMESTAMP;
+UPDATE calendars SET updated_at = CURRENT_TIMESTAMP;
+
+CREATE TRIGGER calendars_updated_at AFTER INSERT ON calendars
+WHEN NEW.updated_at IS NULL
+BEGIN
+    UPDATE calendars SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+
+ALTER TABLE calendar_events ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE calendar_events ADD COLUMN updated_at TIMESTAMP;
+UPDATE calendar_events SET updated_at = CURRENT_TIMESTAMP;
+
+CREATE TRIGGER calendar_events_updated_at AFTER INSERT ON calendar_events
+WHEN NEW.updated_at IS NULL
+BEGIN
+    UPDATE calendar_events SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
@@ -0,0 +1,8 @@
+-- Whatever is in the trash is deleted for good.
+DELETE FROM calendars WHERE deleted_at IS NOT NULL;
+DELETE FROM calendar_events WHERE deleted_at IS NOT NULL;
+
+DROP INDEX calendar_events_deleted_at_idx;
+DROP INDEX calendars_deleted_at_idx;
+ALTER TABLE calendar_events DROP COLUMN deleted_at;
+ALTER TABLE calendars DROP COLUMN deleted_at;
@@ -0,0 +1,9 @@
+-- Deleting a calendar or event moves it to the trash by setting deleted_at;
+-- the purger removes it for good once the retention period has passed.
+-- Events deleted along with their calendar share its deleted_at, which is
+-- how restoring the calendar finds them.
+ALTER TABLE calendars ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE calendar_events ADD COLUMN deleted_at TIMESTAMP;
+
+CREATE INDEX calendars_deleted_at_idx ON calendars (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX calendar_events_deleted_at_idx ON calendar_events (deleted_at) WHERE deleted_at IS NOT NULL;
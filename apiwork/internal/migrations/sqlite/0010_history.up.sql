@@ -0,0 +1,28 @@
+-- Every change to a calendar or event is recorded with who made it, how,
+-- and the row before and after. Rows are never changed or deleted, and
+-- outlive the calendars and events they describe, so entity_id has no
+-- foreign key.
+CREATE TABLE history (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    entity     TEXT NOT NULL CHECK (entity IN ('calendar', 'event')),
+    entity_id  INTEGER NOT NULL,
+    version    INTEGER NOT NULL,
+    action     TEXT NOT NULL,
+    actor      TEXT NOT NULL DEFAULT '',
+    source     TEXT NOT NULL,
+    changed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    before     TEXT,
+    after      TEXT NOT NULL
+);
+
+CREATE INDEX history_entity_idx ON history (entity, entity_id, id);
+
+CREATE TRIGGER history_no_update BEFORE UPDATE ON history
+BEGIN
+    SELECT RAISE(ABORT, 'history is append-only');
+END;
+
+CREATE TRIGGER history_no_delete BEFORE DELETE ON history
+BEGIN
+    SELECT RAISE(ABORT, 'history is append-only');
+END;
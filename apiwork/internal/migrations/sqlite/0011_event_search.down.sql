@@ -0,0 +1 @@
+DROP INDEX calendar_events_organizer_idx;
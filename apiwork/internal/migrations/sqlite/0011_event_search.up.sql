@@ -0,0 +1,4 @@
+-- Postgres adds a full-text index over events here. SQLite has nothing
+-- that folds accents, so store.SQLite matches and ranks events in Go and
+-- only the organizer filter gets an index.
+CREATE INDEX calendar_events_organizer_idx ON calendar_events (LOWER(organizer));
@@ -0,0 +1 @@
+DROP TABLE saved_searches;
@@ -0,0 +1,12 @@
+-- Searches users keep. Pinned ones are listed among the calendars as smart
+-- calendars, whose events are whatever matches the query at the time.
+CREATE TABLE saved_searches (
+    id      INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name    TEXT NOT NULL,
+    query   TEXT NOT NULL,
+    color   TEXT NOT NULL DEFAULT '',
+    pinned  BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE INDEX saved_searches_user_id_idx ON saved_searches (user_id);
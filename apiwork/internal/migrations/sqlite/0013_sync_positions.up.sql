@@ -0,0 +1,3 @@
+-- Postgres records the transaction of each history entry here, for syncs.
+-- SQLite runs one write at a time, so history ids already come in commit
+-- order and syncs read from an id.
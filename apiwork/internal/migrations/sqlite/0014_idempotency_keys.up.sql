@@ -0,0 +1,13 @@
+-- POSTs sent with an Idempotency-Key, kept for the idempotency window so
+-- that a retry gets the original response instead of running again.
+-- status is 0 while the first request is still running.
+CREATE TABLE idempotency_keys (
+    key TEXT PRIMARY KEY,
+    fingerprint TEXT NOT NULL,
+    claimed_at TIMESTAMP NOT NULL,
+    status INTEGER NOT NULL DEFAULT 0,
+    header TEXT NOT NULL DEFAULT '{}',
+    body BLOB
+);
+
+CREATE INDEX idempotency_keys_claimed_at_idx ON idempotency_keys (claimed_at);
@@ -0,0 +1,155 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Define the Go structs based on your TypeScript interfaces
+// The validate tags are checked with package validate before a calendar or
+// event is stored.
+type CalendarEvent struct {
+	ID          string   `json:"id"`
+	Title       string   `json:"title" validate:"required,max=200"`
+	StartTime   string   `json:"startTime" validate:"required,clock"`
+	EndTime     string   `json:"endTime" validate:"required,clock"`
+	Color       string   `json:"color" validate:"color"`
+	Day         int      `json:"day" validate:"required,min=1,max=7"`
+	Description string   `json:"description" validate:"max=10000"`
+	Location    string   `json:"location" validate:"max=500"`
+	Attendees   []string `json:"attendees" validate:"max=100,dive,max=254"`
+	Organizer   string   `json:"organizer" validate:"max=254"`
+	CalendarID  string   `json:"calendarId" validate:"required"`
+	Date        *string  `json:"date,omitempty" validate:"date"` // Use pointer to handle optional field
+	// Version is incremented on every change. Updates and deletes must
+	// name the version they were based on.
+	Version   int    `json:"version,omitempty"`
+	UpdatedAt string `json:"updatedAt,omitempty"`
+	// DeletedAt is set on events in the trash.
+	DeletedAt *string `json:"deletedAt,omitempty"`
+}
+
+type NCalendarEvent struct {
+	Title       string   `json:"title" validate:"required,max=200"`
+	StartTime   string   `json:"startTime" validate:"required,clock"`
+	EndTime     string   `json:"endTime" validate:"required,clock"`
+	Color       string   `json:"color" validate:"color"`
+	Day         int      `json:"day" validate:"required,min=1,max=7"`
+	Description string   `json:"description" validate:"max=10000"`
+	Location    string   `json:"location" validate:"max=500"`
+	Attendees   []string `json:"attendees" validate:"max=100,dive,max=254"`
+	Organizer   string   `json:"organizer" validate:"max=254"`
+	CalendarID  string   `json:"calendarId" validate:"required"`
+	Date        *string  `json:"date,omitempty" validate:"date"` // Use pointer to handle optional field
+}
+
+type Calendar struct {
+	ID      string `json:"id"`
+	Name    string `json:"name" validate:"required,max=100"`
+	Color   string `json:"color" validate:"color"`
+	Visible bool   `json:"visible"`
+	// Version, UpdatedAt and DeletedAt work like those of CalendarEvent.
+	Version   int     `json:"version,omitempty"`
+	UpdatedAt string  `json:"updatedAt,omitempty"`
+	DeletedAt *string `json:"deletedAt,omitempty"`
+	// Query is set on smart calendars, which show a pinned SavedSearch.
+	Query string `json:"query,omitempty"`
+}
+
+type NCalendar struct {
+	Name    string `json:"name" validate:"required,max=100"`
+	Color   string `json:"color" validate:"color"`
+	Visible bool   `json:"visible"`
+}
+
+// SavedSearch is a search query a user keeps. Pinned ones are listed
+// among the calendars as smart calendars, whose events are the events that
+// match the query.
+type SavedSearch struct {
+	ID     string `json:"id"`
+	UserID string `json:"userId"`
+	Name   string `json:"name"`
+	Query  string `json:"query"`
+	Color  string `json:"color"`
+	Pinned bool   `json:"pinned"`
+}
+
+type NSavedSearch struct {
+	Name   string `json:"name"`
+	Query  string `json:"query"`
+	Color  string `json:"color"`
+	Pinned bool   `json:"pinned"`
+}
+
+// Trash lists deleted calendars and events that can still be restored,
+// most recently deleted first.
+type Trash struct {
+	Calendars []Calendar      `json:"calendars"`
+	Events    []CalendarEvent `json:"events"`
+}
+
+// HistoryEntry records one change to a calendar or event: who made it,
+// through what, and the calendar or event before and after. Before is
+// null for a create.
+type HistoryEntry struct {
+	ID        string          `json:"id"`
+	Entity    string          `json:"entity"`
+	EntityID  string          `json:"entityId"`
+	Version   int             `json:"version"`
+	Action    string          `json:"action"`
+	Actor     string          `json:"actor"`
+	Source    string          `json:"source"`
+	ChangedAt string          `json:"changedAt"`
+	Before    json.RawMessage `json:"before"`
+	After     json.RawMessage `json:"after"`
+	// Changes lists the fields that differ between Before and After.
+	Changes map[string]FieldChange `json:"changes"`
+}
+
+// FieldChange is a field's value before and after a change.
+type FieldChange struct {
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+}
+
+// EventSearchResults is one page of the events that matched a search,
+// best match first.
+type EventSearchResults struct {
+	Results []EventSearchHit `json:"results"`
+	Total   int              `json:"total"`
+	Limit   int              `json:"limit"`
+	Offset  int              `json:"offset"`
+}
+
+// EventSearchHit is an event that matched a search. Highlights has the
+// matching parts of its title, location and description, HTML-escaped with
+// the matches wrapped in <mark>.
+type EventSearchHit struct {
+	CalendarEvent
+	Rank       float64           `json:"rank"`
+	Highlights map[string]string `json:"highlights"`
+}
+
+// SyncDelta brings a replica of the calendars and events up to date. It
+// holds the calendars and events created or changed since the sync token
+// the replica last got, and the ids of those deleted since, or everything
+// if Full is set. The replica keeps SyncToken for its next sync.
+type SyncDelta struct {
+	Full             bool            `json:"full"`
+	Calendars        []Calendar      `json:"calendars"`
+	Events           []CalendarEvent `json:"events"`
+	DeletedCalendars []string        `json:"deletedCalendars"`
+	DeletedEvents    []string        `json:"deletedEvents"`
+	SyncToken        string          `json:"syncToken"`
+}
+
+// IdempotentRequest is a POST made with an Idempotency-Key: a fingerprint
+// of the request and, once it has finished, its response. Status is 0
+// while the request is still running.
+type IdempotentRequest struct {
+	Fingerprint string
+	ClaimedAt   time.Time
+	Status      int
+	Header      map[string]string
+	Body        []byte
+}
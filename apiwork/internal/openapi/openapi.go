@@ -0,0 +1,264 @@
+// Package openapi holds the API's OpenAPI 3 description, openapi.json, and
+// a page that shows it, and checks the description against the API: the
+// routes it serves and the Go types it reads and writes.
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Spec is the OpenAPI document.
+//
+//go:embed openapi.json
+var Spec []byte
+
+// Docs is an HTML page that renders Spec, fetched from /openapi.json.
+//
+//go:embed docs.html
+var Docs []byte
+
+// A Route is a method and path the API serves, with path parameters in
+// braces as in the spec.
+type Route struct {
+	Method string
+	Path   string
+}
+
+type document struct {
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas map[string]*schema `json:"schemas"`
+	} `json:"components"`
+}
+
+type schema struct {
+	Ref        string             `json:"$ref"`
+	Type       string             `json:"type"`
+	Properties map[string]*schema `json:"properties"`
+	Items      *schema            `json:"items"`
+	AllOf      []*schema          `json:"allOf"`
+	MaxLength  *int               `json:"maxLength"`
+	MaxItems   *int               `json:"maxItems"`
+}
+
+var methods = map[string]bool{"get": true, "put": true, "post": true, "delete": true, "patch": true, "head": true, "options": true}
+
+// Check lists how Spec and the API differ: routes served that the spec
+// leaves out or the other way round, and component schemas that don't
+// match the Go types registered for them in schemas. A schema matches when
+// it has the same properties as the type's JSON fields, with the same JSON
+// types and the maximum lengths of their validate tags.
+func Check(routes []Route, schemas map[string]interface{}) ([]string, error) {
+	var doc document
+	if err := json.Unmarshal(Spec, &doc); err != nil {
+		return nil, fmt.Errorf("reading openapi.json: %w", err)
+	}
+	c := checker{doc: doc, types: map[string]reflect.Type{}}
+	for name, value := range schemas {
+		c.types[name] = reflect.TypeOf(value)
+	}
+
+	served := map[Route]bool{}
+	for _, route := range routes {
+		route.Method = strings.ToLower(route.Method)
+		served[route] = true
+		if _, ok := doc.Paths[route.Path][route.Method]; !ok {
+			c.problem("%s %s is served but not in the spec", strings.ToUpper(route.Method), route.Path)
+		}
+	}
+	for path, operations := range doc.Paths {
+		for method := range operations {
+			if methods[method] && !served[Route{method, path}] {
+				c.problem("%s %s is in the spec but not served", strings.ToUpper(method), path)
+			}
+		}
+	}
+
+	for name, value := range schemas {
+		s, ok := doc.Components.Schemas[name]
+		if !ok {
+			c.problem("schema %s is missing", name)
+			continue
+		}
+		c.compare(name, s, reflect.TypeOf(value), "")
+	}
+	for name := range doc.Components.Schemas {
+		if _, ok := schemas[name]; !ok {
+			c.problem("schema %s has no Go type to check it against", name)
+		}
+	}
+
+	sort.Strings(c.problems)
+	return c.problems, nil
+}
+
+type checker struct {
+	doc      document
+	types    map[string]reflect.Type
+	problems []string
+}
+
+func (c *checker) problem(format string, args ...interface{}) {
+	c.problems = append(c.problems, fmt.Sprintf(format, args...))
+}
+
+// resolve follows s's $ref, if it has one.
+func (c *checker) resolve(where string, s *schema) *schema {
+	for s != nil && s.Ref != "" {
+		name := strings.TrimPrefix(s.Ref, "#/components/schemas/")
+		target, ok := c.doc.Components.Schemas[name]
+		if !ok {
+			c.problem("%s refers to missing schema %s", where, s.Ref)
+			return nil
+		}
+		s = target
+	}
+	return s
+}
+
+// properties are the properties of s and the schemas it is allOf.
+func (c *checker) properties(where string, s *schema) map[string]*schema {
+	props := map[string]*schema{}
+	for name, p := range s.Properties {
+		props[name] = p
+	}
+	for _, part := range s.AllOf {
+		if part = c.resolve(where, part); part != nil {
+			for name, p := range c.properties(where, part) {
+				props[name] = p
+			}
+		}
+	}
+	return props
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// compare checks s, found at where, against t, with rules the validate
+// tag of the field of type t. A reference to a schema for t is left to the
+// check of that schema.
+func (c *checker) compare(where string, s *schema, t reflect.Type, rules string) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if name, ok := strings.CutPrefix(s.Ref, "#/components/schemas/"); ok {
+		if registered, ok := c.types[name]; ok {
+			if registered != t {
+				c.problem("%s refers to %s, which describes %s, not %s", where, name, registered, t)
+			}
+			return
+		}
+	}
+	if s = c.resolve(where, s); s == nil {
+		return
+	}
+	limit, itemRules := maxRule(rules)
+
+	switch {
+	case t == reflect.TypeOf(json.RawMessage{}) || t.Kind() == reflect.Interface:
+		return // any JSON
+	case t == timeType || t.Kind() == reflect.String:
+		c.expectType(where, s, "string")
+		if limit != nil && (s.MaxLength == nil || *s.MaxLength != *limit) {
+			c.problem("%s should have maxLength %d", where, *limit)
+		}
+	case t.Kind() == reflect.Bool:
+		c.expectType(where, s, "boolean")
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		c.expectType(where, s, "integer")
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		c.expectType(where, s, "number")
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		c.expectType(where, s, "array")
+		if limit != nil && (s.MaxItems == nil || *s.MaxItems != *limit) {
+			c.problem("%s should have maxItems %d", where, *limit)
+		}
+		if s.Items != nil {
+			c.compare(where+"[]", s.Items, t.Elem(), itemRules)
+		}
+	case t.Kind() == reflect.Map:
+		c.expectType(where, s, "object")
+	case t.Kind() == reflect.Struct:
+		c.expectType(where, s, "object")
+		props := c.properties(where, s)
+		fields := jsonFields(t)
+		for name, field := range fields {
+			p, ok := props[name]
+			if !ok {
+				c.problem("%s is missing property %s", where, name)
+				continue
+			}
+			c.compare(join(where, name), p, field.Type, field.Tag.Get("validate"))
+		}
+		for name := range props {
+			if _, ok := fields[name]; !ok {
+				c.problem("%s has property %s, which %s doesn't", where, name, t)
+			}
+		}
+	}
+}
+
+func (c *checker) expectType(where string, s *schema, want string) {
+	if s.Type == "" && len(s.AllOf) > 0 && want == "object" {
+		return
+	}
+	if s.Type != want {
+		c.problem("%s should have type %s, not %q", where, want, s.Type)
+	}
+}
+
+func join(where, name string) string {
+	if where == "" {
+		return name
+	}
+	return where + "." + name
+}
+
+// maxRule finds max=N in a validate tag, and the rules that apply to each
+// item of a list.
+func maxRule(rules string) (*int, string) {
+	var limit *int
+	list := strings.Split(rules, ",")
+	for i, rule := range list {
+		if rule == "dive" {
+			return limit, strings.Join(list[i+1:], ",")
+		}
+		if n, ok := strings.CutPrefix(rule, "max="); ok {
+			if v, err := strconv.Atoi(n); err == nil {
+				limit = &v
+			}
+		}
+	}
+	return limit, ""
+}
+
+// jsonFields are the fields of struct type t by their JSON names, with
+// those of embedded structs promoted as encoding/json does.
+func jsonFields(t reflect.Type) map[string]reflect.StructField {
+	fields := map[string]reflect.StructField{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			for n, f := range jsonFields(field.Type) {
+				fields[n] = f
+			}
+			continue
+		}
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = field
+	}
+	return fields
+}
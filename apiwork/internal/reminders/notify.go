@@ -0,0 +1,121 @@
+package reminders
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Aman221/4723/internal/database"
+	"github.com/Aman221/4723/internal/mailer"
+)
+
+// Notice is a reminder ready to be delivered to one recipient.
+type Notice struct {
+	DeliveryID    int64
+	Recipient     string
+	EventID       string
+	Title         string
+	Location      string
+	Start         time.Time
+	MinutesBefore int
+}
+
+// Subject is the one-line summary used for emails and in-app titles.
+func (n Notice) Subject() string {
+	return fmt.Sprintf("Reminder: %s at %s", n.Title, n.Start.Format("Mon Jan 2 15:04"))
+}
+
+// Body is the plain-text description of the reminder.
+func (n Notice) Body() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s starts %s.\n", n.Title, n.Start.Format("Monday, January 2 at 15:04 MST"))
+	if n.Location != "" {
+		fmt.Fprintf(&b, "Location: %s\n", n.Location)
+	}
+	return b.String()
+}
+
+// Notifier delivers notices through one channel. A notice may be delivered
+// more than once, always with the same DeliveryID, which notifiers use to
+// drop or mark repeats.
+type Notifier interface {
+	Notify(ctx context.Context, n Notice) error
+}
+
+// EmailNotifier sends notices as email.
+type EmailNotifier struct {
+	Mailer mailer.Mailer
+}
+
+// Notify implements Notifier.
+func (e EmailNotifier) Notify(ctx context.Context, n Notice) error {
+	return e.Mailer.Send(ctx, mailer.Message{
+		To:      []string{n.Recipient},
+		Subject: n.Subject(),
+		Text:    n.Body(),
+		// Lets a retried delivery be recognised as a duplicate downstream.
+		Headers: map[string]string{"X-Reminder-ID": fmt.Sprint(n.DeliveryID)},
+	})
+}
+
+// InAppNotifier stores notices in the notifications table, where clients
+// read them through /notifications.
+type InAppNotifier struct{}
+
+// Notify implements Notifier. The delivery ID is unique in notifications,
+// so delivering the same notice twice leaves a single row.
+func (InAppNotifier) Notify(ctx context.Context, n Notice) error {
+	_, err := database.DB.ExecContext(ctx, `
+		INSERT INTO notifications (delivery_id, recipient, event_id, title, body, created_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		ON CONFLICT (delivery_id) DO NOTHING
+	`, n.DeliveryID, n.Recipient, n.EventID, n.Subject(), n.Body())
+	return err
+}
+
+// Notification is an in-app notice shown to a user.
+type Notification struct {
+	ID        string     `json:"id"`
+	Recipient string     `json:"recipient"`
+	EventID   string     `json:"eventId"`
+	Title     string     `json:"title"`
+	Body      string     `json:"body"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ReadAt    *time.Time `json:"readAt,omitempty"`
+}
+
+// Notifications lists a recipient's in-app notifications, newest first.
+func Notifications(recipient string, unreadOnly bool) ([]Notification, error) {
+	query := "SELECT id, recipient, event_id, title, body, created_at, read_at FROM notifications WHERE recipient = $1"
+	if unreadOnly {
+		query += " AND read_at IS NULL"
+	}
+	query += " ORDER BY created_at DESC LIMIT 200"
+
+	rows, err := database.DB.Query(query, strings.ToLower(recipient))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []Notification{}
+	for rows.Next() {
+		var n Notification
+		if err := rows.Scan(&n.ID, &n.Recipient, &n.EventID, &n.Title, &n.Body, &n.CreatedAt, &n.ReadAt); err != nil {
+			return nil, err
+		}
+		list = append(list, n)
+	}
+	return list, rows.Err()
+}
+
+// MarkRead marks a notification as read. It reports whether it existed.
+func MarkRead(id string) (bool, error) {
+	res, err := database.DB.Exec("UPDATE notifications SET read_at = COALESCE(read_at, NOW()) WHERE id = $1", id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
@@ -0,0 +1,47 @@
+package reminders
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Aman221/4723/internal/mailer"
+)
+
+type sentMail []mailer.Message
+
+func (s *sentMail) Send(ctx context.Context, msg mailer.Message) error {
+	*s = append(*s, msg)
+	return nil
+}
+
+func TestEmailNotifier(t *testing.T) {
+	start := time.Date(2026, 1, 7, 9, 0, 0, 0, time.UTC)
+	n := Notice{DeliveryID: 42, Recipient: "bob@example.com", EventID: "7", Title: "Standup", Location: "Room 4", Start: start, MinutesBefore: 10}
+	var sent sentMail
+	notifier := EmailNotifier{Mailer: &sent}
+	// A repeated delivery carries the same ID, for the recipient's mail
+	// system to recognise.
+	for i := 0; i < 2; i++ {
+		if err := notifier.Notify(context.Background(), n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(sent) != 2 {
+		t.Fatalf("sent %d messages, want 2", len(sent))
+	}
+	for _, msg := range sent {
+		if msg.Headers["X-Reminder-ID"] != "42" {
+			t.Errorf("X-Reminder-ID is %q, want 42", msg.Headers["X-Reminder-ID"])
+		}
+		if len(msg.To) != 1 || msg.To[0] != "bob@example.com" {
+			t.Errorf("sent to %v", msg.To)
+		}
+		if want := "Reminder: Standup at Wed Jan 7 09:00"; msg.Subject != want {
+			t.Errorf("subject is %q, want %q", msg.Subject, want)
+		}
+		if want := "Standup starts Wednesday, January 7 at 09:00 UTC.\nLocation: Room 4\n"; msg.Text != want {
+			t.Errorf("text is %q, want %q", msg.Text, want)
+		}
+	}
+}
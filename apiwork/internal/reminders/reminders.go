@@ -0,0 +1,196 @@
+// Package reminders stores reminder settings for events and calendars and
+// runs the scheduler that delivers them.
+//
+// A calendar has a list of default reminders. An event either uses its
+// calendar's defaults or its own overrides. The scheduler turns upcoming
+// reminders into rows of reminder_deliveries, whose unique key makes each
+// reminder one delivery no matter how many server instances run.
+//
+// Deliveries are at least once: a notice is handed to its Notifier again if
+// the instance sending it dies before recording that it was sent. Each
+// notice carries its delivery ID so that a repeat can be recognised; in-app
+// notifications are stored once per ID, and emails carry it in an
+// X-Reminder-ID header.
+package reminders
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Aman221/4723/internal/database"
+)
+
+// Delivery methods.
+const (
+	MethodEmail = "email"
+	MethodInApp = "in-app"
+)
+
+// MaxMinutesBefore caps how early a reminder may fire (four weeks).
+const MaxMinutesBefore = 4 * 7 * 24 * 60
+
+// Reminder fires MinutesBefore minutes before an event starts.
+type Reminder struct {
+	MinutesBefore int    `json:"minutesBefore"`
+	Method        string `json:"method"`
+}
+
+// EventSettings are the reminders of a single event.
+type EventSettings struct {
+	UseDefault bool       `json:"useDefault"`
+	Overrides  []Reminder `json:"overrides"`
+	// Effective lists the reminders that will actually fire.
+	Effective []Reminder `json:"effective"`
+}
+
+// Validate checks a list of reminders.
+func Validate(list []Reminder) error {
+	seen := map[Reminder]bool{}
+	for _, r := range list {
+		if r.Method != MethodEmail && r.Method != MethodInApp {
+			return fmt.Errorf("method must be %q or %q", MethodEmail, MethodInApp)
+		}
+		if r.MinutesBefore < 0 || r.MinutesBefore > MaxMinutesBefore {
+			return fmt.Errorf("minutesBefore must be between 0 and %d", MaxMinutesBefore)
+		}
+		if seen[r] {
+			return errors.New("duplicate reminder")
+		}
+		seen[r] = true
+	}
+	return nil
+}
+
+func queryReminders(query string, args ...interface{}) ([]Reminder, error) {
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []Reminder{}
+	for rows.Next() {
+		var r Reminder
+		if err := rows.Scan(&r.MinutesBefore, &r.Method); err != nil {
+			return nil, err
+		}
+		list = append(list, r)
+	}
+	return list, rows.Err()
+}
+
+// CalendarDefaults returns the default reminders of a calendar.
+func CalendarDefaults(calendarID string) ([]Reminder, error) {
+	return queryReminders("SELECT minutes_before, method FROM calendar_reminders WHERE calendar_id = $1 ORDER BY minutes_before DESC, method", calendarID)
+}
+
+// SetCalendarDefaults replaces the default reminders of a calendar.
+func SetCalendarDefaults(calendarID string, list []Reminder) error {
+	if err := Validate(list); err != nil {
+		return err
+	}
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM calendar_reminders WHERE calendar_id = $1", calendarID); err != nil {
+		return err
+	}
+	for _, r := range list {
+		if _, err := tx.Exec("INSERT INTO calendar_reminders (calendar_id, minutes_before, method) VALUES ($1, $2, $3)",
+			calendarID, r.MinutesBefore, r.Method); err != nil {
+			return err
+		}
+	}
+	// Pending deliveries were computed from the old defaults.
+	_, err = tx.Exec(`
+		DELETE FROM reminder_deliveries
+		WHERE status = 'pending' AND event_id IN (SELECT id FROM calendar_events WHERE calendar_id = $1)
+	`, calendarID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ForEvent returns the reminder settings of an event.
+func ForEvent(eventID string) (EventSettings, error) {
+	settings := EventSettings{UseDefault: true}
+
+	var calendarID string
+	err := database.DB.QueryRow(`
+		SELECT e.calendar_id, COALESCE(s.use_default, TRUE)
+		FROM calendar_events e
+		LEFT JOIN event_reminder_settings s ON s.event_id = e.id
+		WHERE e.id = $1 AND e.deleted_at IS NULL
+	`, eventID).Scan(&calendarID, &settings.UseDefault)
+	if errors.Is(err, sql.ErrNoRows) {
+		return settings, sql.ErrNoRows
+	}
+	if err != nil {
+		return settings, err
+	}
+
+	settings.Overrides, err = queryReminders("SELECT minutes_before, method FROM event_reminders WHERE event_id = $1 ORDER BY minutes_before DESC, method", eventID)
+	if err != nil {
+		return settings, err
+	}
+	if settings.UseDefault {
+		settings.Effective, err = CalendarDefaults(calendarID)
+	} else {
+		settings.Effective = settings.Overrides
+	}
+	return settings, err
+}
+
+// SetForEvent stores whether an event uses its calendar's defaults and its
+// own reminders.
+func SetForEvent(eventID string, useDefault bool, overrides []Reminder) error {
+	if err := Validate(overrides); err != nil {
+		return err
+	}
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO event_reminder_settings (event_id, use_default) VALUES ($1, $2)
+		ON CONFLICT (event_id) DO UPDATE SET use_default = EXCLUDED.use_default
+	`, eventID, useDefault)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM event_reminders WHERE event_id = $1", eventID); err != nil {
+		return err
+	}
+	for _, r := range overrides {
+		if _, err := tx.Exec("INSERT INTO event_reminders (event_id, minutes_before, method) VALUES ($1, $2, $3)",
+			eventID, r.MinutesBefore, r.Method); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec("DELETE FROM reminder_deliveries WHERE event_id = $1 AND status = 'pending'", eventID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// EventChanged drops reminders that were scheduled from an event's previous
+// time. The scheduler recomputes them on its next pass.
+func EventChanged(eventID string) error {
+	_, err := database.DB.Exec("DELETE FROM reminder_deliveries WHERE event_id = $1 AND status = 'pending'", eventID)
+	return err
+}
+
+// EventDeleted drops, in tx, the reminders an event that went into the
+// trash was due to send. Its settings stay, so that they come back if the
+// event is restored, until the trash is purged.
+func EventDeleted(tx *sql.Tx, eventID string) error {
+	_, err := tx.Exec("DELETE FROM reminder_deliveries WHERE event_id = $1 AND status = 'pending'", eventID)
+	return err
+}
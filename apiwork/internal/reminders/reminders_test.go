@@ -0,0 +1,28 @@
+package reminders
+
+import "testing"
+
+func TestValidate(t *testing.T) {
+	valid := [][]Reminder{
+		nil,
+		{{MinutesBefore: 0, Method: MethodEmail}},
+		{{MinutesBefore: 10, Method: MethodEmail}, {MinutesBefore: 10, Method: MethodInApp}},
+		{{MinutesBefore: MaxMinutesBefore, Method: MethodInApp}},
+	}
+	for _, list := range valid {
+		if err := Validate(list); err != nil {
+			t.Errorf("Validate(%v): %v", list, err)
+		}
+	}
+	invalid := [][]Reminder{
+		{{MinutesBefore: 10, Method: "sms"}},
+		{{MinutesBefore: -1, Method: MethodEmail}},
+		{{MinutesBefore: MaxMinutesBefore + 1, Method: MethodEmail}},
+		{{MinutesBefore: 10, Method: MethodEmail}, {MinutesBefore: 10, Method: MethodEmail}},
+	}
+	for _, list := range invalid {
+		if err := Validate(list); err == nil {
+			t.Errorf("Validate(%v) passed", list)
+		}
+	}
+}
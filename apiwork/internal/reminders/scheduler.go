@@ -0,0 +1,347 @@
+package reminders
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Aman221/4723/internal/attendees"
+	"github.com/Aman221/4723/internal/database"
+)
+
+// Scheduler delivers due reminders. Several schedulers may run against the
+// same database: deliveries are materialised with INSERT ... ON CONFLICT DO
+// NOTHING and claimed with FOR UPDATE SKIP LOCKED, so one instance at a time
+// delivers each reminder. A claim is a lease; if an instance dies
+// mid-delivery the row becomes claimable again once the lease expires, and
+// the reminder is delivered again if the notice had gone out.
+type Scheduler struct {
+	// Notifiers maps a delivery method to the channel that delivers it.
+	Notifiers map[string]Notifier
+	// Location is used to interpret event dates and times, which are
+	// stored without a zone.
+	Location *time.Location
+	// Interval is how often the scheduler looks for work.
+	Interval time.Duration
+	// Lookahead is how far ahead deliveries are materialised.
+	Lookahead time.Duration
+	// Grace is how late a reminder may still be delivered, e.g. after the
+	// server was down when it was due.
+	Grace time.Duration
+	// Lease is how long a claimed delivery is reserved for one instance.
+	Lease time.Duration
+	// MaxAttempts bounds retries of a failing delivery.
+	MaxAttempts int
+	// BatchSize is the number of deliveries claimed at once.
+	BatchSize int
+
+	instance string
+}
+
+// NewScheduler returns a scheduler with sensible defaults.
+func NewScheduler(notifiers map[string]Notifier, loc *time.Location) *Scheduler {
+	host, _ := os.Hostname()
+	return &Scheduler{
+		Notifiers:   notifiers,
+		Location:    loc,
+		Interval:    30 * time.Second,
+		Lookahead:   10 * time.Minute,
+		Grace:       time.Hour,
+		Lease:       2 * time.Minute,
+		MaxAttempts: 5,
+		BatchSize:   50,
+		instance:    fmt.Sprintf("%s-%d-%d", host, os.Getpid(), time.Now().UnixNano()),
+	}
+}
+
+// Run processes reminders until ctx is cancelled.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		if err := s.Tick(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("reminders: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Tick materialises upcoming deliveries and delivers the ones that are due.
+func (s *Scheduler) Tick(ctx context.Context) error {
+	now := time.Now()
+	if err := s.materialize(ctx, now); err != nil {
+		return fmt.Errorf("scheduling: %w", err)
+	}
+	if _, err := database.DB.ExecContext(ctx, `
+		UPDATE reminder_deliveries SET status = 'expired', locked_by = NULL, locked_until = NULL
+		WHERE status = 'pending' AND fire_at < $1
+	`, now.Add(-s.Grace)); err != nil {
+		return fmt.Errorf("expiring: %w", err)
+	}
+	for {
+		n, err := s.deliverBatch(ctx)
+		if err != nil {
+			return fmt.Errorf("delivering: %w", err)
+		}
+		if n < s.BatchSize {
+			return nil
+		}
+	}
+}
+
+type scheduledReminder struct {
+	eventID   string
+	startTime string
+	day       int
+	date      sql.NullString
+	organizer string
+	attendees []string
+	reminder  Reminder
+}
+
+// materialize inserts a delivery row for every reminder that fires between
+// now-Grace and now+Lookahead.
+func (s *Scheduler) materialize(ctx context.Context, now time.Time) error {
+	from := now.Add(-s.Grace)
+	to := now.Add(s.Lookahead)
+	// Events start at most MaxMinutesBefore after their reminders fire.
+	lastDate := to.Add(MaxMinutesBefore * time.Minute).In(s.Location).Format("2006-01-02")
+	firstDate := from.In(s.Location).AddDate(0, 0, -1).Format("2006-01-02")
+
+	rows, err := database.DB.QueryContext(ctx, `
+		SELECT e.id, e.start_time, e.day, e.date, e.organizer, `+attendees.Column(database.Postgres, "e")+`, r.minutes_before, r.method
+		FROM calendar_events e
+		LEFT JOIN event_reminder_settings s ON s.event_id = e.id
+		JOIN LATERAL (
+			SELECT er.minutes_before, er.method FROM event_reminders er
+			WHERE er.event_id = e.id AND s.use_default = FALSE
+			UNION ALL
+			SELECT cr.minutes_before, cr.method FROM calendar_reminders cr
+			WHERE cr.calendar_id = e.calendar_id AND COALESCE(s.use_default, TRUE)
+		) r ON TRUE
+		WHERE (e.date IS NULL OR e.date BETWEEN $1 AND $2) AND e.deleted_at IS NULL
+	`, firstDate, lastDate)
+	if err != nil {
+		return err
+	}
+	var due []scheduledReminder
+	for rows.Next() {
+		var sr scheduledReminder
+		var attendeeList string
+		if err := rows.Scan(&sr.eventID, &sr.startTime, &sr.day, &sr.date, &sr.organizer, &attendeeList,
+			&sr.reminder.MinutesBefore, &sr.reminder.Method); err != nil {
+			rows.Close()
+			return err
+		}
+		sr.attendees = attendees.Parse(attendeeList)
+		due = append(due, sr)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(due) == 0 {
+		return nil
+	}
+
+	resourceEmails, err := resourceAddresses(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, sr := range due {
+		lead := time.Duration(sr.reminder.MinutesBefore) * time.Minute
+		for _, start := range s.occurrences(sr, from.Add(lead), to.Add(lead)) {
+			for _, recipient := range recipients(sr, resourceEmails) {
+				_, err := database.DB.ExecContext(ctx, `
+					INSERT INTO reminder_deliveries (event_id, occurrence_start, minutes_before, method, recipient, fire_at, status, attempts, created_at)
+					VALUES ($1, $2, $3, $4, $5, $6, 'pending', 0, NOW())
+					ON CONFLICT (event_id, occurrence_start, minutes_before, method, recipient) DO NOTHING
+				`, sr.eventID, start, sr.reminder.MinutesBefore, sr.reminder.Method, recipient, start.Add(-lead))
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// occurrences returns the start times of the event that fall in [from, to].
+// Weekly events (no date) occur on every matching weekday.
+func (s *Scheduler) occurrences(sr scheduledReminder, from, to time.Time) []time.Time {
+	clock, err := time.Parse("15:04", normalizeClock(sr.startTime))
+	if err != nil {
+		return nil
+	}
+	at := func(day time.Time) time.Time {
+		return time.Date(day.Year(), day.Month(), day.Day(), clock.Hour(), clock.Minute(), 0, 0, s.Location)
+	}
+
+	var starts []time.Time
+	if sr.date.Valid && len(sr.date.String) >= 10 {
+		day, err := time.ParseInLocation("2006-01-02", sr.date.String[:10], s.Location)
+		if err != nil {
+			return nil
+		}
+		if start := at(day); !start.Before(from) && !start.After(to) {
+			starts = append(starts, start)
+		}
+		return starts
+	}
+
+	for day := from.In(s.Location).AddDate(0, 0, -1); !day.After(to.AddDate(0, 0, 1)); day = day.AddDate(0, 0, 1) {
+		weekday := int(day.Weekday())
+		if weekday == 0 {
+			weekday = 7
+		}
+		if weekday != sr.day {
+			continue
+		}
+		if start := at(day); !start.Before(from) && !start.After(to) {
+			starts = append(starts, start)
+		}
+	}
+	return starts
+}
+
+func normalizeClock(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) == 4 && s[1] == ':' {
+		s = "0" + s
+	}
+	if len(s) > 5 {
+		s = s[:5]
+	}
+	return s
+}
+
+// recipients returns who should receive a reminder: the organizer and the
+// attendees, minus rooms and equipment.
+func recipients(sr scheduledReminder, skip map[string]bool) []string {
+	seen := map[string]bool{}
+	var list []string
+	for _, addr := range append([]string{sr.organizer}, sr.attendees...) {
+		addr = strings.ToLower(strings.TrimSpace(addr))
+		if addr == "" || seen[addr] || skip[addr] {
+			continue
+		}
+		if sr.reminder.Method == MethodEmail && !strings.Contains(addr, "@") {
+			continue
+		}
+		seen[addr] = true
+		list = append(list, addr)
+	}
+	return list
+}
+
+func resourceAddresses(ctx context.Context) (map[string]bool, error) {
+	rows, err := database.DB.QueryContext(ctx, "SELECT email FROM resources")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	emails := map[string]bool{}
+	for rows.Next() {
+		var email string
+		if err := rows.Scan(&email); err != nil {
+			return nil, err
+		}
+		emails[strings.ToLower(email)] = true
+	}
+	return emails, rows.Err()
+}
+
+// deliverBatch claims up to BatchSize due deliveries and sends them. It
+// returns how many were claimed.
+func (s *Scheduler) deliverBatch(ctx context.Context) (int, error) {
+	rows, err := database.DB.QueryContext(ctx, `
+		WITH claimed AS (
+			UPDATE reminder_deliveries
+			SET status = 'sending', locked_by = $1, locked_until = NOW() + $2 * INTERVAL '1 second', attempts = attempts + 1
+			WHERE id IN (
+				SELECT id FROM reminder_deliveries
+				WHERE status IN ('pending', 'sending') AND fire_at <= NOW()
+				  AND (locked_until IS NULL OR locked_until < NOW())
+				  AND event_id IN (SELECT id FROM calendar_events WHERE deleted_at IS NULL)
+				ORDER BY fire_at
+				LIMIT $3
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING id, event_id, occurrence_start, minutes_before, method, recipient, attempts
+		)
+		SELECT c.id, c.event_id, c.occurrence_start, c.minutes_before, c.method, c.recipient, c.attempts, e.title, e.location
+		FROM claimed c
+		JOIN calendar_events e ON e.id = c.event_id
+	`, s.instance, int(s.Lease.Seconds()), s.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	type claim struct {
+		notice   Notice
+		method   string
+		attempts int
+	}
+	var claims []claim
+	for rows.Next() {
+		var c claim
+		if err := rows.Scan(&c.notice.DeliveryID, &c.notice.EventID, &c.notice.Start, &c.notice.MinutesBefore,
+			&c.method, &c.notice.Recipient, &c.attempts, &c.notice.Title, &c.notice.Location); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		c.notice.Start = c.notice.Start.In(s.Location)
+		claims = append(claims, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, c := range claims {
+		notifier, ok := s.Notifiers[c.method]
+		var sendErr error
+		if !ok {
+			sendErr = fmt.Errorf("no notifier for method %q", c.method)
+		} else {
+			sendErr = notifier.Notify(ctx, c.notice)
+		}
+		if err := s.finish(ctx, c.notice.DeliveryID, c.attempts, sendErr); err != nil {
+			return 0, err
+		}
+	}
+	return len(claims), nil
+}
+
+// finish records the outcome of a delivery attempt. Failed deliveries are
+// retried with exponential backoff until MaxAttempts is reached.
+func (s *Scheduler) finish(ctx context.Context, id int64, attempts int, sendErr error) error {
+	if sendErr == nil {
+		_, err := database.DB.ExecContext(ctx, `
+			UPDATE reminder_deliveries SET status = 'sent', sent_at = NOW(), locked_by = NULL, locked_until = NULL, last_error = NULL
+			WHERE id = $1 AND locked_by = $2
+		`, id, s.instance)
+		return err
+	}
+
+	log.Printf("reminders: delivery %d failed (attempt %d): %v", id, attempts, sendErr)
+	status := "pending"
+	if attempts >= s.MaxAttempts {
+		status = "failed"
+	}
+	backoff := time.Duration(1<<uint(attempts)) * 30 * time.Second
+	_, err := database.DB.ExecContext(ctx, `
+		UPDATE reminder_deliveries SET status = $1, locked_by = NULL, locked_until = NOW() + $2 * INTERVAL '1 second', last_error = $3
+		WHERE id = $4 AND locked_by = $5
+	`, status, int(backoff.Seconds()), sendErr.Error(), id, s.instance)
+	return err
+}
@@ -0,0 +1,87 @@
+package reminders
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newTestScheduler(t *testing.T) *Scheduler {
+	t.Helper()
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewScheduler(nil, loc)
+}
+
+func TestOccurrences(t *testing.T) {
+	s := newTestScheduler(t)
+	at := func(day string, clock string) time.Time {
+		start, err := time.ParseInLocation("2006-01-02 15:04", day+" "+clock, s.Location)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return start
+	}
+	dated := func(date string) sql.NullString { return sql.NullString{String: date, Valid: true} }
+
+	tests := []struct {
+		name     string
+		sr       scheduledReminder
+		from, to time.Time
+		want     []time.Time
+	}{
+		{"dated, in the window", scheduledReminder{startTime: "9:00", date: dated("2026-01-07")},
+			at("2026-01-07", "08:00"), at("2026-01-07", "10:00"), []time.Time{at("2026-01-07", "09:00")}},
+		{"dated, at the window's edges", scheduledReminder{startTime: "09:00", date: dated("2026-01-07")},
+			at("2026-01-07", "09:00"), at("2026-01-07", "09:00"), []time.Time{at("2026-01-07", "09:00")}},
+		{"dated, before the window", scheduledReminder{startTime: "09:00", date: dated("2026-01-07")},
+			at("2026-01-07", "09:01"), at("2026-01-08", "09:00"), nil},
+		// Postgres returns dates as timestamps, of which the day is used.
+		{"dated as a timestamp", scheduledReminder{startTime: "09:00:00", date: dated("2026-01-07T00:00:00Z")},
+			at("2026-01-07", "00:00"), at("2026-01-08", "00:00"), []time.Time{at("2026-01-07", "09:00")}},
+		{"weekly, every matching weekday", scheduledReminder{startTime: "09:00", day: 3},
+			at("2026-01-01", "00:00"), at("2026-01-21", "00:00"),
+			[]time.Time{at("2026-01-07", "09:00"), at("2026-01-14", "09:00")}},
+		{"weekly on Sunday", scheduledReminder{startTime: "18:30", day: 7},
+			at("2026-01-10", "00:00"), at("2026-01-12", "00:00"), []time.Time{at("2026-01-11", "18:30")}},
+		// The clocks go forward on March 8, 2026; 09:00 stays 09:00.
+		{"weekly across a DST change", scheduledReminder{startTime: "09:00", day: 1},
+			at("2026-03-01", "00:00"), at("2026-03-10", "00:00"),
+			[]time.Time{at("2026-03-02", "09:00"), at("2026-03-09", "09:00")}},
+		{"unreadable time", scheduledReminder{startTime: "noon", day: 3},
+			at("2026-01-01", "00:00"), at("2026-01-21", "00:00"), nil},
+	}
+	for _, tt := range tests {
+		got := s.occurrences(tt.sr, tt.from, tt.to)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if !got[i].Equal(tt.want[i]) {
+				t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestRecipients(t *testing.T) {
+	sr := scheduledReminder{
+		organizer: "Alice@Example.com",
+		attendees: []string{"bob@example.com", " alice@example.com", "room4@example.com", "carol", ""},
+		reminder:  Reminder{Method: MethodEmail},
+	}
+	rooms := map[string]bool{"room4@example.com": true}
+	// Names without an address can't be emailed, but get in-app reminders.
+	if got, want := recipients(sr, rooms), []string{"alice@example.com", "bob@example.com"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("email goes to %v, want %v", got, want)
+	}
+	sr.reminder.Method = MethodInApp
+	if got, want := recipients(sr, rooms), []string{"alice@example.com", "bob@example.com", "carol"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("in-app reminders go to %v, want %v", got, want)
+	}
+}
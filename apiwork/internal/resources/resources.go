@@ -0,0 +1,558 @@
+// Package resources manages bookable rooms and equipment. Resources are
+// stored in the resources table and their answers to invitations in
+// resource_bookings.
+package resources
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Aman221/4723/internal/database"
+	"github.com/Aman221/4723/internal/models"
+	"github.com/Aman221/4723/internal/store"
+)
+
+// Resource kinds that can be booked.
+const (
+	KindRoom      = "room"
+	KindEquipment = "equipment"
+)
+
+// Booking statuses recorded for a resource invited to an event.
+const (
+	StatusAccepted = "accepted"
+	StatusDeclined = "declined"
+)
+
+var (
+	ErrNotFound       = errors.New("resource not found")
+	ErrInvalidKind    = errors.New("kind must be \"room\" or \"equipment\"")
+	ErrInvalidTime    = errors.New("times must be formatted as HH:MM")
+	ErrEndBeforeStart = errors.New("endTime must be after startTime")
+	ErrInvalidDate    = errors.New("date must be formatted as YYYY-MM-DD")
+)
+
+// Resource is a bookable room or piece of equipment. Each resource owns a
+// calendar holding the events it has accepted.
+type Resource struct {
+	ID         string            `json:"id"`
+	Name       string            `json:"name"`
+	Kind       string            `json:"kind"`
+	Email      string            `json:"email"`
+	Capacity   int               `json:"capacity"`
+	Attributes map[string]string `json:"attributes"`
+	CalendarID string            `json:"calendarId"`
+}
+
+// Booking is the answer a resource gave to an event invitation.
+type Booking struct {
+	ResourceID     string `json:"resourceId"`
+	ResourceName   string `json:"resourceName"`
+	EventID        string `json:"eventId"`
+	Status         string `json:"status"`
+	BookingEventID string `json:"bookingEventId,omitempty"`
+}
+
+// Slot describes when an event takes place. Date is optional; events
+// without a date repeat every week on Day.
+type Slot struct {
+	Date      *string
+	Day       int
+	StartTime string
+	EndTime   string
+}
+
+// Invitation carries the parts of an event needed to book resources for it.
+type Invitation struct {
+	EventID   string
+	Title     string
+	Organizer string
+	Attendees []string
+	Slot      Slot
+}
+
+// Filter narrows the resources returned by Available.
+type Filter struct {
+	Kind        string
+	MinCapacity int
+	Attributes  map[string]string
+}
+
+const resourceColumns = "r.id, r.name, r.kind, r.email, r.capacity, r.attributes, r.calendar_id"
+
+func scanResource(scanner interface{ Scan(...interface{}) error }) (Resource, error) {
+	var res Resource
+	var attrs []byte
+	if err := scanner.Scan(&res.ID, &res.Name, &res.Kind, &res.Email, &res.Capacity, &attrs, &res.CalendarID); err != nil {
+		return res, err
+	}
+	res.Attributes = map[string]string{}
+	if len(attrs) > 0 {
+		if err := json.Unmarshal(attrs, &res.Attributes); err != nil {
+			return res, fmt.Errorf("decoding attributes of resource %s: %w", res.ID, err)
+		}
+	}
+	return res, nil
+}
+
+func queryResources(query string, args ...interface{}) ([]Resource, error) {
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	resources := []Resource{}
+	for rows.Next() {
+		res, err := scanResource(rows)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, res)
+	}
+	return resources, rows.Err()
+}
+
+// List returns all resources, optionally restricted to one kind.
+func List(kind string) ([]Resource, error) {
+	if kind == "" {
+		return queryResources("SELECT " + resourceColumns + " FROM resources r ORDER BY r.name")
+	}
+	return queryResources("SELECT "+resourceColumns+" FROM resources r WHERE r.kind = $1 ORDER BY r.name", kind)
+}
+
+// Get returns a single resource.
+func Get(id string) (Resource, error) {
+	res, err := scanResource(database.DB.QueryRow("SELECT "+resourceColumns+" FROM resources r WHERE r.id = $1", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return res, ErrNotFound
+	}
+	return res, err
+}
+
+// ByCalendar returns the resource whose calendar has the given id.
+func ByCalendar(calendarID string) (Resource, error) {
+	res, err := scanResource(database.DB.QueryRow("SELECT "+resourceColumns+" FROM resources r WHERE r.calendar_id = $1", calendarID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return res, ErrNotFound
+	}
+	return res, err
+}
+
+// Validate normalises a resource before it is stored.
+func (r *Resource) Validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	r.Kind = strings.ToLower(strings.TrimSpace(r.Kind))
+	r.Email = strings.ToLower(strings.TrimSpace(r.Email))
+	if r.Name == "" {
+		return errors.New("name is required")
+	}
+	if r.Kind != KindRoom && r.Kind != KindEquipment {
+		return ErrInvalidKind
+	}
+	if r.Email == "" {
+		return errors.New("email is required so the resource can be invited")
+	}
+	if r.Capacity < 0 {
+		return errors.New("capacity cannot be negative")
+	}
+	if r.Attributes == nil {
+		r.Attributes = map[string]string{}
+	}
+	return nil
+}
+
+// Create stores a new resource together with the calendar that backs it.
+func Create(r Resource) (Resource, error) {
+	if err := r.Validate(); err != nil {
+		return r, err
+	}
+	attrs, err := json.Marshal(r.Attributes)
+	if err != nil {
+		return r, err
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return r, err
+	}
+	defer tx.Rollback()
+
+	// Resource calendars stay hidden so bookings don't clutter people's views.
+	cal, err := store.InTx(tx, database.Dialect).CreateCalendar(context.Background(),
+		models.NCalendar{Name: r.Name, Color: "bg-gray-500", Visible: false})
+	if err != nil {
+		return r, err
+	}
+	r.CalendarID = cal.ID
+	err = tx.QueryRow(`
+		INSERT INTO resources (name, kind, email, capacity, attributes, calendar_id)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id
+	`, r.Name, r.Kind, r.Email, r.Capacity, attrs, r.CalendarID).Scan(&r.ID)
+	if err != nil {
+		return r, err
+	}
+	return r, tx.Commit()
+}
+
+// Update replaces the descriptive fields of a resource. The backing
+// calendar is kept and renamed to match.
+func Update(id string, r Resource) (Resource, error) {
+	if err := r.Validate(); err != nil {
+		return r, err
+	}
+	attrs, err := json.Marshal(r.Attributes)
+	if err != nil {
+		return r, err
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return r, err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		UPDATE resources SET name = $1, kind = $2, email = $3, capacity = $4, attributes = $5
+		WHERE id = $6 RETURNING id, calendar_id
+	`, r.Name, r.Kind, r.Email, r.Capacity, attrs, id).Scan(&r.ID, &r.CalendarID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return r, ErrNotFound
+	}
+	if err != nil {
+		return r, err
+	}
+	ctx := context.Background()
+	calendars := store.InTx(tx, database.Dialect)
+	cal, err := calendars.GetCalendar(ctx, r.CalendarID)
+	if err != nil {
+		return r, err
+	}
+	cal.Name = r.Name
+	if _, err := calendars.UpdateCalendar(ctx, cal); err != nil {
+		return r, err
+	}
+	return r, tx.Commit()
+}
+
+// Delete removes a resource, its bookings and its calendar.
+func Delete(id string) error {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM resource_bookings WHERE resource_id = $1", id); err != nil {
+		return err
+	}
+	var calendarID string
+	err = tx.QueryRow("DELETE FROM resources WHERE id = $1 RETURNING calendar_id", id).Scan(&calendarID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	cal, err := store.ReadCalendar(ctx, tx, calendarID)
+	if err != nil {
+		return err
+	}
+	events, err := readEvents(ctx, tx, "SELECT id FROM calendar_events WHERE calendar_id = $1", calendarID)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM calendar_events WHERE calendar_id = $1", calendarID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM calendars WHERE id = $1", calendarID); err != nil {
+		return err
+	}
+	for _, event := range events {
+		if err := store.RecordEventRemoved(ctx, tx, event); err != nil {
+			return err
+		}
+	}
+	if err := store.RecordCalendarRemoved(ctx, tx, cal); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// NormalizeClock turns "9:00", "09:00" or "09:00:00" into "09:00" so that
+// times can be compared as strings.
+func NormalizeClock(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{"15:04", "15:04:05"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Format("15:04"), nil
+		}
+	}
+	if len(s) == 4 && s[1] == ':' {
+		return NormalizeClock("0" + s)
+	}
+	return "", ErrInvalidTime
+}
+
+// Normalize validates the slot and rewrites its times in HH:MM form.
+func (s *Slot) Normalize() error {
+	start, err := NormalizeClock(s.StartTime)
+	if err != nil {
+		return err
+	}
+	end, err := NormalizeClock(s.EndTime)
+	if err != nil {
+		return err
+	}
+	if end <= start {
+		return ErrEndBeforeStart
+	}
+	s.StartTime, s.EndTime = start, end
+	if s.Date != nil && *s.Date != "" {
+		d, err := time.Parse("2006-01-02", *s.Date)
+		if err != nil {
+			return ErrInvalidDate
+		}
+		s.Day = int(d.Weekday())
+		if s.Day == 0 {
+			s.Day = 7
+		}
+	} else {
+		s.Date = nil
+	}
+	return nil
+}
+
+// busyClause matches calendar_events rows that overlap the slot. Dated
+// events clash with events on the same date and with weekly events on the
+// same weekday; weekly events clash with anything on their weekday.
+// Placeholders start at $n.
+func busyClause(s Slot, n int) (string, []interface{}) {
+	if s.Date != nil {
+		clause := fmt.Sprintf("e.deleted_at IS NULL AND (e.date = $%d OR (e.date IS NULL AND e.day = $%d)) AND e.start_time < $%d AND e.end_time > $%d",
+			n, n+1, n+2, n+3)
+		return clause, []interface{}{*s.Date, s.Day, s.EndTime, s.StartTime}
+	}
+	clause := fmt.Sprintf("e.deleted_at IS NULL AND e.day = $%d AND e.start_time < $%d AND e.end_time > $%d", n, n+1, n+2)
+	return clause, []interface{}{s.Day, s.EndTime, s.StartTime}
+}
+
+// Available returns the resources matching the filter whose calendars have
+// nothing booked during the slot.
+func Available(f Filter, s Slot) ([]Resource, error) {
+	if err := s.Normalize(); err != nil {
+		return nil, err
+	}
+	busy, args := busyClause(s, 1)
+	query := "SELECT " + resourceColumns + " FROM resources r WHERE NOT EXISTS (SELECT 1 FROM calendar_events e WHERE e.calendar_id = r.calendar_id AND " + busy + ")"
+	if f.Kind != "" {
+		args = append(args, f.Kind)
+		query += fmt.Sprintf(" AND r.kind = $%d", len(args))
+	}
+	if f.MinCapacity > 0 {
+		args = append(args, f.MinCapacity)
+		query += fmt.Sprintf(" AND r.capacity >= $%d", len(args))
+	}
+	if len(f.Attributes) > 0 {
+		attrs, err := json.Marshal(f.Attributes)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, attrs)
+		query += fmt.Sprintf(" AND r.attributes @> $%d::jsonb", len(args))
+	}
+	query += " ORDER BY r.capacity, r.name"
+	return queryResources(query, args...)
+}
+
+// SyncBookings answers the invitation on behalf of every resource among the
+// attendees. A resource accepts when its calendar is free, which places a
+// copy of the event on that calendar, and declines otherwise. Bookings made
+// for a previous version of the event are replaced.
+func SyncBookings(inv Invitation) ([]Booking, error) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := ReleaseBookings(tx, inv.EventID); err != nil {
+		return nil, err
+	}
+	slot := inv.Slot
+	if err := slot.Normalize(); err != nil {
+		// Events with unparseable times can't be checked, so nothing is booked.
+		return []Booking{}, tx.Commit()
+	}
+
+	emails := make([]string, 0, len(inv.Attendees))
+	for _, a := range inv.Attendees {
+		emails = append(emails, strings.ToLower(strings.TrimSpace(a)))
+	}
+	invited, err := invitedResources(tx, emails)
+	if err != nil {
+		return nil, err
+	}
+	events := store.InTx(tx, database.Dialect)
+
+	bookings := []Booking{}
+	for _, res := range invited {
+		b := Booking{ResourceID: res.ID, ResourceName: res.Name, EventID: inv.EventID, Status: StatusDeclined}
+
+		// Lock the resource row so two concurrent invitations can't both
+		// find the calendar free.
+		if _, err := tx.Exec("SELECT id FROM resources WHERE id = $1 FOR UPDATE", res.ID); err != nil {
+			return nil, err
+		}
+		busy, args := busyClause(slot, 2)
+		var conflicts int
+		err := tx.QueryRow("SELECT COUNT(*) FROM calendar_events e WHERE e.calendar_id = $1 AND "+busy,
+			append([]interface{}{res.CalendarID}, args...)...).Scan(&conflicts)
+		if err != nil {
+			return nil, err
+		}
+
+		var bookingEventID sql.NullString
+		if conflicts == 0 {
+			booking, err := events.CreateEvent(context.Background(), models.NCalendarEvent{
+				Title:      inv.Title,
+				StartTime:  slot.StartTime,
+				EndTime:    slot.EndTime,
+				Color:      "bg-gray-500",
+				Day:        slot.Day,
+				Location:   res.Name,
+				Organizer:  inv.Organizer,
+				CalendarID: res.CalendarID,
+				Date:       slot.Date,
+			})
+			if err != nil {
+				return nil, err
+			}
+			bookingEventID = sql.NullString{String: booking.ID, Valid: true}
+			b.Status = StatusAccepted
+			b.BookingEventID = booking.ID
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO resource_bookings (resource_id, event_id, status, booking_event_id, updated_at)
+			VALUES ($1, $2, $3, $4, NOW())
+		`, res.ID, inv.EventID, b.Status, bookingEventID)
+		if err != nil {
+			return nil, err
+		}
+		bookings = append(bookings, b)
+	}
+	return bookings, tx.Commit()
+}
+
+func invitedResources(tx *sql.Tx, emails []string) ([]Resource, error) {
+	if len(emails) == 0 {
+		return nil, nil
+	}
+	placeholders := make([]string, len(emails))
+	args := make([]interface{}, len(emails))
+	for i, e := range emails {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		args[i] = e
+	}
+	rows, err := tx.Query("SELECT "+resourceColumns+" FROM resources r WHERE r.email IN ("+strings.Join(placeholders, ",")+") ORDER BY r.id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var invited []Resource
+	for rows.Next() {
+		res, err := scanResource(rows)
+		if err != nil {
+			return nil, err
+		}
+		invited = append(invited, res)
+	}
+	return invited, rows.Err()
+}
+
+// ReleaseBookings frees, in tx, every resource booked for the event, e.g.
+// because the event was deleted.
+func ReleaseBookings(tx *sql.Tx, eventID string) error {
+	const booked = "SELECT booking_event_id FROM resource_bookings WHERE event_id = $1 AND booking_event_id IS NOT NULL"
+	ctx := context.Background()
+	events, err := readEvents(ctx, tx, booked, eventID)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM calendar_events WHERE id IN ("+booked+")", eventID); err != nil {
+		return err
+	}
+	for _, event := range events {
+		if err := store.RecordEventRemoved(ctx, tx, event); err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec("DELETE FROM resource_bookings WHERE event_id = $1", eventID)
+	return err
+}
+
+// readEvents reads the events whose ids query selects, for the history of
+// their deletion.
+func readEvents(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) ([]models.CalendarEvent, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	events := make([]models.CalendarEvent, 0, len(ids))
+	for _, id := range ids {
+		event, err := store.ReadEvent(ctx, tx, database.Dialect, id)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// Bookings lists the answers resources gave to an event's invitation.
+func Bookings(eventID string) ([]Booking, error) {
+	rows, err := database.DB.Query(`
+		SELECT b.resource_id, r.name, b.event_id, b.status, b.booking_event_id
+		FROM resource_bookings b
+		JOIN resources r ON r.id = b.resource_id
+		WHERE b.event_id = $1
+		ORDER BY r.name
+	`, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bookings := []Booking{}
+	for rows.Next() {
+		var b Booking
+		var bookingEventID sql.NullString
+		if err := rows.Scan(&b.ResourceID, &b.ResourceName, &b.EventID, &b.Status, &bookingEventID); err != nil {
+			return nil, err
+		}
+		b.BookingEventID = bookingEventID.String
+		bookings = append(bookings, b)
+	}
+	return bookings, rows.Err()
+}
@@ -0,0 +1,109 @@
+package resources
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeClock(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		err  error
+	}{
+		{"09:00", "09:00", nil},
+		{"9:00", "09:00", nil},
+		{" 9:30 ", "09:30", nil},
+		{"23:59:59", "23:59", nil},
+		{"24:00", "", ErrInvalidTime},
+		{"9", "", ErrInvalidTime},
+		{"9am", "", ErrInvalidTime},
+		{"", "", ErrInvalidTime},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeClock(tt.in)
+		if got != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("NormalizeClock(%q) = %q, %v, want %q, %v", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func date(d string) *string { return &d }
+
+func TestSlotNormalize(t *testing.T) {
+	tests := []struct {
+		name string
+		slot Slot
+		want Slot
+		err  error
+	}{
+		{"weekly", Slot{Day: 2, StartTime: "9:00", EndTime: "10:30"}, Slot{Day: 2, StartTime: "09:00", EndTime: "10:30"}, nil},
+		{"dated, which sets the day", Slot{Date: date("2026-01-07"), StartTime: "09:00", EndTime: "10:00"},
+			Slot{Date: date("2026-01-07"), Day: 3, StartTime: "09:00", EndTime: "10:00"}, nil},
+		{"Sunday is day 7", Slot{Date: date("2026-01-11"), StartTime: "09:00", EndTime: "10:00"},
+			Slot{Date: date("2026-01-11"), Day: 7, StartTime: "09:00", EndTime: "10:00"}, nil},
+		{"empty date", Slot{Date: date(""), Day: 1, StartTime: "09:00", EndTime: "10:00"},
+			Slot{Day: 1, StartTime: "09:00", EndTime: "10:00"}, nil},
+		{"bad start", Slot{StartTime: "nine", EndTime: "10:00"}, Slot{}, ErrInvalidTime},
+		{"bad end", Slot{StartTime: "09:00", EndTime: "25:00"}, Slot{}, ErrInvalidTime},
+		{"ends at the start", Slot{StartTime: "10:00", EndTime: "10:00"}, Slot{}, ErrEndBeforeStart},
+		// Compared as written, "9:00" would sort after "10:00".
+		{"ends before the start", Slot{StartTime: "10:00", EndTime: "9:00"}, Slot{}, ErrEndBeforeStart},
+		{"bad date", Slot{Date: date("2026-02-30"), StartTime: "09:00", EndTime: "10:00"}, Slot{}, ErrInvalidDate},
+	}
+	for _, tt := range tests {
+		slot := tt.slot
+		err := slot.Normalize()
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: error %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(slot, tt.want) {
+			t.Errorf("%s: normalized to %+v, want %+v", tt.name, slot, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	r := Resource{Name: " Room 4 ", Kind: "Room", Email: " Room4@Example.com "}
+	if err := r.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	want := Resource{Name: "Room 4", Kind: KindRoom, Email: "room4@example.com", Attributes: map[string]string{}}
+	if !reflect.DeepEqual(r, want) {
+		t.Errorf("normalized to %+v, want %+v", r, want)
+	}
+
+	for _, bad := range []Resource{
+		{Kind: KindRoom, Email: "room@example.com"},
+		{Name: "Projector", Kind: "vehicle", Email: "projector@example.com"},
+		{Name: "Projector", Kind: KindEquipment},
+		{Name: "Projector", Kind: KindEquipment, Email: "projector@example.com", Capacity: -1},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("%+v is valid", bad)
+		}
+	}
+}
+
+func TestBusyClause(t *testing.T) {
+	weekly := Slot{Day: 3, StartTime: "09:00", EndTime: "10:00"}
+	clause, args := busyClause(weekly, 2)
+	if want := "e.deleted_at IS NULL AND e.day = $2 AND e.start_time < $3 AND e.end_time > $4"; clause != want {
+		t.Errorf("weekly clause is %s", clause)
+	}
+	// Overlap is start < other end and end > other start.
+	if want := []interface{}{3, "10:00", "09:00"}; !reflect.DeepEqual(args, want) {
+		t.Errorf("weekly args are %v, want %v", args, want)
+	}
+
+	dated := Slot{Date: date("2026-01-07"), Day: 3, StartTime: "09:00", EndTime: "10:00"}
+	clause, args = busyClause(dated, 1)
+	if want := "e.deleted_at IS NULL AND (e.date = $1 OR (e.date IS NULL AND e.day = $2)) AND e.start_time < $3 AND e.end_time > $4"; clause != want {
+		t.Errorf("dated clause is %s", clause)
+	}
+	if want := []interface{}{"2026-01-07", 3, "10:00", "09:00"}; !reflect.DeepEqual(args, want) {
+		t.Errorf("dated args are %v, want %v", args, want)
+	}
+}
@@ -0,0 +1,104 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"maps"
+	"slices"
+
+	"github.com/Aman221/4723/internal/database"
+	"github.com/Aman221/4723/internal/models"
+)
+
+// handle is where Postgres and SQLite run their queries: the database, or
+// within a batch the batch's transaction.
+type handle struct {
+	db *sql.DB
+	tx *sql.Tx
+}
+
+func (h handle) querier() querier {
+	if h.tx != nil {
+		return h.tx
+	}
+	return h.db
+}
+
+func (h handle) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return h.querier().ExecContext(ctx, query, args...)
+}
+
+func (h handle) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return h.querier().QueryContext(ctx, query, args...)
+}
+
+func (h handle) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return h.querier().QueryRowContext(ctx, query, args...)
+}
+
+// InTx returns a Store whose reads and writes run in tx, for code that
+// writes its own tables in the same transaction, such as resources.
+func InTx(tx *sql.Tx, dialect string) Store {
+	if dialect == database.SQLite {
+		return &SQLite{db: handle{tx: tx}}
+	}
+	return &Postgres{db: handle{tx: tx}}
+}
+
+// batch runs run in a transaction or, within a batch, in a savepoint that
+// is rolled back if run fails. Savepoints nest, so one name does for all.
+func batch(ctx context.Context, h handle, run func(tx handle) error) error {
+	if h.tx == nil {
+		return inTx(ctx, h, func(tx *sql.Tx) error { return run(handle{tx: tx}) })
+	}
+	if _, err := h.tx.ExecContext(ctx, "SAVEPOINT batch"); err != nil {
+		return err
+	}
+	if err := run(h); err != nil {
+		if _, rollbackErr := h.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT batch"); rollbackErr != nil {
+			return rollbackErr
+		}
+		if _, releaseErr := h.tx.ExecContext(ctx, "RELEASE SAVEPOINT batch"); releaseErr != nil {
+			return releaseErr
+		}
+		return err
+	}
+	_, err := h.tx.ExecContext(ctx, "RELEASE SAVEPOINT batch")
+	return err
+}
+
+func (p *Postgres) Batch(ctx context.Context, run func(tx Store) error) error {
+	return batch(ctx, p.db, func(tx handle) error { return run(&Postgres{db: tx}) })
+}
+
+func (s *SQLite) Batch(ctx context.Context, run func(tx Store) error) error {
+	return batch(ctx, s.db, func(tx handle) error { return run(&SQLite{db: tx}) })
+}
+
+// Batch runs run against a copy of the store, which replaces the store's
+// contents if run succeeds. The store stays locked until then, so writes
+// outside the batch wait for it rather than being lost; run must only use
+// tx.
+func (m *Memory) Batch(ctx context.Context, run func(tx Store) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tx := &Memory{
+		nextID:    m.nextID,
+		users:     maps.Clone(m.users),
+		userOf:    maps.Clone(m.userOf),
+		calendars: maps.Clone(m.calendars),
+		events:    map[string]models.CalendarEvent{},
+		history:   slices.Clone(m.history),
+		searches:  maps.Clone(m.searches),
+		keys:      maps.Clone(m.keys),
+	}
+	for id, event := range m.events {
+		tx.events[id] = copyEvent(event)
+	}
+	if err := run(tx); err != nil {
+		return err
+	}
+	m.nextID, m.users, m.userOf, m.calendars, m.events, m.history, m.searches, m.keys =
+		tx.nextID, tx.users, tx.userOf, tx.calendars, tx.events, tx.history, tx.searches, tx.keys
+	return nil
+}
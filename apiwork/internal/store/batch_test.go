@@ -0,0 +1,102 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Aman221/4723/internal/models"
+)
+
+func TestBatch(t *testing.T) {
+	sqlite, _ := newSQLite(t)
+	for name, s := range map[string]Store{"memory": NewMemory(), "sqlite": sqlite} {
+		ctx := context.Background()
+		errStop := errors.New("stop")
+
+		// A failed batch leaves nothing behind.
+		err := s.Batch(ctx, func(tx Store) error {
+			if _, err := tx.CreateCalendar(ctx, models.NCalendar{Name: "Lost"}); err != nil {
+				return err
+			}
+			return errStop
+		})
+		if !errors.Is(err, errStop) {
+			t.Errorf("%s: batch returned %v", name, err)
+		}
+		if calendars, _ := s.ListCalendars(ctx, Page{}); len(calendars) != 0 {
+			t.Errorf("%s: a failed batch left %+v", name, calendars)
+		}
+
+		// A failed nested batch is rolled back alone.
+		var cal models.Calendar
+		err = s.Batch(ctx, func(tx Store) error {
+			var err error
+			if cal, err = tx.CreateCalendar(ctx, models.NCalendar{Name: "Work"}); err != nil {
+				return err
+			}
+			nested := tx.Batch(ctx, func(tx Store) error {
+				if _, err := tx.CreateEvent(ctx, models.NCalendarEvent{Title: "Lost", StartTime: "09:00", EndTime: "10:00", Day: 1, CalendarID: cal.ID}); err != nil {
+					return err
+				}
+				return errStop
+			})
+			if !errors.Is(nested, errStop) {
+				t.Errorf("%s: nested batch returned %v", name, nested)
+			}
+			_, err = tx.CreateEvent(ctx, models.NCalendarEvent{Title: "Kept", StartTime: "09:00", EndTime: "10:00", Day: 1, CalendarID: cal.ID})
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		events, err := s.ListEvents(ctx, []string{cal.ID}, Page{})
+		if err != nil || len(events) != 1 || events[0].Title != "Kept" {
+			t.Errorf("%s: after the batch the events are %+v, %v", name, events, err)
+		}
+		if history, _ := s.History(ctx, EntityCalendar, cal.ID); len(history) != 1 {
+			t.Errorf("%s: calendar history is %+v", name, history)
+		}
+	}
+}
+
+// TestMemoryBatchKeepsOtherWrites checks that a write made while a batch
+// runs is not lost when the batch commits.
+func TestMemoryBatchKeepsOtherWrites(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+	started, writing, release := make(chan struct{}), make(chan struct{}), make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		m.Batch(ctx, func(tx Store) error {
+			close(started)
+			<-release
+			_, err := tx.CreateCalendar(ctx, models.NCalendar{Name: "Batch"})
+			return err
+		})
+	}()
+	<-started
+	go func() {
+		defer wg.Done()
+		close(writing)
+		m.CreateCalendar(ctx, models.NCalendar{Name: "Outside"})
+		m.ClaimIdempotencyKey(ctx, "key", models.IdempotentRequest{}, time.Time{})
+	}()
+	// Give the write time to reach the store before the batch commits.
+	<-writing
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	calendars, _ := m.ListCalendars(ctx, Page{})
+	if len(calendars) != 2 || calendars[0].ID == calendars[1].ID {
+		t.Errorf("calendars are %+v", calendars)
+	}
+	if _, free, _ := m.ClaimIdempotencyKey(ctx, "key", models.IdempotentRequest{}, time.Time{}); free {
+		t.Error("the Idempotency-Key claimed during the batch was lost")
+	}
+}
@@ -0,0 +1,314 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/Aman221/4723/internal/models"
+)
+
+// Every write to a calendar or event appends an entry to its history in the
+// same transaction. The entry records the Change carried by the write's
+// context and the calendar or event before and after the write.
+
+// Entities with a history.
+const (
+	EntityCalendar = "calendar"
+	EntityEvent    = "event"
+)
+
+// Where a change came from.
+const (
+	SourceAPI      = "api"
+	SourceLiveSync = "livesync"
+	SourceCalDAV   = "caldav"
+	SourceImport   = "import"
+)
+
+// Actions recorded in the history. Stores record the first four; a Change
+// can name ActionRevert for an update that goes back to an earlier version.
+const (
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionDelete  = "delete"
+	ActionRestore = "restore"
+	ActionRevert  = "revert"
+)
+
+// Change says who makes the writes done with a context and through what.
+type Change struct {
+	Actor  string
+	Source string
+	// Action, if set, is recorded instead of the write's own action.
+	Action string
+}
+
+type changeKey struct{}
+
+// WithChange returns a context whose writes are recorded as change.
+func WithChange(ctx context.Context, change Change) context.Context {
+	return context.WithValue(ctx, changeKey{}, change)
+}
+
+// ChangeFrom returns the Change of ctx. Writes without one are recorded as
+// coming from the API with no actor.
+func ChangeFrom(ctx context.Context) Change {
+	change, _ := ctx.Value(changeKey{}).(Change)
+	if change.Source == "" {
+		change.Source = SourceAPI
+	}
+	return change
+}
+
+// entry builds the history entry for a write, without its id and time.
+func entry(ctx context.Context, entity, id, action string, version int, before, after interface{}) (models.HistoryEntry, error) {
+	change := ChangeFrom(ctx)
+	if change.Action != "" {
+		action = change.Action
+	}
+	e := models.HistoryEntry{Entity: entity, EntityID: id, Version: version, Action: action,
+		Actor: change.Actor, Source: change.Source}
+	var err error
+	if before != nil {
+		if e.Before, err = json.Marshal(before); err != nil {
+			return e, err
+		}
+	}
+	e.After, err = json.Marshal(after)
+	return e, err
+}
+
+// record appends a write to the history. before is nil for a create.
+func record(ctx context.Context, db querier, entity, id, action string, version int, before, after interface{}) error {
+	e, err := entry(ctx, entity, id, action, version, before, after)
+	if err != nil {
+		return err
+	}
+	var beforeJSON sql.NullString
+	if e.Before != nil {
+		beforeJSON = sql.NullString{String: string(e.Before), Valid: true}
+	}
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO history (entity, entity_id, version, action, actor, source, before, after)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, e.Entity, e.EntityID, e.Version, e.Action, e.Actor, e.Source, beforeJSON, string(e.After))
+	return err
+}
+
+// recordCalendar records a write to a calendar.
+func recordCalendar(ctx context.Context, db querier, action string, before *models.Calendar, after models.Calendar) error {
+	if before == nil {
+		return record(ctx, db, EntityCalendar, after.ID, action, after.Version, nil, after)
+	}
+	return record(ctx, db, EntityCalendar, after.ID, action, after.Version, *before, after)
+}
+
+// recordEvent records a write to an event.
+func recordEvent(ctx context.Context, db querier, action string, before *models.CalendarEvent, after models.CalendarEvent) error {
+	if before == nil {
+		return record(ctx, db, EntityEvent, after.ID, action, after.Version, nil, after)
+	}
+	return record(ctx, db, EntityEvent, after.ID, action, after.Version, *before, after)
+}
+
+// recordEvents records a write to each of events, reading them back to see
+// what it did. columns is eventColumns for the dialect.
+func recordEvents(ctx context.Context, db querier, columns, action string, events []models.CalendarEvent) error {
+	for i := range events {
+		after, err := eventRow(ctx, db, columns, events[i].ID)
+		if err != nil {
+			return err
+		}
+		if err := recordEvent(ctx, db, action, &events[i], after); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// queryHistory reads the history of a calendar or event, oldest first.
+func queryHistory(ctx context.Context, db querier, entity, id string) ([]models.HistoryEntry, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, entity, entity_id, version, action, actor, source, changed_at, before, after
+		FROM history WHERE entity = $1 AND entity_id = $2 ORDER BY id
+	`, entity, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	entries := []models.HistoryEntry{}
+	for rows.Next() {
+		var e models.HistoryEntry
+		var before sql.NullString
+		var after string
+		err := rows.Scan(&e.ID, &e.Entity, &e.EntityID, &e.Version, &e.Action, &e.Actor, &e.Source, &e.ChangedAt,
+			&before, &after)
+		if err != nil {
+			return nil, err
+		}
+		if before.Valid {
+			e.Before = json.RawMessage(before.String)
+		}
+		e.After = json.RawMessage(after)
+		e.Changes = changes(e.Before, e.After)
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// bookkeeping lists the fields every write changes, which changes leaves out.
+var bookkeeping = map[string]bool{"version": true, "updatedAt": true}
+
+// changes compares the JSON objects before and after field by field.
+func changes(before, after json.RawMessage) map[string]models.FieldChange {
+	var was, is map[string]json.RawMessage
+	json.Unmarshal(before, &was)
+	json.Unmarshal(after, &is)
+	diff := map[string]models.FieldChange{}
+	add := func(name string) {
+		if bookkeeping[name] {
+			return
+		}
+		b, a := was[name], is[name]
+		if b == nil {
+			b = json.RawMessage("null")
+		}
+		if a == nil {
+			a = json.RawMessage("null")
+		}
+		if !bytes.Equal(compact(b), compact(a)) {
+			diff[name] = models.FieldChange{Before: b, After: a}
+		}
+	}
+	for name := range was {
+		add(name)
+	}
+	for name := range is {
+		if _, seen := was[name]; !seen {
+			add(name)
+		}
+	}
+	return diff
+}
+
+// compact strips insignificant whitespace from JSON, which Postgres adds
+// to JSONB.
+func compact(raw json.RawMessage) []byte {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, raw); err != nil {
+		return raw
+	}
+	return buf.Bytes()
+}
+
+// calendarRow reads a calendar, in the trash or not.
+func calendarRow(ctx context.Context, db querier, id string) (models.Calendar, error) {
+	cal, err := scanCalendar(db.QueryRowContext(ctx, "SELECT "+calendarColumns+" FROM calendars WHERE id = $1", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return cal, ErrNotFound
+	}
+	return cal, err
+}
+
+// eventRow reads an event, in the trash or not. columns is eventColumns
+// for the dialect.
+func eventRow(ctx context.Context, db querier, columns, id string) (models.CalendarEvent, error) {
+	event, err := scanEvent(db.QueryRowContext(ctx, "SELECT "+columns+" FROM calendar_events e WHERE e.id = $1", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return event, ErrNotFound
+	}
+	return event, err
+}
+
+// stale reports whether a write expecting version must fail with
+// ErrVersionMismatch against a row at current.
+func stale(version, current int) bool {
+	return version != 0 && version != current
+}
+
+// liveCalendarRow reads the calendar a write is based on, which must be
+// outside the trash and at version unless that is 0.
+func liveCalendarRow(ctx context.Context, db querier, id string, version int) (models.Calendar, error) {
+	cal, err := calendarRow(ctx, db, id)
+	if err == nil && cal.DeletedAt != nil {
+		err = ErrNotFound
+	}
+	if err == nil && stale(version, cal.Version) {
+		err = ErrVersionMismatch
+	}
+	return cal, err
+}
+
+// liveEventRow is liveCalendarRow for events.
+func liveEventRow(ctx context.Context, db querier, columns, id string, version int) (models.CalendarEvent, error) {
+	event, err := eventRow(ctx, db, columns, id)
+	if err == nil && event.DeletedAt != nil {
+		err = ErrNotFound
+	}
+	if err == nil && stale(version, event.Version) {
+		err = ErrVersionMismatch
+	}
+	return event, err
+}
+
+// raced turns the result of a write conditional on the version just read
+// into ErrVersionMismatch when another write got there first.
+func raced(res sql.Result, err error) error {
+	err = affected(res, err)
+	if errors.Is(err, ErrNotFound) {
+		return ErrVersionMismatch
+	}
+	return err
+}
+
+// ReadEvent reads an event, in the trash or not, for code that writes
+// events itself rather than through a Store, such as the iCalendar import.
+// It passes what ReadEvent returns before its write to RecordEvent.
+func ReadEvent(ctx context.Context, tx *sql.Tx, dialect, id string) (models.CalendarEvent, error) {
+	return eventRow(ctx, tx, eventColumns(dialect), id)
+}
+
+// RecordEvent records a write that was made to an event in tx outside a
+// Store. before is nil if the write created the event.
+func RecordEvent(ctx context.Context, tx *sql.Tx, dialect, action string, before *models.CalendarEvent, id string) error {
+	after, err := eventRow(ctx, tx, eventColumns(dialect), id)
+	if err != nil {
+		return err
+	}
+	return recordEvent(ctx, tx, action, before, after)
+}
+
+// ReadCalendar is ReadEvent for calendars.
+func ReadCalendar(ctx context.Context, tx *sql.Tx, id string) (models.Calendar, error) {
+	return calendarRow(ctx, tx, id)
+}
+
+// RecordEventRemoved records that an event, as ReadEvent returned it, was
+// deleted in tx outside a Store without going through the trash. The entry
+// ends with a tombstone, as one for a delete into the trash does.
+func RecordEventRemoved(ctx context.Context, tx *sql.Tx, event models.CalendarEvent) error {
+	after := event
+	after.Version, after.UpdatedAt, after.DeletedAt = tombstone(event.Version, event.DeletedAt)
+	return recordEvent(ctx, tx, ActionDelete, &event, after)
+}
+
+// RecordCalendarRemoved is RecordEventRemoved for calendars.
+func RecordCalendarRemoved(ctx context.Context, tx *sql.Tx, cal models.Calendar) error {
+	after := cal
+	after.Version, after.UpdatedAt, after.DeletedAt = tombstone(cal.Version, cal.DeletedAt)
+	return recordCalendar(ctx, tx, ActionDelete, &cal, after)
+}
+
+// tombstone returns the version, update time and deletion time of a row
+// deleted now at version. A row already in the trash keeps its deletion
+// time.
+func tombstone(version int, deletedAt *string) (int, string, *string) {
+	at := now()
+	if deletedAt == nil {
+		deletedAt = &at
+	}
+	return version + 1, at, deletedAt
+}
@@ -0,0 +1,120 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/Aman221/4723/internal/database"
+	"github.com/Aman221/4723/internal/models"
+)
+
+func TestChanges(t *testing.T) {
+	before := json.RawMessage(`{"title": "Standup", "day": 1, "version": 1, "updatedAt": "a", "date": "2026-01-05"}`)
+	after := json.RawMessage(`{"title":"Retro","day":1,"version":2,"updatedAt":"b","location":"Room 4"}`)
+	got := changes(before, after)
+	var fields []string
+	for name := range got {
+		fields = append(fields, name)
+	}
+	sort.Strings(fields)
+	if want := []string{"date", "location", "title"}; !reflect.DeepEqual(fields, want) {
+		t.Errorf("changed %v, want %v", fields, want)
+	}
+	if c := got["date"]; string(c.Before) != `"2026-01-05"` || string(c.After) != "null" {
+		t.Errorf("date changed from %s to %s", c.Before, c.After)
+	}
+	if c := got["location"]; string(c.Before) != "null" || string(c.After) != `"Room 4"` {
+		t.Errorf("location changed from %s to %s", c.Before, c.After)
+	}
+
+	// A create has no before.
+	if got := changes(nil, after); len(got) != 3 {
+		t.Errorf("a create changes %v", got)
+	}
+}
+
+func TestHistoryOnSQLite(t *testing.T) {
+	s, _ := newSQLite(t)
+	ctx := WithChange(context.Background(), Change{Actor: "alice", Source: SourceLiveSync})
+
+	cal, err := s.CreateCalendar(ctx, models.NCalendar{Name: "Work"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev, err := s.CreateEvent(ctx, models.NCalendarEvent{Title: "Standup", StartTime: "09:00", EndTime: "10:00", Day: 1, CalendarID: cal.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev.Title = "Retro"
+	if _, err := s.UpdateEvent(WithChange(ctx, Change{Actor: "bob", Action: ActionRevert}), ev); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := s.History(ctx, EntityEvent, ev.ID)
+	if err != nil || len(history) != 2 {
+		t.Fatalf("history is %+v, %v", history, err)
+	}
+	created, reverted := history[0], history[1]
+	if created.Action != ActionCreate || created.Actor != "alice" || created.Source != SourceLiveSync || created.Version != 1 || created.Before != nil {
+		t.Errorf("create recorded as %+v", created)
+	}
+	// A Change without a source is recorded as coming from the API.
+	if reverted.Action != ActionRevert || reverted.Actor != "bob" || reverted.Source != SourceAPI || reverted.Version != 2 {
+		t.Errorf("revert recorded as %+v", reverted)
+	}
+	if c, ok := reverted.Changes["title"]; !ok || len(reverted.Changes) != 1 || string(c.After) != `"Retro"` {
+		t.Errorf("revert changed %v", reverted.Changes)
+	}
+}
+
+func TestRecordEventRemoved(t *testing.T) {
+	ctx := context.Background()
+	s, db := newSQLite(t)
+	cal, err := s.CreateCalendar(ctx, models.NCalendar{Name: "Work"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev, err := s.CreateEvent(ctx, models.NCalendarEvent{Title: "Standup", StartTime: "09:00", EndTime: "10:00", Day: 1, CalendarID: cal.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	removed, err := ReadEvent(ctx, tx, database.SQLite, ev.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec("DELETE FROM calendar_events WHERE id = ?", ev.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := RecordEventRemoved(ctx, tx, removed); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := s.History(ctx, EntityEvent, ev.ID)
+	if err != nil || len(history) != 2 {
+		t.Fatalf("history is %+v, %v", history, err)
+	}
+	deleted := history[1]
+	if deleted.Action != ActionDelete || deleted.Version != 2 {
+		t.Errorf("delete recorded as %+v", deleted)
+	}
+	// The event after is a tombstone, so the entry shows it going away.
+	var after models.CalendarEvent
+	if err := json.Unmarshal(deleted.After, &after); err != nil || after.DeletedAt == nil || after.Version != 2 {
+		t.Errorf("after the delete the event is %s", deleted.After)
+	}
+	if _, ok := deleted.Changes["deletedAt"]; !ok || len(deleted.Changes) != 1 {
+		t.Errorf("the delete changed %v", deleted.Changes)
+	}
+}
@@ -0,0 +1,118 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/Aman221/4723/internal/models"
+)
+
+// Idempotency keys are plain rows, like saved searches, and their SQL works
+// on both Postgres and SQLite. Times are written from Go in UTC so that
+// SQLite compares them correctly.
+
+func claimIdempotencyKey(ctx context.Context, db handle, key string, claim models.IdempotentRequest, cutoff time.Time) (models.IdempotentRequest, bool, error) {
+	var earlier models.IdempotentRequest
+	claimed := false
+	err := inTx(ctx, db, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE claimed_at < $1", cutoff.UTC()); err != nil {
+			return err
+		}
+		res, err := tx.ExecContext(ctx, `
+			INSERT INTO idempotency_keys (key, fingerprint, claimed_at) VALUES ($1, $2, $3)
+			ON CONFLICT (key) DO NOTHING
+		`, key, claim.Fingerprint, claim.ClaimedAt.UTC())
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil || n == 1 {
+			claimed = err == nil
+			return err
+		}
+		var header string
+		err = tx.QueryRowContext(ctx, "SELECT fingerprint, claimed_at, status, header, body FROM idempotency_keys WHERE key = $1", key).
+			Scan(&earlier.Fingerprint, &earlier.ClaimedAt, &earlier.Status, &header, &earlier.Body)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal([]byte(header), &earlier.Header)
+	})
+	return earlier, claimed, err
+}
+
+func completeIdempotencyKey(ctx context.Context, db querier, key string, response models.IdempotentRequest) error {
+	header, err := json.Marshal(response.Header)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, "UPDATE idempotency_keys SET status = $1, header = $2, body = $3 WHERE key = $4",
+		response.Status, string(header), response.Body, key)
+	return err
+}
+
+func releaseIdempotencyKey(ctx context.Context, db querier, key string, claim models.IdempotentRequest) error {
+	_, err := db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE key = $1 AND fingerprint = $2 AND claimed_at = $3 AND status = 0",
+		key, claim.Fingerprint, claim.ClaimedAt.UTC())
+	return err
+}
+
+func (p *Postgres) ClaimIdempotencyKey(ctx context.Context, key string, claim models.IdempotentRequest, cutoff time.Time) (models.IdempotentRequest, bool, error) {
+	return claimIdempotencyKey(ctx, p.db, key, claim, cutoff)
+}
+
+func (p *Postgres) CompleteIdempotencyKey(ctx context.Context, key string, response models.IdempotentRequest) error {
+	return completeIdempotencyKey(ctx, p.db, key, response)
+}
+
+func (p *Postgres) ReleaseIdempotencyKey(ctx context.Context, key string, claim models.IdempotentRequest) error {
+	return releaseIdempotencyKey(ctx, p.db, key, claim)
+}
+
+func (s *SQLite) ClaimIdempotencyKey(ctx context.Context, key string, claim models.IdempotentRequest, cutoff time.Time) (models.IdempotentRequest, bool, error) {
+	return claimIdempotencyKey(ctx, s.db, key, claim, cutoff)
+}
+
+func (s *SQLite) CompleteIdempotencyKey(ctx context.Context, key string, response models.IdempotentRequest) error {
+	return completeIdempotencyKey(ctx, s.db, key, response)
+}
+
+func (s *SQLite) ReleaseIdempotencyKey(ctx context.Context, key string, claim models.IdempotentRequest) error {
+	return releaseIdempotencyKey(ctx, s.db, key, claim)
+}
+
+func (m *Memory) ClaimIdempotencyKey(ctx context.Context, key string, claim models.IdempotentRequest, cutoff time.Time) (models.IdempotentRequest, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for k, r := range m.keys {
+		if r.ClaimedAt.Before(cutoff) {
+			delete(m.keys, k)
+		}
+	}
+	if earlier, ok := m.keys[key]; ok {
+		return earlier, false, nil
+	}
+	claim.Status, claim.Header, claim.Body = 0, nil, nil
+	m.keys[key] = claim
+	return models.IdempotentRequest{}, true, nil
+}
+
+func (m *Memory) CompleteIdempotencyKey(ctx context.Context, key string, response models.IdempotentRequest) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if claim, ok := m.keys[key]; ok {
+		claim.Status, claim.Header, claim.Body = response.Status, response.Header, response.Body
+		m.keys[key] = claim
+	}
+	return nil
+}
+
+func (m *Memory) ReleaseIdempotencyKey(ctx context.Context, key string, claim models.IdempotentRequest) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if held, ok := m.keys[key]; ok && held.Fingerprint == claim.Fingerprint && held.ClaimedAt.Equal(claim.ClaimedAt) && held.Status == 0 {
+		delete(m.keys, key)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package store
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Aman221/4723/internal/models"
+)
+
+func TestIdempotencyKeys(t *testing.T) {
+	sqlite, _ := newSQLite(t)
+	for name, s := range map[string]Store{"memory": NewMemory(), "sqlite": sqlite} {
+		ctx := context.Background()
+		at := time.Date(2026, 1, 7, 9, 0, 0, 0, time.UTC)
+		claim := models.IdempotentRequest{Fingerprint: "abc", ClaimedAt: at}
+
+		if _, claimed, err := s.ClaimIdempotencyKey(ctx, "k", claim, at.Add(-time.Hour)); err != nil || !claimed {
+			t.Fatalf("%s: first claim is %v, %v", name, claimed, err)
+		}
+		earlier, claimed, err := s.ClaimIdempotencyKey(ctx, "k", claim, at.Add(-time.Hour))
+		if err != nil || claimed {
+			t.Fatalf("%s: second claim is %v, %v", name, claimed, err)
+		}
+		if earlier.Fingerprint != "abc" || !earlier.ClaimedAt.Equal(at) || earlier.Status != 0 {
+			t.Errorf("%s: key in use is %+v", name, earlier)
+		}
+
+		// Releasing someone else's claim leaves the key alone.
+		other := models.IdempotentRequest{Fingerprint: "xyz", ClaimedAt: at}
+		if err := s.ReleaseIdempotencyKey(ctx, "k", other); err != nil {
+			t.Fatal(err)
+		}
+		response := models.IdempotentRequest{Status: 201, Header: map[string]string{"Location": "/events/1"}, Body: []byte(`{"id":"1"}`)}
+		if err := s.CompleteIdempotencyKey(ctx, "k", response); err != nil {
+			t.Fatal(err)
+		}
+		earlier, claimed, err = s.ClaimIdempotencyKey(ctx, "k", claim, at.Add(-time.Hour))
+		if err != nil || claimed {
+			t.Fatalf("%s: claim after completing is %v, %v", name, claimed, err)
+		}
+		if earlier.Status != 201 || !reflect.DeepEqual(earlier.Header, response.Header) || string(earlier.Body) != `{"id":"1"}` {
+			t.Errorf("%s: completed key is %+v", name, earlier)
+		}
+
+		// A completed key is kept even by its own claim's release.
+		if err := s.ReleaseIdempotencyKey(ctx, "k", claim); err != nil {
+			t.Fatal(err)
+		}
+		if _, claimed, _ := s.ClaimIdempotencyKey(ctx, "k", claim, at.Add(-time.Hour)); claimed {
+			t.Errorf("%s: completed key was released", name)
+		}
+
+		// An unfinished claim is released by its owner.
+		if _, claimed, _ := s.ClaimIdempotencyKey(ctx, "j", claim, at.Add(-time.Hour)); !claimed {
+			t.Fatalf("%s: j was not claimed", name)
+		}
+		if err := s.ReleaseIdempotencyKey(ctx, "j", claim); err != nil {
+			t.Fatal(err)
+		}
+		if _, claimed, _ := s.ClaimIdempotencyKey(ctx, "j", claim, at.Add(-time.Hour)); !claimed {
+			t.Errorf("%s: released key is still held", name)
+		}
+
+		// Keys claimed before the cutoff are forgotten.
+		if _, claimed, _ := s.ClaimIdempotencyKey(ctx, "k", claim, at.Add(time.Minute)); !claimed {
+			t.Errorf("%s: old key was kept", name)
+		}
+	}
+}
@@ -0,0 +1,497 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Aman221/4723/internal/attendees"
+	"github.com/Aman221/4723/internal/models"
+)
+
+// Memory is a Store that keeps everything in process. It behaves like
+// Postgres where handlers can tell the difference: ids are increasing
+// integers, results come back in id order and deleting a calendar moves its
+// events to the trash. Search works as it does on SQLite.
+type Memory struct {
+	mu        sync.Mutex
+	nextID    int
+	users     map[string]models.User
+	userOf    map[string]string // calendar or event id -> user id
+	calendars map[string]models.Calendar
+	events    map[string]models.CalendarEvent
+	history   []models.HistoryEntry
+	searches  map[string]models.SavedSearch
+	keys      map[string]models.IdempotentRequest // by Idempotency-Key
+}
+
+// NewMemory returns an empty in-memory Store.
+func NewMemory() *Memory {
+	return &Memory{
+		users:     map[string]models.User{},
+		userOf:    map[string]string{},
+		calendars: map[string]models.Calendar{},
+		events:    map[string]models.CalendarEvent{},
+		searches:  map[string]models.SavedSearch{},
+		keys:      map[string]models.IdempotentRequest{},
+	}
+}
+
+func (m *Memory) newID() string {
+	m.nextID++
+	return strconv.Itoa(m.nextID)
+}
+
+// byID sorts ids numerically.
+func byID(ids []string) {
+	sort.Slice(ids, func(i, j int) bool { return numericLess(ids[i], ids[j]) })
+}
+
+// numericLess compares two ids as numbers.
+func numericLess(a, b string) bool {
+	x, _ := strconv.Atoi(a)
+	y, _ := strconv.Atoi(b)
+	return x < y
+}
+
+// copyEvent returns event with its slices and pointers copied, so callers
+// can't modify stored data.
+func copyEvent(event models.CalendarEvent) models.CalendarEvent {
+	event.Attendees = append([]string{}, event.Attendees...)
+	if event.Date != nil {
+		date := *event.Date
+		event.Date = &date
+	}
+	if event.DeletedAt != nil {
+		deletedAt := *event.DeletedAt
+		event.DeletedAt = &deletedAt
+	}
+	return event
+}
+
+// eventsWhere returns the page of the events outside the trash that keep
+// accepts.
+func (m *Memory) eventsWhere(page Page, keep func(models.CalendarEvent) bool) []models.CalendarEvent {
+	ids := make([]string, 0, len(m.events))
+	for id, event := range m.events {
+		if event.DeletedAt == nil && keep(event) {
+			ids = append(ids, id)
+		}
+	}
+	byID(ids)
+	ids = page.ids(ids)
+	events := make([]models.CalendarEvent, 0, len(ids))
+	for _, id := range ids {
+		events = append(events, copyEvent(m.events[id]))
+	}
+	return events
+}
+
+// calendarsWhere returns the page of the calendars outside the trash that
+// keep accepts.
+func (m *Memory) calendarsWhere(page Page, keep func(models.Calendar) bool) []models.Calendar {
+	ids := make([]string, 0, len(m.calendars))
+	for id, cal := range m.calendars {
+		if cal.DeletedAt == nil && keep(cal) {
+			ids = append(ids, id)
+		}
+	}
+	byID(ids)
+	ids = page.ids(ids)
+	calendars := make([]models.Calendar, 0, len(ids))
+	for _, id := range ids {
+		calendars = append(calendars, m.calendars[id])
+	}
+	return calendars
+}
+
+// now is the UpdatedAt of a write.
+func now() string {
+	return time.Now().UTC().Format(time.RFC3339Nano)
+}
+
+// liveCalendar reports whether a calendar exists and is not in the trash.
+func (m *Memory) liveCalendar(id string) bool {
+	cal, ok := m.calendars[id]
+	return ok && cal.DeletedAt == nil
+}
+
+// record appends a write to the history. before is nil for a create.
+func (m *Memory) record(ctx context.Context, entity, id, action string, version int, before, after interface{}) {
+	e, err := entry(ctx, entity, id, action, version, before, after)
+	if err != nil {
+		panic(err) // calendars and events always marshal
+	}
+	e.ID = strconv.Itoa(len(m.history) + 1)
+	e.ChangedAt = now()
+	m.history = append(m.history, e)
+}
+
+// SetOwner assigns a calendar or event to a user, which the API has no
+// way of doing yet.
+func (m *Memory) SetOwner(id, userID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.userOf[id] = userID
+}
+
+func (m *Memory) GetUser(ctx context.Context, id string) (models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[id]
+	if !ok {
+		return u, ErrNotFound
+	}
+	return u, nil
+}
+
+func (m *Memory) CreateUser(ctx context.Context, user models.User) (models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	user.ID = m.newID()
+	user.DateCreated = now()
+	m.users[user.ID] = user
+	return user, nil
+}
+
+func (m *Memory) ListCalendars(ctx context.Context, page Page) ([]models.Calendar, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.calendarsWhere(page, func(models.Calendar) bool { return true }), nil
+}
+
+func (m *Memory) ListUserCalendars(ctx context.Context, userID string) ([]models.Calendar, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.calendarsWhere(Page{}, func(cal models.Calendar) bool { return m.userOf[cal.ID] == userID }), nil
+}
+
+func (m *Memory) GetCalendar(ctx context.Context, id string) (models.Calendar, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cal, ok := m.calendars[id]
+	if !ok || cal.DeletedAt != nil {
+		return models.Calendar{}, ErrNotFound
+	}
+	return cal, nil
+}
+
+func (m *Memory) CreateCalendar(ctx context.Context, cal models.NCalendar) (models.Calendar, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	created := models.Calendar{ID: m.newID(), Name: cal.Name, Color: cal.Color, Visible: cal.Visible,
+		Version: 1, UpdatedAt: now()}
+	m.calendars[created.ID] = created
+	m.record(ctx, EntityCalendar, created.ID, ActionCreate, created.Version, nil, created)
+	return created, nil
+}
+
+func (m *Memory) UpdateCalendar(ctx context.Context, cal models.Calendar) (models.Calendar, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	current, ok := m.calendars[cal.ID]
+	if !ok || current.DeletedAt != nil {
+		return cal, ErrNotFound
+	}
+	if stale(cal.Version, current.Version) {
+		return cal, ErrVersionMismatch
+	}
+	cal.Version = current.Version + 1
+	cal.UpdatedAt = now()
+	cal.DeletedAt = nil
+	m.calendars[cal.ID] = cal
+	m.record(ctx, EntityCalendar, cal.ID, ActionUpdate, cal.Version, current, cal)
+	return cal, nil
+}
+
+func (m *Memory) SetCalendarVisible(ctx context.Context, id string, visible bool, version int) (models.Calendar, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cal, ok := m.calendars[id]
+	if !ok || cal.DeletedAt != nil {
+		return cal, ErrNotFound
+	}
+	if stale(version, cal.Version) {
+		return cal, ErrVersionMismatch
+	}
+	before := cal
+	cal.Visible = visible
+	cal.Version++
+	cal.UpdatedAt = now()
+	m.calendars[id] = cal
+	m.record(ctx, EntityCalendar, id, ActionUpdate, cal.Version, before, cal)
+	return cal, nil
+}
+
+func (m *Memory) DeleteCalendar(ctx context.Context, id string, version int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cal, ok := m.calendars[id]
+	if !ok || cal.DeletedAt != nil {
+		return ErrNotFound
+	}
+	if stale(version, cal.Version) {
+		return ErrVersionMismatch
+	}
+	before := cal
+	deletedAt := now()
+	cal.DeletedAt = &deletedAt
+	cal.Version++
+	cal.UpdatedAt = deletedAt
+	m.calendars[id] = cal
+	m.record(ctx, EntityCalendar, id, ActionDelete, cal.Version, before, cal)
+	for _, event := range m.eventsWhere(Page{}, func(event models.CalendarEvent) bool { return event.CalendarID == id }) {
+		before := copyEvent(event)
+		event.DeletedAt = &deletedAt
+		event.Version++
+		event.UpdatedAt = deletedAt
+		m.events[event.ID] = event
+		m.record(ctx, EntityEvent, event.ID, ActionDelete, event.Version, before, event)
+	}
+	return nil
+}
+
+func (m *Memory) ListEvents(ctx context.Context, calendarIDs []string, page Page) ([]models.CalendarEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	wanted := map[string]bool{}
+	for _, id := range calendarIDs {
+		wanted[id] = true
+	}
+	return m.eventsWhere(page, func(event models.CalendarEvent) bool { return wanted[event.CalendarID] }), nil
+}
+
+func (m *Memory) ListUserEvents(ctx context.Context, userID string, page Page) ([]models.CalendarEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.eventsWhere(page, func(event models.CalendarEvent) bool { return m.userOf[event.ID] == userID }), nil
+}
+
+func (m *Memory) SearchEvents(ctx context.Context, search EventSearch) (models.EventSearchResults, error) {
+	query, err := ParseQuery(search.Query)
+	if err != nil {
+		return models.EventSearchResults{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	events := m.eventsWhere(Page{}, func(event models.CalendarEvent) bool {
+		return (search.IncludeHidden || m.calendars[event.CalendarID].Visible) && search.keeps(event)
+	})
+	return searchInGo(events, query, search), nil
+}
+
+func (m *Memory) GetEvent(ctx context.Context, id string) (models.CalendarEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	event, ok := m.events[id]
+	if !ok || event.DeletedAt != nil {
+		return models.CalendarEvent{}, ErrNotFound
+	}
+	return copyEvent(event), nil
+}
+
+func (m *Memory) CreateEvent(ctx context.Context, event models.NCalendarEvent) (models.CalendarEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.liveCalendar(event.CalendarID) {
+		return models.CalendarEvent{}, ErrUnknownCalendar
+	}
+	created := copyEvent(models.CalendarEvent{
+		ID:          m.newID(),
+		Title:       event.Title,
+		StartTime:   event.StartTime,
+		EndTime:     event.EndTime,
+		Color:       event.Color,
+		Day:         event.Day,
+		Description: event.Description,
+		Location:    event.Location,
+		Attendees:   attendees.Normalize(event.Attendees),
+		Organizer:   event.Organizer,
+		CalendarID:  event.CalendarID,
+		Date:        event.Date,
+		Version:     1,
+		UpdatedAt:   now(),
+	})
+	m.events[created.ID] = created
+	m.record(ctx, EntityEvent, created.ID, ActionCreate, created.Version, nil, created)
+	return copyEvent(created), nil
+}
+
+func (m *Memory) UpdateEvent(ctx context.Context, event models.CalendarEvent) (models.CalendarEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	current, ok := m.events[event.ID]
+	if !ok || current.DeletedAt != nil {
+		return event, ErrNotFound
+	}
+	if stale(event.Version, current.Version) {
+		return event, ErrVersionMismatch
+	}
+	if !m.liveCalendar(event.CalendarID) {
+		return event, ErrUnknownCalendar
+	}
+	event.Attendees = attendees.Normalize(event.Attendees)
+	event.Version = current.Version + 1
+	event.UpdatedAt = now()
+	event.DeletedAt = nil
+	m.events[event.ID] = copyEvent(event)
+	m.record(ctx, EntityEvent, event.ID, ActionUpdate, event.Version, current, event)
+	return copyEvent(event), nil
+}
+
+func (m *Memory) DeleteEvent(ctx context.Context, id string, version int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	event, ok := m.events[id]
+	if !ok || event.DeletedAt != nil {
+		return ErrNotFound
+	}
+	if stale(version, event.Version) {
+		return ErrVersionMismatch
+	}
+	before := copyEvent(event)
+	deletedAt := now()
+	event.DeletedAt = &deletedAt
+	event.Version++
+	event.UpdatedAt = deletedAt
+	m.events[id] = event
+	m.record(ctx, EntityEvent, id, ActionDelete, event.Version, before, event)
+	return nil
+}
+
+// newestFirst sorts ids by when they went into the trash, most recent
+// first, then by id.
+func newestFirst(ids []string, deletedAt func(id string) string) {
+	byID(ids)
+	sort.SliceStable(ids, func(i, j int) bool { return deletedAt(ids[i]) > deletedAt(ids[j]) })
+}
+
+func (m *Memory) ListTrash(ctx context.Context) (models.Trash, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	trash := models.Trash{Calendars: []models.Calendar{}, Events: []models.CalendarEvent{}}
+
+	var calendarIDs, eventIDs []string
+	for id, cal := range m.calendars {
+		if cal.DeletedAt != nil {
+			calendarIDs = append(calendarIDs, id)
+		}
+	}
+	for id, event := range m.events {
+		if event.DeletedAt != nil {
+			eventIDs = append(eventIDs, id)
+		}
+	}
+	newestFirst(calendarIDs, func(id string) string { return *m.calendars[id].DeletedAt })
+	newestFirst(eventIDs, func(id string) string { return *m.events[id].DeletedAt })
+	for _, id := range calendarIDs {
+		trash.Calendars = append(trash.Calendars, m.calendars[id])
+	}
+	for _, id := range eventIDs {
+		trash.Events = append(trash.Events, copyEvent(m.events[id]))
+	}
+	return trash, nil
+}
+
+func (m *Memory) RestoreCalendar(ctx context.Context, id string) (models.Calendar, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cal, ok := m.calendars[id]
+	if !ok || cal.DeletedAt == nil {
+		return models.Calendar{}, ErrNotFound
+	}
+	before := cal
+	restoredAt := now()
+	cal.DeletedAt = nil
+	cal.Version++
+	cal.UpdatedAt = restoredAt
+	m.calendars[id] = cal
+	m.record(ctx, EntityCalendar, id, ActionRestore, cal.Version, before, cal)
+
+	var eventIDs []string
+	for eventID, event := range m.events {
+		if event.CalendarID == id && event.DeletedAt != nil && *event.DeletedAt == *before.DeletedAt {
+			eventIDs = append(eventIDs, eventID)
+		}
+	}
+	byID(eventIDs)
+	for _, eventID := range eventIDs {
+		event := m.events[eventID]
+		before := copyEvent(event)
+		event.DeletedAt = nil
+		event.Version++
+		event.UpdatedAt = restoredAt
+		m.events[eventID] = event
+		m.record(ctx, EntityEvent, eventID, ActionRestore, event.Version, before, event)
+	}
+	return cal, nil
+}
+
+func (m *Memory) RestoreEvent(ctx context.Context, id string) (models.CalendarEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	event, ok := m.events[id]
+	if !ok || event.DeletedAt == nil {
+		return models.CalendarEvent{}, ErrNotFound
+	}
+	if !m.liveCalendar(event.CalendarID) {
+		return models.CalendarEvent{}, ErrCalendarDeleted
+	}
+	before := copyEvent(event)
+	event.DeletedAt = nil
+	event.Version++
+	event.UpdatedAt = now()
+	m.events[id] = event
+	m.record(ctx, EntityEvent, id, ActionRestore, event.Version, before, event)
+	return copyEvent(event), nil
+}
+
+func (m *Memory) History(ctx context.Context, entity, id string) ([]models.HistoryEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entries := []models.HistoryEntry{}
+	for _, e := range m.history {
+		if e.Entity == entity && e.EntityID == id {
+			e.Changes = changes(e.Before, e.After)
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+func (m *Memory) PurgeTrash(ctx context.Context, cutoff time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	before := func(deletedAt *string) bool {
+		if deletedAt == nil {
+			return false
+		}
+		at, err := time.Parse(time.RFC3339Nano, *deletedAt)
+		return err == nil && at.Before(cutoff)
+	}
+	var n int64
+	for id, event := range m.events {
+		if before(event.DeletedAt) {
+			delete(m.events, id)
+			delete(m.userOf, id)
+			n++
+		}
+	}
+	for id, cal := range m.calendars {
+		if before(cal.DeletedAt) {
+			delete(m.calendars, id)
+			delete(m.userOf, id)
+			n++
+			// Like ON DELETE CASCADE.
+			for eventID, event := range m.events {
+				if event.CalendarID == id {
+					delete(m.events, eventID)
+					delete(m.userOf, eventID)
+				}
+			}
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,72 @@
+package store
+
+import "slices"
+
+// DefaultPageSize and MaxPageSize bound the pages of the calendar and event
+// lists.
+const (
+	DefaultPageSize = 100
+	MaxPageSize     = 500
+)
+
+// Page asks for part of a list in id order: the first Limit items with ids
+// after After or, if Before is set instead, the last Limit items with ids
+// before Before. Ids compare as numbers, and a Limit of 0 means no limit.
+// The zero Page is the whole list.
+type Page struct {
+	After, Before string
+	Limit         int
+}
+
+// Includes reports whether id lies between the bounds of the page.
+func (p Page) Includes(id string) bool {
+	return (p.After == "" || numericLess(p.After, id)) && (p.Before == "" || numericLess(id, p.Before))
+}
+
+// backwards reports whether the page is counted back from Before.
+func (p Page) backwards() bool { return p.Before != "" && p.After == "" }
+
+// sql returns the condition on column and the ORDER BY and LIMIT clauses
+// that select the page, adding their parameters with arg. A page counted
+// backwards comes out in descending order, which inOrder undoes.
+func (p Page) sql(column string, arg func(interface{}) string) (where, orderLimit string) {
+	where, orderLimit = "TRUE", " ORDER BY "+column
+	if p.After != "" {
+		where += " AND " + column + " > " + arg(p.After)
+	}
+	if p.Before != "" {
+		where += " AND " + column + " < " + arg(p.Before)
+	}
+	if p.backwards() {
+		orderLimit += " DESC"
+	}
+	if p.Limit > 0 {
+		orderLimit += " LIMIT " + arg(p.Limit)
+	}
+	return where, orderLimit
+}
+
+// inOrder puts the rows of a page read with sql in ascending order.
+func inOrder[T any](p Page, rows []T) []T {
+	if p.backwards() {
+		slices.Reverse(rows)
+	}
+	return rows
+}
+
+// ids returns the page of ids, which are in ascending order.
+func (p Page) ids(ids []string) []string {
+	var kept []string
+	for _, id := range ids {
+		if p.Includes(id) {
+			kept = append(kept, id)
+		}
+	}
+	if p.Limit > 0 && len(kept) > p.Limit {
+		if p.backwards() {
+			return kept[len(kept)-p.Limit:]
+		}
+		return kept[:p.Limit]
+	}
+	return kept
+}
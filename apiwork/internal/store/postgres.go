@@ -0,0 +1,700 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/Aman221/4723/internal/attendees"
+	"github.com/Aman221/4723/internal/database"
+	"github.com/Aman221/4723/internal/models"
+)
+
+// Postgres is a Store backed by the tables created by the migrations
+// package.
+type Postgres struct {
+	db handle
+}
+
+// NewPostgres returns a Store using db.
+func NewPostgres(db *sql.DB) *Postgres {
+	return &Postgres{db: handle{db: db}}
+}
+
+// eventColumns lists the columns scanEvent reads, for the event aliased as
+// e. Attendees come from event_attendees as a JSON array.
+func eventColumns(dialect string) string {
+	return "e.id, e.title, e.start_time, e.end_time, e.color, e.day, e.description, e.location, " +
+		attendees.Column(dialect, "e") + ", e.organizer, e.calendar_id, e.date, e.version, e.updated_at, e.deleted_at"
+}
+
+var pgEventColumns = eventColumns(database.Postgres)
+
+// calendarColumns lists the columns scanCalendar reads.
+const calendarColumns = "id, name, color, visible, version, updated_at, deleted_at"
+
+// pgArg returns a func that adds a parameter to args and returns its
+// placeholder.
+func pgArg(args *[]interface{}) func(interface{}) string {
+	return func(v interface{}) string {
+		*args = append(*args, v)
+		return "$" + strconv.Itoa(len(*args))
+	}
+}
+
+// validID reports whether id can name a row. Ids are serial integers;
+// anything else can't exist, and passing it to Postgres would fail the
+// query instead.
+func validID(id string) bool {
+	_, err := strconv.Atoi(id)
+	return err == nil
+}
+
+func scanEvent(scanner interface{ Scan(...interface{}) error }) (models.CalendarEvent, error) {
+	var event models.CalendarEvent
+	var attendeeList string
+	var date, deletedAt sql.NullString
+	err := scanner.Scan(&event.ID, &event.Title, &event.StartTime, &event.EndTime, &event.Color, &event.Day,
+		&event.Description, &event.Location, &attendeeList, &event.Organizer, &event.CalendarID, &date,
+		&event.Version, &event.UpdatedAt, &deletedAt)
+	if err != nil {
+		return event, err
+	}
+	if date.Valid {
+		event.Date = &date.String
+	}
+	if deletedAt.Valid {
+		event.DeletedAt = &deletedAt.String
+	}
+	event.Attendees = attendees.Parse(attendeeList)
+	return event, nil
+}
+
+func scanCalendar(scanner interface{ Scan(...interface{}) error }) (models.Calendar, error) {
+	var cal models.Calendar
+	var deletedAt sql.NullString
+	err := scanner.Scan(&cal.ID, &cal.Name, &cal.Color, &cal.Visible, &cal.Version, &cal.UpdatedAt, &deletedAt)
+	if deletedAt.Valid {
+		cal.DeletedAt = &deletedAt.String
+	}
+	return cal, err
+}
+
+// extraColumns scans a row with more columns than scanEvent or
+// scanCalendar read, putting the rest into extra.
+type extraColumns struct {
+	row   interface{ Scan(...interface{}) error }
+	extra []interface{}
+}
+
+func (x extraColumns) Scan(dest ...interface{}) error {
+	return x.row.Scan(append(dest, x.extra...)...)
+}
+
+func queryEvents(ctx context.Context, db querier, query string, args ...interface{}) ([]models.CalendarEvent, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	events := []models.CalendarEvent{}
+	for rows.Next() {
+		event, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+func queryCalendars(ctx context.Context, db querier, query string, args ...interface{}) ([]models.Calendar, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	calendars := []models.Calendar{}
+	for rows.Next() {
+		cal, err := scanCalendar(rows)
+		if err != nil {
+			return nil, err
+		}
+		calendars = append(calendars, cal)
+	}
+	return calendars, rows.Err()
+}
+
+// affected turns an UPDATE or DELETE result into ErrNotFound when no row
+// matched.
+func affected(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// querier is satisfied by *sql.DB, *sql.Tx and handle.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// inTx runs write in a transaction, which it commits if write succeeds.
+// Within a batch, write joins the batch's transaction.
+func inTx(ctx context.Context, db handle, write func(tx *sql.Tx) error) error {
+	return inTxWith(ctx, db, nil, write)
+}
+
+// inTxWith is inTx with options for a transaction of its own.
+func inTxWith(ctx context.Context, db handle, opts *sql.TxOptions, write func(tx *sql.Tx) error) error {
+	if db.tx != nil {
+		return write(db.tx)
+	}
+	tx, err := db.db.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := write(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// liveCalendar returns ErrUnknownCalendar unless the calendar exists and
+// is not in the trash.
+func liveCalendar(ctx context.Context, db querier, id string) error {
+	var exists int
+	err := db.QueryRowContext(ctx, "SELECT 1 FROM calendars WHERE id = $1 AND deleted_at IS NULL", id).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrUnknownCalendar
+	}
+	return err
+}
+
+// notResourceCalendar returns ErrResourceCalendar if the calendar belongs
+// to a resource. Purging it from the trash would break the resource.
+func notResourceCalendar(ctx context.Context, db querier, id string) error {
+	var exists int
+	err := db.QueryRowContext(ctx, "SELECT 1 FROM resources WHERE calendar_id = $1", id).Scan(&exists)
+	if err == nil {
+		return ErrResourceCalendar
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	return err
+}
+
+// foreignKey maps a foreign key violation on calendar_id to
+// ErrUnknownCalendar.
+func foreignKey(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+		return ErrUnknownCalendar
+	}
+	return err
+}
+
+func (p *Postgres) GetUser(ctx context.Context, id string) (models.User, error) {
+	var u models.User
+	if !validID(id) {
+		return u, ErrNotFound
+	}
+	err := p.db.QueryRowContext(ctx, "SELECT id, username, email, date_created FROM users WHERE id = $1", id).
+		Scan(&u.ID, &u.Username, &u.Email, &u.DateCreated)
+	if errors.Is(err, sql.ErrNoRows) {
+		return u, ErrNotFound
+	}
+	return u, err
+}
+
+func (p *Postgres) CreateUser(ctx context.Context, user models.User) (models.User, error) {
+	err := p.db.QueryRowContext(ctx, "INSERT INTO users (username, email) VALUES ($1, $2) RETURNING id, date_created",
+		user.Username, user.Email).Scan(&user.ID, &user.DateCreated)
+	return user, err
+}
+
+func (p *Postgres) ListCalendars(ctx context.Context, page Page) ([]models.Calendar, error) {
+	var args []interface{}
+	where, order := page.sql("id", pgArg(&args))
+	calendars, err := queryCalendars(ctx, p.db, "SELECT "+calendarColumns+" FROM calendars WHERE deleted_at IS NULL AND "+where+order, args...)
+	return inOrder(page, calendars), err
+}
+
+func (p *Postgres) ListUserCalendars(ctx context.Context, userID string) ([]models.Calendar, error) {
+	if !validID(userID) {
+		return []models.Calendar{}, nil
+	}
+	return queryCalendars(ctx, p.db, "SELECT "+calendarColumns+" FROM calendars WHERE user_id = $1 AND deleted_at IS NULL ORDER BY id", userID)
+}
+
+func (p *Postgres) GetCalendar(ctx context.Context, id string) (models.Calendar, error) {
+	if !validID(id) {
+		return models.Calendar{}, ErrNotFound
+	}
+	cal, err := scanCalendar(p.db.QueryRowContext(ctx, "SELECT "+calendarColumns+" FROM calendars WHERE id = $1 AND deleted_at IS NULL", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return cal, ErrNotFound
+	}
+	return cal, err
+}
+
+func (p *Postgres) CreateCalendar(ctx context.Context, cal models.NCalendar) (models.Calendar, error) {
+	var created models.Calendar
+	err := inTx(ctx, p.db, func(tx *sql.Tx) error {
+		var err error
+		created, err = scanCalendar(tx.QueryRowContext(ctx, "INSERT INTO calendars (name, color, visible) VALUES ($1, $2, $3) RETURNING "+calendarColumns,
+			cal.Name, cal.Color, cal.Visible))
+		if err != nil {
+			return err
+		}
+		return recordCalendar(ctx, tx, ActionCreate, nil, created)
+	})
+	return created, err
+}
+
+func (p *Postgres) UpdateCalendar(ctx context.Context, cal models.Calendar) (models.Calendar, error) {
+	if !validID(cal.ID) {
+		return models.Calendar{}, ErrNotFound
+	}
+	var updated models.Calendar
+	err := inTx(ctx, p.db, func(tx *sql.Tx) error {
+		before, err := liveCalendarRow(ctx, tx, cal.ID, cal.Version)
+		if err != nil {
+			return err
+		}
+		updated, err = scanCalendar(tx.QueryRowContext(ctx, `
+			UPDATE calendars SET name = $1, color = $2, visible = $3, version = version + 1, updated_at = NOW()
+			WHERE id = $4 AND version = $5
+			RETURNING `+calendarColumns,
+			cal.Name, cal.Color, cal.Visible, cal.ID, before.Version))
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrVersionMismatch
+		}
+		if err != nil {
+			return err
+		}
+		return recordCalendar(ctx, tx, ActionUpdate, &before, updated)
+	})
+	return updated, err
+}
+
+func (p *Postgres) SetCalendarVisible(ctx context.Context, id string, visible bool, version int) (models.Calendar, error) {
+	if !validID(id) {
+		return models.Calendar{}, ErrNotFound
+	}
+	var updated models.Calendar
+	err := inTx(ctx, p.db, func(tx *sql.Tx) error {
+		before, err := liveCalendarRow(ctx, tx, id, version)
+		if err != nil {
+			return err
+		}
+		updated, err = scanCalendar(tx.QueryRowContext(ctx, `
+			UPDATE calendars SET visible = $1, version = version + 1, updated_at = NOW()
+			WHERE id = $2 AND version = $3
+			RETURNING `+calendarColumns,
+			visible, id, before.Version))
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrVersionMismatch
+		}
+		if err != nil {
+			return err
+		}
+		return recordCalendar(ctx, tx, ActionUpdate, &before, updated)
+	})
+	return updated, err
+}
+
+func (p *Postgres) DeleteCalendar(ctx context.Context, id string, version int) error {
+	if !validID(id) {
+		return ErrNotFound
+	}
+	return inTx(ctx, p.db, func(tx *sql.Tx) error {
+		before, err := liveCalendarRow(ctx, tx, id, version)
+		if err != nil {
+			return err
+		}
+		if err := notResourceCalendar(ctx, tx, id); err != nil {
+			return err
+		}
+		events, err := queryEvents(ctx, tx, "SELECT "+pgEventColumns+" FROM calendar_events e WHERE e.calendar_id = $1 AND e.deleted_at IS NULL ORDER BY e.id", id)
+		if err != nil {
+			return err
+		}
+
+		// NOW() is the start of the transaction, so the calendar and its
+		// events get the same deleted_at.
+		deleted, err := scanCalendar(tx.QueryRowContext(ctx, `
+			UPDATE calendars SET deleted_at = NOW(), version = version + 1, updated_at = NOW()
+			WHERE id = $1 AND version = $2
+			RETURNING `+calendarColumns, id, before.Version))
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrVersionMismatch
+		}
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `
+			UPDATE calendar_events SET deleted_at = NOW(), version = version + 1, updated_at = NOW()
+			WHERE calendar_id = $1 AND deleted_at IS NULL
+		`, id)
+		if err != nil {
+			return err
+		}
+		if err := recordCalendar(ctx, tx, ActionDelete, &before, deleted); err != nil {
+			return err
+		}
+		return recordEvents(ctx, tx, pgEventColumns, ActionDelete, events)
+	})
+}
+
+func (p *Postgres) ListEvents(ctx context.Context, calendarIDs []string, page Page) ([]models.CalendarEvent, error) {
+	ids := []string{}
+	for _, id := range calendarIDs {
+		if validID(id) {
+			ids = append(ids, id)
+		}
+	}
+	args := []interface{}{pq.Array(ids)}
+	where, order := page.sql("e.id", pgArg(&args))
+	events, err := queryEvents(ctx, p.db, "SELECT "+pgEventColumns+" FROM calendar_events e WHERE e.calendar_id = ANY($1::int[]) AND e.deleted_at IS NULL AND "+where+order,
+		args...)
+	return inOrder(page, events), err
+}
+
+func (p *Postgres) ListUserEvents(ctx context.Context, userID string, page Page) ([]models.CalendarEvent, error) {
+	if !validID(userID) {
+		return []models.CalendarEvent{}, nil
+	}
+	args := []interface{}{userID}
+	where, order := page.sql("e.id", pgArg(&args))
+	events, err := queryEvents(ctx, p.db, "SELECT "+pgEventColumns+" FROM calendar_events e WHERE e.user_id = $1 AND e.deleted_at IS NULL AND "+where+order, args...)
+	return inOrder(page, events), err
+}
+
+func (p *Postgres) SearchEvents(ctx context.Context, search EventSearch) (models.EventSearchResults, error) {
+	results := models.EventSearchResults{Results: []models.EventSearchHit{}, Limit: search.limit(), Offset: search.Offset}
+	calendarIDs := []string{}
+	for _, id := range search.CalendarIDs {
+		if validID(id) {
+			calendarIDs = append(calendarIDs, id)
+		}
+	}
+	if len(search.CalendarIDs) > 0 && len(calendarIDs) == 0 {
+		return results, nil
+	}
+
+	query, err := ParseQuery(search.Query)
+	if err != nil {
+		return results, err
+	}
+	args := []interface{}{search.IncludeHidden, pq.Array(calendarIDs), search.From, search.To, search.Attendee, search.Organizer}
+	arg := pgArg(&args)
+	// The query compiles to a condition whose every value is a parameter.
+	// q.query, its terms that aren't negated, ranks and highlights.
+	matching := `
+		FROM calendar_events e
+		JOIN calendars c ON c.id = e.calendar_id
+		CROSS JOIN (SELECT ` + query.postgresRank(arg) + `) AS q(query)
+		WHERE e.deleted_at IS NULL AND (c.visible OR $1)
+		  AND (cardinality($2::int[]) = 0 OR e.calendar_id = ANY($2::int[]))
+		  AND ($3 = '' OR e.date >= $3) AND ($4 = '' OR e.date <= $4)
+		  AND ($5 = '' OR EXISTS (SELECT 1 FROM event_attendees a WHERE a.event_id = e.id AND LOWER(a.attendee) = LOWER($5)))
+		  AND ($6 = '' OR LOWER(e.organizer) = LOWER($6))
+		  AND ` + query.postgres(arg)
+
+	if err := p.db.QueryRowContext(ctx, "SELECT COUNT(*)"+matching, args...).Scan(&results.Total); err != nil {
+		return results, err
+	}
+	whole := arg(`StartSel="` + markStart + `", StopSel="` + markStop + `", HighlightAll=true`)
+	fragments := arg(`StartSel="` + markStart + `", StopSel="` + markStop + `", MaxFragments=2, MinWords=8, MaxWords=20, FragmentDelimiter=" … "`)
+	limit, offset := arg(results.Limit), arg(search.Offset)
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT `+pgEventColumns+`,
+		       COALESCE(ts_rank(e.search_vector, q.query), 0) AS rank,
+		       CASE WHEN to_tsvector('event_search', e.title) @@ q.query THEN ts_headline('event_search', e.title, q.query, `+whole+`) END,
+		       CASE WHEN to_tsvector('event_search', e.location) @@ q.query THEN ts_headline('event_search', e.location, q.query, `+whole+`) END,
+		       CASE WHEN to_tsvector('event_search', e.description) @@ q.query THEN ts_headline('event_search', e.description, q.query, `+fragments+`) END
+		`+matching+`
+		ORDER BY rank DESC, e.date NULLS LAST, e.start_time, e.id
+		LIMIT `+limit+` OFFSET `+offset, args...)
+	if err != nil {
+		return results, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		hit := models.EventSearchHit{Highlights: map[string]string{}}
+		var title, location, description sql.NullString
+		hit.CalendarEvent, err = scanEvent(extraColumns{rows, []interface{}{&hit.Rank, &title, &location, &description}})
+		if err != nil {
+			return results, err
+		}
+		for name, text := range map[string]sql.NullString{"title": title, "location": location, "description": description} {
+			if text.Valid {
+				hit.Highlights[name] = highlight(text.String)
+			}
+		}
+		results.Results = append(results.Results, hit)
+	}
+	return results, rows.Err()
+}
+
+func (p *Postgres) GetEvent(ctx context.Context, id string) (models.CalendarEvent, error) {
+	if !validID(id) {
+		return models.CalendarEvent{}, ErrNotFound
+	}
+	event, err := scanEvent(p.db.QueryRowContext(ctx, "SELECT "+pgEventColumns+" FROM calendar_events e WHERE e.id = $1 AND e.deleted_at IS NULL", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return event, ErrNotFound
+	}
+	return event, err
+}
+
+func (p *Postgres) CreateEvent(ctx context.Context, event models.NCalendarEvent) (models.CalendarEvent, error) {
+	if !validID(event.CalendarID) {
+		return models.CalendarEvent{}, ErrUnknownCalendar
+	}
+	var created models.CalendarEvent
+	err := inTx(ctx, p.db, func(tx *sql.Tx) error {
+		var id string
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO calendar_events (title, start_time, end_time, color, day, description, location, organizer, calendar_id, date)
+			SELECT $1, $2, $3, $4, $5, $6, $7, $8, id, $10 FROM calendars WHERE id = $9 AND deleted_at IS NULL
+			RETURNING id
+		`, event.Title, event.StartTime, event.EndTime, event.Color, event.Day, event.Description, event.Location,
+			event.Organizer, event.CalendarID, event.Date).Scan(&id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrUnknownCalendar
+		}
+		if err != nil {
+			return err
+		}
+		if err := attendees.Set(ctx, tx, id, event.Attendees); err != nil {
+			return err
+		}
+		if created, err = eventRow(ctx, tx, pgEventColumns, id); err != nil {
+			return err
+		}
+		return recordEvent(ctx, tx, ActionCreate, nil, created)
+	})
+	return created, err
+}
+
+func (p *Postgres) UpdateEvent(ctx context.Context, event models.CalendarEvent) (models.CalendarEvent, error) {
+	if !validID(event.ID) {
+		return models.CalendarEvent{}, ErrNotFound
+	}
+	if !validID(event.CalendarID) {
+		return models.CalendarEvent{}, ErrUnknownCalendar
+	}
+	var updated models.CalendarEvent
+	err := inTx(ctx, p.db, func(tx *sql.Tx) error {
+		before, err := liveEventRow(ctx, tx, pgEventColumns, event.ID, event.Version)
+		if err != nil {
+			return err
+		}
+		if err := liveCalendar(ctx, tx, event.CalendarID); err != nil {
+			return err
+		}
+		res, err := tx.ExecContext(ctx, `
+			UPDATE calendar_events
+			SET title = $1, start_time = $2, end_time = $3, color = $4, day = $5, description = $6, location = $7, organizer = $8, calendar_id = $9, date = $10,
+			    version = version + 1, updated_at = NOW()
+			WHERE id = $11 AND version = $12
+		`, event.Title, event.StartTime, event.EndTime, event.Color, event.Day, event.Description, event.Location,
+			event.Organizer, event.CalendarID, event.Date, event.ID, before.Version)
+		if err := raced(res, err); err != nil {
+			return foreignKey(err)
+		}
+		if err := attendees.Set(ctx, tx, event.ID, event.Attendees); err != nil {
+			return err
+		}
+		if updated, err = eventRow(ctx, tx, pgEventColumns, event.ID); err != nil {
+			return err
+		}
+		return recordEvent(ctx, tx, ActionUpdate, &before, updated)
+	})
+	return updated, err
+}
+
+func (p *Postgres) DeleteEvent(ctx context.Context, id string, version int) error {
+	if !validID(id) {
+		return ErrNotFound
+	}
+	return inTx(ctx, p.db, func(tx *sql.Tx) error {
+		before, err := liveEventRow(ctx, tx, pgEventColumns, id, version)
+		if err != nil {
+			return err
+		}
+		res, err := tx.ExecContext(ctx, `
+			UPDATE calendar_events SET deleted_at = NOW(), version = version + 1, updated_at = NOW()
+			WHERE id = $1 AND version = $2
+		`, id, before.Version)
+		if err := raced(res, err); err != nil {
+			return err
+		}
+		deleted, err := eventRow(ctx, tx, pgEventColumns, id)
+		if err != nil {
+			return err
+		}
+		return recordEvent(ctx, tx, ActionDelete, &before, deleted)
+	})
+}
+
+func (p *Postgres) ListTrash(ctx context.Context) (models.Trash, error) {
+	var trash models.Trash
+	var err error
+	trash.Calendars, err = queryCalendars(ctx, p.db,
+		"SELECT "+calendarColumns+" FROM calendars WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC, id")
+	if err != nil {
+		return trash, err
+	}
+	trash.Events, err = queryEvents(ctx, p.db,
+		"SELECT "+pgEventColumns+" FROM calendar_events e WHERE e.deleted_at IS NOT NULL ORDER BY e.deleted_at DESC, e.id")
+	return trash, err
+}
+
+func (p *Postgres) RestoreCalendar(ctx context.Context, id string) (models.Calendar, error) {
+	if !validID(id) {
+		return models.Calendar{}, ErrNotFound
+	}
+	return restoreCalendar(ctx, p.db, pgEventColumns, id, "NOW()")
+}
+
+func (p *Postgres) RestoreEvent(ctx context.Context, id string) (models.CalendarEvent, error) {
+	if !validID(id) {
+		return models.CalendarEvent{}, ErrNotFound
+	}
+	return restoreEvent(ctx, p.db, pgEventColumns, id, "NOW()")
+}
+
+func (p *Postgres) History(ctx context.Context, entity, id string) ([]models.HistoryEntry, error) {
+	if !validID(id) {
+		return []models.HistoryEntry{}, nil
+	}
+	return queryHistory(ctx, p.db, entity, id)
+}
+
+func (p *Postgres) PurgeTrash(ctx context.Context, cutoff time.Time) (int64, error) {
+	return purgeTrash(ctx, p.db, cutoff)
+}
+
+// restoreCalendar takes a calendar and the events deleted with it out of
+// the trash.
+func restoreCalendar(ctx context.Context, db handle, columns, id, now string) (models.Calendar, error) {
+	var restored models.Calendar
+	err := inTx(ctx, db, func(tx *sql.Tx) error {
+		before, err := calendarRow(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if before.DeletedAt == nil {
+			return ErrNotFound
+		}
+		// The events first, while the calendar still has its deleted_at.
+		const deletedWithCalendar = "calendar_id = $1 AND deleted_at = (SELECT deleted_at FROM calendars WHERE id = $1)"
+		events, err := queryEvents(ctx, tx, "SELECT "+columns+" FROM calendar_events e WHERE e."+deletedWithCalendar+" ORDER BY e.id", id)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `
+			UPDATE calendar_events SET deleted_at = NULL, version = version + 1, updated_at = `+now+`
+			WHERE `+deletedWithCalendar, id)
+		if err != nil {
+			return err
+		}
+		res, err := tx.ExecContext(ctx, `
+			UPDATE calendars SET deleted_at = NULL, version = version + 1, updated_at = `+now+`
+			WHERE id = $1 AND deleted_at IS NOT NULL
+		`, id)
+		if err := affected(res, err); err != nil {
+			return err
+		}
+		if restored, err = calendarRow(ctx, tx, id); err != nil {
+			return err
+		}
+		if err := recordCalendar(ctx, tx, ActionRestore, &before, restored); err != nil {
+			return err
+		}
+		return recordEvents(ctx, tx, columns, ActionRestore, events)
+	})
+	return restored, err
+}
+
+// restoreEvent takes an event out of the trash unless its calendar is in
+// there too.
+func restoreEvent(ctx context.Context, db handle, columns, id, now string) (models.CalendarEvent, error) {
+	var restored models.CalendarEvent
+	err := inTx(ctx, db, func(tx *sql.Tx) error {
+		before, err := eventRow(ctx, tx, columns, id)
+		if err != nil {
+			return err
+		}
+		if before.DeletedAt == nil {
+			return ErrNotFound
+		}
+		cal, err := calendarRow(ctx, tx, before.CalendarID)
+		if err != nil {
+			return err
+		}
+		if cal.DeletedAt != nil {
+			return ErrCalendarDeleted
+		}
+		res, err := tx.ExecContext(ctx, `
+			UPDATE calendar_events SET deleted_at = NULL, version = version + 1, updated_at = `+now+`
+			WHERE id = $1 AND deleted_at IS NOT NULL
+		`, id)
+		if err := affected(res, err); err != nil {
+			return err
+		}
+		if restored, err = eventRow(ctx, tx, columns, id); err != nil {
+			return err
+		}
+		return recordEvent(ctx, tx, ActionRestore, &before, restored)
+	})
+	return restored, err
+}
+
+// purgeTrash deletes the events and then the calendars that went into the
+// trash before cutoff, with the reminder settings the events kept there.
+func purgeTrash(ctx context.Context, db handle, cutoff time.Time) (int64, error) {
+	var total int64
+	err := inTx(ctx, db, func(tx *sql.Tx) error {
+		total = 0
+		for _, table := range []string{"event_reminders", "event_reminder_settings"} {
+			_, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE event_id IN (SELECT id FROM calendar_events WHERE deleted_at < $1)", cutoff)
+			if err != nil {
+				return err
+			}
+		}
+		for _, table := range []string{"calendar_events", "calendars"} {
+			// Resource calendars can't be deleted any more, but ones that
+			// were stay in the trash: the resource still refers to them.
+			query := "DELETE FROM " + table + " WHERE deleted_at < $1"
+			if table == "calendars" {
+				query += " AND id NOT IN (SELECT calendar_id FROM resources)"
+			}
+			res, err := tx.ExecContext(ctx, query, cutoff)
+			if err != nil {
+				return err
+			}
+			n, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			total += n
+		}
+		return nil
+	})
+	return total, err
+}
@@ -0,0 +1,48 @@
+package store
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Purger permanently deletes calendars and events that have been in the
+// trash for longer than Retention. Running several against one database is
+// harmless.
+type Purger struct {
+	Store     Store
+	Retention time.Duration
+	Interval  time.Duration
+}
+
+// Run purges the trash until ctx is cancelled.
+func (p *Purger) Run(ctx context.Context) {
+	interval := p.Interval
+	if interval == 0 {
+		interval = time.Hour
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := p.Tick(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			log.Printf("trash: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Tick purges what went into the trash more than Retention before now.
+func (p *Purger) Tick(ctx context.Context, now time.Time) error {
+	n, err := p.Store.PurgeTrash(ctx, now.Add(-p.Retention))
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		log.Printf("trash: purged %d calendars and events", n)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Aman221/4723/internal/models"
+)
+
+func TestPurger(t *testing.T) {
+	sqlite, _ := newSQLite(t)
+	for name, s := range map[string]Store{"memory": NewMemory(), "sqlite": sqlite} {
+		ctx := context.Background()
+		cal, err := s.CreateCalendar(ctx, models.NCalendar{Name: "Work"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var events []models.CalendarEvent
+		for _, title := range []string{"Standup", "Retro"} {
+			ev, err := s.CreateEvent(ctx, models.NCalendarEvent{Title: title, StartTime: "09:00", EndTime: "10:00", Day: 1, CalendarID: cal.ID})
+			if err != nil {
+				t.Fatal(err)
+			}
+			events = append(events, ev)
+		}
+		if err := s.DeleteEvent(ctx, events[0].ID, 0); err != nil {
+			t.Fatal(err)
+		}
+
+		p := &Purger{Store: s, Retention: time.Hour}
+		if err := p.Tick(ctx, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+		if trash, _ := s.ListTrash(ctx); len(trash.Events) != 1 {
+			t.Errorf("%s: purged %+v before its time", name, events[0])
+		}
+
+		if err := p.Tick(ctx, time.Now().Add(2*time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+		if trash, _ := s.ListTrash(ctx); len(trash.Events) != 0 {
+			t.Errorf("%s: trash still holds %+v", name, trash)
+		}
+		if _, err := s.RestoreEvent(ctx, events[0].ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: restoring a purged event: %v", name, err)
+		}
+		if _, err := s.GetEvent(ctx, events[1].ID); err != nil {
+			t.Errorf("%s: the event left alone: %v", name, err)
+		}
+		if history, err := s.History(ctx, EntityEvent, events[0].ID); err != nil || len(history) != 2 {
+			t.Errorf("%s: history of the purged event is %d entries, %v", name, len(history), err)
+		}
+	}
+}
@@ -0,0 +1,547 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Aman221/4723/internal/models"
+)
+
+// A search query is a list of terms, all of which must match:
+//
+//	alice                  a word, matching the words it is a prefix of
+//	"room 4"               a phrase, matching those words in that order
+//	location:"room 4"      a word or phrase in one field: title, location,
+//	                       description, organizer or attendee
+//	after:2026-01-01       the date is after, before or on a day; date:>=,
+//	                       date:<=, date:>, date:< and date: compare too
+//	-cancelled             anything but the term
+//	lunch OR dinner        either term; AND binds tighter than OR
+//	(lunch OR dinner) -x   parentheses group terms
+//
+// Matching ignores case and accents. Weekly events have no date, so a date
+// comparison never matches them.
+
+// textFields are the fields a word or phrase can be restricted to, and
+// dateFields the ones that compare dates, with the comparison each makes.
+var (
+	textFields = []string{"title", "location", "description", "organizer", "attendee"}
+	dateFields = map[string]string{"after": ">", "before": "<", "on": "=", "date": "="}
+)
+
+// QueryError describes a search query that doesn't parse.
+type QueryError struct {
+	Problem string
+	// Token is the offending part of the query and Column where it starts,
+	// counting characters from 1.
+	Token  string
+	Column int
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("%q at column %d: %s", e.Token, e.Column, e.Problem)
+}
+
+// Query is a parsed search query.
+type Query struct {
+	root queryNode
+	// text are the terms that aren't negated, which rank and highlight
+	// the matches.
+	text []textTerm
+}
+
+// ParseQuery parses a search query, returning a *QueryError if it is
+// malformed. An empty query matches everything.
+func ParseQuery(query string) (Query, error) {
+	tokens, err := lex(query)
+	if err != nil {
+		return Query{}, err
+	}
+	p := &queryParser{tokens: tokens}
+	var q Query
+	if len(tokens) > 0 {
+		if q.root, err = p.or(); err != nil {
+			return Query{}, err
+		}
+		if t := p.peek(); t != nil {
+			return Query{}, t.errorf("unexpected term")
+		}
+	}
+	q.text = positiveTerms(q.root, nil)
+	return q, nil
+}
+
+// matches reports whether event matches the query.
+func (q Query) matches(event models.CalendarEvent) bool {
+	return q.root == nil || q.root.matches(event)
+}
+
+// postgres compiles the query into a condition on calendar_events e,
+// adding its parameters with arg, which returns the placeholder for one.
+func (q Query) postgres(arg func(interface{}) string) string {
+	if q.root == nil {
+		return "TRUE"
+	}
+	return q.root.postgres(arg)
+}
+
+// postgresRank returns a tsquery that ORs together the terms that aren't
+// negated, to rank and highlight with, or NULL if there are none.
+func (q Query) postgresRank(arg func(interface{}) string) string {
+	if len(q.text) == 0 {
+		return "NULL::tsquery"
+	}
+	parts := make([]string, len(q.text))
+	for i, t := range q.text {
+		parts[i] = t.tsquery(arg)
+	}
+	return "(" + strings.Join(parts, " || ") + ")"
+}
+
+// words returns the folded words of the terms that aren't negated.
+func (q Query) words() []string {
+	var all []string
+	for _, t := range q.text {
+		all = append(all, t.words...)
+	}
+	return all
+}
+
+// Tokens
+
+type tokenKind int
+
+const (
+	tokenWord tokenKind = iota
+	tokenPhrase
+	tokenOpen
+	tokenClose
+	tokenMinus
+)
+
+type token struct {
+	kind tokenKind
+	// text is the token as written, value a phrase without its quotes.
+	text, value string
+	// column counts characters from 1, offset bytes from 0.
+	column, offset int
+}
+
+func (t *token) errorf(format string, args ...interface{}) error {
+	return &QueryError{Problem: fmt.Sprintf(format, args...), Token: t.text, Column: t.column}
+}
+
+// end is the byte offset just past the token.
+func (t *token) end() int { return t.offset + len(t.text) }
+
+func isOperator(r rune) bool { return r == '(' || r == ')' || r == '"' }
+
+func startsSpace(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return unicode.IsSpace(r)
+}
+
+// lex splits a query into words, quoted phrases, parentheses and the minus
+// signs that negate what directly follows them.
+func lex(query string) ([]*token, error) {
+	var tokens []*token
+	column := 0
+	for i := 0; i < len(query); {
+		r, size := utf8.DecodeRuneInString(query[i:])
+		column++
+		t := &token{offset: i, column: column}
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+			continue
+		case r == '(' || r == ')':
+			t.kind, t.text = tokenOpen, string(r)
+			if r == ')' {
+				t.kind = tokenClose
+			}
+		case r == '"':
+			stop := strings.IndexByte(query[i+1:], '"')
+			if stop < 0 {
+				t.text = query[i:]
+				return nil, t.errorf("unterminated quote")
+			}
+			t.kind, t.text, t.value = tokenPhrase, query[i:i+stop+2], query[i+1:i+stop+1]
+		case r == '-' && i+size < len(query) && !startsSpace(query[i+size:]):
+			t.kind, t.text = tokenMinus, "-"
+		default:
+			stop := strings.IndexFunc(query[i:], func(r rune) bool { return unicode.IsSpace(r) || isOperator(r) })
+			if stop < 0 {
+				stop = len(query) - i
+			}
+			t.kind, t.text = tokenWord, query[i:i+stop]
+		}
+		tokens = append(tokens, t)
+		i += len(t.text)
+		column += utf8.RuneCountInString(t.text) - 1
+	}
+	return tokens, nil
+}
+
+// Parser
+
+type queryParser struct {
+	tokens []*token
+	next   int
+	// depth is how many parentheses are open.
+	depth int
+}
+
+func (p *queryParser) peek() *token {
+	if p.next < len(p.tokens) {
+		return p.tokens[p.next]
+	}
+	return nil
+}
+
+func (p *queryParser) take() *token {
+	t := p.peek()
+	p.next++
+	return t
+}
+
+// isWord reports whether t is the bare word w.
+func isWord(t *token, w string) bool {
+	return t != nil && t.kind == tokenWord && t.text == w
+}
+
+// or parses terms separated by OR.
+func (p *queryParser) or() (queryNode, error) {
+	var nodes orNode
+	for {
+		node, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		if node != nil {
+			nodes = append(nodes, node)
+		}
+		if !isWord(p.peek(), "OR") {
+			break
+		}
+		or := p.take()
+		if node == nil || !p.startsTerm() {
+			return nil, or.errorf("OR needs a term on both sides")
+		}
+	}
+	if len(nodes) <= 1 {
+		return first(nodes), nil
+	}
+	return nodes, nil
+}
+
+// and parses terms that follow each other, optionally joined by AND.
+func (p *queryParser) and() (queryNode, error) {
+	var nodes andNode
+	for {
+		t := p.peek()
+		if t == nil || isWord(t, "OR") || t.kind == tokenClose && p.depth > 0 {
+			break
+		}
+		if isWord(t, "AND") {
+			p.take()
+			if len(nodes) == 0 || !p.startsTerm() {
+				return nil, t.errorf("AND needs a term on both sides")
+			}
+			continue
+		}
+		node, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		if node != nil {
+			nodes = append(nodes, node)
+		}
+	}
+	if len(nodes) <= 1 {
+		return first(nodes), nil
+	}
+	return nodes, nil
+}
+
+// startsTerm reports whether a term follows.
+func (p *queryParser) startsTerm() bool {
+	t := p.peek()
+	return t != nil && t.kind != tokenClose && !isWord(t, "OR") && !isWord(t, "AND")
+}
+
+// first returns the only node of nodes, or nil if there are none.
+func first(nodes []queryNode) queryNode {
+	if len(nodes) == 0 {
+		return nil
+	}
+	return nodes[0]
+}
+
+// unary parses a term, a negated term or a group. Words without letters
+// or digits are skipped and return nil.
+func (p *queryParser) unary() (queryNode, error) {
+	t := p.take()
+	switch t.kind {
+	case tokenMinus:
+		next := p.peek()
+		if next == nil || next.offset != t.end() || next.kind == tokenClose {
+			return nil, t.errorf("nothing to negate")
+		}
+		node, err := p.unary()
+		if err != nil || node == nil {
+			return nil, t.errorf("nothing to negate")
+		}
+		return notNode{node}, nil
+	case tokenOpen:
+		p.depth++
+		if next := p.peek(); next != nil && next.kind == tokenClose {
+			return nil, t.errorf("empty parentheses")
+		}
+		node, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if close := p.take(); close == nil || close.kind != tokenClose {
+			return nil, t.errorf("unclosed parenthesis")
+		}
+		p.depth--
+		return node, nil
+	case tokenClose:
+		return nil, t.errorf("unmatched closing parenthesis")
+	case tokenPhrase:
+		return newTextTerm("", t.value, true), nil
+	}
+	return p.field(t)
+}
+
+// field parses a word, which may be a field and its value. A quoted value
+// directly follows the colon.
+func (p *queryParser) field(t *token) (queryNode, error) {
+	name, value, found := strings.Cut(t.text, ":")
+	if !found || name == "" || strings.IndexFunc(name, func(r rune) bool { return !unicode.IsLetter(r) }) >= 0 {
+		// Not a field, e.g. 10:30.
+		if term := newTextTerm("", t.text, false); len(term.words) > 0 {
+			return term, nil
+		}
+		return nil, nil
+	}
+	name = strings.ToLower(name)
+	_, isDate := dateFields[name]
+	if !isDate && !contains(textFields, name) {
+		return nil, t.errorf("unknown field %q; the fields are title, location, description, organizer, attendee, date, after, before and on", name)
+	}
+	phrase := false
+	if next := p.peek(); value == "" && next != nil && next.kind == tokenPhrase && next.offset == t.end() {
+		p.take()
+		t = &token{text: t.text + next.text, column: t.column}
+		value, phrase = next.value, true
+	}
+	if isDate {
+		return parseDate(t, name, value)
+	}
+	term := newTextTerm(name, value, phrase)
+	if len(term.words) == 0 {
+		return nil, t.errorf("%s: needs a word or phrase to match", name)
+	}
+	return term, nil
+}
+
+// parseDate parses the value of a date field, such as 2026-01-01 for
+// after: or >=2026-01-01 for date:.
+func parseDate(t *token, name, value string) (queryNode, error) {
+	op := dateFields[name]
+	if name == "date" {
+		for _, prefix := range []string{">=", "<=", ">", "<", "="} {
+			if strings.HasPrefix(value, prefix) {
+				op, value = prefix, strings.TrimPrefix(value, prefix)
+				break
+			}
+		}
+	}
+	if _, err := time.Parse("2006-01-02", value); err != nil {
+		return nil, t.errorf("%s: needs a date as YYYY-MM-DD", name)
+	}
+	return dateNode{op, value}, nil
+}
+
+// positiveTerms collects the text terms of node that aren't negated.
+func positiveTerms(node queryNode, terms []textTerm) []textTerm {
+	switch n := node.(type) {
+	case textTerm:
+		terms = append(terms, n)
+	case andNode:
+		for _, child := range n {
+			terms = positiveTerms(child, terms)
+		}
+	case orNode:
+		for _, child := range n {
+			terms = positiveTerms(child, terms)
+		}
+	}
+	return terms
+}
+
+// Query nodes match events in Go, for SQLite and the in-memory store, and
+// compile to SQL for Postgres, whose full-text search matches words the
+// same way, with English stemming on top.
+
+type queryNode interface {
+	matches(event models.CalendarEvent) bool
+	postgres(arg func(interface{}) string) string
+}
+
+// textTerm is a word or phrase, in one field or any of them.
+type textTerm struct {
+	field string
+	// text is the term as written and words its folded words. A single
+	// unquoted word matches the words it is a prefix of; anything else
+	// must match whole words in order.
+	text   string
+	words  []string
+	prefix bool
+}
+
+func newTextTerm(field, text string, phrase bool) textTerm {
+	terms := queryTerms(text)
+	return textTerm{field: field, text: text, words: terms, prefix: !phrase && len(terms) == 1}
+}
+
+// texts returns the texts of event the term is matched against.
+func (t textTerm) texts(event models.CalendarEvent) []string {
+	switch t.field {
+	case "title":
+		return []string{event.Title}
+	case "location":
+		return []string{event.Location}
+	case "description":
+		return []string{event.Description}
+	case "organizer":
+		return []string{event.Organizer}
+	case "attendee":
+		return event.Attendees
+	}
+	return append([]string{event.Title, event.Location, event.Description, event.Organizer}, event.Attendees...)
+}
+
+// in reports whether the term occurs in text.
+func (t textTerm) in(text string) bool {
+	spans := words(text)
+	for i := 0; i+len(t.words) <= len(spans); i++ {
+		found := true
+		for j, w := range t.words {
+			word := fold(text[spans[i+j].start:spans[i+j].end])
+			if t.prefix && !strings.HasPrefix(word, w) || !t.prefix && word != w {
+				found = false
+				break
+			}
+		}
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+func (t textTerm) matches(event models.CalendarEvent) bool {
+	for _, text := range t.texts(event) {
+		if t.in(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// tsquery returns the term as a tsquery. A single word becomes a prefix
+// query, whose only characters are the word's letters and digits, and
+// anything else goes through phraseto_tsquery, which takes plain text.
+func (t textTerm) tsquery(arg func(interface{}) string) string {
+	if t.prefix {
+		return "to_tsquery('event_search', " + arg("'"+t.words[0]+"':*") + ")"
+	}
+	return "phraseto_tsquery('event_search', " + arg(t.text) + ")"
+}
+
+func (t textTerm) postgres(arg func(interface{}) string) string {
+	query := t.tsquery(arg)
+	switch t.field {
+	case "":
+		return "e.search_vector @@ " + query
+	case "attendee":
+		return "EXISTS (SELECT 1 FROM event_attendees a WHERE a.event_id = e.id AND to_tsvector('event_search', a.attendee) @@ " + query + ")"
+	}
+	return "to_tsvector('event_search', COALESCE(e." + t.field + ", '')) @@ " + query
+}
+
+// dateNode compares the date of an event with a day.
+type dateNode struct{ op, date string }
+
+func (d dateNode) matches(event models.CalendarEvent) bool {
+	if event.Date == nil {
+		return false
+	}
+	switch d.op {
+	case ">":
+		return *event.Date > d.date
+	case ">=":
+		return *event.Date >= d.date
+	case "<":
+		return *event.Date < d.date
+	case "<=":
+		return *event.Date <= d.date
+	}
+	return *event.Date == d.date
+}
+
+func (d dateNode) postgres(arg func(interface{}) string) string {
+	// op is one of the fixed operators above, never text from the query.
+	return "(e.date IS NOT NULL AND e.date " + d.op + " " + arg(d.date) + ")"
+}
+
+type notNode struct{ node queryNode }
+
+func (n notNode) matches(event models.CalendarEvent) bool { return !n.node.matches(event) }
+
+func (n notNode) postgres(arg func(interface{}) string) string {
+	return "NOT (" + n.node.postgres(arg) + ")"
+}
+
+type andNode []queryNode
+
+func (n andNode) matches(event models.CalendarEvent) bool {
+	for _, child := range n {
+		if !child.matches(event) {
+			return false
+		}
+	}
+	return true
+}
+
+func (n andNode) postgres(arg func(interface{}) string) string {
+	return joinNodes(n, " AND ", arg)
+}
+
+type orNode []queryNode
+
+func (n orNode) matches(event models.CalendarEvent) bool {
+	for _, child := range n {
+		if child.matches(event) {
+			return true
+		}
+	}
+	return false
+}
+
+func (n orNode) postgres(arg func(interface{}) string) string {
+	return joinNodes(n, " OR ", arg)
+}
+
+func joinNodes(nodes []queryNode, op string, arg func(interface{}) string) string {
+	parts := make([]string, len(nodes))
+	for i, node := range nodes {
+		parts[i] = node.postgres(arg)
+	}
+	return "(" + strings.Join(parts, op) + ")"
+}
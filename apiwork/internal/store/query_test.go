@@ -0,0 +1,215 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Aman221/4723/internal/models"
+)
+
+// describe writes a parsed query as a string, to compare parses with.
+func describe(node queryNode) string {
+	switch n := node.(type) {
+	case nil:
+		return ""
+	case textTerm:
+		text := strings.Join(n.words, " ")
+		if !n.prefix {
+			text = `"` + text + `"`
+		}
+		if n.field != "" {
+			text = n.field + ":" + text
+		}
+		return text
+	case dateNode:
+		return "date" + n.op + n.date
+	case notNode:
+		return "-" + describe(n.node)
+	case andNode:
+		return describeAll("AND", n)
+	case orNode:
+		return describeAll("OR", n)
+	}
+	return fmt.Sprintf("%T", node)
+}
+
+func describeAll(op string, nodes []queryNode) string {
+	parts := []string{op}
+	for _, node := range nodes {
+		parts = append(parts, describe(node))
+	}
+	return "(" + strings.Join(parts, " ") + ")"
+}
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"", ""},
+		{"   ", ""},
+		{"alice", "alice"},
+		{"Élodie", "elodie"},
+		{`"room 4"`, `"room 4"`},
+		{`"lunch"`, `"lunch"`},
+		{"alice bob", "(AND alice bob)"},
+		{"alice AND bob", "(AND alice bob)"},
+		{"lunch OR dinner", "(OR lunch dinner)"},
+		{"a b OR c", "(OR (AND a b) c)"},
+		{"a OR b c", "(OR a (AND b c))"},
+		{"(lunch OR dinner) -cancelled", "(AND (OR lunch dinner) -cancelled)"},
+		{"-(a OR b)", "-(OR a b)"},
+		{"--a", "--a"},
+		{"a - b", "(AND a b)"},
+		{"lunch -", "lunch"},
+		{"title:standup", "title:standup"},
+		{"LOCATION:Kitchen", "location:kitchen"},
+		{`location:"room 4"`, `location:"room 4"`},
+		{"attendee:alice@example.com", `attendee:"alice example com"`},
+		{"after:2026-01-01", "date>2026-01-01"},
+		{"before:2026-01-01", "date<2026-01-01"},
+		{"on:2026-01-01", "date=2026-01-01"},
+		{"date:2026-01-01", "date=2026-01-01"},
+		{"date:>=2026-01-01", "date>=2026-01-01"},
+		{"date:<2026-01-01", "date<2026-01-01"},
+		{`on:"2026-01-01"`, "date=2026-01-01"},
+		{"10:30", `"10 30"`},
+		{"... alice", "alice"},
+	}
+	for _, tt := range tests {
+		q, err := ParseQuery(tt.query)
+		if err != nil {
+			t.Errorf("ParseQuery(%q): %v", tt.query, err)
+			continue
+		}
+		if got := describe(q.root); got != tt.want {
+			t.Errorf("ParseQuery(%q) = %s, want %s", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	// The messages are what a search answers with in its 400, after "q: ".
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{`"room 4`, `"\"room 4" at column 1: unterminated quote`},
+		{`alice "room`, `"\"room" at column 7: unterminated quote`},
+		{"OR dinner", `"OR" at column 1: OR needs a term on both sides`},
+		{"lunch OR", `"OR" at column 7: OR needs a term on both sides`},
+		{"lunch OR OR dinner", `"OR" at column 7: OR needs a term on both sides`},
+		{"AND lunch", `"AND" at column 1: AND needs a term on both sides`},
+		{"lunch AND", `"AND" at column 7: AND needs a term on both sides`},
+		{"lunch -)", `"-" at column 7: nothing to negate`},
+		{"-)", `"-" at column 1: nothing to negate`},
+		{"-- ", `"-" at column 1: nothing to negate`},
+		{"()", `"(" at column 1: empty parentheses`},
+		{"(lunch", `"(" at column 1: unclosed parenthesis`},
+		{"lunch)", `")" at column 6: unmatched closing parenthesis`},
+		{"(a) b)", `")" at column 6: unmatched closing parenthesis`},
+		{"room:4", `"room:4" at column 1: unknown field "room"; the fields are title, location, description, organizer, attendee, date, after, before and on`},
+		{"title:", `"title:" at column 1: title: needs a word or phrase to match`},
+		{"title:...", `"title:..." at column 1: title: needs a word or phrase to match`},
+		{"after:tomorrow", `"after:tomorrow" at column 1: after: needs a date as YYYY-MM-DD`},
+		{`on:"Jan 1"`, `"on:\"Jan 1\"" at column 1: on: needs a date as YYYY-MM-DD`},
+		{"date:>=2026-13-01", `"date:>=2026-13-01" at column 1: date: needs a date as YYYY-MM-DD`},
+		{"café )", `")" at column 6: unmatched closing parenthesis`},
+	}
+	for _, tt := range tests {
+		_, err := ParseQuery(tt.query)
+		var qerr *QueryError
+		if !errors.As(err, &qerr) {
+			t.Errorf("ParseQuery(%q) = %v, want a *QueryError", tt.query, err)
+			continue
+		}
+		if got := err.Error(); got != tt.want {
+			t.Errorf("ParseQuery(%q) error = %s\nwant %s", tt.query, got, tt.want)
+		}
+	}
+}
+
+func date(d string) *string { return &d }
+
+// matchEvents are what TestQueryMatches searches.
+var matchEvents = []models.CalendarEvent{
+	{ID: "1", Title: "Team standup", Location: "Room 4", Organizer: "alice@example.com", Attendees: []string{"bob@example.com"}, Date: date("2026-01-05")},
+	{ID: "2", Title: "Lunch", Location: "Café Élysée", Description: "Bring the roadmap", Date: date("2026-01-10")},
+	{ID: "3", Title: "Dinner", Location: "room 40", Attendees: []string{"alice@example.com", "carol@example.com"}, Date: date("2026-02-01")},
+	{ID: "4", Title: "Weekly review", Description: "Cancelled until further notice"},
+}
+
+func TestQueryMatches(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"1", "2", "3", "4"}},
+		{"lunch", []string{"2"}},
+		{"LUN", []string{"2"}},
+		{"cafe elysee", []string{"2"}},
+		{"café", []string{"2"}},
+		{"room", []string{"1", "3"}},
+		{`"room 4"`, []string{"1"}},
+		{`location:"room 40"`, []string{"3"}},
+		{"room 4", []string{"1", "3"}},
+		{"title:room", nil},
+		{"description:roadmap", []string{"2"}},
+		{"organizer:alice", []string{"1"}},
+		{"attendee:alice", []string{"3"}},
+		{"alice", []string{"1", "3"}},
+		{"lunch OR dinner", []string{"2", "3"}},
+		{"room -standup", []string{"3"}},
+		{"-(lunch OR dinner)", []string{"1", "4"}},
+		{"(lunch OR dinner) room", []string{"3"}},
+		{"after:2026-01-05", []string{"2", "3"}},
+		{"before:2026-01-10", []string{"1"}},
+		{"on:2026-01-10", []string{"2"}},
+		{"date:>=2026-01-10", []string{"2", "3"}},
+		{"date:<=2026-01-10", []string{"1", "2"}},
+		{"-after:2000-01-01", []string{"4"}},
+		{"cancelled", []string{"4"}},
+		{"10:30", nil},
+	}
+	for _, tt := range tests {
+		q, err := ParseQuery(tt.query)
+		if err != nil {
+			t.Errorf("ParseQuery(%q): %v", tt.query, err)
+			continue
+		}
+		var got []string
+		for _, event := range matchEvents {
+			if q.matches(event) {
+				got = append(got, event.ID)
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q matches %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestSearchInGo(t *testing.T) {
+	q, err := ParseQuery("room OR lunch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := searchInGo(matchEvents, q, EventSearch{Limit: 2})
+	if results.Total != 3 || len(results.Results) != 2 {
+		t.Fatalf("got %d of %d results, want 2 of 3", len(results.Results), results.Total)
+	}
+	// The titles outrank the locations, and equal ranks go by date.
+	var got []string
+	for _, hit := range results.Results {
+		got = append(got, hit.ID)
+	}
+	if want := []string{"2", "1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("results are %v, want %v", got, want)
+	}
+	if want := "<mark>Room</mark> 4"; results.Results[1].Highlights["location"] != want {
+		t.Errorf("location highlight is %q, want %q", results.Results[1].Highlights["location"], want)
+	}
+}
@@ -0,0 +1,213 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/Aman221/4723/internal/models"
+)
+
+// Saved searches are plain rows without versions or history, and their SQL
+// works on both Postgres and SQLite.
+
+const savedSearchColumns = "id, user_id, name, query, color, pinned"
+
+func scanSavedSearch(scanner interface{ Scan(...interface{}) error }) (models.SavedSearch, error) {
+	var s models.SavedSearch
+	err := scanner.Scan(&s.ID, &s.UserID, &s.Name, &s.Query, &s.Color, &s.Pinned)
+	return s, err
+}
+
+func listSavedSearches(ctx context.Context, db querier, userID string) ([]models.SavedSearch, error) {
+	query, args := "SELECT "+savedSearchColumns+" FROM saved_searches ORDER BY id", []interface{}{}
+	if userID != "" {
+		if !validID(userID) {
+			return []models.SavedSearch{}, nil
+		}
+		query, args = "SELECT "+savedSearchColumns+" FROM saved_searches WHERE user_id = $1 ORDER BY id", []interface{}{userID}
+	}
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	searches := []models.SavedSearch{}
+	for rows.Next() {
+		s, err := scanSavedSearch(rows)
+		if err != nil {
+			return nil, err
+		}
+		searches = append(searches, s)
+	}
+	return searches, rows.Err()
+}
+
+func getSavedSearch(ctx context.Context, db querier, id string) (models.SavedSearch, error) {
+	if !validID(id) {
+		return models.SavedSearch{}, ErrNotFound
+	}
+	s, err := scanSavedSearch(db.QueryRowContext(ctx, "SELECT "+savedSearchColumns+" FROM saved_searches WHERE id = $1", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return s, ErrNotFound
+	}
+	return s, err
+}
+
+func createSavedSearch(ctx context.Context, db handle, userID string, search models.NSavedSearch) (models.SavedSearch, error) {
+	if !validID(userID) {
+		return models.SavedSearch{}, ErrNotFound
+	}
+	var created models.SavedSearch
+	err := inTx(ctx, db, func(tx *sql.Tx) error {
+		var exists bool
+		if err := tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)", userID).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return ErrNotFound
+		}
+		var err error
+		created, err = scanSavedSearch(tx.QueryRowContext(ctx, `
+			INSERT INTO saved_searches (user_id, name, query, color, pinned) VALUES ($1, $2, $3, $4, $5)
+			RETURNING `+savedSearchColumns,
+			userID, search.Name, search.Query, search.Color, search.Pinned))
+		return err
+	})
+	return created, err
+}
+
+func updateSavedSearch(ctx context.Context, db querier, search models.SavedSearch) (models.SavedSearch, error) {
+	if !validID(search.ID) {
+		return models.SavedSearch{}, ErrNotFound
+	}
+	saved, err := scanSavedSearch(db.QueryRowContext(ctx, `
+		UPDATE saved_searches SET name = $1, query = $2, color = $3, pinned = $4 WHERE id = $5
+		RETURNING `+savedSearchColumns,
+		search.Name, search.Query, search.Color, search.Pinned, search.ID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return saved, ErrNotFound
+	}
+	return saved, err
+}
+
+func deleteSavedSearch(ctx context.Context, db querier, id string) error {
+	if !validID(id) {
+		return ErrNotFound
+	}
+	result, err := db.ExecContext(ctx, "DELETE FROM saved_searches WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (p *Postgres) ListSavedSearches(ctx context.Context, userID string) ([]models.SavedSearch, error) {
+	return listSavedSearches(ctx, p.db, userID)
+}
+
+func (p *Postgres) GetSavedSearch(ctx context.Context, id string) (models.SavedSearch, error) {
+	return getSavedSearch(ctx, p.db, id)
+}
+
+func (p *Postgres) CreateSavedSearch(ctx context.Context, userID string, search models.NSavedSearch) (models.SavedSearch, error) {
+	return createSavedSearch(ctx, p.db, userID, search)
+}
+
+func (p *Postgres) UpdateSavedSearch(ctx context.Context, search models.SavedSearch) (models.SavedSearch, error) {
+	return updateSavedSearch(ctx, p.db, search)
+}
+
+func (p *Postgres) DeleteSavedSearch(ctx context.Context, id string) error {
+	return deleteSavedSearch(ctx, p.db, id)
+}
+
+func (s *SQLite) ListSavedSearches(ctx context.Context, userID string) ([]models.SavedSearch, error) {
+	return listSavedSearches(ctx, s.db, userID)
+}
+
+func (s *SQLite) GetSavedSearch(ctx context.Context, id string) (models.SavedSearch, error) {
+	return getSavedSearch(ctx, s.db, id)
+}
+
+func (s *SQLite) CreateSavedSearch(ctx context.Context, userID string, search models.NSavedSearch) (models.SavedSearch, error) {
+	return createSavedSearch(ctx, s.db, userID, search)
+}
+
+func (s *SQLite) UpdateSavedSearch(ctx context.Context, search models.SavedSearch) (models.SavedSearch, error) {
+	return updateSavedSearch(ctx, s.db, search)
+}
+
+func (s *SQLite) DeleteSavedSearch(ctx context.Context, id string) error {
+	return deleteSavedSearch(ctx, s.db, id)
+}
+
+func (m *Memory) ListSavedSearches(ctx context.Context, userID string) ([]models.SavedSearch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ids := []string{}
+	for id, s := range m.searches {
+		if userID == "" || s.UserID == userID {
+			ids = append(ids, id)
+		}
+	}
+	byID(ids)
+	searches := make([]models.SavedSearch, 0, len(ids))
+	for _, id := range ids {
+		searches = append(searches, m.searches[id])
+	}
+	return searches, nil
+}
+
+func (m *Memory) GetSavedSearch(ctx context.Context, id string) (models.SavedSearch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.searches[id]
+	if !ok {
+		return s, ErrNotFound
+	}
+	return s, nil
+}
+
+func (m *Memory) CreateSavedSearch(ctx context.Context, userID string, search models.NSavedSearch) (models.SavedSearch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.users[userID]; !ok {
+		return models.SavedSearch{}, ErrNotFound
+	}
+	created := models.SavedSearch{
+		ID:     m.newID(),
+		UserID: userID,
+		Name:   search.Name,
+		Query:  search.Query,
+		Color:  search.Color,
+		Pinned: search.Pinned,
+	}
+	m.searches[created.ID] = created
+	return created, nil
+}
+
+func (m *Memory) UpdateSavedSearch(ctx context.Context, search models.SavedSearch) (models.SavedSearch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	current, ok := m.searches[search.ID]
+	if !ok {
+		return search, ErrNotFound
+	}
+	search.UserID = current.UserID
+	m.searches[search.ID] = search
+	return search, nil
+}
+
+func (m *Memory) DeleteSavedSearch(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.searches[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.searches, id)
+	return nil
+}
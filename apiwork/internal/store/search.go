@@ -0,0 +1,284 @@
+package store
+
+import (
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/Aman221/4723/internal/models"
+)
+
+// DefaultSearchLimit and MaxSearchLimit bound the page size of SearchEvents.
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+)
+
+// EventSearch describes a search for events. Query is a search query (see
+// ParseQuery) matched against the words of an event's title, location,
+// organizer, attendees and description; the other fields narrow the
+// search. At least one of them should be set.
+type EventSearch struct {
+	Query         string
+	IncludeHidden bool
+	CalendarIDs   []string
+	// From and To, "YYYY-MM-DD", restrict the search to events dated
+	// between them, inclusive. Weekly events have no date and are left out.
+	From, To string
+	// Attendee and Organizer must match exactly, ignoring case.
+	Attendee  string
+	Organizer string
+	// Limit is the page size, DefaultSearchLimit if 0.
+	Limit, Offset int
+}
+
+func (s EventSearch) limit() int {
+	if s.Limit <= 0 {
+		return DefaultSearchLimit
+	}
+	if s.Limit > MaxSearchLimit {
+		return MaxSearchLimit
+	}
+	return s.Limit
+}
+
+// keeps reports whether event passes the filters of s, apart from the
+// hidden calendar check.
+func (s EventSearch) keeps(event models.CalendarEvent) bool {
+	if len(s.CalendarIDs) > 0 && !contains(s.CalendarIDs, event.CalendarID) {
+		return false
+	}
+	if (s.From != "" || s.To != "") && event.Date == nil {
+		return false
+	}
+	if s.From != "" && *event.Date < s.From || s.To != "" && *event.Date > s.To {
+		return false
+	}
+	if s.Attendee != "" && !containsFold(event.Attendees, s.Attendee) {
+		return false
+	}
+	return s.Organizer == "" || strings.EqualFold(event.Organizer, s.Organizer)
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Highlighted matches are wrapped in these private-use characters, which
+// ordinary text doesn't contain, and turned into <mark> tags once the text
+// is HTML-escaped.
+const (
+	markStart = "\ue000"
+	markStop  = "\ue001"
+)
+
+// highlight HTML-escapes text and turns the match markers into <mark>.
+func highlight(text string) string {
+	return strings.NewReplacer(markStart, "<mark>", markStop, "</mark>").Replace(html.EscapeString(text))
+}
+
+// Postgres ranks and highlights with its full-text search. The other stores
+// match in Go, and the rank adds up the weights of the fields each term
+// that isn't negated matches, which are the default weights Postgres gives
+// ts_rank for the same fields.
+var fieldWeights = []struct {
+	weight float64
+	fields []string
+	text   func(models.CalendarEvent) string
+}{
+	{1.0, []string{"title"}, func(e models.CalendarEvent) string { return e.Title }},
+	{0.4, []string{"location"}, func(e models.CalendarEvent) string { return e.Location }},
+	{0.2, []string{"organizer", "attendee"}, func(e models.CalendarEvent) string { return e.Organizer + " " + strings.Join(e.Attendees, " ") }},
+	{0.1, []string{"description"}, func(e models.CalendarEvent) string { return e.Description }},
+}
+
+// accents maps accented Latin letters to the letter without the accent.
+var accents = map[rune]rune{}
+
+func init() {
+	for base, accented := range map[rune]string{
+		'a': "àáâãäåāăą", 'c': "çćĉċč", 'd': "ďđ", 'e': "èéêëēĕėęě", 'g': "ĝğġģ", 'h': "ĥħ",
+		'i': "ìíîïĩīĭįı", 'j': "ĵ", 'k': "ķ", 'l': "ĺļľŀł", 'n': "ñńņňŉ", 'o': "òóôõöøōŏő",
+		'r': "ŕŗř", 's': "śŝşš", 't': "ţťŧ", 'u': "ùúûüũūŭůűų", 'w': "ŵ", 'y': "ýÿŷ", 'z': "źżž",
+	} {
+		for _, r := range accented {
+			accents[r] = base
+		}
+	}
+}
+
+// fold lowercases s and strips accents from it.
+func fold(s string) string {
+	return strings.Map(func(r rune) rune {
+		r = unicode.ToLower(r)
+		if base, ok := accents[r]; ok {
+			return base
+		}
+		return r
+	}, s)
+}
+
+// span is a word of a text, as byte offsets.
+type span struct{ start, end int }
+
+// words splits text into words of letters and digits.
+func words(text string) []span {
+	var spans []span
+	start := -1
+	for i, r := range text {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if isWord && start < 0 {
+			start = i
+		} else if !isWord && start >= 0 {
+			spans = append(spans, span{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, span{start, len(text)})
+	}
+	return spans
+}
+
+// queryTerms returns the folded words of a query.
+func queryTerms(query string) []string {
+	var terms []string
+	for _, w := range words(query) {
+		terms = append(terms, fold(query[w.start:w.end]))
+	}
+	return terms
+}
+
+// matches reports whether the word matches one of terms.
+func matches(word string, terms []string) bool {
+	word = fold(word)
+	for _, term := range terms {
+		if strings.HasPrefix(word, term) {
+			return true
+		}
+	}
+	return false
+}
+
+// firstMatch returns the index of the first of spans, the words of text,
+// that matches terms, or -1.
+func firstMatch(text string, spans []span, terms []string) int {
+	for i, w := range spans {
+		if matches(text[w.start:w.end], terms) {
+			return i
+		}
+	}
+	return -1
+}
+
+// mark puts match markers around the words of text that match terms.
+// With snippet set it keeps only the words around the first match.
+func mark(text string, terms []string, snippet bool) (string, bool) {
+	spans := words(text)
+	first := firstMatch(text, spans, terms)
+	if first < 0 {
+		return "", false
+	}
+	from, to := 0, len(text)
+	if snippet {
+		const before, after = 8, 12
+		if first > before {
+			from = spans[first-before].start
+		}
+		if last := first + after; last < len(spans) {
+			to = spans[last].end
+		}
+	}
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString("… ")
+	}
+	at := from
+	for _, w := range spans {
+		if w.start < from || w.end > to || !matches(text[w.start:w.end], terms) {
+			continue
+		}
+		b.WriteString(text[at:w.start])
+		b.WriteString(markStart + text[w.start:w.end] + markStop)
+		at = w.end
+	}
+	b.WriteString(text[at:to])
+	if to < len(text) {
+		b.WriteString(" …")
+	}
+	return b.String(), true
+}
+
+// searchInGo matches events that already passed the filters of search
+// against query, then ranks, highlights and pages them.
+func searchInGo(events []models.CalendarEvent, query Query, search EventSearch) models.EventSearchResults {
+	terms := query.words()
+	hits := []models.EventSearchHit{}
+	for _, event := range events {
+		if !query.matches(event) {
+			continue
+		}
+		hit := models.EventSearchHit{CalendarEvent: event, Highlights: map[string]string{}}
+		for _, term := range query.text {
+			for _, field := range fieldWeights {
+				if (term.field == "" || contains(field.fields, term.field)) && term.in(field.text(event)) {
+					hit.Rank += field.weight
+				}
+			}
+		}
+		if len(terms) > 0 {
+			for name, text := range map[string]string{"title": event.Title, "location": event.Location} {
+				if marked, ok := mark(text, terms, false); ok {
+					hit.Highlights[name] = highlight(marked)
+				}
+			}
+			if marked, ok := mark(event.Description, terms, true); ok {
+				hit.Highlights["description"] = highlight(marked)
+			}
+		}
+		hits = append(hits, hit)
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].Rank != hits[j].Rank {
+			return hits[i].Rank > hits[j].Rank
+		}
+		return searchOrder(hits[i].CalendarEvent, hits[j].CalendarEvent)
+	})
+
+	results := models.EventSearchResults{Total: len(hits), Limit: search.limit(), Offset: search.Offset}
+	from := min(search.Offset, len(hits))
+	to := min(from+results.Limit, len(hits))
+	results.Results = hits[from:to]
+	return results
+}
+
+// searchOrder orders equally ranked events by date, undated last, then
+// start time and id, as Postgres does.
+func searchOrder(a, b models.CalendarEvent) bool {
+	switch {
+	case a.Date == nil && b.Date != nil:
+		return false
+	case a.Date != nil && b.Date == nil:
+		return true
+	case a.Date != nil && *a.Date != *b.Date:
+		return *a.Date < *b.Date
+	case a.StartTime != b.StartTime:
+		return a.StartTime < b.StartTime
+	}
+	return numericLess(a.ID, b.ID)
+}
@@ -0,0 +1,87 @@
+package store
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Aman221/4723/internal/models"
+)
+
+func TestMark(t *testing.T) {
+	text := "one two three four five six seven eight nine Standup eleven twelve thirteen fourteen fifteen sixteen seventeen eighteen nineteen twenty twenty-one twenty-two"
+	got, ok := mark(text, []string{"standup"}, true)
+	want := "… two three four five six seven eight nine " + markStart + "Standup" + markStop + " eleven twelve thirteen fourteen fifteen sixteen seventeen eighteen nineteen twenty twenty-one …"
+	if !ok || got != want {
+		t.Errorf("snippet is %q, want %q", got, want)
+	}
+	if _, ok := mark(text, []string{"retro"}, true); ok {
+		t.Error("marked a text without matches")
+	}
+	if got := highlight(markStart + "R&D" + markStop + " <b>"); got != "<mark>R&amp;D</mark> &lt;b&gt;" {
+		t.Errorf("highlighted %q", got)
+	}
+}
+
+func TestSearchEventsFilters(t *testing.T) {
+	sqlite, _ := newSQLite(t)
+	for name, s := range map[string]Store{"memory": NewMemory(), "sqlite": sqlite} {
+		ctx := context.Background()
+		work, _ := s.CreateCalendar(ctx, models.NCalendar{Name: "Work", Visible: true})
+		hidden, _ := s.CreateCalendar(ctx, models.NCalendar{Name: "Private"})
+		ids := map[string]string{}
+		for _, ev := range []models.NCalendarEvent{
+			{Title: "Standup", Date: date("2026-01-05"), Organizer: "Alice@example.com", CalendarID: work.ID},
+			{Title: "Café standup", Date: date("2026-01-12"), Attendees: []string{"Bob@example.com"}, CalendarID: work.ID},
+			{Title: "Planning", Description: "standup notes & <agenda>", CalendarID: work.ID},
+			{Title: "Standup", Date: date("2026-01-06"), CalendarID: hidden.ID},
+		} {
+			ev.StartTime, ev.EndTime, ev.Day = "09:00", "10:00", 1
+			created, err := s.CreateEvent(ctx, ev)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids[created.Title+" "+created.CalendarID] = created.ID
+		}
+		standup, cafe, planning, private := ids["Standup "+work.ID], ids["Café standup "+work.ID], ids["Planning "+work.ID], ids["Standup "+hidden.ID]
+
+		tests := []struct {
+			search EventSearch
+			want   []string
+		}{
+			// Titles outrank descriptions; equal ranks go by date, weekly events last.
+			{EventSearch{Query: "STANDUP"}, []string{standup, cafe, planning}},
+			{EventSearch{Query: "cafe"}, []string{cafe}},
+			{EventSearch{Query: "standup", IncludeHidden: true}, []string{standup, private, cafe, planning}},
+			{EventSearch{Query: "standup", CalendarIDs: []string{hidden.ID}, IncludeHidden: true}, []string{private}},
+			{EventSearch{Query: "standup", From: "2026-01-06"}, []string{cafe}},
+			{EventSearch{To: "2026-01-11", IncludeHidden: true}, []string{standup, private}},
+			{EventSearch{Attendee: "bob@EXAMPLE.com"}, []string{cafe}},
+			{EventSearch{Organizer: "alice@example.com"}, []string{standup}},
+			{EventSearch{Query: "standup", Limit: 1, Offset: 1}, []string{cafe}},
+		}
+		for _, tt := range tests {
+			results, err := s.SearchEvents(ctx, tt.search)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, hit := range results.Results {
+				got = append(got, hit.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s: %+v found %v, want %v", name, tt.search, got, tt.want)
+			}
+		}
+
+		results, _ := s.SearchEvents(ctx, EventSearch{Query: "standup", Limit: 1, Offset: 1})
+		if results.Total != 3 || results.Limit != 1 || results.Offset != 1 {
+			t.Errorf("%s: page is %d of %d from %d", name, results.Limit, results.Total, results.Offset)
+		}
+		results, _ = s.SearchEvents(ctx, EventSearch{Query: "notes"})
+		if len(results.Results) != 1 || !strings.Contains(results.Results[0].Highlights["description"], "<mark>notes</mark> &amp; &lt;agenda&gt;") {
+			t.Errorf("%s: highlights are %+v", name, results.Results)
+		}
+	}
+}
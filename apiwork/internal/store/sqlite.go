@@ -0,0 +1,364 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+
+	"github.com/Aman221/4723/internal/attendees"
+	"github.com/Aman221/4723/internal/database"
+	"github.com/Aman221/4723/internal/models"
+)
+
+// SQLite is a Store for single-user and embedded deployments, backed by
+// the same tables as Postgres.
+type SQLite struct {
+	db handle
+}
+
+// NewSQLite returns a Store using db, which must have been opened with
+// foreign keys enabled (database.InitDB does this).
+func NewSQLite(db *sql.DB) *SQLite {
+	return &SQLite{db: handle{db: db}}
+}
+
+// sqliteForeignKey maps a foreign key violation to ErrUnknownCalendar.
+func sqliteForeignKey(err error) error {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintForeignKey {
+		return ErrUnknownCalendar
+	}
+	return err
+}
+
+var sqliteEventColumns = eventColumns(database.SQLite)
+
+func (s *SQLite) GetUser(ctx context.Context, id string) (models.User, error) {
+	var u models.User
+	err := s.db.QueryRowContext(ctx, "SELECT id, username, email, date_created FROM users WHERE id = ?", id).
+		Scan(&u.ID, &u.Username, &u.Email, &u.DateCreated)
+	if errors.Is(err, sql.ErrNoRows) {
+		return u, ErrNotFound
+	}
+	return u, err
+}
+
+func (s *SQLite) CreateUser(ctx context.Context, user models.User) (models.User, error) {
+	err := s.db.QueryRowContext(ctx, "INSERT INTO users (username, email) VALUES (?, ?) RETURNING id, date_created",
+		user.Username, user.Email).Scan(&user.ID, &user.DateCreated)
+	return user, err
+}
+
+// sqliteArg returns a func that adds a parameter to args and returns its
+// placeholder.
+func sqliteArg(args *[]interface{}) func(interface{}) string {
+	return func(v interface{}) string {
+		*args = append(*args, v)
+		return "?"
+	}
+}
+
+func (s *SQLite) ListCalendars(ctx context.Context, page Page) ([]models.Calendar, error) {
+	var args []interface{}
+	where, order := page.sql("id", sqliteArg(&args))
+	calendars, err := queryCalendars(ctx, s.db, "SELECT "+calendarColumns+" FROM calendars WHERE deleted_at IS NULL AND "+where+order, args...)
+	return inOrder(page, calendars), err
+}
+
+func (s *SQLite) ListUserCalendars(ctx context.Context, userID string) ([]models.Calendar, error) {
+	return queryCalendars(ctx, s.db, "SELECT "+calendarColumns+" FROM calendars WHERE user_id = ? AND deleted_at IS NULL ORDER BY id", userID)
+}
+
+func (s *SQLite) GetCalendar(ctx context.Context, id string) (models.Calendar, error) {
+	cal, err := scanCalendar(s.db.QueryRowContext(ctx, "SELECT "+calendarColumns+" FROM calendars WHERE id = ? AND deleted_at IS NULL", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return cal, ErrNotFound
+	}
+	return cal, err
+}
+
+func (s *SQLite) CreateCalendar(ctx context.Context, cal models.NCalendar) (models.Calendar, error) {
+	var created models.Calendar
+	err := inTx(ctx, s.db, func(tx *sql.Tx) error {
+		// The updated_at trigger runs after RETURNING is evaluated, so read
+		// the row back.
+		var id string
+		err := tx.QueryRowContext(ctx, "INSERT INTO calendars (name, color, visible) VALUES (?, ?, ?) RETURNING id",
+			cal.Name, cal.Color, cal.Visible).Scan(&id)
+		if err != nil {
+			return err
+		}
+		if created, err = calendarRow(ctx, tx, id); err != nil {
+			return err
+		}
+		return recordCalendar(ctx, tx, ActionCreate, nil, created)
+	})
+	return created, err
+}
+
+func (s *SQLite) UpdateCalendar(ctx context.Context, cal models.Calendar) (models.Calendar, error) {
+	var updated models.Calendar
+	err := inTx(ctx, s.db, func(tx *sql.Tx) error {
+		before, err := liveCalendarRow(ctx, tx, cal.ID, cal.Version)
+		if err != nil {
+			return err
+		}
+		res, err := tx.ExecContext(ctx, `
+			UPDATE calendars SET name = ?, color = ?, visible = ?, version = version + 1, updated_at = CURRENT_TIMESTAMP
+			WHERE id = ? AND version = ?
+		`, cal.Name, cal.Color, cal.Visible, cal.ID, before.Version)
+		if err := raced(res, err); err != nil {
+			return err
+		}
+		if updated, err = calendarRow(ctx, tx, cal.ID); err != nil {
+			return err
+		}
+		return recordCalendar(ctx, tx, ActionUpdate, &before, updated)
+	})
+	return updated, err
+}
+
+func (s *SQLite) SetCalendarVisible(ctx context.Context, id string, visible bool, version int) (models.Calendar, error) {
+	var updated models.Calendar
+	err := inTx(ctx, s.db, func(tx *sql.Tx) error {
+		before, err := liveCalendarRow(ctx, tx, id, version)
+		if err != nil {
+			return err
+		}
+		res, err := tx.ExecContext(ctx, `
+			UPDATE calendars SET visible = ?, version = version + 1, updated_at = CURRENT_TIMESTAMP
+			WHERE id = ? AND version = ?
+		`, visible, id, before.Version)
+		if err := raced(res, err); err != nil {
+			return err
+		}
+		if updated, err = calendarRow(ctx, tx, id); err != nil {
+			return err
+		}
+		return recordCalendar(ctx, tx, ActionUpdate, &before, updated)
+	})
+	return updated, err
+}
+
+func (s *SQLite) DeleteCalendar(ctx context.Context, id string, version int) error {
+	return inTx(ctx, s.db, func(tx *sql.Tx) error {
+		before, err := liveCalendarRow(ctx, tx, id, version)
+		if err != nil {
+			return err
+		}
+		if err := notResourceCalendar(ctx, tx, id); err != nil {
+			return err
+		}
+		events, err := queryEvents(ctx, tx, "SELECT "+sqliteEventColumns+" FROM calendar_events e WHERE e.calendar_id = ? AND e.deleted_at IS NULL ORDER BY e.id", id)
+		if err != nil {
+			return err
+		}
+
+		// The calendar and its events get the same deleted_at.
+		deletedAt := time.Now().UTC()
+		res, err := tx.ExecContext(ctx, `
+			UPDATE calendars SET deleted_at = ?, version = version + 1, updated_at = CURRENT_TIMESTAMP
+			WHERE id = ? AND version = ?
+		`, deletedAt, id, before.Version)
+		if err := raced(res, err); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `
+			UPDATE calendar_events SET deleted_at = ?, version = version + 1, updated_at = CURRENT_TIMESTAMP
+			WHERE calendar_id = ? AND deleted_at IS NULL
+		`, deletedAt, id)
+		if err != nil {
+			return err
+		}
+		deleted, err := calendarRow(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if err := recordCalendar(ctx, tx, ActionDelete, &before, deleted); err != nil {
+			return err
+		}
+		return recordEvents(ctx, tx, sqliteEventColumns, ActionDelete, events)
+	})
+}
+
+func (s *SQLite) ListEvents(ctx context.Context, calendarIDs []string, page Page) ([]models.CalendarEvent, error) {
+	if len(calendarIDs) == 0 {
+		return []models.CalendarEvent{}, nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(calendarIDs)), ",")
+	args := make([]interface{}, len(calendarIDs))
+	for i, id := range calendarIDs {
+		args[i] = id
+	}
+	where, order := page.sql("e.id", sqliteArg(&args))
+	events, err := queryEvents(ctx, s.db, "SELECT "+sqliteEventColumns+" FROM calendar_events e WHERE e.calendar_id IN ("+placeholders+") AND e.deleted_at IS NULL AND "+where+order, args...)
+	return inOrder(page, events), err
+}
+
+func (s *SQLite) ListUserEvents(ctx context.Context, userID string, page Page) ([]models.CalendarEvent, error) {
+	args := []interface{}{userID}
+	where, order := page.sql("e.id", sqliteArg(&args))
+	events, err := queryEvents(ctx, s.db, "SELECT "+sqliteEventColumns+" FROM calendar_events e WHERE e.user_id = ? AND e.deleted_at IS NULL AND "+where+order, args...)
+	return inOrder(page, events), err
+}
+
+func (s *SQLite) SearchEvents(ctx context.Context, search EventSearch) (models.EventSearchResults, error) {
+	parsed, err := ParseQuery(search.Query)
+	if err != nil {
+		return models.EventSearchResults{}, err
+	}
+	// The filters run here and the query in Go (see searchInGo).
+	query := `
+		SELECT ` + sqliteEventColumns + `
+		FROM calendar_events e
+		JOIN calendars c ON e.calendar_id = c.id
+		WHERE e.deleted_at IS NULL AND (c.visible OR ?)`
+	args := []interface{}{search.IncludeHidden}
+	if len(search.CalendarIDs) > 0 {
+		query += " AND e.calendar_id IN (" + strings.TrimSuffix(strings.Repeat("?,", len(search.CalendarIDs)), ",") + ")"
+		for _, id := range search.CalendarIDs {
+			args = append(args, id)
+		}
+	}
+	if search.From != "" {
+		query += " AND e.date >= ?"
+		args = append(args, search.From)
+	}
+	if search.To != "" {
+		query += " AND e.date <= ?"
+		args = append(args, search.To)
+	}
+	if search.Attendee != "" {
+		query += " AND EXISTS (SELECT 1 FROM event_attendees a WHERE a.event_id = e.id AND LOWER(a.attendee) = LOWER(?))"
+		args = append(args, search.Attendee)
+	}
+	if search.Organizer != "" {
+		query += " AND LOWER(e.organizer) = LOWER(?)"
+		args = append(args, search.Organizer)
+	}
+	events, err := queryEvents(ctx, s.db, query+" ORDER BY e.id", args...)
+	if err != nil {
+		return models.EventSearchResults{}, err
+	}
+	return searchInGo(events, parsed, search), nil
+}
+
+func (s *SQLite) GetEvent(ctx context.Context, id string) (models.CalendarEvent, error) {
+	event, err := scanEvent(s.db.QueryRowContext(ctx, "SELECT "+sqliteEventColumns+" FROM calendar_events e WHERE e.id = ? AND e.deleted_at IS NULL", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return event, ErrNotFound
+	}
+	return event, err
+}
+
+func (s *SQLite) CreateEvent(ctx context.Context, event models.NCalendarEvent) (models.CalendarEvent, error) {
+	var created models.CalendarEvent
+	err := inTx(ctx, s.db, func(tx *sql.Tx) error {
+		var eventID string
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO calendar_events (title, start_time, end_time, color, day, description, location, organizer, calendar_id, date)
+			SELECT ?, ?, ?, ?, ?, ?, ?, ?, id, ? FROM calendars WHERE id = ? AND deleted_at IS NULL
+			RETURNING id
+		`, event.Title, event.StartTime, event.EndTime, event.Color, event.Day, event.Description, event.Location,
+			event.Organizer, event.Date, event.CalendarID).Scan(&eventID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrUnknownCalendar
+		}
+		if err != nil {
+			return err
+		}
+		if err := attendees.Set(ctx, tx, eventID, event.Attendees); err != nil {
+			return err
+		}
+		if created, err = eventRow(ctx, tx, sqliteEventColumns, eventID); err != nil {
+			return err
+		}
+		return recordEvent(ctx, tx, ActionCreate, nil, created)
+	})
+	return created, err
+}
+
+func (s *SQLite) UpdateEvent(ctx context.Context, event models.CalendarEvent) (models.CalendarEvent, error) {
+	var updated models.CalendarEvent
+	err := inTx(ctx, s.db, func(tx *sql.Tx) error {
+		before, err := liveEventRow(ctx, tx, sqliteEventColumns, event.ID, event.Version)
+		if err != nil {
+			return err
+		}
+		if err := liveCalendar(ctx, tx, event.CalendarID); err != nil {
+			return err
+		}
+		res, err := tx.ExecContext(ctx, `
+			UPDATE calendar_events
+			SET title = ?, start_time = ?, end_time = ?, color = ?, day = ?, description = ?, location = ?, organizer = ?, calendar_id = ?, date = ?,
+			    version = version + 1, updated_at = CURRENT_TIMESTAMP
+			WHERE id = ? AND version = ?
+		`, event.Title, event.StartTime, event.EndTime, event.Color, event.Day, event.Description, event.Location,
+			event.Organizer, event.CalendarID, event.Date, event.ID, before.Version)
+		if err := raced(res, err); err != nil {
+			return sqliteForeignKey(err)
+		}
+		if err := attendees.Set(ctx, tx, event.ID, event.Attendees); err != nil {
+			return err
+		}
+		if updated, err = eventRow(ctx, tx, sqliteEventColumns, event.ID); err != nil {
+			return err
+		}
+		return recordEvent(ctx, tx, ActionUpdate, &before, updated)
+	})
+	return updated, err
+}
+
+func (s *SQLite) DeleteEvent(ctx context.Context, id string, version int) error {
+	return inTx(ctx, s.db, func(tx *sql.Tx) error {
+		before, err := liveEventRow(ctx, tx, sqliteEventColumns, id, version)
+		if err != nil {
+			return err
+		}
+		res, err := tx.ExecContext(ctx, `
+			UPDATE calendar_events SET deleted_at = ?, version = version + 1, updated_at = CURRENT_TIMESTAMP
+			WHERE id = ? AND version = ?
+		`, time.Now().UTC(), id, before.Version)
+		if err := raced(res, err); err != nil {
+			return err
+		}
+		deleted, err := eventRow(ctx, tx, sqliteEventColumns, id)
+		if err != nil {
+			return err
+		}
+		return recordEvent(ctx, tx, ActionDelete, &before, deleted)
+	})
+}
+
+func (s *SQLite) ListTrash(ctx context.Context) (models.Trash, error) {
+	var trash models.Trash
+	var err error
+	trash.Calendars, err = queryCalendars(ctx, s.db,
+		"SELECT "+calendarColumns+" FROM calendars WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC, id")
+	if err != nil {
+		return trash, err
+	}
+	trash.Events, err = queryEvents(ctx, s.db,
+		"SELECT "+sqliteEventColumns+" FROM calendar_events e WHERE e.deleted_at IS NOT NULL ORDER BY e.deleted_at DESC, e.id")
+	return trash, err
+}
+
+func (s *SQLite) RestoreCalendar(ctx context.Context, id string) (models.Calendar, error) {
+	return restoreCalendar(ctx, s.db, sqliteEventColumns, id, "CURRENT_TIMESTAMP")
+}
+
+func (s *SQLite) RestoreEvent(ctx context.Context, id string) (models.CalendarEvent, error) {
+	return restoreEvent(ctx, s.db, sqliteEventColumns, id, "CURRENT_TIMESTAMP")
+}
+
+func (s *SQLite) History(ctx context.Context, entity, id string) ([]models.HistoryEntry, error) {
+	return queryHistory(ctx, s.db, entity, id)
+}
+
+func (s *SQLite) PurgeTrash(ctx context.Context, cutoff time.Time) (int64, error) {
+	return purgeTrash(ctx, s.db, cutoff.UTC())
+}
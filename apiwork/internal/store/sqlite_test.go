@@ -0,0 +1,146 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Aman221/4723/internal/database"
+	"github.com/Aman221/4723/internal/migrations"
+	"github.com/Aman221/4723/internal/models"
+)
+
+// newSQLite returns a SQLite store on a fresh, fully migrated database.
+func newSQLite(t *testing.T) (*SQLite, *sql.DB) {
+	t.Helper()
+	db, err := sql.Open("sqlite3", "file:"+filepath.Join(t.TempDir(), "calendar.db")+"?_foreign_keys=on&_txlock=immediate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := migrations.Up(context.Background(), db, database.SQLite); err != nil {
+		t.Fatal(err)
+	}
+	return NewSQLite(db), db
+}
+
+func TestSQLiteCalendarsAndEvents(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newSQLite(t)
+
+	user, err := s.CreateUser(ctx, models.User{Username: "alice", Email: "alice@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.GetUser(ctx, user.ID); err != nil || got != user {
+		t.Errorf("GetUser = %+v, %v, want %+v", got, err, user)
+	}
+
+	cal, err := s.CreateCalendar(ctx, models.NCalendar{Name: "Work", Color: "#00f", Visible: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cal.Version != 1 || cal.Name != "Work" || !cal.Visible {
+		t.Errorf("created %+v", cal)
+	}
+	cal.Name = "Office"
+	cal, err = s.UpdateCalendar(ctx, cal)
+	if err != nil || cal.Version != 2 || cal.Name != "Office" {
+		t.Fatalf("UpdateCalendar = %+v, %v", cal, err)
+	}
+	if _, err := s.SetCalendarVisible(ctx, cal.ID, false, 1); !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("hiding a stale calendar: %v", err)
+	}
+
+	date := "2026-01-07"
+	ev, err := s.CreateEvent(ctx, models.NCalendarEvent{
+		Title: "Standup", StartTime: "09:00", EndTime: "09:15", Day: 3, Date: &date,
+		CalendarID: cal.ID, Attendees: []string{"bob", " carol ", "bob"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev.Version != 1 || ev.Date == nil || *ev.Date != date || !reflect.DeepEqual(ev.Attendees, []string{"bob", "carol"}) {
+		t.Errorf("created %+v", ev)
+	}
+	if _, err := s.CreateEvent(ctx, models.NCalendarEvent{Title: "Lost", StartTime: "09:00", EndTime: "10:00", Day: 1, CalendarID: "999"}); !errors.Is(err, ErrUnknownCalendar) {
+		t.Errorf("creating an event in no calendar: %v", err)
+	}
+
+	ev.Title, ev.Attendees = "Daily standup", []string{"dave"}
+	ev, err = s.UpdateEvent(ctx, ev)
+	if err != nil || ev.Version != 2 || !reflect.DeepEqual(ev.Attendees, []string{"dave"}) {
+		t.Fatalf("UpdateEvent = %+v, %v", ev, err)
+	}
+	stale := ev
+	stale.Version = 1
+	if _, err := s.UpdateEvent(ctx, stale); !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("updating a stale event: %v", err)
+	}
+	if got, err := s.GetEvent(ctx, ev.ID); err != nil || !reflect.DeepEqual(got, ev) {
+		t.Errorf("GetEvent = %+v, %v, want %+v", got, err, ev)
+	}
+
+	events, err := s.ListEvents(ctx, []string{cal.ID}, Page{})
+	if err != nil || len(events) != 1 || events[0].ID != ev.ID {
+		t.Errorf("ListEvents = %+v, %v", events, err)
+	}
+}
+
+func TestSQLiteTrash(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newSQLite(t)
+
+	cal, err := s.CreateCalendar(ctx, models.NCalendar{Name: "Work"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []models.CalendarEvent
+	for _, title := range []string{"Standup", "Review"} {
+		ev, err := s.CreateEvent(ctx, models.NCalendarEvent{Title: title, StartTime: "09:00", EndTime: "10:00", Day: 1, CalendarID: cal.ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, ev)
+	}
+
+	if err := s.DeleteEvent(ctx, events[0].ID, events[0].Version); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetEvent(ctx, events[0].ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetEvent of a trashed event: %v", err)
+	}
+	if err := s.DeleteCalendar(ctx, cal.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreateEvent(ctx, models.NCalendarEvent{Title: "Late", StartTime: "09:00", EndTime: "10:00", Day: 1, CalendarID: cal.ID}); !errors.Is(err, ErrUnknownCalendar) {
+		t.Errorf("creating an event in a trashed calendar: %v", err)
+	}
+
+	trash, err := s.ListTrash(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trash.Calendars) != 1 || len(trash.Events) != 2 || trash.Calendars[0].DeletedAt == nil {
+		t.Errorf("trash holds %+v", trash)
+	}
+
+	// Restoring the calendar brings back the events deleted with it, but
+	// not the one deleted before.
+	if _, err := s.RestoreCalendar(ctx, cal.ID); err != nil {
+		t.Fatal(err)
+	}
+	live, err := s.ListEvents(ctx, []string{cal.ID}, Page{})
+	if err != nil || len(live) != 1 || live[0].ID != events[1].ID {
+		t.Errorf("after restoring the calendar: %+v, %v", live, err)
+	}
+	if _, err := s.RestoreEvent(ctx, events[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.RestoreEvent(ctx, events[0].ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("restoring a live event: %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+// Package store persists users, calendars and events. Handlers talk to a
+// Store instead of issuing SQL themselves; NewPostgres and NewSQLite back
+// it with a database and NewMemory keeps everything in process, which lets
+// the HTTP handlers run under httptest without a database.
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Aman221/4723/internal/database"
+	"github.com/Aman221/4723/internal/models"
+)
+
+var (
+	// ErrNotFound is returned when the requested user, calendar or event
+	// does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrUnknownCalendar is returned when an event refers to a calendar
+	// that does not exist.
+	ErrUnknownCalendar = errors.New("calendar does not exist")
+	// ErrVersionMismatch is returned when a calendar or event has changed
+	// since the version the caller based its write on.
+	ErrVersionMismatch = errors.New("version does not match")
+	// ErrCalendarDeleted is returned when restoring an event whose calendar
+	// is in the trash.
+	ErrCalendarDeleted = errors.New("calendar is in the trash")
+	// ErrResourceCalendar is returned when deleting the calendar of a room
+	// or piece of equipment, which goes only with its resource.
+	ErrResourceCalendar = errors.New("calendar belongs to a resource")
+)
+
+// Store is the persistence layer behind the user, calendar and event
+// endpoints. Listing methods return empty slices rather than nil, and
+// those that take a Page return that part of the list.
+//
+// Writes to an existing calendar or event take the version the caller
+// expects it to have and fail with ErrVersionMismatch if it has changed; a
+// version of 0 skips the check. Each write increments the version and
+// returns the stored result.
+//
+// Deleting moves a calendar or event to the trash, along with the events
+// of a deleted calendar. Everything except ListTrash and the restore
+// methods treats what is in the trash as gone.
+//
+// Every write to a calendar or event is recorded in its history, with the
+// Change of the write's context (see WithChange).
+type Store interface {
+	GetUser(ctx context.Context, id string) (models.User, error)
+	CreateUser(ctx context.Context, user models.User) (models.User, error)
+
+	ListCalendars(ctx context.Context, page Page) ([]models.Calendar, error)
+	ListUserCalendars(ctx context.Context, userID string) ([]models.Calendar, error)
+	GetCalendar(ctx context.Context, id string) (models.Calendar, error)
+	CreateCalendar(ctx context.Context, cal models.NCalendar) (models.Calendar, error)
+	// UpdateCalendar expects the calendar to be at cal.Version.
+	UpdateCalendar(ctx context.Context, cal models.Calendar) (models.Calendar, error)
+	SetCalendarVisible(ctx context.Context, id string, visible bool, version int) (models.Calendar, error)
+	DeleteCalendar(ctx context.Context, id string, version int) error
+
+	// ListEvents returns the events of the given calendars.
+	ListEvents(ctx context.Context, calendarIDs []string, page Page) ([]models.CalendarEvent, error)
+	ListUserEvents(ctx context.Context, userID string, page Page) ([]models.CalendarEvent, error)
+	// SearchEvents returns a page of the events that match search, best
+	// match first. Events in hidden calendars are left out unless
+	// search.IncludeHidden is set.
+	SearchEvents(ctx context.Context, search EventSearch) (models.EventSearchResults, error)
+	GetEvent(ctx context.Context, id string) (models.CalendarEvent, error)
+	CreateEvent(ctx context.Context, event models.NCalendarEvent) (models.CalendarEvent, error)
+	// UpdateEvent expects the event to be at event.Version.
+	UpdateEvent(ctx context.Context, event models.CalendarEvent) (models.CalendarEvent, error)
+	DeleteEvent(ctx context.Context, id string, version int) error
+
+	ListTrash(ctx context.Context) (models.Trash, error)
+	// RestoreCalendar takes a calendar and the events deleted with it out
+	// of the trash.
+	RestoreCalendar(ctx context.Context, id string) (models.Calendar, error)
+	RestoreEvent(ctx context.Context, id string) (models.CalendarEvent, error)
+	// PurgeTrash permanently deletes what went into the trash before
+	// cutoff and returns how many calendars and events it removed.
+	PurgeTrash(ctx context.Context, cutoff time.Time) (int64, error)
+
+	// History returns the changes to the calendar or event with the given
+	// id, oldest first. entity is EntityCalendar or EntityEvent. The history
+	// is kept after the calendar or event is purged.
+	History(ctx context.Context, entity, id string) ([]models.HistoryEntry, error)
+	// Sync returns the changes to calendars and events since the SyncToken
+	// of an earlier delta, or everything if since is empty. Calendars and
+	// events in the trash count as deleted. It returns ErrSyncReset if
+	// since is not a position in the history.
+	Sync(ctx context.Context, since string) (models.SyncDelta, error)
+
+	// Batch calls run with a Store whose reads and writes share one
+	// transaction, which commits if run returns nil and rolls back if not.
+	// Batch on that Store runs a nested batch in a savepoint: if its run
+	// fails, only its writes are rolled back.
+	Batch(ctx context.Context, run func(tx Store) error) error
+
+	// ClaimIdempotencyKey claims key for a request, first forgetting keys
+	// claimed before cutoff. It returns true if the key was free; if not,
+	// it returns the request that holds it.
+	ClaimIdempotencyKey(ctx context.Context, key string, claim models.IdempotentRequest, cutoff time.Time) (models.IdempotentRequest, bool, error)
+	// CompleteIdempotencyKey stores the response to the request holding key.
+	CompleteIdempotencyKey(ctx context.Context, key string, response models.IdempotentRequest) error
+	// ReleaseIdempotencyKey forgets key, so that its request can run again,
+	// if it is still held by claim and running. Another request may have
+	// taken it over or finished it since.
+	ReleaseIdempotencyKey(ctx context.Context, key string, claim models.IdempotentRequest) error
+
+	// ListSavedSearches returns the saved searches of a user, or of every
+	// user if userID is empty.
+	ListSavedSearches(ctx context.Context, userID string) ([]models.SavedSearch, error)
+	GetSavedSearch(ctx context.Context, id string) (models.SavedSearch, error)
+	// CreateSavedSearch returns ErrNotFound if the user doesn't exist.
+	CreateSavedSearch(ctx context.Context, userID string, search models.NSavedSearch) (models.SavedSearch, error)
+	// UpdateSavedSearch replaces everything but the owner.
+	UpdateSavedSearch(ctx context.Context, search models.SavedSearch) (models.SavedSearch, error)
+	DeleteSavedSearch(ctx context.Context, id string) error
+}
+
+var (
+	_ Store = (*Postgres)(nil)
+	_ Store = (*SQLite)(nil)
+	_ Store = (*Memory)(nil)
+)
+
+// New returns the Store for a database opened with the given dialect (see
+// database.Dialect).
+func New(db *sql.DB, dialect string) Store {
+	if dialect == database.SQLite {
+		return NewSQLite(db)
+	}
+	return NewPostgres(db)
+}
@@ -0,0 +1,198 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+
+	"github.com/Aman221/4723/internal/models"
+)
+
+// A sync reads the calendars and events with history entries after a
+// position in the history, and the position it read up to. On SQLite,
+// which runs one write at a time, history ids come in commit order and the
+// position is the last id. Postgres numbers entries as transactions insert
+// them, so one can commit id 10 after a sync has read id 11. There the
+// position is the oldest transaction still running when the sync read (the
+// xmin of its snapshot), and the next sync reads the entries of that
+// transaction and every later one. That sends some changes twice, which a
+// replica applies like any other.
+
+// ErrSyncReset is returned for a sync position that is not in the history,
+// as after the database was restored from a backup.
+var ErrSyncReset = errors.New("sync position is not in the history")
+
+// syncRead reads a delta in tx. head is the position of the read and after
+// the condition on history h, with since as $1, that picks the entries
+// after since. A nil since reads everything.
+func syncRead(ctx context.Context, tx querier, columns string, since interface{}, head, after string) (models.SyncDelta, error) {
+	delta := models.SyncDelta{Full: since == nil, SyncToken: head, DeletedCalendars: []string{}, DeletedEvents: []string{}}
+	var err error
+	if delta.Full {
+		if delta.Calendars, err = queryCalendars(ctx, tx, "SELECT "+calendarColumns+" FROM calendars WHERE deleted_at IS NULL ORDER BY id"); err != nil {
+			return delta, err
+		}
+		delta.Events, err = queryEvents(ctx, tx, "SELECT "+columns+" FROM calendar_events e WHERE e.deleted_at IS NULL ORDER BY e.id")
+		return delta, err
+	}
+
+	rows, err := tx.QueryContext(ctx, "SELECT DISTINCT h.entity, h.entity_id FROM history h WHERE "+after+" ORDER BY h.entity_id", since)
+	if err != nil {
+		return delta, err
+	}
+	defer rows.Close()
+	changed := map[string][]string{}
+	for rows.Next() {
+		var entity, id string
+		if err := rows.Scan(&entity, &id); err != nil {
+			return delta, err
+		}
+		changed[entity] = append(changed[entity], id)
+	}
+	if err := rows.Err(); err != nil {
+		return delta, err
+	}
+
+	inHistory := func(entity string) string {
+		return "(SELECT h.entity_id FROM history h WHERE h.entity = '" + entity + "' AND " + after + ")"
+	}
+	calendars, err := queryCalendars(ctx, tx, "SELECT "+calendarColumns+" FROM calendars WHERE id IN "+inHistory(EntityCalendar), since)
+	if err != nil {
+		return delta, err
+	}
+	events, err := queryEvents(ctx, tx, "SELECT "+columns+" FROM calendar_events e WHERE e.id IN "+inHistory(EntityEvent), since)
+	if err != nil {
+		return delta, err
+	}
+	delta.Calendars, delta.DeletedCalendars = live(changed[EntityCalendar], calendars,
+		func(cal models.Calendar) (string, bool) { return cal.ID, cal.DeletedAt == nil })
+	delta.Events, delta.DeletedEvents = live(changed[EntityEvent], events,
+		func(event models.CalendarEvent) (string, bool) { return event.ID, event.DeletedAt == nil })
+	return delta, nil
+}
+
+// live sorts the rows read for the changed ids, which are in ascending
+// order, into those outside the trash and the ids of the rest: those in
+// the trash or purged.
+func live[T any](changed []string, rows []T, key func(T) (string, bool)) ([]T, []string) {
+	byID := map[string]T{}
+	for _, row := range rows {
+		id, _ := key(row)
+		byID[id] = row
+	}
+	kept, deleted := []T{}, []string{}
+	for _, id := range changed {
+		row, ok := byID[id]
+		if ok {
+			_, ok = key(row)
+		}
+		if ok {
+			kept = append(kept, row)
+		} else {
+			deleted = append(deleted, id)
+		}
+	}
+	return kept, deleted
+}
+
+func (p *Postgres) Sync(ctx context.Context, since string) (models.SyncDelta, error) {
+	var delta models.SyncDelta
+	err := inTxWith(ctx, p.db, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}, func(tx *sql.Tx) error {
+		var head string
+		if err := tx.QueryRowContext(ctx, "SELECT pg_snapshot_xmin(pg_current_snapshot())::text").Scan(&head); err != nil {
+			return err
+		}
+		var from interface{}
+		if since != "" {
+			n, err := strconv.ParseUint(since, 10, 64)
+			current, _ := strconv.ParseUint(head, 10, 64)
+			if err != nil || n > current {
+				return ErrSyncReset
+			}
+			from = since
+		}
+		var err error
+		delta, err = syncRead(ctx, tx, pgEventColumns, from, head, "h.txid >= $1::xid8")
+		return err
+	})
+	return delta, err
+}
+
+func (s *SQLite) Sync(ctx context.Context, since string) (models.SyncDelta, error) {
+	var delta models.SyncDelta
+	err := inTx(ctx, s.db, func(tx *sql.Tx) error {
+		var head int64
+		if err := tx.QueryRowContext(ctx, "SELECT COALESCE(MAX(id), 0) FROM history").Scan(&head); err != nil {
+			return err
+		}
+		var from interface{}
+		if since != "" {
+			n, err := strconv.ParseInt(since, 10, 64)
+			if err != nil || n < 0 || n > head {
+				return ErrSyncReset
+			}
+			from = n
+		}
+		var err error
+		delta, err = syncRead(ctx, tx, sqliteEventColumns, from, strconv.FormatInt(head, 10), "h.id > $1")
+		return err
+	})
+	return delta, err
+}
+
+func (m *Memory) Sync(ctx context.Context, since string) (models.SyncDelta, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delta := models.SyncDelta{
+		Full:             since == "",
+		Calendars:        []models.Calendar{},
+		Events:           []models.CalendarEvent{},
+		DeletedCalendars: []string{},
+		DeletedEvents:    []string{},
+		SyncToken:        strconv.Itoa(len(m.history)),
+	}
+	var calendarIDs, eventIDs []string
+	if delta.Full {
+		for id := range m.calendars {
+			calendarIDs = append(calendarIDs, id)
+		}
+		for id := range m.events {
+			eventIDs = append(eventIDs, id)
+		}
+	} else {
+		n, err := strconv.Atoi(since)
+		if err != nil || n < 0 || n > len(m.history) {
+			return delta, ErrSyncReset
+		}
+		seen := map[string]bool{}
+		for _, e := range m.history[n:] {
+			if seen[e.Entity+e.EntityID] {
+				continue
+			}
+			seen[e.Entity+e.EntityID] = true
+			if e.Entity == EntityCalendar {
+				calendarIDs = append(calendarIDs, e.EntityID)
+			} else {
+				eventIDs = append(eventIDs, e.EntityID)
+			}
+		}
+	}
+	byID(calendarIDs)
+	byID(eventIDs)
+	for _, id := range calendarIDs {
+		if cal, ok := m.calendars[id]; ok && cal.DeletedAt == nil {
+			delta.Calendars = append(delta.Calendars, cal)
+		} else if !delta.Full {
+			delta.DeletedCalendars = append(delta.DeletedCalendars, id)
+		}
+	}
+	for _, id := range eventIDs {
+		if event, ok := m.events[id]; ok && event.DeletedAt == nil {
+			delta.Events = append(delta.Events, copyEvent(event))
+		} else if !delta.Full {
+			delta.DeletedEvents = append(delta.DeletedEvents, id)
+		}
+	}
+	return delta, nil
+}
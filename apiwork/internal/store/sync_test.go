@@ -0,0 +1,57 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Aman221/4723/internal/models"
+)
+
+func TestSync(t *testing.T) {
+	sqlite, _ := newSQLite(t)
+	for name, s := range map[string]Store{"memory": NewMemory(), "sqlite": sqlite} {
+		ctx := context.Background()
+		work, _ := s.CreateCalendar(ctx, models.NCalendar{Name: "Work"})
+		home, _ := s.CreateCalendar(ctx, models.NCalendar{Name: "Home"})
+		ev, err := s.CreateEvent(ctx, models.NCalendarEvent{Title: "Standup", StartTime: "09:00", EndTime: "10:00", Day: 1, CalendarID: home.ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		full, err := s.Sync(ctx, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !full.Full || len(full.Calendars) != 2 || len(full.Events) != 1 {
+			t.Errorf("%s: full sync is %+v", name, full)
+		}
+
+		work.Name = "Office"
+		if _, err := s.UpdateCalendar(ctx, work); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.DeleteCalendar(ctx, home.ID, 0); err != nil {
+			t.Fatal(err)
+		}
+		delta, err := s.Sync(ctx, full.SyncToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if delta.Full || len(delta.Calendars) != 1 || delta.Calendars[0].Name != "Office" || len(delta.Events) != 0 ||
+			len(delta.DeletedCalendars) != 1 || delta.DeletedCalendars[0] != home.ID ||
+			len(delta.DeletedEvents) != 1 || delta.DeletedEvents[0] != ev.ID {
+			t.Errorf("%s: delta is %+v", name, delta)
+		}
+
+		again, err := s.Sync(ctx, delta.SyncToken)
+		if err != nil || len(again.Calendars)+len(again.DeletedCalendars)+len(again.Events)+len(again.DeletedEvents) != 0 {
+			t.Errorf("%s: delta without changes is %+v, %v", name, again, err)
+		}
+		for _, since := range []string{"999", "-1", "x"} {
+			if _, err := s.Sync(ctx, since); !errors.Is(err, ErrSyncReset) {
+				t.Errorf("%s: sync since %q: %v", name, since, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,143 @@
+// Package validate checks structs against the rules in their validate
+// tags, such as
+//
+//	Title string `json:"title" validate:"required,max=200"`
+//
+// Rules are separated by commas and checked in order; a field reports only
+// the first rule it breaks. Fields are named by their JSON names.
+//
+//	required  not blank, zero or nil
+//	min=N     strings of at least N characters, numbers of at least N
+//	max=N     strings of at most N characters, lists of at most N items,
+//	          numbers of at most N
+//	clock     an "HH:MM" time of day
+//	date      a "YYYY-MM-DD" date
+//	color     "#rgb", "#rrggbb" or a Tailwind background class such as
+//	          "bg-blue-500"
+//	dive      the rules after it apply to each item of a list
+//
+// Rules other than required pass blank values, so optional fields only
+// need checking when they are set.
+package validate
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// A Problem is what is wrong with one field.
+type Problem struct {
+	Field   string
+	Message string
+}
+
+var colorPattern = regexp.MustCompile(`^(#[0-9a-fA-F]{3}|#[0-9a-fA-F]{6}|bg-[a-z]+-(50|[1-9]00|950))$`)
+
+// Struct lists the problems with v, a struct or a pointer to one, in the
+// order of its fields. It panics on a rule it doesn't know, which is a bug
+// in the tag rather than in v.
+func Struct(v interface{}) []Problem {
+	value := reflect.Indirect(reflect.ValueOf(v))
+	var problems []Problem
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		rules, ok := field.Tag.Lookup("validate")
+		if !ok {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" {
+			name = field.Name
+		}
+		problems = append(problems, check(name, value.Field(i), strings.Split(rules, ","))...)
+	}
+	return problems
+}
+
+// check applies rules to the field named name.
+func check(name string, value reflect.Value, rules []string) []Problem {
+	if value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			if len(rules) > 0 && rules[0] == "required" {
+				return []Problem{{name, name + " is required"}}
+			}
+			return nil
+		}
+		value = value.Elem()
+	}
+	for i, rule := range rules {
+		if rule == "dive" {
+			var problems []Problem
+			for j := 0; j < value.Len(); j++ {
+				problems = append(problems, check(fmt.Sprintf("%s[%d]", name, j), value.Index(j), rules[i+1:])...)
+			}
+			return problems
+		}
+		if message := breaks(value, rule); message != "" {
+			return []Problem{{name, name + " " + message}}
+		}
+	}
+	return nil
+}
+
+// breaks says how value breaks rule, or returns "" if it doesn't.
+func breaks(value reflect.Value, rule string) string {
+	rule, arg, _ := strings.Cut(rule, "=")
+	if rule == "required" {
+		if value.Kind() == reflect.String && strings.TrimSpace(value.String()) == "" || value.IsZero() {
+			return "is required"
+		}
+		return ""
+	}
+	if value.IsZero() {
+		return ""
+	}
+	switch rule {
+	case "min", "max":
+		limit, err := strconv.Atoi(arg)
+		if err != nil {
+			panic("validate: " + rule + " needs a number, not " + strconv.Quote(arg))
+		}
+		n, unit := measure(value)
+		if rule == "min" && n < limit {
+			return fmt.Sprintf("must be at least %d%s", limit, unit)
+		}
+		if rule == "max" && n > limit {
+			return fmt.Sprintf("must be at most %d%s", limit, unit)
+		}
+	case "clock":
+		if _, err := time.Parse("15:04", value.String()); err != nil {
+			return "must be HH:MM"
+		}
+	case "date":
+		if _, err := time.Parse("2006-01-02", value.String()); err != nil {
+			return "must be YYYY-MM-DD"
+		}
+	case "color":
+		if !colorPattern.MatchString(value.String()) {
+			return "must be #rgb, #rrggbb or a class such as bg-blue-500"
+		}
+	default:
+		panic("validate: unknown rule " + strconv.Quote(rule))
+	}
+	return ""
+}
+
+// measure is the size min and max compare: the length of a string or
+// list, or a number itself, with the unit to report it in.
+func measure(value reflect.Value) (int, string) {
+	switch value.Kind() {
+	case reflect.String:
+		return utf8.RuneCountInString(value.String()), " characters"
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return value.Len(), " items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(value.Int()), ""
+	}
+	panic("validate: min and max don't apply to " + value.Kind().String())
+}
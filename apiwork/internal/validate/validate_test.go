@@ -0,0 +1,90 @@
+package validate
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type event struct {
+	Title     string   `json:"title" validate:"required,max=10"`
+	StartTime string   `json:"startTime" validate:"required,clock"`
+	Date      *string  `json:"date,omitempty" validate:"date"`
+	Color     string   `json:"color" validate:"color"`
+	Day       int      `json:"day" validate:"min=1,max=7"`
+	Attendees []string `json:"attendees" validate:"max=2,dive,required,min=3"`
+	Notes     string
+	Internal  string `validate:"max=3"`
+}
+
+func valid() event {
+	date := "2026-01-07"
+	return event{
+		Title:     "Standup",
+		StartTime: "09:00",
+		Date:      &date,
+		Color:     "#ff0000",
+		Day:       3,
+		Attendees: []string{"bob", "carol"},
+	}
+}
+
+func TestStruct(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(e *event)
+		want   []Problem
+	}{
+		{"valid", func(e *event) {}, nil},
+		{"pointer fields may be nil", func(e *event) { e.Date = nil }, nil},
+		{"optional fields may be blank", func(e *event) { e.Color, e.Day, e.Attendees = "", 0, nil }, nil},
+		{"required", func(e *event) { e.Title = "  " }, []Problem{{"title", "title is required"}}},
+		{"max characters", func(e *event) { e.Title = "Weekly standup" }, []Problem{{"title", "title must be at most 10 characters"}}},
+		{"max counts runes", func(e *event) { e.Title = "Café Élysée" }, []Problem{{"title", "title must be at most 10 characters"}}},
+		{"first broken rule only", func(e *event) { e.StartTime = "" }, []Problem{{"startTime", "startTime is required"}}},
+		{"clock", func(e *event) { e.StartTime = "25:00" }, []Problem{{"startTime", "startTime must be HH:MM"}}},
+		{"clock without a leading zero", func(e *event) { e.StartTime = "9:00" }, nil},
+		{"date", func(e *event) { e.Date = new(string); *e.Date = "2026-13-01" }, []Problem{{"date", "date must be YYYY-MM-DD"}}},
+		{"Tailwind color", func(e *event) { e.Color = "bg-blue-500" }, nil},
+		{"short hex color", func(e *event) { e.Color = "#f00" }, nil},
+		{"color", func(e *event) { e.Color = "red" }, []Problem{{"color", "color must be #rgb, #rrggbb or a class such as bg-blue-500"}}},
+		{"min number", func(e *event) { e.Day = -1 }, []Problem{{"day", "day must be at least 1"}}},
+		{"max number", func(e *event) { e.Day = 8 }, []Problem{{"day", "day must be at most 7"}}},
+		{"max items", func(e *event) { e.Attendees = []string{"bob", "carol", "dave"} }, []Problem{{"attendees", "attendees must be at most 2 items"}}},
+		{"dive", func(e *event) { e.Attendees = []string{"", "al"} }, []Problem{
+			{"attendees[0]", "attendees[0] is required"},
+			{"attendees[1]", "attendees[1] must be at least 3 characters"},
+		}},
+		{"fields without a JSON name", func(e *event) { e.Internal = "long" }, []Problem{{"Internal", "Internal must be at most 3 characters"}}},
+		{"several fields in order", func(e *event) { e.Title, e.Day = "", 9 }, []Problem{
+			{"title", "title is required"},
+			{"day", "day must be at most 7"},
+		}},
+	}
+	for _, tt := range tests {
+		e := valid()
+		tt.change(&e)
+		if got := Struct(&e); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestStructTakesValues(t *testing.T) {
+	e := valid()
+	e.Title = ""
+	if got := Struct(e); len(got) != 1 || got[0].Field != "title" {
+		t.Errorf("got %v, want a problem with title", got)
+	}
+}
+
+func TestStructPanicsOnUnknownRules(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(r.(string), `"email"`) {
+			t.Errorf("recovered %v, want a panic naming the rule", r)
+		}
+	}()
+	Struct(struct {
+		Email string `validate:"email"`
+	}{"bob"})
+}
@@ -192,7 +192,7 @@ export default function Home() {
   // Delete an event
   const handleDeleteEvent = async (eventId: string) => {
     try {
-      await api.deleteEvent(eventId)
+      await api.deleteEvent(eventId, events.find((event) => event.id === eventId)?.version)
 
       // Remove the event from the events list
       setEvents((prev) => prev.filter((event) => event.id !== eventId))
@@ -208,7 +208,7 @@ export default function Home() {
   // Update a calendar
   const handleUpdateCalendar = async (calendarId: string, data: { name?: string; color?: string }) => {
     try {
-      const updatedCalendar = await api.updateCalendar(calendarId, data)
+      const updatedCalendar = await api.updateCalendar(calendarId, data, calendars.find((cal) => cal.id === calendarId)?.version)
       
       // Update the calendars list
       setCalendars((prev) => prev.map((cal) => (cal.id === calendarId ? updatedCalendar : cal)))
@@ -234,7 +234,7 @@ export default function Home() {
   // Delete a calendar
   const handleDeleteCalendar = async (calendarId: string) => {
     try {
-      await api.deleteCalendar(calendarId)
+      await api.deleteCalendar(calendarId, calendars.find((cal) => cal.id === calendarId)?.version)
       
       // Remove the calendar from the calendars list
       setCalendars((prev) => prev.filter((cal) => cal.id !== calendarId))
@@ -14,6 +14,7 @@ export interface CalendarEvent {
   organizer: string
   calendarId: string
   date?: string
+  version?: number
 }
 
 export interface Calendar {
@@ -21,6 +22,22 @@ export interface Calendar {
   name: string
   color: string
   visible: boolean
+  version?: number
+  // Set on smart calendars, whose events are those matching a saved search
+  query?: string
+}
+
+// A search match; highlights are HTML with the matches in <mark>
+export interface EventSearchHit extends CalendarEvent {
+  rank: number
+  highlights: { title?: string; location?: string; description?: string }
+}
+
+export interface EventSearchResults {
+  results: EventSearchHit[]
+  total: number
+  limit: number
+  offset: number
 }
 
 // API base URL
@@ -29,25 +46,44 @@ const API_BASE_URL = 'http://127.0.0.1:8080';
 // Helper function for API requests
 const apiRequest = async <T>(
   endpoint: string, 
-  method: 'GET' | 'POST' | 'PUT' | 'DELETE' = 'GET',
-  body?: any
+  method: 'GET' | 'POST' | 'PUT' | 'PATCH' | 'DELETE' = 'GET',
+  body?: any,
+  version?: number
 ): Promise<T> => {
+  const headers: Record<string, string> = {
+    'Content-Type': 'application/json',
+  };
+  // Updates and deletes name the version they were based on
+  if (version) {
+    headers['If-Match'] = `"${version}"`;
+  }
+  // Creates carry a key so that retrying one after a network error can't
+  // create it twice
+  if (method === 'POST') {
+    headers['Idempotency-Key'] = crypto.randomUUID();
+  }
   const options: RequestInit = {
     method,
-    headers: {
-      'Content-Type': 'application/json',
-    },
+    headers,
     // credentials: 'include', // Include cookies if your API uses session authentication
   };
 
-  if (body && (method === 'POST' || method === 'PUT')) {
+  if (body && (method === 'POST' || method === 'PUT' || method === 'PATCH')) {
     options.body = JSON.stringify(body);
   }
 
-  const response = await fetch(`${API_BASE_URL}${endpoint}`, options);
+  let response: Response;
+  try {
+    response = await fetch(`${API_BASE_URL}${endpoint}`, options);
+  } catch (error) {
+    if (method !== 'POST') {
+      throw error;
+    }
+    response = await fetch(`${API_BASE_URL}${endpoint}`, options);
+  }
   
   if (!response.ok) {
-    throw new Error(`API error: ${response.status} ${response.statusText}`);
+    throw await apiError(response);
   }
   
   // For DELETE requests that don't return content
@@ -58,6 +94,36 @@ const apiRequest = async <T>(
   return await response.json();
 };
 
+// Errors come as {"error": {"code", "message", "details", "requestId"}};
+// anything else in the way, such as a proxy, may answer with plain text
+const apiError = async (response: Response): Promise<Error> => {
+  try {
+    const { error } = await response.json();
+    if (error?.message) {
+      return new Error(`API error: ${response.status} ${error.message}`);
+    }
+  } catch {
+    // not JSON
+  }
+  return new Error(`API error: ${response.status} ${response.statusText}`);
+};
+
+// Lists come a page at a time; this follows the Link header's rel="next"
+// until it has every item
+const apiRequestAll = async <T>(endpoint: string): Promise<T[]> => {
+  const items: T[] = [];
+  let next: string | null = endpoint;
+  while (next) {
+    const response: Response = await fetch(`${API_BASE_URL}${next}`);
+    if (!response.ok) {
+      throw await apiError(response);
+    }
+    items.push(...(await response.json()));
+    next = response.headers.get('Link')?.match(/<([^>]*)>;\s*rel="next"/)?.[1] ?? null;
+  }
+  return items;
+};
+
 // Helper function to handle query parameters
 const buildQueryString = (params: Record<string, any>): string => {
   const searchParams = new URLSearchParams();
@@ -84,7 +150,7 @@ export const getDayFromDate = (dateString: string): number => {
 export const api = {
   // Get all calendars
   getCalendars: async (): Promise<Calendar[]> => {
-    return apiRequest<Calendar[]>('/calendars');
+    return apiRequestAll<Calendar>('/calendars');
   },
 
   // Add a new calendar
@@ -93,13 +159,14 @@ export const api = {
   },
 
   // Update calendar
-  updateCalendar: async (id: string, data: { name?: string; color?: string; visible?: boolean }): Promise<Calendar> => {
-    return apiRequest<Calendar>(`/calendars/${id}`, 'PUT', data);
+  updateCalendar: async (id: string, data: { name?: string; color?: string; visible?: boolean }, version?: number): Promise<Calendar> => {
+    // PATCH leaves the fields that aren't sent unchanged
+    return apiRequest<Calendar>(`/calendars/${id}`, 'PATCH', data, version);
   },
 
   // Delete calendar
-  deleteCalendar: async (id: string): Promise<void> => {
-    await apiRequest<void>(`/calendars/${id}`, 'DELETE');
+  deleteCalendar: async (id: string, version?: number): Promise<void> => {
+    await apiRequest<void>(`/calendars/${id}`, 'DELETE', undefined, version);
   },
 
   // Update calendar visibility
@@ -112,16 +179,17 @@ export const api = {
   // Get events filtered by visible calendars
   getEvents: async (visibleCalendarIds: string[]): Promise<CalendarEvent[]> => {
     const queryString = buildQueryString({ calendarIds: visibleCalendarIds });
-    return apiRequest<CalendarEvent[]>(`/events${queryString}`);
+    return apiRequestAll<CalendarEvent>(`/events${queryString}`);
   },
 
-  // Search events
-  searchEvents: async (query: string, includeHidden = false): Promise<CalendarEvent[]> => {
+  // Search events, best match first
+  searchEvents: async (query: string, includeHidden = false): Promise<EventSearchHit[]> => {
     const queryString = buildQueryString({ 
-      query, 
+      q: query, 
       includeHidden: includeHidden.toString() 
     });
-    return apiRequest<CalendarEvent[]>(`/events/search${queryString}`);
+    const page = await apiRequest<EventSearchResults>(`/events/search${queryString}`);
+    return page.results;
   },
 
   // Add a new event
@@ -141,12 +209,12 @@ export const api = {
       updatedData.day = getDayFromDate(updatedData.date);
     }
     
-    return apiRequest<CalendarEvent>(`/events/${eventId}`, 'PUT', updatedData);
+    return apiRequest<CalendarEvent>(`/events/${eventId}`, 'PATCH', updatedData);
   },
 
   // Delete an event
-  deleteEvent: async (eventId: string): Promise<void> => {
-    await apiRequest<void>(`/events/${eventId}`, 'DELETE');
+  deleteEvent: async (eventId: string, version?: number): Promise<void> => {
+    await apiRequest<void>(`/events/${eventId}`, 'DELETE', undefined, version);
   },
 
   // Get current calendar date